@@ -2,40 +2,320 @@ package blacklist
 
 import (
 	"bufio"
-	"bytes"
-	"os"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/file"
+	log "github.com/sirupsen/logrus"
 )
 
 var NoOpBlacklist = func(uuid string) (bool, error) { return false, nil }
 
+var uuidRegex = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+const expiryDateLayout = "2006-01-02"
+
+// ErrInvalidEntry is returned when adding an entry with an invalid uuid or reason
+var ErrInvalidEntry = errors.New("Invalid blacklist entry")
+
 // IsBlacklisted filter function
 type IsBlacklisted func(uuid string) (bool, error)
 
-// NewFileBasedBlacklist returns a function of type Blacklist which will cross-check uuids against a file based blacklist
-func NewFileBasedBlacklist(file string) (IsBlacklisted, error) {
-	f, err := os.Open(file)
+// Entry is a blacklisted uuid, with an optional reason and expiry. Expired entries are no longer blacklisted.
+type Entry struct {
+	UUID    string     `json:"uuid"`
+	Reason  string     `json:"reason,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Expired returns true if the entry has an expiry, which has passed
+func (e Entry) Expired(now time.Time) bool {
+	return e.Expires != nil && !now.Before(*e.Expires)
+}
+
+// Stats counts the entries in the blacklist, and the blacklisted uuids found since startup
+type Stats struct {
+	Entries int    `json:"entries"`
+	Expired int    `json:"expired"`
+	Hits    uint64 `json:"hits"`
+}
+
+// Blacklist is an in memory blacklist, indexed by uuid
+type Blacklist interface {
+	IsBlacklisted(uuid string) (bool, error)
+	Entries() []Entry
+	Stats() Stats
+	Add(entry Entry) error
+	Remove(uuid string) (bool, error)
+	Replace(entries []Entry)
+	Check() error
+}
+
+type indexedBlacklist struct {
+	hits      uint64 // first, for 64-bit atomic alignment
+	lock      *sync.RWMutex
+	writeLock *sync.Mutex
+	store     Store
+	entries   map[string]Entry
+	reloadErr error
+}
+
+// NewIndexedBlacklist returns a Blacklist containing the given entries, which is not persisted
+func NewIndexedBlacklist(entries []Entry) Blacklist {
+	return newIndexedBlacklist(nil, entries)
+}
+
+func newIndexedBlacklist(store Store, entries []Entry) *indexedBlacklist {
+	b := &indexedBlacklist{lock: &sync.RWMutex{}, writeLock: &sync.Mutex{}, store: store}
+	b.Replace(entries)
+	return b
+}
+
+// NewFileBasedBlacklist loads the given file into a blacklist, which is reloaded whenever the file changes
+func NewFileBasedBlacklist(ctx context.Context, path string, refreshInterval time.Duration) (Blacklist, error) {
+	watcher, err := file.NewFileWatcher([]string{path}, refreshInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewFileStore(path)
+	entries, err := store.Load()
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	return func(uuid string) (bool, error) {
-		f, err := os.Open(file)
-		if err != nil {
-			return false, err
+	b := newIndexedBlacklist(store, entries)
+	log.WithField("file", path).WithField("entries", len(entries)).Info("Loaded blacklist.")
+
+	// the file is reloaded through the store, so that reloads cannot race with changes made through Add and Remove
+	go watcher.Watch(ctx, filepath.Base(path), func(string) { b.reload() })
+	return b, nil
+}
+
+// NewStoredBlacklist loads the blacklist from the given store, seeding it from the seed store if it is empty
+func NewStoredBlacklist(ctx context.Context, store Store, seed Store, refreshInterval time.Duration) (Blacklist, error) {
+	entries, err := store.Load()
+	if err == ErrNotStored && seed != nil {
+		log.Info("No blacklist has been stored yet, seeding it from the blacklist file.")
+		entries, err = seed.Load()
+		if err == nil {
+			err = store.Save(entries)
+		}
+	}
+
+	if err != nil && err != ErrNotStored {
+		return nil, err
+	}
+
+	b := newIndexedBlacklist(store, entries)
+	log.WithField("entries", len(entries)).Info("Loaded blacklist.")
+
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				b.reload()
+			}
 		}
-		defer f.Close()
+	}()
+	return b, nil
+}
 
-		uuidAsBytes := []byte(uuid)
+// ParseEntries reads one blacklist entry per line, formatted as <uuid>[,<expiry>[,<reason>]]
+func ParseEntries(r io.Reader) ([]Entry, error) {
+	var entries []Entry
 
-		scanner := bufio.NewScanner(f)
-		for scanner.Scan() {
-			if bytes.Contains(scanner.Bytes(), uuidAsBytes) {
-				return true, nil
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "--") || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(text, ",", 3)
+		entry := Entry{UUID: strings.ToLower(strings.TrimSpace(fields[0]))}
+		if !uuidRegex.MatchString(entry.UUID) {
+			return nil, fmt.Errorf("Invalid uuid %v on line %v of the blacklist", fields[0], line)
+		}
+
+		if len(fields) > 1 && strings.TrimSpace(fields[1]) != "" {
+			expires, err := parseExpiry(strings.TrimSpace(fields[1]))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid expiry %v on line %v of the blacklist", fields[1], line)
 			}
+			entry.Expires = &expires
+		}
+
+		if len(fields) > 2 {
+			entry.Reason = strings.TrimSpace(fields[2])
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+func parseExpiry(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(expiryDateLayout, value)
+}
+
+func (b *indexedBlacklist) reload() {
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	entries, err := b.store.Load()
+	if err == ErrNotStored {
+		return
+	}
+
+	b.lock.Lock()
+	b.reloadErr = err
+	b.lock.Unlock()
+
+	if err != nil {
+		log.WithError(err).Warn("Failed to reload the blacklist, the previous blacklist will continue to be used.")
+		return
+	}
+
+	b.Replace(entries)
+	log.WithField("entries", len(entries)).Debug("Reloaded blacklist.")
+}
+
+func (b *indexedBlacklist) IsBlacklisted(uuid string) (bool, error) {
+	b.lock.RLock()
+	entry, ok := b.entries[strings.ToLower(uuid)]
+	b.lock.RUnlock()
+
+	if !ok || entry.Expired(time.Now()) {
+		return false, nil
+	}
+
+	atomic.AddUint64(&b.hits, 1)
+	return true, nil
+}
+
+// Entries returns every entry in the blacklist ordered by uuid, including expired entries
+func (b *indexedBlacklist) Entries() []Entry {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	entries := make([]Entry, 0, len(b.entries))
+	for _, entry := range b.entries {
+		entries = append(entries, entry)
+	}
+
+	sortEntries(entries)
+	return entries
+}
+
+// Add adds the entry to the blacklist, replacing any existing entry for the same uuid
+func (b *indexedBlacklist) Add(entry Entry) error {
+	entry.UUID = strings.ToLower(strings.TrimSpace(entry.UUID))
+	if !uuidRegex.MatchString(entry.UUID) {
+		return fmt.Errorf("%w, %v is not a valid uuid", ErrInvalidEntry, entry.UUID)
+	}
+
+	if strings.ContainsAny(entry.Reason, "\r\n") {
+		return fmt.Errorf("%w, the reason must be a single line", ErrInvalidEntry)
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	entries := b.Entries()
+	replaced := false
+	for i := range entries {
+		if entries[i].UUID == entry.UUID {
+			entries[i] = entry
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		entries = append(entries, entry)
+	}
+
+	return b.save(entries)
+}
+
+// Remove removes the uuid from the blacklist, returning false if it was not blacklisted
+func (b *indexedBlacklist) Remove(uuid string) (bool, error) {
+	uuid = strings.ToLower(strings.TrimSpace(uuid))
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	entries := b.Entries()
+	for i := range entries {
+		if entries[i].UUID == uuid {
+			return true, b.save(append(entries[:i], entries[i+1:]...))
+		}
+	}
+
+	return false, nil
+}
+
+func (b *indexedBlacklist) save(entries []Entry) error {
+	if b.store != nil {
+		if err := b.store.Save(entries); err != nil {
+			log.WithError(err).Warn("Failed to save the blacklist.")
+			return err
 		}
+	}
+
+	b.Replace(entries)
+	return nil
+}
+
+func (b *indexedBlacklist) Stats() Stats {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	now := time.Now()
+	stats := Stats{Entries: len(b.entries), Hits: atomic.LoadUint64(&b.hits)}
+	for _, entry := range b.entries {
+		if entry.Expired(now) {
+			stats.Expired++
+		}
+	}
+	return stats
+}
+
+// Replace replaces every entry in the blacklist with the given entries. Hits are not reset.
+func (b *indexedBlacklist) Replace(entries []Entry) {
+	index := make(map[string]Entry, len(entries))
+	for _, entry := range entries {
+		entry.UUID = strings.ToLower(entry.UUID)
+		index[entry.UUID] = entry
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.entries = index
+}
 
-		err = scanner.Err()
-		return false, err
-	}, nil
+// Check returns an error if the blacklist failed to reload after its most recent change
+func (b *indexedBlacklist) Check() error {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.reloadErr
 }
@@ -1,13 +1,23 @@
 package blacklist
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFileBasedBlacklist(t *testing.T) {
-	blacklist, err := NewFileBasedBlacklist("./test_blacklist.txt")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blacklist, err := NewFileBasedBlacklist(ctx, "./test_blacklist.txt", time.Minute)
 	assert.NoError(t, err)
 
 	uuids := map[string]bool{
@@ -17,16 +27,102 @@ func TestFileBasedBlacklist(t *testing.T) {
 		"2f34db47-687f-499e-a4c0-8fced650ba25": false,
 		"002c88c6-cd6c-11df-ab20-00144feab49a": true,
 		"4fce28d4-2401-4c17-b484-29da67386cba": false,
+		"002c88c6":                             false,
+		"SYNTHETIC REQUEST":                    false,
 	}
 
 	for uuid, expectedValid := range uuids {
-		actualValid, err := blacklist(uuid)
+		actualValid, err := blacklist.IsBlacklisted(uuid)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedValid, actualValid, "The validation should match")
 	}
+
+	stats := blacklist.Stats()
+	assert.Equal(t, 12300, stats.Entries)
+	assert.Equal(t, 0, stats.Expired)
+	assert.Equal(t, uint64(3), stats.Hits)
 }
 
 func TestFileNotFound(t *testing.T) {
-	_, err := NewFileBasedBlacklist("./not-a-real-file.txt")
+	_, err := NewFileBasedBlacklist(context.Background(), "./not-a-real-file.txt", time.Minute)
 	assert.Error(t, err)
 }
+
+func TestFileBasedBlacklistReloads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blacklist")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "blacklist.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte("335a60b8-3092-11e0-9de3-00144feabdc0\n"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blacklist, err := NewFileBasedBlacklist(ctx, path, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("271f1e94-cd71-11df-9c82-00144feab49a\n"), 0644))
+	assert.Eventually(t, func() bool {
+		blacklisted, _ := blacklist.IsBlacklisted("271f1e94-cd71-11df-9c82-00144feab49a")
+		return blacklisted
+	}, time.Second, 10*time.Millisecond)
+
+	blacklisted, _ := blacklist.IsBlacklisted("335a60b8-3092-11e0-9de3-00144feabdc0")
+	assert.False(t, blacklisted, "removed entries should no longer be blacklisted")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("not-a-uuid\n"), 0644))
+	assert.Eventually(t, func() bool { return blacklist.Check() != nil }, time.Second, 10*time.Millisecond)
+
+	blacklisted, _ = blacklist.IsBlacklisted("271f1e94-cd71-11df-9c82-00144feab49a")
+	assert.True(t, blacklisted, "the previous blacklist should be kept if the file is invalid")
+}
+
+func TestParseEntries(t *testing.T) {
+	entries, err := ParseEntries(strings.NewReader(`-- a comment
+# another comment
+
+335A60B8-3092-11E0-9DE3-00144FEABDC0
+271f1e94-cd71-11df-9c82-00144feab49a,2017-01-02
+399f1746-f1ae-49c1-a633-b0875a035372,2017-01-02T03:04:05Z,Legal request, see ticket
+2f34db47-687f-499e-a4c0-8fced650ba25,,Broken content
+`))
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	expires := time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, Entry{UUID: "335a60b8-3092-11e0-9de3-00144feabdc0"}, entries[0])
+	assert.Equal(t, Entry{UUID: "271f1e94-cd71-11df-9c82-00144feab49a", Expires: &expires}, entries[1])
+	assert.Equal(t, Entry{UUID: "399f1746-f1ae-49c1-a633-b0875a035372", Expires: &expiresAt, Reason: "Legal request, see ticket"}, entries[2])
+	assert.Equal(t, Entry{UUID: "2f34db47-687f-499e-a4c0-8fced650ba25", Reason: "Broken content"}, entries[3])
+}
+
+func TestParseInvalidEntries(t *testing.T) {
+	_, err := ParseEntries(strings.NewReader("335a60b8-3092-11e0-9de3-00144feabdc0\n335a60b8\n"))
+	assert.EqualError(t, err, "Invalid uuid 335a60b8 on line 2 of the blacklist")
+
+	_, err = ParseEntries(strings.NewReader("335a60b8-3092-11e0-9de3-00144feabdc0,tomorrow\n"))
+	assert.EqualError(t, err, "Invalid expiry tomorrow on line 1 of the blacklist")
+}
+
+func TestExpiredEntriesAreNotBlacklisted(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	blacklist := NewIndexedBlacklist([]Entry{
+		{UUID: "335a60b8-3092-11e0-9de3-00144feabdc0", Expires: &past},
+		{UUID: "271f1e94-cd71-11df-9c82-00144feab49a", Expires: &future},
+	})
+
+	blacklisted, err := blacklist.IsBlacklisted("335a60b8-3092-11e0-9de3-00144feabdc0")
+	assert.NoError(t, err)
+	assert.False(t, blacklisted)
+
+	blacklisted, err = blacklist.IsBlacklisted("271f1e94-cd71-11df-9c82-00144feab49a")
+	assert.NoError(t, err)
+	assert.True(t, blacklisted)
+
+	assert.Equal(t, Stats{Entries: 2, Expired: 1, Hits: 1}, blacklist.Stats())
+	assert.Len(t, blacklist.Entries(), 2)
+}
@@ -0,0 +1,43 @@
+package blacklist
+
+import (
+	"github.com/stretchr/testify/mock"
+)
+
+type MockBlacklist struct {
+	mock.Mock
+}
+
+func (m *MockBlacklist) IsBlacklisted(uuid string) (bool, error) {
+	args := m.Called(uuid)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBlacklist) Entries() []Entry {
+	args := m.Called()
+	return args.Get(0).([]Entry)
+}
+
+func (m *MockBlacklist) Stats() Stats {
+	args := m.Called()
+	return args.Get(0).(Stats)
+}
+
+func (m *MockBlacklist) Add(entry Entry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockBlacklist) Remove(uuid string) (bool, error) {
+	args := m.Called(uuid)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBlacklist) Replace(entries []Entry) {
+	m.Called(entries)
+}
+
+func (m *MockBlacklist) Check() error {
+	args := m.Called()
+	return args.Error(0)
+}
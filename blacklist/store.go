@@ -0,0 +1,198 @@
+package blacklist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/etcd/keys"
+	"github.com/Financial-Times/publish-carousel/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+const storeContentType = "text/plain"
+
+// ErrNotStored is returned by a Store which has never had a blacklist saved to it
+var ErrNotStored = errors.New("No blacklist has been stored")
+
+// ErrConcurrentModification is returned when another instance has changed the stored blacklist
+var ErrConcurrentModification = errors.New("Blacklist has been modified by another instance, please retry")
+
+// Store persists the blacklist, in the format read by ParseEntries
+type Store interface {
+	Load() ([]Entry, error)
+	Save(entries []Entry) error
+}
+
+// FormatEntries writes the entries in the format read by ParseEntries, ordered by uuid
+func FormatEntries(w io.Writer, entries []Entry) error {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sortEntries(sorted)
+
+	for _, entry := range sorted {
+		line := entry.UUID
+		if entry.Expires != nil || entry.Reason != "" {
+			line += ","
+		}
+
+		if entry.Expires != nil {
+			line += entry.Expires.Format(time.RFC3339)
+		}
+
+		if entry.Reason != "" {
+			line += "," + entry.Reason
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].UUID < entries[j].UUID })
+}
+
+type fileStore struct {
+	path string
+}
+
+// NewFileStore returns a Store which reads and writes the given blacklist file
+func NewFileStore(path string) Store {
+	return &fileStore{path: path}
+}
+
+func (f *fileStore) Load() ([]Entry, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseEntries(file)
+}
+
+func (f *fileStore) Save(entries []Entry) error {
+	buf := &bytes.Buffer{}
+	if err := FormatEntries(buf, entries); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), "."+filepath.Base(f.path)+"-")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+type s3Store struct {
+	rw  s3.ReadWriter
+	id  string
+	key string
+}
+
+// NewS3Store returns a Store which reads and writes the blacklist at <id>/<key> in S3
+func NewS3Store(rw s3.ReadWriter, id string, key string) Store {
+	return &s3Store{rw: rw, id: id, key: key}
+}
+
+func (s *s3Store) Load() ([]Entry, error) {
+	found, body, _, err := s.rw.Read(s.id + "/" + s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, ErrNotStored
+	}
+	defer body.Close()
+
+	return ParseEntries(body)
+}
+
+func (s *s3Store) Save(entries []Entry) error {
+	buf := &bytes.Buffer{}
+	if err := FormatEntries(buf, entries); err != nil {
+		return err
+	}
+	return s.rw.Write(s.id, s.key, buf.Bytes(), storeContentType)
+}
+
+type etcdStore struct {
+	store   keys.VersionedStore
+	key     string
+	lock    *sync.Mutex
+	version uint64
+}
+
+// NewEtcdStore returns a Store which reads and writes the blacklist at the given etcd key
+func NewEtcdStore(store keys.VersionedStore, key string) Store {
+	return &etcdStore{store: store, key: key, lock: &sync.Mutex{}}
+}
+
+func (e *etcdStore) Load() ([]Entry, error) {
+	value, version, found, err := e.store.Get(e.key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, ErrNotStored
+	}
+
+	entries, err := ParseEntries(bytes.NewBufferString(value))
+	if err != nil {
+		return nil, err
+	}
+
+	e.lock.Lock()
+	e.version = version
+	e.lock.Unlock()
+
+	return entries, nil
+}
+
+func (e *etcdStore) Save(entries []Entry) error {
+	buf := &bytes.Buffer{}
+	if err := FormatEntries(buf, entries); err != nil {
+		return err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	version, swapped, err := e.store.CompareAndSwap(e.key, buf.String(), e.version)
+	if err != nil {
+		return err
+	}
+
+	if !swapped {
+		log.WithField("key", e.key).Warn("Blacklist in etcd has been changed by another instance, refusing to overwrite it.")
+		return ErrConcurrentModification
+	}
+
+	e.version = version
+	return nil
+}
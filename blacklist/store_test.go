@@ -0,0 +1,213 @@
+package blacklist
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeVersionedStore struct {
+	values   map[string]string
+	versions map[string]uint64
+	version  uint64
+}
+
+func newFakeVersionedStore() *fakeVersionedStore {
+	return &fakeVersionedStore{values: make(map[string]string), versions: make(map[string]uint64)}
+}
+
+func (f *fakeVersionedStore) Get(key string) (string, uint64, bool, error) {
+	value, ok := f.values[key]
+	return value, f.versions[key], ok, nil
+}
+
+func (f *fakeVersionedStore) CompareAndSwap(key string, value string, version uint64) (uint64, bool, error) {
+	if f.versions[key] != version {
+		return 0, false, nil
+	}
+
+	f.version++
+	f.values[key] = value
+	f.versions[key] = f.version
+	return f.version, true, nil
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "blacklist")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func testEntries() []Entry {
+	expires := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []Entry{
+		{UUID: "335a60b8-3092-11e0-9de3-00144feabdc0", Reason: "Legal request, see ticket", Expires: &expires},
+		{UUID: "271f1e94-cd71-11df-9c82-00144feab49a"},
+		{UUID: "399f1746-f1ae-49c1-a633-b0875a035372", Reason: "Broken content"},
+	}
+}
+
+func TestFormatEntries(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, FormatEntries(buf, testEntries()))
+
+	assert.Equal(t, `271f1e94-cd71-11df-9c82-00144feab49a
+335a60b8-3092-11e0-9de3-00144feabdc0,2017-01-02T03:04:05Z,Legal request, see ticket
+399f1746-f1ae-49c1-a633-b0875a035372,,Broken content
+`, buf.String())
+
+	entries, err := ParseEntries(buf)
+	require.NoError(t, err)
+
+	expected := testEntries()
+	sortEntries(expected)
+	assert.Equal(t, expected, entries)
+}
+
+func TestStoreConformance(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"file": func(t *testing.T) Store {
+			path := filepath.Join(tempDir(t), "blacklist.txt")
+			require.NoError(t, ioutil.WriteFile(path, []byte{}, 0644))
+			return NewFileStore(path)
+		},
+		"s3": func(t *testing.T) Store {
+			return NewS3Store(s3.NewFileReadWriter(tempDir(t)), "blacklist", "carousel_blacklist.txt")
+		},
+		"etcd": func(t *testing.T) Store {
+			return NewEtcdStore(newFakeVersionedStore(), "/ft/config/publish-carousel/blacklist")
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+
+			require.NoError(t, store.Save(testEntries()))
+
+			entries, err := store.Load()
+			require.NoError(t, err)
+
+			expected := testEntries()
+			sortEntries(expected)
+			assert.Equal(t, expected, entries)
+
+			require.NoError(t, store.Save(entries[1:]))
+			entries, err = store.Load()
+			require.NoError(t, err)
+			assert.Equal(t, expected[1:], entries)
+		})
+	}
+}
+
+func TestStoresWithNothingSaved(t *testing.T) {
+	_, err := NewS3Store(s3.NewFileReadWriter(tempDir(t)), "blacklist", "carousel_blacklist.txt").Load()
+	assert.Equal(t, ErrNotStored, err)
+
+	_, err = NewEtcdStore(newFakeVersionedStore(), "/blacklist").Load()
+	assert.Equal(t, ErrNotStored, err)
+}
+
+func TestEtcdStoreRefusesToOverwriteAnotherInstance(t *testing.T) {
+	api := newFakeVersionedStore()
+	first := NewEtcdStore(api, "/blacklist")
+	second := NewEtcdStore(api, "/blacklist")
+
+	require.NoError(t, first.Save(testEntries()))
+	assert.Equal(t, ErrConcurrentModification, second.Save(nil))
+
+	_, err := second.Load()
+	require.NoError(t, err)
+	require.NoError(t, second.Save(nil))
+
+	assert.Equal(t, ErrConcurrentModification, first.Save(testEntries()))
+}
+
+func TestStoredBlacklistIsSeeded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewEtcdStore(newFakeVersionedStore(), "/blacklist")
+	seed := NewFileStore("./test_blacklist.txt")
+
+	blist, err := NewStoredBlacklist(ctx, store, seed, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 12300, blist.Stats().Entries)
+
+	entries, err := store.Load()
+	require.NoError(t, err)
+	assert.Len(t, entries, 12300, "the store should have been seeded")
+}
+
+func TestStoredBlacklistAddAndRemove(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	api := newFakeVersionedStore()
+	blist, err := NewStoredBlacklist(ctx, NewEtcdStore(api, "/blacklist"), nil, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, blist.Entries())
+
+	require.NoError(t, blist.Add(Entry{UUID: "335A60B8-3092-11E0-9DE3-00144FEABDC0", Reason: "Legal request"}))
+	require.NoError(t, blist.Add(Entry{UUID: "271f1e94-cd71-11df-9c82-00144feab49a"}))
+	require.NoError(t, blist.Add(Entry{UUID: "335a60b8-3092-11e0-9de3-00144feabdc0", Reason: "Updated reason"}))
+
+	blacklisted, _ := blist.IsBlacklisted("335a60b8-3092-11e0-9de3-00144feabdc0")
+	assert.True(t, blacklisted)
+	assert.Equal(t, "271f1e94-cd71-11df-9c82-00144feab49a\n335a60b8-3092-11e0-9de3-00144feabdc0,,Updated reason\n", api.values["/blacklist"])
+
+	removed, err := blist.Remove("335a60b8-3092-11e0-9de3-00144feabdc0")
+	require.NoError(t, err)
+	assert.True(t, removed)
+
+	removed, err = blist.Remove("335a60b8-3092-11e0-9de3-00144feabdc0")
+	require.NoError(t, err)
+	assert.False(t, removed)
+
+	blacklisted, _ = blist.IsBlacklisted("335a60b8-3092-11e0-9de3-00144feabdc0")
+	assert.False(t, blacklisted)
+	assert.Equal(t, "271f1e94-cd71-11df-9c82-00144feab49a\n", api.values["/blacklist"])
+}
+
+type failingStore struct{}
+
+func (failingStore) Load() ([]Entry, error) { return nil, nil }
+func (failingStore) Save(entries []Entry) error {
+	return errors.New("oh no")
+}
+
+func TestFailedSaveDoesNotChangeBlacklist(t *testing.T) {
+	blist := newIndexedBlacklist(failingStore{}, nil)
+
+	err := blist.Add(Entry{UUID: "335a60b8-3092-11e0-9de3-00144feabdc0"})
+	assert.EqualError(t, err, "oh no")
+
+	blacklisted, _ := blist.IsBlacklisted("335a60b8-3092-11e0-9de3-00144feabdc0")
+	assert.False(t, blacklisted)
+}
+
+func TestFileBasedBlacklistSavesChanges(t *testing.T) {
+	path := filepath.Join(tempDir(t), "blacklist.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte("-- a comment\n335a60b8-3092-11e0-9de3-00144feabdc0\n"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blist, err := NewFileBasedBlacklist(ctx, path, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, blist.Add(Entry{UUID: "271f1e94-cd71-11df-9c82-00144feab49a", Reason: "Broken content"}))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "271f1e94-cd71-11df-9c82-00144feab49a,,Broken content\n335a60b8-3092-11e0-9de3-00144feabdc0\n", string(b))
+}
@@ -94,6 +94,20 @@ func (e *externalService) String() string {
 	return desc
 }
 
+// ReadEnvironments returns the read environments which the service is checked in
+func (e *externalService) ReadEnvironments() []cluster.ReadEnvironment {
+	var environments []cluster.ReadEnvironment
+	for _, env := range e.environmentService.GetEnvironments() {
+		environment := cluster.ReadEnvironment{Name: env.name, ReadURL: env.readURL}
+		if env.credentials != nil {
+			environment.Username = env.credentials.username
+			environment.Password = env.credentials.password
+		}
+		environments = append(environments, environment)
+	}
+	return environments
+}
+
 func gtgURLFor(env readEnvironment, serviceName string) string {
 	return env.readURL.String() + "/__" + serviceName + "/__gtg"
 }
@@ -118,3 +118,12 @@ func (m *MockWatcher) Read(key string) (string, error) {
 	args := m.Called(key)
 	return args.String(0), args.Error(1)
 }
+
+type MockReadEnvironments struct {
+	mock.Mock
+}
+
+func (m *MockReadEnvironments) ReadEnvironments() []ReadEnvironment {
+	args := m.Called()
+	return args.Get(0).([]ReadEnvironment)
+}
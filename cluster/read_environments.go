@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ReadEnvironment is a delivery cluster which published content can be read back from
+type ReadEnvironment struct {
+	Name     string
+	ReadURL  *url.URL
+	Username string
+	Password string
+}
+
+// ReadEnvironments is implemented by external services which know the current delivery clusters
+type ReadEnvironments interface {
+	ReadEnvironments() []ReadEnvironment
+}
+
+// Get reads the json document at the path in the read environment into v, or returns false if it is not found
+func (e ReadEnvironment) Get(client HttpClient, path string, v interface{}) (bool, error) {
+	url := strings.TrimSuffix(e.ReadURL.String(), "/") + path
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if e.Username != "" {
+		req.SetBasicAuth(e.Username, e.Password)
+	}
+
+	req.Header.Add("User-Agent", "UPP Publish Carousel")
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("Read API %v returned a non-200 code: %v", url, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReadEnvironment(t *testing.T, rawURL string, basicAuth bool) ReadEnvironment {
+	readURL, err := url.Parse(rawURL)
+	require.NoError(t, err)
+
+	env := ReadEnvironment{Name: "environment1", ReadURL: readURL}
+	if basicAuth {
+		env.Username = "user1"
+		env.Password = "password1"
+	}
+	return env
+}
+
+func TestReadEnvironmentGet(t *testing.T) {
+	server := SetupFakeServerBasicAuth(t, 200, "/content/a-uuid", `{"publishReference":"tid_1234"}`, true, func() {})
+	defer server.Close()
+
+	content := struct {
+		PublishReference string `json:"publishReference"`
+	}{}
+
+	found, err := testReadEnvironment(t, server.URL+"/", true).Get(http.DefaultClient, "/content/a-uuid", &content)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "tid_1234", content.PublishReference)
+}
+
+func TestReadEnvironmentGetNotFound(t *testing.T) {
+	server := SetupFakeServerNoAuth(t, 404, "/content/a-uuid", "", false, func() {})
+	defer server.Close()
+
+	found, err := testReadEnvironment(t, server.URL, false).Get(http.DefaultClient, "/content/a-uuid", &struct{}{})
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestReadEnvironmentGetFails(t *testing.T) {
+	server := SetupFakeServerNoAuth(t, 503, "/content/a-uuid", "", false, func() {})
+	defer server.Close()
+
+	found, err := testReadEnvironment(t, server.URL, false).Get(http.DefaultClient, "/content/a-uuid", &struct{}{})
+	assert.EqualError(t, err, "Read API "+server.URL+"/content/a-uuid returned a non-200 code: 503")
+	assert.False(t, found)
+}
@@ -0,0 +1,268 @@
+package cms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// BreakerClosed means the notifier is called as normal
+	BreakerClosed = "closed"
+	// BreakerOpen means the notifier is failing, and is not called until the breaker's open timeout has passed
+	BreakerOpen = "open"
+	// BreakerHalfOpen means the notifier is being probed, one publish at a time, to see if it has recovered
+	BreakerHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned instead of calling the notifier while its circuit breaker is open
+var ErrCircuitOpen = errors.New("Circuit breaker is open")
+
+// Breaker is implemented by notifiers which stop calling their target while it is failing
+type Breaker interface {
+	State() string
+	// Wait blocks until the notifier can be called again, or the context is done
+	Wait(ctx context.Context) error
+}
+
+// BreakerConfig configures when a circuit breaker opens, and how it recovers
+type BreakerConfig struct {
+	// FailureRate is the fraction of publishes, between 0 and 1, which must fail within the window for the breaker to open
+	FailureRate float64
+	// Window is the number of recent publishes which the failure rate is calculated over
+	Window int
+	// OpenTimeout is how long the breaker stays open before it starts probing the notifier
+	OpenTimeout time.Duration
+	// Probes is the number of consecutive successful probes needed to close the breaker
+	Probes int
+}
+
+// Validate checks the breaker configuration
+func (c BreakerConfig) Validate() error {
+	if c.FailureRate <= 0 || c.FailureRate > 1 {
+		return fmt.Errorf("Invalid circuit breaker failure rate %v, please use a value between 0 and 1", c.FailureRate)
+	}
+
+	if c.Window < 1 {
+		return fmt.Errorf("Invalid circuit breaker window %v, please use at least 1 publish", c.Window)
+	}
+
+	if c.OpenTimeout <= 0 {
+		return fmt.Errorf("Invalid circuit breaker open timeout %v", c.OpenTimeout)
+	}
+
+	if c.Probes < 1 {
+		return fmt.Errorf("Invalid circuit breaker probes %v, please use at least 1 probe", c.Probes)
+	}
+	return nil
+}
+
+type circuitBreaker struct {
+	Notifier
+	name   string
+	config BreakerConfig
+
+	lock      *sync.Mutex
+	state     string
+	outcomes  []bool
+	next      int
+	count     int
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	successes int
+	changed   chan struct{}
+}
+
+// NewCircuitBreaker returns a notifier which returns ErrCircuitOpen while the given notifier is failing
+func NewCircuitBreaker(name string, notifier Notifier, config BreakerConfig) (Notifier, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &circuitBreaker{
+		Notifier: notifier,
+		name:     name,
+		config:   config,
+		lock:     &sync.Mutex{},
+		state:    BreakerClosed,
+		outcomes: make([]bool, config.Window),
+		changed:  make(chan struct{}),
+	}, nil
+}
+
+func (b *circuitBreaker) Notify(ctx context.Context, origin string, tid string, content *native.Content, hash string) error {
+	state, ok := b.acquire()
+	if !ok {
+		return fmt.Errorf("%w for notifier %v", ErrCircuitOpen, b.name)
+	}
+
+	err := b.Notifier.Notify(ctx, origin, tid, content, hash)
+	b.record(state, err)
+	return err
+}
+
+func (b *circuitBreaker) State() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.halfOpenIfExpired()
+	return b.state
+}
+
+func (b *circuitBreaker) Wait(ctx context.Context) error {
+	for {
+		b.lock.Lock()
+		b.halfOpenIfExpired()
+		ready := b.state == BreakerClosed || (b.state == BreakerHalfOpen && !b.probing)
+		changed := b.changed
+		remaining := b.config.OpenTimeout - time.Since(b.openedAt)
+		open := b.state == BreakerOpen
+		b.lock.Unlock()
+
+		if ready {
+			return nil
+		}
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if open {
+			timer = time.NewTimer(remaining)
+			expired = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			err := ctx.Err()
+			if timer != nil {
+				timer.Stop()
+			}
+			return err
+		case <-changed:
+		case <-expired:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// acquire returns whether the notifier can be called, and the state of the breaker at the time
+func (b *circuitBreaker) acquire() (string, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.halfOpenIfExpired()
+	switch b.state {
+	case BreakerClosed:
+		return b.state, true
+	case BreakerHalfOpen:
+		if b.probing {
+			return b.state, false
+		}
+		b.probing = true
+		return b.state, true
+	}
+	return b.state, false
+}
+
+// record counts the outcome of a publish made in the given state, ignoring permanent failures and cancelled publishes
+func (b *circuitBreaker) record(state string, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if state != b.state {
+		return
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		if b.state == BreakerHalfOpen {
+			b.probing = false
+			b.broadcast()
+		}
+		return
+	}
+
+	if ErrorClass(err) == PermanentError {
+		err = nil
+	}
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+		if err != nil {
+			b.open(err)
+			return
+		}
+
+		b.successes++
+		if b.successes >= b.config.Probes {
+			b.close()
+		} else {
+			b.broadcast()
+		}
+		return
+	}
+
+	if b.count == len(b.outcomes) && b.outcomes[b.next] {
+		b.failures--
+	}
+
+	b.outcomes[b.next] = err != nil
+	b.next = (b.next + 1) % len(b.outcomes)
+	if b.count < len(b.outcomes) {
+		b.count++
+	}
+
+	if err != nil {
+		b.failures++
+	}
+
+	if b.count == len(b.outcomes) && float64(b.failures) >= b.config.FailureRate*float64(b.count) {
+		b.open(err)
+	}
+}
+
+func (b *circuitBreaker) open(err error) {
+	log.WithField("notifier", b.name).WithField("openTimeout", b.config.OpenTimeout.String()).WithError(err).Warn("Circuit breaker opened, cycles will wait for the notifier to recover.")
+	b.state = BreakerOpen
+	b.openedAt = time.Now()
+	b.reset()
+}
+
+func (b *circuitBreaker) close() {
+	log.WithField("notifier", b.name).Info("Circuit breaker closed, the notifier has recovered.")
+	b.state = BreakerClosed
+	b.reset()
+}
+
+func (b *circuitBreaker) halfOpenIfExpired() {
+	if b.state != BreakerOpen || time.Since(b.openedAt) < b.config.OpenTimeout {
+		return
+	}
+
+	log.WithField("notifier", b.name).Info("Circuit breaker half-open, probing the notifier.")
+	b.state = BreakerHalfOpen
+	b.reset()
+}
+
+func (b *circuitBreaker) reset() {
+	b.outcomes = make([]bool, b.config.Window)
+	b.next = 0
+	b.count = 0
+	b.failures = 0
+	b.probing = false
+	b.successes = 0
+	b.broadcast()
+}
+
+// broadcast wakes up every cycle waiting on the breaker. The lock must be held.
+func (b *circuitBreaker) broadcast() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
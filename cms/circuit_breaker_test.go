@@ -0,0 +1,197 @@
+package cms
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testBreakerConfig = BreakerConfig{FailureRate: 0.5, Window: 4, OpenTimeout: 50 * time.Millisecond, Probes: 2}
+
+func notifyTimes(t *testing.T, notifier Notifier, times int) {
+	for i := 0; i < times; i++ {
+		notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{}, "hash")
+	}
+}
+
+func TestCircuitBreakerOpensAtFailureRate(t *testing.T) {
+	failing := new(MockNotifier)
+	failing.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(errors.New("oh dear")).Times(2)
+	failing.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(nil).Times(2)
+
+	notifier, err := NewCircuitBreaker("cms", failing, testBreakerConfig)
+	require.NoError(t, err)
+
+	notifyTimes(t, notifier, 3)
+	assert.Equal(t, BreakerClosed, notifier.(Breaker).State(), "the window isn't full yet")
+
+	notifyTimes(t, notifier, 1)
+	assert.Equal(t, BreakerOpen, notifier.(Breaker).State())
+
+	err = notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{}, "hash")
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.EqualError(t, err, "Circuit breaker is open for notifier cms")
+	failing.AssertNumberOfCalls(t, "Notify", 4)
+}
+
+func TestCircuitBreakerStaysClosedBelowFailureRate(t *testing.T) {
+	notifier := new(MockNotifier)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(errors.New("oh dear")).Times(1)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(nil)
+
+	breaker, err := NewCircuitBreaker("cms", notifier, testBreakerConfig)
+	require.NoError(t, err)
+
+	notifyTimes(t, breaker, 10)
+	assert.Equal(t, BreakerClosed, breaker.(Breaker).State())
+	notifier.AssertNumberOfCalls(t, "Notify", 10)
+}
+
+func TestCircuitBreakerClosesAfterSuccessfulProbes(t *testing.T) {
+	notifier := new(MockNotifier)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(errors.New("oh dear")).Times(4)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(nil)
+
+	breaker, err := NewCircuitBreaker("cms", notifier, testBreakerConfig)
+	require.NoError(t, err)
+
+	notifyTimes(t, breaker, 4)
+	require.Equal(t, BreakerOpen, breaker.(Breaker).State())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	require.NoError(t, breaker.(Breaker).Wait(ctx))
+	assert.True(t, time.Since(start) >= 40*time.Millisecond, "should wait for the open timeout")
+	assert.Equal(t, BreakerHalfOpen, breaker.(Breaker).State())
+
+	notifyTimes(t, breaker, 1)
+	assert.Equal(t, BreakerHalfOpen, breaker.(Breaker).State())
+
+	notifyTimes(t, breaker, 1)
+	assert.Equal(t, BreakerClosed, breaker.(Breaker).State())
+}
+
+func TestCircuitBreakerReopensWhenProbeFails(t *testing.T) {
+	notifier := new(MockNotifier)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(errors.New("oh dear"))
+
+	breaker, err := NewCircuitBreaker("cms", notifier, testBreakerConfig)
+	require.NoError(t, err)
+
+	notifyTimes(t, breaker, 4)
+	time.Sleep(60 * time.Millisecond)
+	require.Equal(t, BreakerHalfOpen, breaker.(Breaker).State())
+
+	notifyTimes(t, breaker, 1)
+	assert.Equal(t, BreakerOpen, breaker.(Breaker).State())
+	notifier.AssertNumberOfCalls(t, "Notify", 5)
+}
+
+func TestCircuitBreakerAllowsOneProbeAtATime(t *testing.T) {
+	release := make(chan struct{})
+	probing := make(chan struct{})
+
+	notifier := new(MockNotifier)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(errors.New("oh dear")).Times(4)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(nil).Run(func(args mock.Arguments) {
+		close(probing)
+		<-release
+	}).Once()
+
+	breaker, err := NewCircuitBreaker("cms", notifier, testBreakerConfig)
+	require.NoError(t, err)
+
+	notifyTimes(t, breaker, 4)
+	time.Sleep(60 * time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		done <- breaker.Notify(context.Background(), "origin", "tid_1234", &native.Content{}, "hash")
+	}()
+	<-probing
+
+	err = breaker.Notify(context.Background(), "origin", "tid_1234", &native.Content{}, "hash")
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, breaker.(Breaker).Wait(ctx), "should wait for the probe to finish")
+
+	close(release)
+	assert.NoError(t, <-done)
+	assert.NoError(t, breaker.(Breaker).Wait(context.Background()))
+}
+
+func TestCircuitBreakerWaitIsCancelled(t *testing.T) {
+	notifier := new(MockNotifier)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(errors.New("oh dear"))
+
+	breaker, err := NewCircuitBreaker("cms", notifier, BreakerConfig{FailureRate: 1, Window: 1, OpenTimeout: time.Hour, Probes: 1})
+	require.NoError(t, err)
+
+	notifyTimes(t, breaker, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, breaker.(Breaker).Wait(ctx))
+}
+
+func TestCircuitBreakerChecksNotifier(t *testing.T) {
+	notifier := new(MockNotifier)
+	notifier.On("Check").Return(errors.New("unhealthy"))
+
+	breaker, err := NewCircuitBreaker("cms", notifier, testBreakerConfig)
+	require.NoError(t, err)
+
+	assert.EqualError(t, breaker.Check(), "unhealthy")
+}
+
+func TestInvalidBreakerConfig(t *testing.T) {
+	tests := []struct {
+		config BreakerConfig
+		err    string
+	}{
+		{config: BreakerConfig{FailureRate: 0, Window: 1, OpenTimeout: time.Second, Probes: 1}, err: "Invalid circuit breaker failure rate 0, please use a value between 0 and 1"},
+		{config: BreakerConfig{FailureRate: 1.5, Window: 1, OpenTimeout: time.Second, Probes: 1}, err: "Invalid circuit breaker failure rate 1.5, please use a value between 0 and 1"},
+		{config: BreakerConfig{FailureRate: 0.5, Window: 0, OpenTimeout: time.Second, Probes: 1}, err: "Invalid circuit breaker window 0, please use at least 1 publish"},
+		{config: BreakerConfig{FailureRate: 0.5, Window: 1, OpenTimeout: 0, Probes: 1}, err: "Invalid circuit breaker open timeout 0s"},
+		{config: BreakerConfig{FailureRate: 0.5, Window: 1, OpenTimeout: time.Second, Probes: 0}, err: "Invalid circuit breaker probes 0, please use at least 1 probe"},
+	}
+
+	for _, test := range tests {
+		_, err := NewCircuitBreaker("cms", new(MockNotifier), test.config)
+		assert.EqualError(t, err, test.err)
+	}
+}
+
+func TestCircuitBreakerIgnoresPermanentFailures(t *testing.T) {
+	notifier := new(MockNotifier)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(&NotifierError{Class: PermanentError, StatusCode: 400, err: errors.New("bad content")})
+
+	breaker, err := NewCircuitBreaker("cms", notifier, testBreakerConfig)
+	require.NoError(t, err)
+
+	notifyTimes(t, breaker, 10)
+	assert.Equal(t, BreakerClosed, breaker.(Breaker).State(), "bad content shouldn't open the breaker")
+	notifier.AssertNumberOfCalls(t, "Notify", 10)
+}
+
+func TestCircuitBreakerIgnoresCancelledNotifications(t *testing.T) {
+	notifier := new(MockNotifier)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(context.Canceled)
+
+	breaker, err := NewCircuitBreaker("cms", notifier, testBreakerConfig)
+	require.NoError(t, err)
+
+	notifyTimes(t, breaker, 10)
+	assert.Equal(t, BreakerClosed, breaker.(Breaker).State(), "stopping a cycle shouldn't open the breaker")
+	notifier.AssertNumberOfCalls(t, "Notify", 10)
+}
@@ -2,76 +2,181 @@ package cms
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
+	"time"
 
 	"github.com/Financial-Times/publish-carousel/cluster"
 	"github.com/Financial-Times/publish-carousel/native"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// CMSNotifierName is the name of the default notifier, which posts content to the cms-notifier
+	CMSNotifierName = "cms"
+	// KafkaNotifierName is the name of the notifier which produces content straight to Kafka
+	KafkaNotifierName = "kafka"
+	// AnnotationsNotifierName is the name of the notifier which posts annotations to the cms-metadata-notifier
+	AnnotationsNotifierName = "annotations"
+)
+
 // Notifier handles the publishing of the content to the cms-notifier
 type Notifier interface {
-	Notify(origin string, tid string, content *native.Content, hash string) error
+	Notify(ctx context.Context, origin string, tid string, content *native.Content, hash string) error
 	Check() error
 }
 
+// StatusNotifier is a Notifier which can also return the status of the response to a publish
+type StatusNotifier interface {
+	Notifier
+	NotifyWithStatus(ctx context.Context, origin string, tid string, content *native.Content, hash string) (int, error)
+}
+
 type cmsNotifier struct {
 	cluster.Service
 	client      cluster.HttpClient
 	notifierURL string
+	retry       RetryConfig
+	sleep       func(context.Context, time.Duration) error
 }
 
-// NewNotifier returns a new cms notifier instance
+// NewNotifier returns a new cms notifier instance, which does not retry failed publishes
 func NewNotifier(notifierURL string, client cluster.HttpClient) (Notifier, error) {
+	return NewRetryingNotifier(notifierURL, client, RetryConfig{})
+}
+
+// NewRetryingNotifier returns a new cms notifier instance, which retries transient failures with a backoff
+func NewRetryingNotifier(notifierURL string, client cluster.HttpClient, retry RetryConfig) (Notifier, error) {
 	s, err := cluster.NewService("cms-notifier", notifierURL, false)
 	if err != nil {
 		return nil, err
 	}
-	return &cmsNotifier{s, client, notifierURL}, nil
+	return &cmsNotifier{Service: s, client: client, notifierURL: notifierURL, retry: retry, sleep: sleep}, nil
+}
+
+// NewAnnotationsNotifier returns a retrying notifier which posts annotations to the cms-metadata-notifier
+func NewAnnotationsNotifier(notifierURL string, client cluster.HttpClient, retry RetryConfig) (Notifier, error) {
+	s, err := cluster.NewService("cms-metadata-notifier", notifierURL, false)
+	if err != nil {
+		return nil, err
+	}
+	return &cmsNotifier{Service: s, client: client, notifierURL: notifierURL, retry: retry, sleep: sleep}, nil
+}
+
+const (
+	notifyPath   = "/notify"
+	originHeader = "X-Origin-System-Id"
+)
+
+// Headers returns the headers which are sent to the cms-notifier with the content
+func Headers(origin string, tid string, content *native.Content, hash string) map[string]string {
+	if content.OriginSystemID != "" {
+		origin = content.OriginSystemID
+	}
+
+	return map[string]string{
+		"Content-Type":  content.ContentType,
+		"X-Request-Id":  tid,
+		"X-Native-Hash": hash,
+		originHeader:    origin,
+	}
 }
 
-const notifyPath = "/notify"
+// sleep waits for the backoff, unless the context is cancelled first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-func (c *cmsNotifier) Notify(origin string, tid string, content *native.Content, hash string) error {
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *cmsNotifier) Notify(ctx context.Context, origin string, tid string, content *native.Content, hash string) error {
+	_, err := c.NotifyWithStatus(ctx, origin, tid, content, hash)
+	return err
+}
+
+// NotifyWithStatus publishes the content, returning the status of the last response from the cms-notifier
+func (c *cmsNotifier) NotifyWithStatus(ctx context.Context, origin string, tid string, content *native.Content, hash string) (int, error) {
 	b := new(bytes.Buffer)
 
 	enc := json.NewEncoder(b)
 	err := enc.Encode(content.Body)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	req, err := http.NewRequest("POST", c.notifierURL+notifyPath, b)
-	req.Header.Add("User-Agent", "UPP Publish Carousel")
-	req.Header.Add("Content-Type", content.ContentType)
-	req.Header.Add("X-Request-Id", tid)
-	req.Header.Add("X-Native-Hash", hash)
-	if content.OriginSystemID != "" {
-		origin = content.OriginSystemID
+	headers := Headers(origin, tid, content, hash)
+	log.WithField("transaction_id", tid).WithField("nativeHash", hash).Info(fmt.Sprintf("Calling CMS notifier with contentType=%s, Origin=%s", content.ContentType, headers[originHeader]))
+
+	for attempt := 1; ; attempt++ {
+		status, err := c.post(ctx, headers, b.Bytes())
+		if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+			return status, ctxErr
+		}
+
+		notifierErr := &NotifierError{}
+		if !errors.As(err, &notifierErr) {
+			return status, err
+		}
+
+		notifierErr.Attempts = attempt
+		if notifierErr.Class != TransientError || attempt > c.retry.MaxRetries {
+			log.WithField("transaction_id", tid).WithField("class", notifierErr.Class).WithField("status", notifierErr.StatusCode).WithField("attempts", attempt).Warn("Failed to call the CMS notifier.")
+			return status, err
+		}
+
+		delay, ok := c.retry.backoff(attempt, notifierErr.RetryAfter)
+		if !ok {
+			log.WithField("transaction_id", tid).WithField("status", notifierErr.StatusCode).WithField("retryAfter", notifierErr.RetryAfter.String()).Warn("The CMS notifier asked for a longer delay than the maximum backoff, not retrying.")
+			return status, err
+		}
+
+		log.WithField("transaction_id", tid).WithField("status", notifierErr.StatusCode).WithField("attempt", attempt).WithField("backoff", delay.String()).WithError(err).Warn("Transient failure calling the CMS notifier, retrying.")
+		if err := c.sleep(ctx, delay); err != nil {
+			log.WithField("transaction_id", tid).WithField("attempt", attempt).Info("Publish cancelled while waiting to retry the CMS notifier.")
+			return status, err
+		}
 	}
-	req.Header.Add("X-Origin-System-Id", origin)
-	log.WithField("transaction_id", tid).WithField("nativeHash", hash).Info(fmt.Sprintf("Calling CMS notifier with contentType=%s, Origin=%s", content.ContentType, origin))
+}
 
+func (c *cmsNotifier) post(ctx context.Context, headers map[string]string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.notifierURL+notifyPath, bytes.NewReader(body))
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	req.Header.Add("User-Agent", "UPP Publish Carousel")
+	for name, value := range headers {
+		req.Header.Add(name, value)
 	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return err
+		return 0, &NotifierError{Class: TransientError, err: err}
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return nil
+		return resp.StatusCode, nil
 	}
 
 	dump, _ := httputil.DumpResponse(resp, true)
 	log.Info(string(dump))
 
-	return fmt.Errorf("A non 2xx error code was received by the CMS Notifier! Status: %v", resp.StatusCode)
+	return resp.StatusCode, &NotifierError{
+		Class:      classifyStatus(resp.StatusCode),
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		err:        fmt.Errorf("A non 2xx error code was received by the CMS Notifier! Status: %v", resp.StatusCode),
+	}
 }
@@ -1,17 +1,21 @@
 package cms
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/publish-carousel/cluster"
 	"github.com/Financial-Times/publish-carousel/native"
 	"github.com/husobee/vestigo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func (m *mockNotifierServer) startMockNotifierServer(t *testing.T) *httptest.Server {
@@ -68,7 +72,7 @@ func TestNotify(t *testing.T) {
 	notifier, err := NewNotifier(server.URL, &http.Client{})
 	assert.NoError(t, err)
 
-	err = notifier.Notify("origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
+	err = notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
 	assert.NoError(t, err)
 	mockNotifier.AssertExpectations(t)
 }
@@ -82,10 +86,27 @@ func TestNotifyWithMsgOrigin(t *testing.T) {
 	notifier, err := NewNotifier(server.URL, &http.Client{})
 	assert.NoError(t, err)
 
-	err = notifier.Notify("origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json", OriginSystemID: "systemOriginId"}, "12345")
+	err = notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json", OriginSystemID: "systemOriginId"}, "12345")
 	assert.NoError(t, err)
 	mockNotifier.AssertExpectations(t)
 }
+
+func TestNotifyAnnotations(t *testing.T) {
+	mockNotifier := new(mockNotifierServer)
+	mockNotifier.On("Notify", "http://cmdb.ft.com/systems/pac", "tid_1234", "12345", "application/json").Return(200)
+
+	server := mockNotifier.startMockNotifierServer(t)
+
+	notifier, err := NewAnnotationsNotifier(server.URL, &http.Client{}, RetryConfig{})
+	require.NoError(t, err)
+
+	err = notifier.Notify(context.Background(), "http://cmdb.ft.com/systems/pac", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
+	assert.NoError(t, err)
+	mockNotifier.AssertExpectations(t)
+
+	_, err = NewAnnotationsNotifier(":#", &http.Client{}, RetryConfig{})
+	assert.Error(t, err)
+}
 func TestNotifyFails(t *testing.T) {
 	mockNotifier := new(mockNotifierServer)
 	mockNotifier.On("Notify", "origin", "tid_1234", "12345", "application/json").Return(500)
@@ -95,7 +116,7 @@ func TestNotifyFails(t *testing.T) {
 	notifier, err := NewNotifier(server.URL, &http.Client{})
 	assert.NoError(t, err)
 
-	err = notifier.Notify("origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
+	err = notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
 	assert.Error(t, err)
 	mockNotifier.AssertExpectations(t)
 }
@@ -104,7 +125,7 @@ func TestNotifierNotRunning(t *testing.T) {
 	notifier, err := NewNotifier("http://localhost", &http.Client{})
 	assert.NoError(t, err)
 
-	err = notifier.Notify("origin", "tid_1234", &native.Content{}, "12345")
+	err = notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{}, "12345")
 	assert.Error(t, err)
 }
 
@@ -114,7 +135,7 @@ func TestJSONFails(t *testing.T) {
 
 	body := make(map[string]interface{})
 	body["error"] = func() {}
-	err = notifier.Notify("origin", "tid_1234", &native.Content{Body: body}, "12345")
+	err = notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{Body: body}, "12345")
 	assert.Error(t, err)
 }
 
@@ -171,6 +192,140 @@ func TestCMSNotifierClosesResponseBodies(t *testing.T) {
 	c.On("Do", mock.AnythingOfType("*http.Request")).Return(resp, nil)
 	body.On("Close").Return(nil)
 
-	notifier.Notify("origin", "tid", &native.Content{}, "hash")
+	notifier.Notify(context.Background(), "origin", "tid", &native.Content{}, "hash")
 	mock.AssertExpectationsForObjects(t, c, body)
 }
+
+func retryingNotifier(t *testing.T, url string, retry RetryConfig) (*cmsNotifier, *[]time.Duration) {
+	notifier, err := NewRetryingNotifier(url, &http.Client{}, retry)
+	require.NoError(t, err)
+
+	var sleeps []time.Duration
+	c := notifier.(*cmsNotifier)
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return c, &sleeps
+}
+
+func statusServer(statuses []int, retryAfter string) (*httptest.Server, *int) {
+	calls := new(int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[len(statuses)-1]
+		if *calls < len(statuses) {
+			status = statuses[*calls]
+		}
+		*calls++
+
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+	}))
+	return server, calls
+}
+
+func TestNotifyRetriesTransientFailures(t *testing.T) {
+	server, calls := statusServer([]int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, "2")
+	defer server.Close()
+
+	notifier, sleeps := retryingNotifier(t, server.URL, RetryConfig{MaxRetries: 3, MinBackoff: time.Second, MaxBackoff: 30 * time.Second})
+
+	err := notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, *calls)
+
+	require.Len(t, *sleeps, 2)
+	for _, sleep := range *sleeps {
+		assert.True(t, sleep >= 2*time.Second, "should honour the Retry-After")
+	}
+}
+
+func TestNotifyWithStatus(t *testing.T) {
+	server, _ := statusServer([]int{http.StatusServiceUnavailable, http.StatusAccepted}, "")
+	defer server.Close()
+
+	notifier, _ := retryingNotifier(t, server.URL, RetryConfig{MaxRetries: 1, MinBackoff: time.Second, MaxBackoff: 30 * time.Second})
+
+	status, err := notifier.NotifyWithStatus(context.Background(), "origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, status, "should return the status of the last attempt")
+
+	failing, _ := statusServer([]int{http.StatusBadRequest}, "")
+	defer failing.Close()
+
+	notifier, _ = retryingNotifier(t, failing.URL, RetryConfig{})
+
+	status, err = notifier.NotifyWithStatus(context.Background(), "origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestNotifyDoesNotRetryPermanentFailures(t *testing.T) {
+	server, calls := statusServer([]int{http.StatusBadRequest}, "")
+	defer server.Close()
+
+	notifier, sleeps := retryingNotifier(t, server.URL, RetryConfig{MaxRetries: 3, MinBackoff: time.Second, MaxBackoff: 30 * time.Second})
+
+	err := notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
+	assert.EqualError(t, err, "A non 2xx error code was received by the CMS Notifier! Status: 400 (permanent)")
+	assert.Equal(t, PermanentError, ErrorClass(err))
+	assert.Equal(t, 1, *calls)
+	assert.Empty(t, *sleeps)
+}
+
+func TestNotifyGivesUpAfterMaxRetries(t *testing.T) {
+	server, calls := statusServer([]int{http.StatusBadGateway}, "")
+	defer server.Close()
+
+	notifier, sleeps := retryingNotifier(t, server.URL, RetryConfig{MaxRetries: 2, MinBackoff: time.Second, MaxBackoff: 30 * time.Second})
+
+	err := notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
+	assert.EqualError(t, err, "A non 2xx error code was received by the CMS Notifier! Status: 502 (transient, after 3 attempts)")
+
+	notifierErr := &NotifierError{}
+	require.True(t, errors.As(err, &notifierErr))
+	assert.Equal(t, TransientError, notifierErr.Class)
+	assert.Equal(t, http.StatusBadGateway, notifierErr.StatusCode)
+	assert.Equal(t, 3, notifierErr.Attempts)
+	assert.Equal(t, 3, *calls)
+	assert.Len(t, *sleeps, 2)
+}
+
+func TestNotifyDoesNotRetryIfRetryAfterIsTooLong(t *testing.T) {
+	server, calls := statusServer([]int{http.StatusTooManyRequests}, "120")
+	defer server.Close()
+
+	notifier, sleeps := retryingNotifier(t, server.URL, RetryConfig{MaxRetries: 3, MinBackoff: time.Second, MaxBackoff: 30 * time.Second})
+
+	err := notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
+	assert.Equal(t, TransientError, ErrorClass(err))
+	assert.Equal(t, 1, *calls)
+	assert.Empty(t, *sleeps)
+}
+
+func TestNotifierNetworkErrorsAreTransient(t *testing.T) {
+	notifier, sleeps := retryingNotifier(t, "http://localhost:1", RetryConfig{MaxRetries: 1, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	err := notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{}, "12345")
+	assert.Equal(t, TransientError, ErrorClass(err))
+	assert.Len(t, *sleeps, 1)
+}
+
+func TestNotifyBackoffIsCancelledWithTheContext(t *testing.T) {
+	server, calls := statusServer([]int{http.StatusServiceUnavailable}, "")
+	defer server.Close()
+
+	notifier, err := NewRetryingNotifier(server.URL, &http.Client{}, RetryConfig{MaxRetries: 3, MinBackoff: time.Minute, MaxBackoff: time.Minute})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err = notifier.Notify(ctx, "origin", "tid_1234", &native.Content{Body: map[string]interface{}{"uuid": "uuid"}, ContentType: "application/json"}, "12345")
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "should stop waiting to retry when the context is cancelled")
+	assert.Equal(t, 1, *calls)
+}
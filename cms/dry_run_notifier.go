@@ -0,0 +1,44 @@
+package cms
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	log "github.com/sirupsen/logrus"
+)
+
+type dryRunNotifier struct {
+	name string
+}
+
+// NewDryRunNotifier returns a notifier which logs the content it would have sent to the named notifier
+func NewDryRunNotifier(name string) Notifier {
+	return &dryRunNotifier{name: name}
+}
+
+func (d *dryRunNotifier) Notify(ctx context.Context, origin string, tid string, content *native.Content, hash string) error {
+	data, err := json.Marshal(content.Body)
+	if err != nil {
+		return err
+	}
+
+	if content.OriginSystemID != "" {
+		origin = content.OriginSystemID
+	}
+
+	log.WithField("notifier", d.name).
+		WithField("cycle", content.Cycle).
+		WithField("transaction_id", tid).
+		WithField("nativeHash", hash).
+		WithField("origin", origin).
+		WithField("contentType", content.ContentType).
+		WithField("transforms", content.Transforms).
+		WithField("body", string(data)).
+		Info("Dry run, not calling the notifier.")
+	return nil
+}
+
+func (d *dryRunNotifier) Check() error {
+	return nil
+}
@@ -0,0 +1,46 @@
+package cms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunNotifier(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	log.SetFormatter(&log.JSONFormatter{})
+	defer log.SetFormatter(&log.TextFormatter{})
+	defer log.SetOutput(os.Stderr)
+
+	notifier := NewDryRunNotifier(CMSNotifierName)
+	content := &native.Content{
+		Body:           map[string]interface{}{"uuid": "fake-uuid"},
+		ContentType:    "application/json",
+		OriginSystemID: "methode-web-pub",
+		Transforms:     []string{"strip: remove /deprecated"},
+	}
+
+	err := notifier.Notify(context.Background(), "fake-origin", "tid_1234", content, "hash")
+	require.NoError(t, err)
+
+	entry := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+
+	assert.Equal(t, "Dry run, not calling the notifier.", entry["msg"])
+	assert.Equal(t, "cms", entry["notifier"])
+	assert.Equal(t, "tid_1234", entry["transaction_id"])
+	assert.Equal(t, "hash", entry["nativeHash"])
+	assert.Equal(t, "methode-web-pub", entry["origin"])
+	assert.Equal(t, []interface{}{"strip: remove /deprecated"}, entry["transforms"])
+	assert.Equal(t, `{"uuid":"fake-uuid"}`, entry["body"])
+
+	assert.NoError(t, notifier.Check())
+}
@@ -0,0 +1,138 @@
+package cms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// FanOutAll only succeeds if every target succeeds
+	FanOutAll = "all"
+	// FanOutAny succeeds if at least one target succeeds
+	FanOutAny = "any"
+	// FanOutPrimary succeeds if the first (primary) target succeeds. The other targets are best-effort.
+	FanOutPrimary = "primary"
+)
+
+// Target is a named notifier which a fan-out notifier publishes to
+type Target struct {
+	Name     string
+	Notifier Notifier
+}
+
+// FanOutError records the targets which failed during a fan-out publish, and whether the publish failed
+type FanOutError struct {
+	Mode   string
+	Failed bool
+	Errors map[string]error
+}
+
+func (e *FanOutError) Error() string {
+	var names []string
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var msgs []string
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%v: %v", name, e.Errors[name]))
+	}
+	return fmt.Sprintf("Failed to notify fan-out targets (%v): %v", e.Mode, strings.Join(msgs, "; "))
+}
+
+// Is reports whether any target failed with the given error
+func (e *FanOutError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+type fanOutNotifier struct {
+	mode    string
+	targets []Target
+}
+
+// NewFanOutNotifier returns a notifier which publishes to every target in parallel, succeeding according to the mode
+func NewFanOutNotifier(mode string, targets []Target) (Notifier, error) {
+	mode = strings.ToLower(mode)
+	if mode != FanOutAll && mode != FanOutAny && mode != FanOutPrimary {
+		return nil, fmt.Errorf("Invalid fan-out mode %v, please use %v, %v or %v", mode, FanOutAll, FanOutAny, FanOutPrimary)
+	}
+
+	if len(targets) == 0 {
+		return nil, errors.New("Please provide at least one fan-out target")
+	}
+
+	return &fanOutNotifier{mode: mode, targets: targets}, nil
+}
+
+func (f *fanOutNotifier) Notify(ctx context.Context, origin string, tid string, content *native.Content, hash string) error {
+	errs := f.each(func(target Target) error {
+		return target.Notifier.Notify(ctx, origin, tid, content, hash)
+	})
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	for name, err := range errs {
+		log.WithField("transaction_id", tid).WithField("target", name).WithError(err).Warn("Failed to notify fan-out target")
+	}
+
+	return &FanOutError{Mode: f.mode, Failed: f.failed(errs), Errors: errs}
+}
+
+// Check reports the unhealthy targets, if the notifier could not succeed with them
+func (f *fanOutNotifier) Check() error {
+	errs := f.each(func(target Target) error {
+		return target.Notifier.Check()
+	})
+
+	if !f.failed(errs) {
+		return nil
+	}
+	return &FanOutError{Mode: f.mode, Failed: true, Errors: errs}
+}
+
+func (f *fanOutNotifier) failed(errs map[string]error) bool {
+	switch f.mode {
+	case FanOutAny:
+		return len(errs) == len(f.targets)
+	case FanOutPrimary:
+		_, failed := errs[f.targets[0].Name]
+		return failed
+	}
+	return len(errs) > 0
+}
+
+func (f *fanOutNotifier) each(fn func(target Target) error) map[string]error {
+	lock := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+
+	errs := make(map[string]error)
+	for _, target := range f.targets {
+		wg.Add(1)
+		go func(target Target) {
+			defer wg.Done()
+			if err := fn(target); err != nil {
+				lock.Lock()
+				errs[target.Name] = err
+				lock.Unlock()
+			}
+		}(target)
+	}
+
+	wg.Wait()
+	return errs
+}
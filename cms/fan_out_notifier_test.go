@@ -0,0 +1,111 @@
+package cms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func fanOutTargets(errs ...error) ([]Target, []*MockNotifier) {
+	names := []string{"primary", "secondary", "shadow"}
+
+	var targets []Target
+	var mocks []*MockNotifier
+	for i, err := range errs {
+		m := new(MockNotifier)
+		m.On("Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash").Return(err)
+		m.On("Check").Return(err)
+
+		targets = append(targets, Target{Name: names[i], Notifier: m})
+		mocks = append(mocks, m)
+	}
+	return targets, mocks
+}
+
+func TestFanOutModes(t *testing.T) {
+	failure := errors.New("oh dear")
+
+	tests := []struct {
+		mode   string
+		errs   []error
+		failed bool
+	}{
+		{mode: FanOutAll, errs: []error{nil, nil, failure}, failed: true},
+		{mode: FanOutAny, errs: []error{failure, failure, nil}, failed: false},
+		{mode: FanOutAny, errs: []error{failure, failure, failure}, failed: true},
+		{mode: FanOutPrimary, errs: []error{nil, failure, failure}, failed: false},
+		{mode: FanOutPrimary, errs: []error{failure, nil, nil}, failed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			targets, mocks := fanOutTargets(test.errs...)
+
+			notifier, err := NewFanOutNotifier(test.mode, targets)
+			require.NoError(t, err)
+
+			err = notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{}, "hash")
+
+			fanOut := &FanOutError{}
+			require.True(t, errors.As(err, &fanOut))
+			assert.Equal(t, test.failed, fanOut.Failed)
+			assert.Equal(t, test.mode, fanOut.Mode)
+
+			for i, target := range targets {
+				if test.errs[i] != nil {
+					assert.Equal(t, failure, fanOut.Errors[target.Name])
+				} else {
+					assert.NotContains(t, fanOut.Errors, target.Name)
+				}
+				mocks[i].AssertCalled(t, "Notify", mock.Anything, "origin", "tid_1234", &native.Content{}, "hash")
+			}
+
+			if test.failed {
+				assert.Error(t, notifier.Check())
+			} else {
+				assert.NoError(t, notifier.Check())
+			}
+		})
+	}
+}
+
+func TestFanOutSucceeds(t *testing.T) {
+	targets, mocks := fanOutTargets(nil, nil)
+
+	notifier, err := NewFanOutNotifier("ALL", targets)
+	require.NoError(t, err)
+
+	assert.NoError(t, notifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{}, "hash"))
+	assert.NoError(t, notifier.Check())
+
+	for _, m := range mocks {
+		m.AssertExpectations(t)
+	}
+}
+
+func TestFanOutErrorMessage(t *testing.T) {
+	err := &FanOutError{Mode: FanOutAll, Failed: true, Errors: map[string]error{"shadow": errors.New("shadow is down"), "cms": errors.New("cms is down")}}
+	assert.EqualError(t, err, "Failed to notify fan-out targets (all): cms: cms is down; shadow: shadow is down")
+}
+
+func TestFanOutErrorIs(t *testing.T) {
+	err := &FanOutError{Mode: FanOutAll, Failed: true, Errors: map[string]error{"shadow": errors.New("shadow is down"), "cms": fmt.Errorf("%w for notifier cms", ErrCircuitOpen)}}
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+
+	err = &FanOutError{Mode: FanOutAll, Failed: true, Errors: map[string]error{"shadow": errors.New("shadow is down")}}
+	assert.False(t, errors.Is(err, ErrCircuitOpen))
+}
+
+func TestNewFanOutNotifierFails(t *testing.T) {
+	_, err := NewFanOutNotifier("some", []Target{{Name: "cms", Notifier: new(MockNotifier)}})
+	assert.EqualError(t, err, "Invalid fan-out mode some, please use all, any or primary")
+
+	_, err = NewFanOutNotifier(FanOutAll, nil)
+	assert.EqualError(t, err, "Please provide at least one fan-out target")
+}
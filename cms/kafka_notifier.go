@@ -0,0 +1,121 @@
+package cms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Shopify/sarama"
+	log "github.com/sirupsen/logrus"
+)
+
+const uuidAttr = "uuid"
+
+type kafkaNotifier struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+	topic    string
+}
+
+// NewKafkaNotifier returns a notifier which produces content straight to the given Kafka topic
+func NewKafkaNotifier(brokers []string, topic string) (Notifier, error) {
+	config := sarama.NewConfig()
+	config.ClientID = "publish-carousel"
+	config.Version = sarama.V0_11_0_0
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = 3
+	config.Producer.Timeout = 10 * time.Second
+
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &kafkaNotifier{client: client, producer: producer, topic: topic}, nil
+}
+
+func (k *kafkaNotifier) Notify(ctx context.Context, origin string, tid string, content *native.Content, hash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg, err := newKafkaMessage(k.topic, origin, tid, content, hash)
+	if err != nil {
+		return err
+	}
+
+	partition, offset, err := k.producer.SendMessage(msg)
+	if err != nil {
+		return fmt.Errorf("Failed to produce to Kafka topic %v: %v", k.topic, err)
+	}
+
+	log.WithField("transaction_id", tid).WithField("nativeHash", hash).WithField("partition", partition).WithField("offset", offset).Info(fmt.Sprintf("Produced to Kafka topic %s with contentType=%s", k.topic, content.ContentType))
+	return nil
+}
+
+// Check verifies that the topic exists, and that its partitions have leaders which can be produced to
+func (k *kafkaNotifier) Check() error {
+	if err := k.client.RefreshMetadata(k.topic); err != nil {
+		return err
+	}
+
+	partitions, err := k.client.WritablePartitions(k.topic)
+	if err != nil {
+		return err
+	}
+
+	if len(partitions) == 0 {
+		return fmt.Errorf("Kafka topic %v has no writable partitions", k.topic)
+	}
+	return nil
+}
+
+// Close stops the producer, and closes its connections to the brokers
+func (k *kafkaNotifier) Close() error {
+	if err := k.producer.Close(); err != nil {
+		return err
+	}
+	return k.client.Close()
+}
+
+// newKafkaMessage builds the message for the content, keyed by its uuid
+func newKafkaMessage(topic string, origin string, tid string, content *native.Content, hash string) (*sarama.ProducerMessage, error) {
+	b := new(bytes.Buffer)
+
+	enc := json.NewEncoder(b)
+	err := enc.Encode(content.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if content.OriginSystemID != "" {
+		origin = content.OriginSystemID
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(b.Bytes()),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("Content-Type"), Value: []byte(content.ContentType)},
+			{Key: []byte("X-Request-Id"), Value: []byte(tid)},
+			{Key: []byte("X-Native-Hash"), Value: []byte(hash)},
+			{Key: []byte("X-Origin-System-Id"), Value: []byte(origin)},
+		},
+	}
+
+	if uuid, ok := content.Body[uuidAttr].(string); ok && uuid != "" {
+		msg.Key = sarama.StringEncoder(uuid)
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,135 @@
+package cms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTopic = "NativeCmsPublicationEvents"
+
+func newFakeBroker(t *testing.T, produceErr sarama.KError) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(testTopic, 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t).
+			SetVersion(3).
+			SetError(testTopic, 0, produceErr),
+	})
+	return broker
+}
+
+func producedRequests(broker *sarama.MockBroker) []*sarama.ProduceRequest {
+	var requests []*sarama.ProduceRequest
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.ProduceRequest); ok {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+func TestKafkaNotifier(t *testing.T) {
+	broker := newFakeBroker(t, sarama.ErrNoError)
+	defer broker.Close()
+
+	notifier, err := NewKafkaNotifier([]string{broker.Addr()}, testTopic)
+	require.NoError(t, err)
+	defer notifier.(*kafkaNotifier).Close()
+
+	content := &native.Content{Body: map[string]interface{}{"uuid": "fake-uuid"}, ContentType: "application/json"}
+	err = notifier.Notify(context.Background(), "methode-web-pub", "tid_1234", content, "hash")
+	assert.NoError(t, err)
+
+	requests := producedRequests(broker)
+	require.Len(t, requests, 1)
+	assert.Equal(t, int16(3), requests[0].Version, "headers require produce requests with record batches")
+	assert.Equal(t, sarama.WaitForAll, requests[0].RequiredAcks)
+
+	assert.NoError(t, notifier.Check())
+}
+
+func TestKafkaNotifierFails(t *testing.T) {
+	broker := newFakeBroker(t, sarama.ErrMessageSizeTooLarge)
+	defer broker.Close()
+
+	notifier, err := NewKafkaNotifier([]string{broker.Addr()}, testTopic)
+	require.NoError(t, err)
+	defer notifier.(*kafkaNotifier).Close()
+
+	content := &native.Content{Body: map[string]interface{}{"uuid": "fake-uuid"}, ContentType: "application/json"}
+	err = notifier.Notify(context.Background(), "methode-web-pub", "tid_1234", content, "hash")
+	assert.EqualError(t, err, "Failed to produce to Kafka topic NativeCmsPublicationEvents: kafka server: Message was too large, server rejected it to avoid allocation error.")
+}
+
+func TestKafkaNotifierCheckMissingTopic(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID()),
+	})
+
+	notifier, err := NewKafkaNotifier([]string{broker.Addr()}, testTopic)
+	require.NoError(t, err)
+	defer notifier.(*kafkaNotifier).Close()
+
+	assert.Error(t, notifier.Check())
+}
+
+func TestKafkaNotifierNoBrokers(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	addr := broker.Addr()
+	broker.Close()
+
+	_, err := NewKafkaNotifier([]string{addr}, testTopic)
+	assert.Error(t, err)
+}
+
+func TestNewKafkaMessage(t *testing.T) {
+	content := &native.Content{
+		Body:           map[string]interface{}{"uuid": "fake-uuid", "title": "A title"},
+		ContentType:    "application/json",
+		OriginSystemID: "http://cmdb.ft.com/systems/methode-web-pub",
+	}
+
+	msg, err := newKafkaMessage(testTopic, "fake-origin", "tid_1234", content, "hash")
+	require.NoError(t, err)
+
+	assert.Equal(t, testTopic, msg.Topic)
+	assert.Equal(t, sarama.StringEncoder("fake-uuid"), msg.Key)
+
+	value, err := msg.Value.Encode()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"uuid":"fake-uuid","title":"A title"}`, string(value))
+
+	headers := make(map[string]string)
+	for _, header := range msg.Headers {
+		headers[string(header.Key)] = string(header.Value)
+	}
+
+	assert.Equal(t, map[string]string{
+		"Content-Type":       "application/json",
+		"X-Request-Id":       "tid_1234",
+		"X-Native-Hash":      "hash",
+		"X-Origin-System-Id": "http://cmdb.ft.com/systems/methode-web-pub",
+	}, headers)
+}
+
+func TestNewKafkaMessageWithoutOriginSystemID(t *testing.T) {
+	msg, err := newKafkaMessage(testTopic, "fake-origin", "tid_1234", &native.Content{Body: map[string]interface{}{}}, "hash")
+	require.NoError(t, err)
+	assert.Nil(t, msg.Key)
+
+	for _, header := range msg.Headers {
+		if string(header.Key) == "X-Origin-System-Id" {
+			assert.Equal(t, "fake-origin", string(header.Value))
+		}
+	}
+}
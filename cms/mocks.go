@@ -1,6 +1,8 @@
 package cms
 
 import (
+	"context"
+
 	"github.com/Financial-Times/publish-carousel/native"
 	"github.com/stretchr/testify/mock"
 )
@@ -9,8 +11,8 @@ type MockNotifier struct {
 	mock.Mock
 }
 
-func (m *MockNotifier) Notify(origin string, tid string, content *native.Content, hash string) error {
-	args := m.Called(origin, tid, content, hash)
+func (m *MockNotifier) Notify(ctx context.Context, origin string, tid string, content *native.Content, hash string) error {
+	args := m.Called(ctx, origin, tid, content, hash)
 	return args.Error(0)
 }
 
@@ -18,3 +20,28 @@ func (m *MockNotifier) Check() error {
 	args := m.Called()
 	return args.Error(0)
 }
+
+// MockStatusNotifier is a notifier which returns the status of its responses
+type MockStatusNotifier struct {
+	MockNotifier
+}
+
+func (m *MockStatusNotifier) NotifyWithStatus(ctx context.Context, origin string, tid string, content *native.Content, hash string) (int, error) {
+	args := m.Called(ctx, origin, tid, content, hash)
+	return args.Int(0), args.Error(1)
+}
+
+// MockBreaker is a notifier with a circuit breaker
+type MockBreaker struct {
+	MockNotifier
+}
+
+func (m *MockBreaker) State() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockBreaker) Wait(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
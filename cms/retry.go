@@ -0,0 +1,101 @@
+package cms
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// PermanentError is the class of failures which will fail again if retried
+	PermanentError = "permanent"
+	// TransientError is the class of failures caused by the notifier being unavailable or overloaded
+	TransientError = "transient"
+)
+
+// NotifierError is a failed call to the cms-notifier, classified as a permanent or transient failure
+type NotifierError struct {
+	Class      string
+	StatusCode int
+	RetryAfter time.Duration
+	Attempts   int
+	err        error
+}
+
+func (e *NotifierError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%v (%v, after %v attempts)", e.err, e.Class, e.Attempts)
+	}
+	return fmt.Sprintf("%v (%v)", e.err, e.Class)
+}
+
+func (e *NotifierError) Unwrap() error {
+	return e.err
+}
+
+// ErrorClass returns whether the error is a permanent or transient failure, or an empty string if it was not classified
+func ErrorClass(err error) string {
+	notifierErr := &NotifierError{}
+	if errors.As(err, &notifierErr) {
+		return notifierErr.Class
+	}
+	return ""
+}
+
+func classifyStatus(status int) string {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return TransientError
+	}
+	return PermanentError
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a number of seconds or an http date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// RetryConfig bounds the retries of transient failures
+type RetryConfig struct {
+	// MaxRetries is the number of times a publish is retried after the first attempt
+	MaxRetries int
+	// MinBackoff is the delay before the first retry, which doubles for each retry after it
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between retries
+	MaxBackoff time.Duration
+}
+
+// backoff returns the jittered delay before the given retry, or false if the Retry-After is too long
+func (c RetryConfig) backoff(retry int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > c.MaxBackoff {
+		return 0, false
+	}
+
+	delay := c.MinBackoff << uint(retry-1)
+	if delay > c.MaxBackoff || delay < c.MinBackoff {
+		delay = c.MaxBackoff
+	}
+
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	if delay < retryAfter {
+		delay = retryAfter
+	}
+	return delay, true
+}
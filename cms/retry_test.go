@@ -0,0 +1,73 @@
+package cms
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyStatus(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		assert.Equal(t, TransientError, classifyStatus(status), "status %v", status)
+	}
+
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusInternalServerError} {
+		assert.Equal(t, PermanentError, classifyStatus(status), "status %v", status)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &NotifierError{Class: TransientError, err: errors.New("oh dear")})
+	assert.Equal(t, TransientError, ErrorClass(err))
+	assert.Equal(t, "", ErrorClass(errors.New("oh dear")))
+	assert.Equal(t, "", ErrorClass(nil))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 5*time.Second, parseRetryAfter("5", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter("Thu, 01 Jun 2017 12:01:30 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Thu, 01 Jun 2017 11:00:00 GMT", now), "dates in the past should be ignored")
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+}
+
+func TestBackoff(t *testing.T) {
+	config := RetryConfig{MaxRetries: 10, MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{retry: 1, min: 500 * time.Millisecond, max: time.Second},
+		{retry: 2, min: time.Second, max: 2 * time.Second},
+		{retry: 3, min: 2 * time.Second, max: 4 * time.Second},
+		{retry: 5, min: 5 * time.Second, max: 10 * time.Second},
+		{retry: 80, min: 5 * time.Second, max: 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 100; i++ {
+			delay, ok := config.backoff(test.retry, 0)
+			assert.True(t, ok)
+			assert.True(t, delay >= test.min && delay <= test.max, "retry %v backoff %v should be between %v and %v", test.retry, delay, test.min, test.max)
+		}
+	}
+}
+
+func TestBackoffHonoursRetryAfter(t *testing.T) {
+	config := RetryConfig{MaxRetries: 3, MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	delay, ok := config.backoff(1, 8*time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, 8*time.Second, delay)
+
+	_, ok = config.backoff(1, 11*time.Second)
+	assert.False(t, ok, "shouldn't wait longer than the maximum backoff")
+}
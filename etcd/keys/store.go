@@ -0,0 +1,137 @@
+package keys
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const storeTimeout = 10 * time.Second
+
+// etcd v2 error codes
+const (
+	errorCodeKeyNotFound = 100
+	errorCodeTestFailed  = 101
+	errorCodeNodeExist   = 105
+)
+
+// VersionedStore is a key value store supporting compare-and-swap writes, such as etcd
+type VersionedStore interface {
+	Get(key string) (string, uint64, bool, error)
+	CompareAndSwap(key string, value string, version uint64) (uint64, bool, error)
+}
+
+type etcdStore struct {
+	endpoints []string
+	client    *http.Client
+}
+
+type keysResponse struct {
+	ErrorCode int    `json:"errorCode"`
+	Message   string `json:"message"`
+	Node      struct {
+		Value         string `json:"value"`
+		ModifiedIndex uint64 `json:"modifiedIndex"`
+	} `json:"node"`
+}
+
+// NewStore returns a store which reads and writes keys through the etcd v2 keys API, trying each endpoint in turn
+func NewStore(endpoints []string) (VersionedStore, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("No etcd endpoints configured")
+	}
+
+	return &etcdStore{endpoints: endpoints, client: &http.Client{Timeout: storeTimeout}}, nil
+}
+
+// Get returns the value of the key along with its version (the etcd modified index), or false if the key does not exist
+func (e *etcdStore) Get(key string) (string, uint64, bool, error) {
+	resp, err := e.do(http.MethodGet, key, nil)
+	if err != nil {
+		return "", 0, false, err
+	}
+
+	switch resp.ErrorCode {
+	case 0:
+		return resp.Node.Value, resp.Node.ModifiedIndex, true, nil
+	case errorCodeKeyNotFound:
+		return "", 0, false, nil
+	default:
+		return "", 0, false, resp.err(key)
+	}
+}
+
+// CompareAndSwap sets the key if its version matches, where a version of 0 means the key does not exist yet
+func (e *etcdStore) CompareAndSwap(key string, value string, version uint64) (uint64, bool, error) {
+	form := url.Values{"value": {value}}
+	if version == 0 {
+		form.Set("prevExist", "false")
+	} else {
+		form.Set("prevIndex", strconv.FormatUint(version, 10))
+	}
+
+	resp, err := e.do(http.MethodPut, key, form)
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch resp.ErrorCode {
+	case 0:
+		return resp.Node.ModifiedIndex, true, nil
+	case errorCodeTestFailed, errorCodeNodeExist:
+		return 0, false, nil
+	default:
+		return 0, false, resp.err(key)
+	}
+}
+
+// do sends the request to each endpoint until one responds
+func (e *etcdStore) do(method string, key string, form url.Values) (*keysResponse, error) {
+	var err error
+	for _, endpoint := range e.endpoints {
+		var resp *keysResponse
+		resp, err = e.doWithEndpoint(endpoint, method, key, form)
+		if err == nil {
+			return resp, nil
+		}
+	}
+	return nil, err
+}
+
+func (e *etcdStore) doWithEndpoint(endpoint string, method string, key string, form url.Values) (*keysResponse, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(endpoint, "/")+"/v2/keys/"+strings.TrimPrefix(key, "/"), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	keysResp := &keysResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(keysResp); err != nil {
+		return nil, fmt.Errorf("Unexpected response from etcd, status %v: %v", resp.StatusCode, err)
+	}
+	return keysResp, nil
+}
+
+func (r *keysResponse) err(key string) error {
+	return fmt.Errorf(`etcd request for "%v" failed with error code %v: %v`, key, r.ErrorCode, r.Message)
+}
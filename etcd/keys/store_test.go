@@ -0,0 +1,147 @@
+package keys
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type node struct {
+	Key           string `json:"key"`
+	Value         string `json:"value"`
+	ModifiedIndex uint64 `json:"modifiedIndex"`
+}
+
+// fakeEtcd implements the parts of the etcd v2 keys API which the store uses
+type fakeEtcd struct {
+	sync.Mutex
+	nodes    map[string]node
+	index    uint64
+	requests []*http.Request
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	r.ParseForm()
+	f.requests = append(f.requests, r)
+
+	key := strings.TrimPrefix(r.URL.Path, "/v2/keys")
+	existing, found := f.nodes[key]
+
+	respond := func(status int, body interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !found {
+			respond(http.StatusNotFound, map[string]interface{}{"errorCode": 100, "message": "Key not found"})
+			return
+		}
+		respond(http.StatusOK, map[string]interface{}{"action": "get", "node": existing})
+	case http.MethodPut:
+		if r.PostForm.Get("prevExist") == "false" && found {
+			respond(http.StatusPreconditionFailed, map[string]interface{}{"errorCode": 105, "message": "Key already exists"})
+			return
+		}
+
+		if prevIndex := r.PostForm.Get("prevIndex"); prevIndex != "" && (!found || prevIndex != strconv.FormatUint(existing.ModifiedIndex, 10)) {
+			respond(http.StatusPreconditionFailed, map[string]interface{}{"errorCode": 101, "message": "Compare failed"})
+			return
+		}
+
+		f.index++
+		f.nodes[key] = node{Key: key, Value: r.PostForm.Get("value"), ModifiedIndex: f.index}
+		respond(http.StatusOK, map[string]interface{}{"action": "compareAndSwap", "node": f.nodes[key]})
+	default:
+		respond(http.StatusMethodNotAllowed, map[string]interface{}{"errorCode": 405, "message": "Method not allowed"})
+	}
+}
+
+func startFakeEtcd(t *testing.T) (*fakeEtcd, *httptest.Server) {
+	etcd := &fakeEtcd{nodes: make(map[string]node), index: 10}
+	return etcd, httptest.NewServer(etcd)
+}
+
+func TestStoreCompareAndSwap(t *testing.T) {
+	etcd, server := startFakeEtcd(t)
+	defer server.Close()
+
+	store, err := NewStore([]string{server.URL})
+	require.NoError(t, err)
+
+	key := "/ft/publish-carousel/test"
+
+	_, _, found, err := store.Get(key)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	version, ok, err := store.CompareAndSwap(key, "first", 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "false", etcd.requests[1].PostForm.Get("prevExist"), "a version of 0 should require that the key does not exist")
+
+	_, ok, err = store.CompareAndSwap(key, "conflict", 0)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = store.CompareAndSwap(key, "stale", version-1)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, strconv.FormatUint(version-1, 10), etcd.requests[3].PostForm.Get("prevIndex"))
+
+	newVersion, ok, err := store.CompareAndSwap(key, "second", version)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	value, actualVersion, found, err := store.Get(key)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "second", value)
+	assert.Equal(t, newVersion, actualVersion)
+}
+
+func TestStoreTriesEachEndpoint(t *testing.T) {
+	_, server := startFakeEtcd(t)
+	defer server.Close()
+
+	store, err := NewStore([]string{"http://localhost:1", server.URL + "/"})
+	require.NoError(t, err)
+
+	_, ok, err := store.CompareAndSwap("/key", "value", 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestStoreFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"errorCode": 300, "message": "Raft internal error"}`))
+	}))
+	defer server.Close()
+
+	store, err := NewStore([]string{server.URL})
+	require.NoError(t, err)
+
+	_, _, err = store.CompareAndSwap("/key", "value", 0)
+	assert.EqualError(t, err, `etcd request for "/key" failed with error code 300: Raft internal error`)
+
+	_, _, _, err = store.Get("/key")
+	assert.Error(t, err)
+}
+
+func TestStoreNoEndpoints(t *testing.T) {
+	_, err := NewStore(nil)
+	assert.EqualError(t, err, "No etcd endpoints configured")
+}
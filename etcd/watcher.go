@@ -22,6 +22,15 @@ type etcdWatcher struct {
 
 // NewEtcdWatcher returns a new etcd watcher
 func NewEtcdWatcher(endpointsList []string) (Watcher, error) {
+	api, err := newKeysAPI(endpointsList)
+	if err != nil {
+		return nil, err
+	}
+
+	return &etcdWatcher{api}, nil
+}
+
+func newKeysAPI(endpointsList []string) (etcdClient.KeysAPI, error) {
 	transport := &http.Transport{
 		Dial: proxy.Direct.Dial,
 		ResponseHeaderTimeout: 10 * time.Second,
@@ -40,8 +49,7 @@ func NewEtcdWatcher(endpointsList []string) (Watcher, error) {
 		return nil, err
 	}
 
-	api := etcdClient.NewKeysAPI(client)
-	return &etcdWatcher{api}, nil
+	return etcdClient.NewKeysAPI(client), nil
 }
 
 func (e *etcdWatcher) Read(key string) (string, error) {
@@ -0,0 +1,271 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// Allow publishes content matching the rule
+	Allow = "allow"
+	// Deny skips content matching the rule
+	Deny = "deny"
+)
+
+const lastModifiedAttr = "lastModified"
+
+// Config holds the global filter rules, and the rules for each cycle and task
+type Config struct {
+	Global []*Rule            `yaml:"global"`
+	Cycles map[string][]*Rule `yaml:"cycles"`
+	Tasks  map[string][]*Rule `yaml:"tasks"`
+}
+
+// Rule matches content which matches all of its configured conditions
+type Rule struct {
+	Name        string        `yaml:"name" json:"name"`
+	Action      string        `yaml:"action" json:"action"`
+	Type        []string      `yaml:"type" json:"type,omitempty"`
+	ContentType []string      `yaml:"contentType" json:"contentType,omitempty"`
+	Origin      []string      `yaml:"origin" json:"origin,omitempty"`
+	Fields      []*FieldMatch `yaml:"fields" json:"fields,omitempty"`
+	OlderThan   string        `yaml:"olderThan" json:"olderThan,omitempty"`
+	NewerThan   string        `yaml:"newerThan" json:"newerThan,omitempty"`
+
+	olderThan time.Duration
+	newerThan time.Duration
+}
+
+// FieldMatch matches the values found at a JSONPath in the content body
+type FieldMatch struct {
+	Path    string   `yaml:"path" json:"path"`
+	Equals  []string `yaml:"equals" json:"equals,omitempty"`
+	Matches string   `yaml:"matches" json:"matches,omitempty"`
+	Exists  *bool    `yaml:"exists" json:"exists,omitempty"`
+
+	path    path
+	matches *regexp.Regexp
+}
+
+// Decision is the outcome of filtering content. Rule is empty if no rule matched.
+type Decision struct {
+	Publish bool
+	Rule    string
+}
+
+// Chain is an ordered list of rules, where the first rule which matches the content decides whether it is published
+type Chain []*Rule
+
+// LoadConfigFromFile reads and validates the filter rules in the given yaml file
+func LoadConfigFromFile(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(data)
+}
+
+// ParseConfig parses and validates the filter rules in the given yaml
+func ParseConfig(data []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, err
+	}
+
+	return config, config.Validate()
+}
+
+// Validate checks and compiles every rule in the config
+func (c *Config) Validate() error {
+	for _, rule := range c.Global {
+		if err := rule.compile(); err != nil {
+			return err
+		}
+	}
+
+	for cycle, rules := range c.Cycles {
+		for _, rule := range rules {
+			if err := rule.compile(); err != nil {
+				return fmt.Errorf("Invalid filter rules for cycle %v: %v", cycle, err)
+			}
+		}
+	}
+
+	for task, rules := range c.Tasks {
+		for _, rule := range rules {
+			if err := rule.compile(); err != nil {
+				return fmt.Errorf("Invalid filter rules for task %v: %v", task, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ForCycle returns the named cycle's own rules, followed by the global rules
+func (c *Config) ForCycle(name string) Chain {
+	chain := Chain{}
+	chain = append(chain, c.Cycles[name]...)
+	return append(chain, c.Global...)
+}
+
+// ForTask returns the rules which only apply to cycles running the named task
+func (c *Config) ForTask(name string) Chain {
+	return append(Chain{}, c.Tasks[name]...)
+}
+
+// Check decides whether the content should be published
+func (c Chain) Check(uuid string, content *native.Content) (Decision, error) {
+	if content == nil || content.Body == nil {
+		return Decision{}, errors.New("no body found")
+	}
+
+	now := time.Now()
+	for _, rule := range c {
+		if rule.matches(content, now) {
+			return Decision{Publish: rule.Action == Allow, Rule: rule.Name}, nil
+		}
+	}
+
+	return Decision{Publish: true}, nil
+}
+
+func (r *Rule) compile() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("Please provide a name for every filter rule")
+	}
+
+	r.Action = strings.ToLower(r.Action)
+	if r.Action != Allow && r.Action != Deny {
+		return fmt.Errorf("Invalid action %v for filter rule %v, please use %v or %v", r.Action, r.Name, Allow, Deny)
+	}
+
+	if len(r.Type) == 0 && len(r.ContentType) == 0 && len(r.Origin) == 0 && len(r.Fields) == 0 && r.OlderThan == "" && r.NewerThan == "" {
+		return fmt.Errorf("Filter rule %v has no conditions", r.Name)
+	}
+
+	var err error
+	if r.OlderThan != "" {
+		if r.olderThan, err = time.ParseDuration(r.OlderThan); err != nil {
+			return fmt.Errorf("Invalid olderThan for filter rule %v: %v", r.Name, err)
+		}
+	}
+
+	if r.NewerThan != "" {
+		if r.newerThan, err = time.ParseDuration(r.NewerThan); err != nil {
+			return fmt.Errorf("Invalid newerThan for filter rule %v: %v", r.Name, err)
+		}
+	}
+
+	for _, field := range r.Fields {
+		if field.path, err = compilePath(field.Path); err != nil {
+			return fmt.Errorf("Invalid field for filter rule %v: %v", r.Name, err)
+		}
+
+		if field.Matches != "" {
+			if field.matches, err = regexp.Compile(field.Matches); err != nil {
+				return fmt.Errorf("Invalid field regex for filter rule %v: %v", r.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Rule) matches(content *native.Content, now time.Time) bool {
+	if len(r.Type) > 0 {
+		contentType, _ := content.Body["type"].(string)
+		if !containsFold(r.Type, contentType) {
+			return false
+		}
+	}
+
+	if len(r.ContentType) > 0 {
+		mediaType, _, err := mime.ParseMediaType(content.ContentType)
+		if err != nil || !containsFold(r.ContentType, mediaType) {
+			return false
+		}
+	}
+
+	if len(r.Origin) > 0 && !containsFold(r.Origin, content.OriginSystemID) {
+		return false
+	}
+
+	for _, field := range r.Fields {
+		if !field.matchesBody(content.Body) {
+			return false
+		}
+	}
+
+	if r.OlderThan != "" || r.NewerThan != "" {
+		lastModified, ok := lastModifiedOf(content)
+		if !ok {
+			return false
+		}
+
+		age := now.Sub(lastModified)
+		if r.OlderThan != "" && age <= r.olderThan {
+			return false
+		}
+
+		if r.NewerThan != "" && age >= r.newerThan {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f *FieldMatch) matchesBody(body map[string]interface{}) bool {
+	values := f.path.resolve(body)
+	if f.Exists != nil && !*f.Exists {
+		return len(values) == 0
+	}
+
+	if len(f.Equals) == 0 && f.matches == nil {
+		return len(values) > 0
+	}
+
+	for _, value := range values {
+		str := fmt.Sprintf("%v", value)
+		if contains(f.Equals, str) || (f.matches != nil && f.matches.MatchString(str)) {
+			return true
+		}
+	}
+	return false
+}
+
+func lastModifiedOf(content *native.Content) (time.Time, bool) {
+	value, ok := content.Body[lastModifiedAttr].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	lastModified, err := time.Parse(time.RFC3339Nano, value)
+	return lastModified, err == nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
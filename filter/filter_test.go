@@ -0,0 +1,271 @@
+package filter
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const skipImages = `
+global:
+-  name: skip-images
+   action: deny
+   type:
+   - Image
+`
+
+func compiled(t *testing.T, config *Config) *Config {
+	require.NoError(t, config.Validate())
+	return config
+}
+
+func parsed(t *testing.T, yaml string) *Config {
+	config, err := ParseConfig([]byte(yaml))
+	require.NoError(t, err)
+	return config
+}
+
+func TestSkipImages(t *testing.T) {
+	chain := parsed(t, skipImages).ForCycle("methode-whole-archive")
+
+	decision, err := chain.Check("fake-uuid", &native.Content{Body: map[string]interface{}{"type": "Image"}})
+	assert.NoError(t, err)
+	assert.False(t, decision.Publish)
+	assert.Equal(t, "skip-images", decision.Rule)
+
+	decision, err = chain.Check("fake-uuid", &native.Content{Body: map[string]interface{}{"type": "image"}})
+	assert.NoError(t, err)
+	assert.False(t, decision.Publish)
+}
+
+func TestSkipImagesPublishesContent(t *testing.T) {
+	chain := parsed(t, skipImages).ForCycle("methode-whole-archive")
+
+	decision, err := chain.Check("fake-uuid", &native.Content{Body: map[string]interface{}{"type": "Content"}})
+	assert.NoError(t, err)
+	assert.True(t, decision.Publish)
+	assert.Equal(t, "", decision.Rule)
+
+	decision, err = chain.Check("fake-uuid", &native.Content{Body: map[string]interface{}{}})
+	assert.NoError(t, err)
+	assert.True(t, decision.Publish, "content with no type should be published")
+}
+
+func TestCheckFailsMissingBody(t *testing.T) {
+	chain := parsed(t, skipImages).ForCycle("methode-whole-archive")
+
+	_, err := chain.Check("fake-uuid", &native.Content{})
+	assert.EqualError(t, err, "no body found")
+
+	_, err = chain.Check("fake-uuid", nil)
+	assert.EqualError(t, err, "no body found")
+}
+
+func TestCycleRulesTakePrecedence(t *testing.T) {
+	config := compiled(t, &Config{
+		Global: []*Rule{{Name: "skip-images", Action: Deny, Type: []string{"Image"}}},
+		Cycles: map[string][]*Rule{
+			"images": {{Name: "publish-images", Action: Allow, Type: []string{"Image"}}},
+		},
+	})
+
+	image := &native.Content{Body: map[string]interface{}{"type": "Image"}}
+
+	decision, err := config.ForCycle("images").Check("fake-uuid", image)
+	assert.NoError(t, err)
+	assert.True(t, decision.Publish)
+	assert.Equal(t, "publish-images", decision.Rule)
+
+	decision, err = config.ForCycle("methode-whole-archive").Check("fake-uuid", image)
+	assert.NoError(t, err)
+	assert.False(t, decision.Publish)
+	assert.Equal(t, "skip-images", decision.Rule)
+}
+
+func TestRuleMatchesAllConditions(t *testing.T) {
+	config := compiled(t, &Config{
+		Global: []*Rule{{
+			Name:        "skip-methode-lists",
+			Action:      Deny,
+			Type:        []string{"List"},
+			ContentType: []string{"application/json"},
+			Origin:      []string{"http://cmdb.ft.com/systems/methode-web-pub"},
+		}},
+	})
+	chain := config.ForCycle("test")
+
+	list := &native.Content{
+		Body:           map[string]interface{}{"type": "List"},
+		ContentType:    "application/json; charset=utf-8",
+		OriginSystemID: "http://cmdb.ft.com/systems/methode-web-pub",
+	}
+
+	decision, err := chain.Check("fake-uuid", list)
+	assert.NoError(t, err)
+	assert.False(t, decision.Publish)
+
+	list.OriginSystemID = "http://cmdb.ft.com/systems/wordpress"
+	decision, err = chain.Check("fake-uuid", list)
+	assert.NoError(t, err)
+	assert.True(t, decision.Publish, "rules only match if every condition matches")
+
+	list.OriginSystemID = "http://cmdb.ft.com/systems/methode-web-pub"
+	list.ContentType = "application/xml"
+	decision, err = chain.Check("fake-uuid", list)
+	assert.NoError(t, err)
+	assert.True(t, decision.Publish)
+}
+
+func TestFieldRules(t *testing.T) {
+	yes := true
+	no := false
+
+	config := compiled(t, &Config{
+		Global: []*Rule{
+			{Name: "allow-ft", Action: Allow, Fields: []*FieldMatch{{Path: "$.brands[*].id", Equals: []string{"ft"}}}},
+			{Name: "deny-test-titles", Action: Deny, Fields: []*FieldMatch{{Path: "$.title", Matches: "^TEST"}}},
+			{Name: "deny-unbranded", Action: Deny, Fields: []*FieldMatch{{Path: "$.brands", Exists: &no}}},
+			{Name: "deny-other-brands", Action: Deny, Fields: []*FieldMatch{{Path: "$.brands", Exists: &yes}}},
+		},
+	})
+	chain := config.ForCycle("test")
+
+	tests := []struct {
+		name    string
+		body    map[string]interface{}
+		publish bool
+		rule    string
+	}{
+		{
+			name:    "ft brand",
+			body:    map[string]interface{}{"brands": []interface{}{map[string]interface{}{"id": "sub-brand"}, map[string]interface{}{"id": "ft"}}},
+			publish: true,
+			rule:    "allow-ft",
+		},
+		{
+			name:    "nested bson documents",
+			body:    map[string]interface{}{"brands": []interface{}{bson.M{"id": "ft"}}},
+			publish: true,
+			rule:    "allow-ft",
+		},
+		{
+			name: "test title",
+			body: map[string]interface{}{"title": "TEST please ignore"},
+			rule: "deny-test-titles",
+		},
+		{
+			name: "no brands",
+			body: map[string]interface{}{"title": "A real title"},
+			rule: "deny-unbranded",
+		},
+		{
+			name: "other brands",
+			body: map[string]interface{}{"brands": []interface{}{map[string]interface{}{"id": "other"}}},
+			rule: "deny-other-brands",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision, err := chain.Check("fake-uuid", &native.Content{Body: test.body})
+			assert.NoError(t, err)
+			assert.Equal(t, test.publish, decision.Publish)
+			assert.Equal(t, test.rule, decision.Rule)
+		})
+	}
+}
+
+func TestAgeRules(t *testing.T) {
+	config := compiled(t, &Config{
+		Global: []*Rule{
+			{Name: "skip-old", Action: Deny, OlderThan: "720h"},
+			{Name: "skip-new", Action: Deny, NewerThan: "1h"},
+		},
+	})
+	chain := config.ForCycle("test")
+
+	check := func(lastModified interface{}) Decision {
+		decision, err := chain.Check("fake-uuid", &native.Content{Body: map[string]interface{}{lastModifiedAttr: lastModified}})
+		require.NoError(t, err)
+		return decision
+	}
+
+	assert.Equal(t, "skip-old", check(time.Now().Add(-time.Hour*24*60).Format(time.RFC3339Nano)).Rule)
+	assert.Equal(t, "skip-new", check(time.Now().Add(-time.Minute).Format(time.RFC3339Nano)).Rule)
+	assert.True(t, check(time.Now().Add(-time.Hour*24).Format(time.RFC3339Nano)).Publish)
+	assert.True(t, check("not a date").Publish, "content with an unparseable lastModified does not match age rules")
+	assert.True(t, check(nil).Publish)
+}
+
+func TestValidateFails(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *Rule
+		err  string
+	}{
+		{name: "no name", rule: &Rule{Action: Deny, Type: []string{"Image"}}, err: "Please provide a name for every filter rule"},
+		{name: "bad action", rule: &Rule{Name: "r", Action: "skip", Type: []string{"Image"}}, err: "Invalid action skip for filter rule r, please use allow or deny"},
+		{name: "no conditions", rule: &Rule{Name: "r", Action: Deny}, err: "Filter rule r has no conditions"},
+		{name: "bad duration", rule: &Rule{Name: "r", Action: Deny, OlderThan: "a year"}, err: `Invalid olderThan for filter rule r: time: invalid duration "a year"`},
+		{name: "bad path", rule: &Rule{Name: "r", Action: Deny, Fields: []*FieldMatch{{Path: "$.brands[x]"}}}, err: "Invalid field for filter rule r: Invalid path $.brands[x]"},
+		{name: "bad regex", rule: &Rule{Name: "r", Action: Deny, Fields: []*FieldMatch{{Path: "$.title", Matches: "("}}}, err: "Invalid field regex for filter rule r: error parsing regexp: missing closing ): `(`"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := (&Config{Global: []*Rule{test.rule}}).Validate()
+			assert.EqualError(t, err, test.err)
+		})
+	}
+
+	err := (&Config{Cycles: map[string][]*Rule{"test": {{Name: "r", Action: Deny}}}}).Validate()
+	assert.EqualError(t, err, "Invalid filter rules for cycle test: Filter rule r has no conditions")
+
+	err = (&Config{Tasks: map[string][]*Rule{"annotations": {{Name: "r", Action: Deny}}}}).Validate()
+	assert.EqualError(t, err, "Invalid filter rules for task annotations: Filter rule r has no conditions")
+}
+
+func TestForTask(t *testing.T) {
+	config := &Config{
+		Global: []*Rule{{Name: "skip-images", Action: Deny, Type: []string{"Image"}}},
+		Tasks:  map[string][]*Rule{"annotations": {{Name: "only-pac", Action: Deny, Origin: []string{"methode-web-pub"}}}},
+	}
+	require.NoError(t, config.Validate())
+
+	chain := config.ForTask("annotations")
+	require.Len(t, chain, 1)
+	assert.Equal(t, "only-pac", chain[0].Name)
+	assert.Empty(t, config.ForTask("content"))
+}
+
+func TestLoadConfigFromFile(t *testing.T) {
+	config, err := LoadConfigFromFile("../filters.yml")
+	require.NoError(t, err)
+
+	decision, err := config.ForCycle("methode-whole-archive").Check("fake-uuid", &native.Content{Body: map[string]interface{}{"type": "Image"}})
+	assert.NoError(t, err)
+	assert.False(t, decision.Publish)
+}
+
+func TestLoadConfigFromFileRejectsUnknownFields(t *testing.T) {
+	f, err := ioutil.TempFile("", "filters")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	f.WriteString("global:\n- name: skip-images\n  action: deny\n  types: [Image]\n")
+	f.Close()
+
+	_, err = LoadConfigFromFile(f.Name())
+	assert.Error(t, err)
+}
+
+func TestLoadConfigFromMissingFile(t *testing.T) {
+	_, err := LoadConfigFromFile("does-not-exist.yml")
+	assert.Error(t, err)
+}
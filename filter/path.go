@@ -0,0 +1,99 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+var pathSegmentRegex = regexp.MustCompile(`^([^\[\]]*)((?:\[(?:\d+|\*)\])*)$`)
+var pathIndexRegex = regexp.MustCompile(`\[(\d+|\*)\]`)
+
+const wildcard = -1
+
+type pathStep struct {
+	key     string
+	isIndex bool
+	index   int
+}
+
+// path is a compiled subset of JSONPath, such as $.brands[*].id or $.body.items[0]
+type path []pathStep
+
+func compilePath(expr string) (path, error) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(expr), "$"), ".")
+	if trimmed == "" {
+		return nil, fmt.Errorf("Invalid path %v", expr)
+	}
+
+	var compiled path
+	for _, segment := range strings.Split(trimmed, ".") {
+		match := pathSegmentRegex.FindStringSubmatch(segment)
+		if match == nil || (match[1] == "" && match[2] == "") {
+			return nil, fmt.Errorf("Invalid path %v", expr)
+		}
+
+		if match[1] != "" {
+			compiled = append(compiled, pathStep{key: match[1]})
+		}
+
+		for _, index := range pathIndexRegex.FindAllStringSubmatch(match[2], -1) {
+			if index[1] == "*" {
+				compiled = append(compiled, pathStep{isIndex: true, index: wildcard})
+				continue
+			}
+
+			i, _ := strconv.Atoi(index[1])
+			compiled = append(compiled, pathStep{isIndex: true, index: i})
+		}
+	}
+
+	return compiled, nil
+}
+
+// resolve returns every value found at the path in the body
+func (p path) resolve(body map[string]interface{}) []interface{} {
+	values := []interface{}{body}
+	for _, step := range p {
+		var next []interface{}
+		for _, value := range values {
+			next = append(next, step.apply(value)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func (s pathStep) apply(value interface{}) []interface{} {
+	if !s.isIndex {
+		var obj map[string]interface{}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			obj = v
+		case bson.M: // nested documents read from mongo
+			obj = v
+		}
+
+		if child, ok := obj[s.key]; ok {
+			return []interface{}{child}
+		}
+		return nil
+	}
+
+	arr, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	if s.index == wildcard {
+		return arr
+	}
+
+	if s.index < len(arr) {
+		return []interface{}{arr[s.index]}
+	}
+	return nil
+}
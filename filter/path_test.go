@@ -0,0 +1,55 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestCompilePath(t *testing.T) {
+	p, err := compilePath("$.body.items[0].ids[*]")
+	require.NoError(t, err)
+	assert.Equal(t, path{{key: "body"}, {key: "items"}, {isIndex: true, index: 0}, {key: "ids"}, {isIndex: true, index: wildcard}}, p)
+
+	p, err = compilePath("type")
+	require.NoError(t, err)
+	assert.Equal(t, path{{key: "type"}}, p)
+}
+
+func TestCompileInvalidPaths(t *testing.T) {
+	for _, expr := range []string{"", "$", "$.", "$.a..b", "$.a[b]", "$.a[1"} {
+		_, err := compilePath(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	body := map[string]interface{}{
+		"title": "A title",
+		"body": bson.M{
+			"items": []interface{}{
+				bson.M{"ids": []interface{}{"a", "b"}},
+				map[string]interface{}{"ids": []interface{}{"c"}},
+			},
+		},
+	}
+
+	tests := map[string][]interface{}{
+		"$.title":                {"A title"},
+		"$.body.items[0].ids[*]": {"a", "b"},
+		"$.body.items[*].ids[*]": {"a", "b", "c"},
+		"$.body.items[1].ids[0]": {"c"},
+		"$.body.items[2].ids":    nil,
+		"$.title.missing":        nil,
+		"$.body.items.ids":       nil,
+		"$.missing[*]":           nil,
+	}
+
+	for expr, expected := range tests {
+		p, err := compilePath(expr)
+		require.NoError(t, err)
+		assert.Equal(t, expected, p.resolve(body), expr)
+	}
+}
@@ -8,15 +8,22 @@ require (
 	github.com/Financial-Times/service-status-go v0.0.0-20160323111542-3f5199736a3d
 	github.com/Financial-Times/transactionid-utils-go v0.1.1-0.20170302164445-e13211a785e5
 	github.com/GeertJohan/go.rice v0.0.0-20170123135425-4bbccbfa39e7 // indirect
+	github.com/Shopify/sarama v1.19.0
 	github.com/aws/aws-sdk-go v1.33.10
 	github.com/coreos/etcd v2.3.8+incompatible
 	github.com/daaku/go.zipexe v0.0.0-20150329023125-a5fe2436ffcb // indirect
+	github.com/eapache/go-resiliency v1.1.0 // indirect
+	github.com/eapache/go-xerial-snappy v0.0.0-20180814174437-776d5712da21 // indirect
+	github.com/eapache/queue v1.1.0 // indirect
+	github.com/golang/snappy v1.0.0 // indirect
 	github.com/hashicorp/go-version v0.0.0-20170202080759-03c5bf6be031 // indirect
 	github.com/husobee/vestigo v1.0.1
 	github.com/kardianos/osext v0.0.0-20170309185600-9d302b58e975 // indirect
 	github.com/kr/pretty v0.2.0 // indirect
 	github.com/pborman/uuid v0.0.0-20160209185913-a97ce2ca70fa
+	github.com/pierrec/lz4 v2.0.5+incompatible // indirect
 	github.com/pkg/errors v0.9.1
+	github.com/rcrowley/go-metrics v0.0.0-20250401214520-65e299d6c5c9 // indirect
 	github.com/sirupsen/logrus v0.11.4
 	github.com/stretchr/testify v1.5.1
 	golang.org/x/net v0.0.0-20200202094626-16171245cfb2
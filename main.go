@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,13 +21,17 @@ import (
 	cluster_file "github.com/Financial-Times/publish-carousel/cluster/file"
 	"github.com/Financial-Times/publish-carousel/cms"
 	"github.com/Financial-Times/publish-carousel/etcd"
+	"github.com/Financial-Times/publish-carousel/etcd/keys"
 	"github.com/Financial-Times/publish-carousel/file"
-	"github.com/Financial-Times/publish-carousel/image"
+	"github.com/Financial-Times/publish-carousel/filter"
 	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/recording"
 	"github.com/Financial-Times/publish-carousel/resources"
 	"github.com/Financial-Times/publish-carousel/s3"
 	"github.com/Financial-Times/publish-carousel/scheduler"
 	"github.com/Financial-Times/publish-carousel/tasks"
+	"github.com/Financial-Times/publish-carousel/transform"
+	"github.com/Financial-Times/publish-carousel/verify"
 	"github.com/Financial-Times/service-status-go/httphandlers"
 	"github.com/husobee/vestigo"
 	log "github.com/sirupsen/logrus"
@@ -58,12 +66,47 @@ func main() {
 			EnvVar: "CYCLES_FILE",
 			Usage:  "Path to the YML cycle configuration file.",
 		},
+		cli.StringFlag{
+			Name:   "filters",
+			Value:  "./filters.yml",
+			EnvVar: "FILTERS_FILE",
+			Usage:  "Path to the YML content filter rules, which decide what content each cycle publishes.",
+		},
+		cli.StringFlag{
+			Name:   "transforms",
+			Value:  "./transforms.yml",
+			EnvVar: "TRANSFORMS_FILE",
+			Usage:  "Path to the YML content transforms, which are applied to the content before it is published.",
+		},
+		cli.BoolFlag{
+			Name:   "dry-run",
+			EnvVar: "DRY_RUN",
+			Usage:  "Log the content which would have been published, rather than sending it to the cms-notifier.",
+		},
 		cli.StringFlag{
 			Name:   "blacklist",
 			Value:  "./carousel_blacklist.txt",
 			EnvVar: "BLACKLIST_FILE",
 			Usage:  "Path to the plaintxt blacklist file, which contains blacklisted uuids.",
 		},
+		cli.StringFlag{
+			Name:   "blacklist-refresh-interval",
+			Value:  "30s",
+			EnvVar: "BLACKLIST_REFRESH_INTERVAL",
+			Usage:  "Interval for checking the blacklist file (or store) for changes, which are reloaded without a restart.",
+		},
+		cli.StringFlag{
+			Name:   "blacklist-store",
+			Value:  "file",
+			EnvVar: "BLACKLIST_STORE",
+			Usage:  "Where changes to the blacklist made through the API are saved, one of file (the blacklist file), s3 (the state backend) or etcd.",
+		},
+		cli.StringFlag{
+			Name:   "blacklist-etcd-key",
+			Value:  "/ft/config/publish-carousel/blacklist",
+			EnvVar: "BLACKLIST_ETCD_KEY",
+			Usage:  "The etcd key to save the blacklist to, if the blacklist store is etcd.",
+		},
 		cli.StringFlag{
 			Name:   "mongo-db",
 			Value:  "localhost:27017",
@@ -82,6 +125,155 @@ func main() {
 			EnvVar: "CMS_NOTIFIER_URL",
 			Usage:  "The CMS Notifier instance to POST publishes to.",
 		},
+		cli.StringFlag{
+			Name:   "annotations-notifier-url",
+			Value:  "",
+			EnvVar: "ANNOTATIONS_NOTIFIER_URL",
+			Usage:  `The CMS Metadata Notifier instance to POST annotations to, for cycles with the "annotations" task.`,
+		},
+		cli.StringFlag{
+			Name:   "annotations-origins",
+			Value:  "methode-web-pub=http://cmdb.ft.com/systems/methode-web-pub,pac=http://cmdb.ft.com/systems/pac,next-video-editor=http://cmdb.ft.com/systems/next-video-editor",
+			EnvVar: "ANNOTATIONS_ORIGINS",
+			Usage:  "Comma separated list of origin=X-Origin-System-Id pairs, which map the origins of annotations to the origin system ids they are published with.",
+		},
+		cli.StringFlag{
+			Name:   "notifier-targets",
+			Value:  "",
+			EnvVar: "NOTIFIER_TARGETS",
+			Usage:  "Comma separated list of additional CMS Notifier instances, as name=url, which cycles can select as notifiers or fan-out targets.",
+		},
+		cli.StringFlag{
+			Name:   "kafka-brokers",
+			Value:  "",
+			EnvVar: "KAFKA_BROKERS",
+			Usage:  `Comma separated list of Kafka brokers, which cycles with the "kafka" notifier produce to directly. The Kafka notifier is disabled if this is empty.`,
+		},
+		cli.StringFlag{
+			Name:   "kafka-topic",
+			Value:  "NativeCmsPublicationEvents",
+			EnvVar: "KAFKA_TOPIC",
+			Usage:  "The Kafka topic which cycles with the \"kafka\" notifier produce to.",
+		},
+		cli.IntFlag{
+			Name:   "notifier-max-retries",
+			Value:  3,
+			EnvVar: "NOTIFIER_MAX_RETRIES",
+			Usage:  "The number of times a publish to a CMS Notifier is retried after a transient failure, i.e. a network error, or a 429, 502, 503 or 504 response.",
+		},
+		cli.StringFlag{
+			Name:   "notifier-min-backoff",
+			Value:  "1s",
+			EnvVar: "NOTIFIER_MIN_BACKOFF",
+			Usage:  "The delay before the first retry of a publish to a CMS Notifier, which doubles (with jitter) for each retry after it.",
+		},
+		cli.StringFlag{
+			Name:   "notifier-max-backoff",
+			Value:  "30s",
+			EnvVar: "NOTIFIER_MAX_BACKOFF",
+			Usage:  "The maximum delay between retries of a publish to a CMS Notifier. Publishes are not retried if the CMS Notifier's Retry-After is longer than this.",
+		},
+		cli.Float64Flag{
+			Name:   "circuit-breaker-failure-rate",
+			Value:  0.5,
+			EnvVar: "CIRCUIT_BREAKER_FAILURE_RATE",
+			Usage:  "The fraction of publishes to a CMS Notifier which must fail before its circuit breaker opens, and cycles wait for it to recover. Set to 0 to disable the circuit breakers.",
+		},
+		cli.IntFlag{
+			Name:   "circuit-breaker-window",
+			Value:  20,
+			EnvVar: "CIRCUIT_BREAKER_WINDOW",
+			Usage:  "The number of recent publishes which the circuit breaker failure rate is calculated over.",
+		},
+		cli.StringFlag{
+			Name:   "circuit-breaker-open-timeout",
+			Value:  "1m",
+			EnvVar: "CIRCUIT_BREAKER_OPEN_TIMEOUT",
+			Usage:  "How long a circuit breaker stays open before it probes the CMS Notifier again.",
+		},
+		cli.IntFlag{
+			Name:   "circuit-breaker-probes",
+			Value:  3,
+			EnvVar: "CIRCUIT_BREAKER_PROBES",
+			Usage:  "The number of successful probes needed to close a circuit breaker.",
+		},
+		cli.StringFlag{
+			Name:   "record",
+			Value:  "",
+			EnvVar: "RECORD",
+			Usage:  `Record every publish to the notifiers, one of "file" (JSONL files in --record-dir) or "s3" (JSONL objects in the state backend). Publishes are not recorded if this is empty.`,
+		},
+		cli.StringFlag{
+			Name:   "record-dir",
+			Value:  "./recordings",
+			EnvVar: "RECORD_DIR",
+			Usage:  `The directory to write recordings to, when recording to "file".`,
+		},
+		cli.IntFlag{
+			Name:   "record-max-size",
+			Value:  64,
+			EnvVar: "RECORD_MAX_SIZE",
+			Usage:  "The maximum size (in MB) of a recording file or object, before a new one is started.",
+		},
+		cli.StringFlag{
+			Name:   "record-flush-interval",
+			Value:  "1m",
+			EnvVar: "RECORD_FLUSH_INTERVAL",
+			Usage:  `How often buffered recordings are saved, when recording to "s3".`,
+		},
+		cli.BoolFlag{
+			Name:   "verify",
+			EnvVar: "VERIFY",
+			Usage:  "Verify that every publish reaches the read environments with the carousel's transaction id, by polling their read API.",
+		},
+		cli.StringFlag{
+			Name:   "verify-path",
+			Value:  "/__document-store-api/content/{uuid}",
+			EnvVar: "VERIFY_PATH",
+			Usage:  "The path of the read API to poll in each read environment, where {uuid} is replaced with the uuid of the published content.",
+		},
+		cli.StringFlag{
+			Name:   "verify-delay",
+			Value:  "30s",
+			EnvVar: "VERIFY_DELAY",
+			Usage:  "How long after a publish the read environments are first checked. Publishes found on the first check are verified, and publishes found later are late.",
+		},
+		cli.StringFlag{
+			Name:   "verify-interval",
+			Value:  "30s",
+			EnvVar: "VERIFY_INTERVAL",
+			Usage:  "The time between checks of the read environments, until the published content is found.",
+		},
+		cli.StringFlag{
+			Name:   "verify-timeout",
+			Value:  "5m",
+			EnvVar: "VERIFY_TIMEOUT",
+			Usage:  "How long after a publish the content is reported as missing, if it has not reached every read environment.",
+		},
+		cli.IntFlag{
+			Name:   "verify-window",
+			Value:  100,
+			EnvVar: "VERIFY_WINDOW",
+			Usage:  "The number of recent publishes of each cycle which the missing rate is calculated over.",
+		},
+		cli.IntFlag{
+			Name:   "verify-max-pending",
+			Value:  1000,
+			EnvVar: "VERIFY_MAX_PENDING",
+			Usage:  "The number of publishes which can be verified at once. Publishes beyond this are not verified.",
+		},
+		cli.Float64Flag{
+			Name:   "verify-max-missing-rate",
+			Value:  0.1,
+			EnvVar: "VERIFY_MAX_MISSING_RATE",
+			Usage:  "The fraction of a cycle's recent publishes which can be missing from the read environments before the MissingPublishes healthcheck fails.",
+		},
+		cli.StringFlag{
+			Name:   "consistency-path",
+			Value:  "/__document-store-api/content/{uuid}",
+			EnvVar: "CONSISTENCY_PATH",
+			Usage:  `The path of the read API which cycles with the "consistency-check" task compare content with, where {uuid} is replaced with the uuid of the content.`,
+		},
 		cli.StringFlag{
 			Name:   "pam-url",
 			Value:  "http://localhost:8080/__publish-availability-monitor",
@@ -106,6 +298,35 @@ func main() {
 			Value:  "",
 			Usage:  "The S3 Bucket to save carousel states.",
 		},
+		cli.StringFlag{
+			Name:   "state-backend",
+			EnvVar: "STATE_BACKEND",
+			Value:  "s3",
+			Usage:  `Where to save carousel states and UUID manifests, one of "s3" or "file".`,
+		},
+		cli.StringFlag{
+			Name:   "state-dir",
+			EnvVar: "STATE_DIR",
+			Value:  "./state",
+			Usage:  `The directory to save carousel states and UUID manifests, when the state backend is "file".`,
+		},
+		cli.StringFlag{
+			Name:   "metadata-backend",
+			EnvVar: "METADATA_BACKEND",
+			Value:  "state",
+			Usage:  `Where to save cycle metadata, one of "state" (the state backend) or "etcd".`,
+		},
+		cli.StringFlag{
+			Name:   "metadata-etcd-prefix",
+			EnvVar: "METADATA_ETCD_PREFIX",
+			Value:  "/ft/config/publish-carousel/cycles",
+			Usage:  `The etcd key prefix to save cycle metadata under, when the metadata backend is "etcd".`,
+		},
+		cli.BoolFlag{
+			Name:   "metadata-dual-write",
+			EnvVar: "METADATA_DUAL_WRITE",
+			Usage:  `When the metadata backend is "etcd", also write cycle metadata to the state backend, and fall back to reading it from there. Used to migrate cycle metadata into etcd.`,
+		},
 		cli.StringFlag{
 			Name:   "api-yml",
 			EnvVar: "API_YML",
@@ -154,6 +375,107 @@ func main() {
 			EnvVar: "CHECKPOINT_INTERVAL",
 			Usage:  "Interval for saving metadata checkpoints",
 		},
+		cli.IntFlag{
+			Name:   "checkpoint-retention-count",
+			Value:  0,
+			EnvVar: "CHECKPOINT_RETENTION_COUNT",
+			Usage:  "The number of most recent checkpoints and UUID manifests to keep for each cycle. 0 disables this rule.",
+		},
+		cli.StringFlag{
+			Name:   "checkpoint-retention-age",
+			Value:  "",
+			EnvVar: "CHECKPOINT_RETENTION_AGE",
+			Usage:  "Keep checkpoints and UUID manifests younger than this duration (e.g. 168h). Empty disables this rule.",
+		},
+		cli.StringFlag{
+			Name:   "checkpoint-prune-interval",
+			Value:  "1h",
+			EnvVar: "CHECKPOINT_PRUNE_INTERVAL",
+			Usage:  "Interval for pruning checkpoints and UUID manifests which are no longer retained",
+		},
+		cli.IntFlag{
+			Name:   "stall-multiple",
+			Value:  10,
+			EnvVar: "STALL_MULTIPLE",
+			Usage:  "A running cycle has stalled if it has not made progress for this multiple of its throttle. 0 disables stalled cycle detection.",
+		},
+		cli.StringFlag{
+			Name:   "stall-minimum",
+			Value:  "10m",
+			EnvVar: "STALL_MINIMUM",
+			Usage:  "The shortest time without progress after which a running cycle has stalled, i.e. to allow for loading large collections from mongo",
+		},
+		cli.StringFlag{
+			Name:   "stall-check-interval",
+			Value:  "1m",
+			EnvVar: "STALL_CHECK_INTERVAL",
+			Usage:  "Interval for checking whether the running cycles have stalled",
+		},
+		cli.BoolFlag{
+			Name:   "stall-restart",
+			EnvVar: "STALL_RESTART",
+			Usage:  "Restart stalled cycles from their current position",
+		},
+		cli.IntFlag{
+			Name:   "recovery-max-attempts",
+			Value:  5,
+			EnvVar: "RECOVERY_MAX_ATTEMPTS",
+			Usage:  "The number of times an unhealthy cycle is restarted before giving up until it is resumed. 0 disables the automatic recovery of unhealthy cycles.",
+		},
+		cli.StringFlag{
+			Name:   "recovery-initial-backoff",
+			Value:  "1m",
+			EnvVar: "RECOVERY_INITIAL_BACKOFF",
+			Usage:  "The wait before an unhealthy cycle is first restarted, which doubles for each further attempt",
+		},
+		cli.StringFlag{
+			Name:   "recovery-maximum-backoff",
+			Value:  "30m",
+			EnvVar: "RECOVERY_MAXIMUM_BACKOFF",
+			Usage:  "The longest wait between the restarts of an unhealthy cycle",
+		},
+		cli.StringFlag{
+			Name:   "recovery-healthy-period",
+			Value:  "1h",
+			EnvVar: "RECOVERY_HEALTHY_PERIOD",
+			Usage:  "How long a restarted cycle must stay healthy before its restart attempts are reset, unless it finishes an iteration first",
+		},
+		cli.StringFlag{
+			Name:   "recovery-check-interval",
+			Value:  "30s",
+			EnvVar: "RECOVERY_CHECK_INTERVAL",
+			Usage:  "Interval for checking whether any unhealthy cycles are due to be restarted",
+		},
+		cli.StringFlag{
+			Name:   "cluster-check-interval",
+			Value:  "30s",
+			EnvVar: "CLUSTER_CHECK_INTERVAL",
+			Usage:  "Interval for checking the health of the publish availability monitor and the lagchecks",
+		},
+		cli.IntFlag{
+			Name:   "cluster-unhealthy-threshold",
+			Value:  2,
+			EnvVar: "CLUSTER_UNHEALTHY_THRESHOLD",
+			Usage:  "The number of consecutive failed checks after which the cluster is unhealthy, and the scheduler is shut down",
+		},
+		cli.IntFlag{
+			Name:   "cluster-healthy-threshold",
+			Value:  3,
+			EnvVar: "CLUSTER_HEALTHY_THRESHOLD",
+			Usage:  "The number of consecutive successful checks after which an unhealthy cluster is healthy again, and the scheduler is restarted",
+		},
+		cli.StringFlag{
+			Name:   "cluster-minimum-healthy",
+			Value:  "1m",
+			EnvVar: "CLUSTER_MINIMUM_HEALTHY",
+			Usage:  "The shortest time the cluster stays healthy after recovering, before it can become unhealthy again",
+		},
+		cli.StringFlag{
+			Name:   "cluster-minimum-unhealthy",
+			Value:  "5m",
+			EnvVar: "CLUSTER_MINIMUM_UNHEALTHY",
+			Usage:  "The shortest time the cluster stays unhealthy, and the scheduler stays shut down, before the cluster can become healthy again",
+		},
 		cli.StringFlag{
 			Name:   "configs-dir",
 			Value:  "/configs",
@@ -171,6 +493,8 @@ func main() {
 	log.SetFormatter(&log.JSONFormatter{})
 	log.SetLevel(log.InfoLevel)
 
+	app.Commands = []cli.Command{replayCommand()}
+
 	app.Action = func(ctx *cli.Context) {
 		log.Info("Starting the Publish Carousel.")
 
@@ -178,11 +502,32 @@ func main() {
 			panic(fmt.Sprintf("Provided MongoDB URLs are invalid: %s", err))
 		}
 
-		s3rw := s3.NewReadWriter(ctx.String("aws-region"), ctx.String("s3-bucket"))
-		stateRw := scheduler.NewS3MetadataReadWriter(s3rw)
+		s3rw, err := newStateReadWriter(ctx)
+		if err != nil {
+			panic(err)
+		}
+		stateRw, err := newMetadataReadWriter(ctx, s3rw)
+		if err != nil {
+			panic(err)
+		}
+
+		filters, err := filter.LoadConfigFromFile(ctx.String("filters"))
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load content filter rules: %v", err))
+		}
 
-		isImage := image.NewFilter()
-		blacklist, err := blacklist.NewFileBasedBlacklist(ctx.String("blacklist"))
+		transforms, err := transform.LoadConfigFromFile(ctx.String("transforms"))
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load content transforms: %v", err))
+		}
+
+		blacklistRefreshInterval, err := time.ParseDuration(ctx.String("blacklist-refresh-interval"))
+		if err != nil {
+			log.WithError(err).Error("Invalid blacklist refresh interval, defaulting to 30s.")
+			blacklistRefreshInterval = time.Second * 30
+		}
+
+		blist, err := newBlacklist(ctx, s3rw, blacklistRefreshInterval)
 		if err != nil {
 			panic(err)
 		}
@@ -192,7 +537,20 @@ func main() {
 		mongo := native.NewMongoDatabase(ctx.String("mongo-db"), ctx.Int("mongo-timeout"))
 
 		reader := native.NewMongoNativeReader(mongo)
-		notifier, err := cms.NewNotifier(ctx.String("cms-notifier-url"), client)
+		minBackoff, err := time.ParseDuration(ctx.String("notifier-min-backoff"))
+		if err != nil {
+			log.WithError(err).Error("Invalid notifier minimum backoff, defaulting to one second.")
+			minBackoff = time.Second
+		}
+
+		maxBackoff, err := time.ParseDuration(ctx.String("notifier-max-backoff"))
+		if err != nil {
+			log.WithError(err).Error("Invalid notifier maximum backoff, defaulting to 30 seconds.")
+			maxBackoff = 30 * time.Second
+		}
+
+		retry := cms.RetryConfig{MaxRetries: ctx.Int("notifier-max-retries"), MinBackoff: minBackoff, MaxBackoff: maxBackoff}
+		notifier, err := cms.NewRetryingNotifier(ctx.String("cms-notifier-url"), client, retry)
 		if err != nil {
 			log.WithError(err).Error("Error in CMS Notifier configuration")
 		}
@@ -207,7 +565,88 @@ func main() {
 			panic(err)
 		}
 
-		task := tasks.NewNativeContentPublishTask(reader, notifier, isImage)
+		notifiers := map[string]cms.Notifier{cms.CMSNotifierName: notifier}
+		for _, target := range strings.Split(ctx.String("notifier-targets"), ",") {
+			if strings.TrimSpace(target) == "" {
+				continue
+			}
+
+			name, url, err := parseNotifierTarget(target)
+			if err == nil {
+				notifiers[name], err = cms.NewRetryingNotifier(url, client, retry)
+			}
+
+			if err != nil {
+				log.WithError(err).WithField("target", target).Error("Error in CMS Notifier target configuration")
+				delete(notifiers, name)
+			}
+		}
+
+		if url := ctx.String("annotations-notifier-url"); url != "" {
+			annotations, err := cms.NewAnnotationsNotifier(url, client, retry)
+			if err != nil {
+				log.WithError(err).Error("Error in CMS Metadata Notifier configuration")
+			} else {
+				notifiers[cms.AnnotationsNotifierName] = annotations
+			}
+		}
+
+		annotationsOrigins, err := parseAnnotationsOrigins(ctx.String("annotations-origins"))
+		if err != nil {
+			log.WithError(err).Error("Error in annotations origins configuration, annotations will be published with their own origins")
+		}
+
+		if brokers := ctx.String("kafka-brokers"); brokers != "" {
+			kafka, err := cms.NewKafkaNotifier(strings.Split(brokers, ","), ctx.String("kafka-topic"))
+			if err != nil {
+				log.WithError(err).Error("Error in Kafka notifier configuration")
+			} else {
+				notifiers[cms.KafkaNotifierName] = kafka
+			}
+		}
+
+		recorder, err := newRecordingWriter(ctx, s3rw)
+		if err != nil {
+			log.WithError(err).Error("Error in recording configuration, publishes will not be recorded")
+		}
+
+		if recorder != nil {
+			for name, n := range notifiers {
+				notifiers[name] = recording.NewNotifier(name, n, recorder)
+			}
+		}
+
+		if failureRate := ctx.Float64("circuit-breaker-failure-rate"); failureRate > 0 {
+			openTimeout, err := time.ParseDuration(ctx.String("circuit-breaker-open-timeout"))
+			if err != nil {
+				log.WithError(err).Error("Invalid circuit breaker open timeout, defaulting to one minute.")
+				openTimeout = time.Minute
+			}
+
+			config := cms.BreakerConfig{FailureRate: failureRate, Window: ctx.Int("circuit-breaker-window"), OpenTimeout: openTimeout, Probes: ctx.Int("circuit-breaker-probes")}
+			if err := config.Validate(); err != nil {
+				log.WithError(err).Error("Error in circuit breaker configuration, the CMS Notifiers will be called without circuit breakers")
+			} else {
+				for name, n := range notifiers {
+					if name == cms.KafkaNotifierName {
+						continue
+					}
+					notifiers[name], _ = cms.NewCircuitBreaker(name, n, config)
+				}
+			}
+		}
+
+		if ctx.Bool("dry-run") {
+			log.Warn("Dry run enabled, content will be logged rather than sent to the CMS notifier.")
+		}
+
+		publishNotifiers := notifiers
+		if ctx.Bool("dry-run") {
+			publishNotifiers = make(map[string]cms.Notifier)
+			for name := range notifiers {
+				publishNotifiers[name] = cms.NewDryRunNotifier(name)
+			}
+		}
 
 		defaultThrottle, err := time.ParseDuration(ctx.String("default-throttle"))
 		if err != nil {
@@ -220,15 +659,11 @@ func main() {
 			checkpointInterval = time.Hour
 		}
 
-		uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(mongo, s3rw, blacklist)
-
-		sched, configError := scheduler.LoadSchedulerFromFile(ctx.String("cycles"), uuidCollectionBuilder, task, stateRw, defaultThrottle, checkpointInterval)
-		if configError != nil {
-			log.WithError(configError).Error("Failed to load cycles configuration file")
-		}
+		uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(mongo, s3rw, blist.IsBlacklisted)
 
 		var deliveryLagcheck cluster.Service
 		var manualToggle, autoToggle string
+		var watchToggles func(sched scheduler.Scheduler)
 
 		if ctx.StringSlice("etcd-peers")[0] == "NOT_AVAILABLE" {
 			log.Info("Sourcing configs from file.")
@@ -245,8 +680,10 @@ func main() {
 
 			log.WithField("manualToggle", manualToggle).WithField("autoToggle", autoToggle).Info("Read configs!")
 
-			go fileWatcher.Watch(context.Background(), "toggle", sched.ManualToggleHandler)
-			go fileWatcher.Watch(context.Background(), "active-cluster", sched.AutomaticToggleHandler)
+			watchToggles = func(sched scheduler.Scheduler) {
+				go fileWatcher.Watch(context.Background(), "toggle", sched.ManualToggleHandler)
+				go fileWatcher.Watch(context.Background(), "active-cluster", sched.AutomaticToggleHandler)
+			}
 		} else {
 			log.Info("Sourcing configs from etcd.")
 			etcdWatcher, err := etcd.NewEtcdWatcher(ctx.StringSlice("etcd-peers"))
@@ -267,44 +704,346 @@ func main() {
 			if err != nil {
 				panic(err)
 			}
-			go etcdWatcher.Watch(context.Background(), ctx.String("toggle-etcd-key"), sched.ManualToggleHandler)
-			go etcdWatcher.Watch(context.Background(), ctx.String("active-cluster-etcd-key"), sched.AutomaticToggleHandler)
+			watchToggles = func(sched scheduler.Scheduler) {
+				go etcdWatcher.Watch(context.Background(), ctx.String("toggle-etcd-key"), sched.ManualToggleHandler)
+				go etcdWatcher.Watch(context.Background(), ctx.String("active-cluster-etcd-key"), sched.AutomaticToggleHandler)
+			}
+		}
 
+		var verifier verify.Verifier
+		if ctx.Bool("verify") && !ctx.Bool("dry-run") {
+			verifier, err = newVerifier(ctx, deliveryLagcheck, client)
+			if err != nil {
+				log.WithError(err).Error("Error in publish verification configuration, publishes will not be verified")
+			}
+		}
+
+		publishContent := func(n cms.Notifier) (tasks.Task, error) {
+			return tasks.NewNativeContentPublishTask(reader, n, blist.IsBlacklisted), nil
+		}
+
+		registry := tasks.NewRegistry()
+		for _, definition := range []tasks.Definition{
+			{Name: tasks.ContentTaskName, New: publishContent},
+			{Name: tasks.AnnotationsTaskName, Notifier: cms.AnnotationsNotifierName, New: func(n cms.Notifier) (tasks.Task, error) {
+				return tasks.NewNativeAnnotationsPublishTask(reader, n, blist.IsBlacklisted, annotationsOrigins), nil
+			}},
+			{Name: tasks.ConsistencyCheckTaskName, New: func(n cms.Notifier) (tasks.Task, error) {
+				environments, ok := deliveryLagcheck.(cluster.ReadEnvironments)
+				if !ok {
+					return nil, errors.New("The read environments are not available")
+				}
+				publish, _ := publishContent(n)
+				return tasks.NewConsistencyCheckTask(publish, environments, client, ctx.String("consistency-path"), tasks.NewS3DiscrepancyReport(s3rw))
+			}},
+		} {
+			definition.Filters = filters.ForTask(definition.Name)
+			definition.Transforms = transforms.ForTask(definition.Name)
+			if err := registry.Register(definition); err != nil {
+				log.WithError(err).WithField("task", definition.Name).Error("Failed to register task")
+			}
+		}
+
+		sched, configError := scheduler.LoadSchedulerFromFile(ctx.String("cycles"), uuidCollectionBuilder, registry, publishNotifiers, filters, transforms, verifier, stateRw, defaultThrottle, checkpointInterval)
+		if configError != nil {
+			log.WithError(configError).Error("Failed to load cycles configuration file")
 		}
 
+		retentionPolicy := s3.RetentionPolicy{KeepLast: ctx.Int("checkpoint-retention-count")}
+		if ctx.String("checkpoint-retention-age") != "" {
+			retentionPolicy.MaxAge, err = time.ParseDuration(ctx.String("checkpoint-retention-age"))
+			if err != nil {
+				log.WithError(err).Error("Invalid checkpoint retention age, checkpoints will not be pruned by age.")
+			}
+		}
+
+		pruneInterval, err := time.ParseDuration(ctx.String("checkpoint-prune-interval"))
+		if err != nil {
+			log.WithError(err).Error("Invalid checkpoint prune interval, defaulting to hourly.")
+			pruneInterval = time.Hour
+		}
+
+		scheduler.NewRetentionPruner(sched, stateRw, uuidCollectionBuilder, retentionPolicy, pruneInterval).Start()
+
+		if ctx.Int("stall-multiple") > 0 {
+			stallMinimum, err := time.ParseDuration(ctx.String("stall-minimum"))
+			if err != nil {
+				log.WithError(err).Error("Invalid stall minimum, defaulting to 10 minutes.")
+				stallMinimum = 10 * time.Minute
+			}
+
+			stallCheckInterval, err := time.ParseDuration(ctx.String("stall-check-interval"))
+			if err != nil {
+				log.WithError(err).Error("Invalid stall check interval, defaulting to every minute.")
+				stallCheckInterval = time.Minute
+			}
+
+			scheduler.NewWatchdog(sched, ctx.Int("stall-multiple"), stallMinimum, ctx.Bool("stall-restart"), stallCheckInterval).Start()
+		} else {
+			log.Info("Stalled cycle detection is disabled.")
+		}
+
+		if ctx.Int("recovery-max-attempts") > 0 {
+			initialBackoff, err := time.ParseDuration(ctx.String("recovery-initial-backoff"))
+			if err != nil {
+				log.WithError(err).Error("Invalid recovery initial backoff, defaulting to 1 minute.")
+				initialBackoff = time.Minute
+			}
+
+			maximumBackoff, err := time.ParseDuration(ctx.String("recovery-maximum-backoff"))
+			if err != nil {
+				log.WithError(err).Error("Invalid recovery maximum backoff, defaulting to 30 minutes.")
+				maximumBackoff = 30 * time.Minute
+			}
+
+			healthyPeriod, err := time.ParseDuration(ctx.String("recovery-healthy-period"))
+			if err != nil {
+				log.WithError(err).Error("Invalid recovery healthy period, defaulting to 1 hour.")
+				healthyPeriod = time.Hour
+			}
+
+			recoveryCheckInterval, err := time.ParseDuration(ctx.String("recovery-check-interval"))
+			if err != nil {
+				log.WithError(err).Error("Invalid recovery check interval, defaulting to every 30 seconds.")
+				recoveryCheckInterval = 30 * time.Second
+			}
+
+			scheduler.NewSupervisor(sched, initialBackoff, maximumBackoff, ctx.Int("recovery-max-attempts"), healthyPeriod, recoveryCheckInterval).Start()
+		} else {
+			log.Info("Automatic recovery of unhealthy cycles is disabled.")
+		}
+
+		watchToggles(sched)
 		sched.ManualToggleHandler(manualToggle)
 		sched.AutomaticToggleHandler(autoToggle)
 		sched.RestorePreviousState()
 		sched.Start()
 
+		clusterMonitor := newClusterMonitor(ctx, sched, pam, publishingLagcheck, deliveryLagcheck)
+		clusterMonitor.Start()
+
 		api, _ := ioutil.ReadFile(ctx.String("api-yml"))
 
-		shutdown(sched)
-		serve(mongo, sched, s3rw, notifier, api, configError, pam, publishingLagcheck, deliveryLagcheck)
+		shutdown(sched, clusterMonitor, notifiers, recorder, verifier)
+		serve(mongo, sched, s3rw, notifiers, blist, verifier, api, configError, clusterMonitor)
 	}
 
 	app.Run(os.Args)
 }
 
-func shutdown(sched scheduler.Scheduler) {
+func parseNotifierTarget(target string) (string, string, error) {
+	parts := strings.SplitN(strings.TrimSpace(target), "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("Invalid notifier target %v, please use name=url", target)
+	}
+
+	if parts[0] == cms.CMSNotifierName || parts[0] == cms.KafkaNotifierName || parts[0] == cms.AnnotationsNotifierName {
+		return "", "", fmt.Errorf("Notifier target name %v is reserved", parts[0])
+	}
+	return parts[0], parts[1], nil
+}
+
+func parseAnnotationsOrigins(mapping string) (map[string]string, error) {
+	origins := make(map[string]string)
+	for _, pair := range strings.Split(mapping, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid annotations origin %v, please use origin=X-Origin-System-Id", pair)
+		}
+		origins[parts[0]] = parts[1]
+	}
+	return origins, nil
+}
+
+func newStateReadWriter(ctx *cli.Context) (s3.ReadWriter, error) {
+	switch ctx.String("state-backend") {
+	case "s3":
+		return s3.NewReadWriter(ctx.String("aws-region"), ctx.String("s3-bucket")), nil
+	case "file":
+		log.WithField("dir", ctx.String("state-dir")).Info("Saving carousel states to the local filesystem.")
+		return s3.NewFileReadWriter(ctx.String("state-dir")), nil
+	default:
+		return nil, fmt.Errorf("Unsupported state backend %v", ctx.String("state-backend"))
+	}
+}
+
+func newMetadataReadWriter(ctx *cli.Context, s3rw s3.ReadWriter) (scheduler.MetadataReadWriter, error) {
+	switch ctx.String("metadata-backend") {
+	case "state":
+		return scheduler.NewS3MetadataReadWriter(s3rw), nil
+	case "etcd":
+		if ctx.StringSlice("etcd-peers")[0] == "NOT_AVAILABLE" {
+			return nil, errors.New("Cannot save cycle metadata to etcd, as no etcd peers are available")
+		}
+
+		store, err := keys.NewStore(ctx.StringSlice("etcd-peers"))
+		if err != nil {
+			return nil, err
+		}
+
+		etcdRw := scheduler.NewEtcdMetadataReadWriter(store, ctx.String("metadata-etcd-prefix"))
+		if ctx.Bool("metadata-dual-write") {
+			log.Info("Writing cycle metadata to both etcd and the state backend.")
+			return scheduler.NewDualMetadataReadWriter(etcdRw, scheduler.NewS3MetadataReadWriter(s3rw)), nil
+		}
+		return etcdRw, nil
+	default:
+		return nil, fmt.Errorf("Unsupported metadata backend %v", ctx.String("metadata-backend"))
+	}
+}
+
+func newRecordingWriter(ctx *cli.Context, s3rw s3.ReadWriter) (recording.Writer, error) {
+	maxBytes := ctx.Int("record-max-size") * 1024 * 1024
+
+	switch ctx.String("record") {
+	case "":
+		return nil, nil
+	case "file":
+		log.WithField("dir", ctx.String("record-dir")).Info("Recording publishes to the local filesystem.")
+		return recording.NewFileWriter(ctx.String("record-dir"), int64(maxBytes))
+	case "s3":
+		flushInterval, err := time.ParseDuration(ctx.String("record-flush-interval"))
+		if err != nil {
+			log.WithError(err).Error("Invalid recording flush interval, defaulting to one minute.")
+			flushInterval = time.Minute
+		}
+
+		log.Info("Recording publishes to the state backend.")
+		return recording.NewS3Writer(s3rw, "recordings", maxBytes, flushInterval), nil
+	default:
+		return nil, fmt.Errorf("Unsupported recording destination %v", ctx.String("record"))
+	}
+}
+
+func newVerifier(ctx *cli.Context, delivery cluster.Service, client cluster.HttpClient) (verify.Verifier, error) {
+	environments, ok := delivery.(cluster.ReadEnvironments)
+	if !ok {
+		return nil, errors.New("The read environments are not available")
+	}
+
+	config := verify.Config{
+		Path:           ctx.String("verify-path"),
+		Window:         ctx.Int("verify-window"),
+		MaxPending:     ctx.Int("verify-max-pending"),
+		MaxMissingRate: ctx.Float64("verify-max-missing-rate"),
+	}
+
+	var err error
+	if config.Delay, err = time.ParseDuration(ctx.String("verify-delay")); err != nil {
+		return nil, fmt.Errorf("Invalid verification delay: %v", err)
+	}
+
+	if config.Interval, err = time.ParseDuration(ctx.String("verify-interval")); err != nil {
+		return nil, fmt.Errorf("Invalid verification interval: %v", err)
+	}
+
+	if config.Timeout, err = time.ParseDuration(ctx.String("verify-timeout")); err != nil {
+		return nil, fmt.Errorf("Invalid verification timeout: %v", err)
+	}
+
+	log.WithField("delay", config.Delay).WithField("timeout", config.Timeout).Info("Verifying that publishes reach the read environments.")
+	return verify.NewVerifier(environments, client, config)
+}
+
+func newBlacklist(ctx *cli.Context, s3rw s3.ReadWriter, refreshInterval time.Duration) (blacklist.Blacklist, error) {
+	seed := blacklist.NewFileStore(ctx.String("blacklist"))
+
+	switch ctx.String("blacklist-store") {
+	case "file":
+		return blacklist.NewFileBasedBlacklist(context.Background(), ctx.String("blacklist"), refreshInterval)
+	case "s3":
+		return blacklist.NewStoredBlacklist(context.Background(), blacklist.NewS3Store(s3rw, "blacklist", filepath.Base(ctx.String("blacklist"))), seed, refreshInterval)
+	case "etcd":
+		if ctx.StringSlice("etcd-peers")[0] == "NOT_AVAILABLE" {
+			return nil, errors.New("Cannot save the blacklist to etcd, as no etcd peers are available")
+		}
+
+		store, err := keys.NewStore(ctx.StringSlice("etcd-peers"))
+		if err != nil {
+			return nil, err
+		}
+		return blacklist.NewStoredBlacklist(context.Background(), blacklist.NewEtcdStore(store, ctx.String("blacklist-etcd-key")), seed, refreshInterval)
+	default:
+		return nil, fmt.Errorf("Unsupported blacklist store %v", ctx.String("blacklist-store"))
+	}
+}
+
+func newClusterMonitor(ctx *cli.Context, sched scheduler.Scheduler, services ...cluster.Service) *scheduler.ClusterMonitor {
+	interval, err := time.ParseDuration(ctx.String("cluster-check-interval"))
+	if err != nil {
+		log.WithError(err).Error("Invalid cluster check interval, defaulting to every 30 seconds.")
+		interval = 30 * time.Second
+	}
+
+	minimumHealthy, err := time.ParseDuration(ctx.String("cluster-minimum-healthy"))
+	if err != nil {
+		log.WithError(err).Error("Invalid cluster minimum healthy time, defaulting to 1 minute.")
+		minimumHealthy = time.Minute
+	}
+
+	minimumUnhealthy, err := time.ParseDuration(ctx.String("cluster-minimum-unhealthy"))
+	if err != nil {
+		log.WithError(err).Error("Invalid cluster minimum unhealthy time, defaulting to 5 minutes.")
+		minimumUnhealthy = 5 * time.Minute
+	}
+
+	unhealthyThreshold := ctx.Int("cluster-unhealthy-threshold")
+	if unhealthyThreshold < 1 {
+		log.WithField("threshold", unhealthyThreshold).Error("Invalid cluster unhealthy threshold, defaulting to 1 check.")
+		unhealthyThreshold = 1
+	}
+
+	healthyThreshold := ctx.Int("cluster-healthy-threshold")
+	if healthyThreshold < 1 {
+		log.WithField("threshold", healthyThreshold).Error("Invalid cluster healthy threshold, defaulting to 1 check.")
+		healthyThreshold = 1
+	}
+
+	return scheduler.NewClusterMonitor(sched, unhealthyThreshold, healthyThreshold, minimumHealthy, minimumUnhealthy, interval, services...)
+}
+
+func shutdown(sched scheduler.Scheduler, clusterMonitor *scheduler.ClusterMonitor, notifiers map[string]cms.Notifier, closers ...io.Closer) {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
 		signal := <-signals
 		log.WithField("signal", signal).Info("Stopping scheduler after receiving OS signal")
+		clusterMonitor.Stop()
 		err := sched.Shutdown()
 		if err != nil {
 			log.WithError(err).Error("Error in stopping scheduler")
 		}
+
+		for name, notifier := range notifiers {
+			if closer, ok := notifier.(io.Closer); ok {
+				if err := closer.Close(); err != nil {
+					log.WithError(err).WithField("notifier", name).Error("Error in closing notifier")
+				}
+			}
+		}
+
+		for _, closer := range closers {
+			if closer == nil {
+				continue
+			}
+
+			if err := closer.Close(); err != nil {
+				log.WithError(err).Error("Error in closing")
+			}
+		}
 		os.Exit(0)
 	}()
 }
 
-func serve(mongo native.DB, sched scheduler.Scheduler, s3rw s3.ReadWriter, notifier cms.Notifier, api []byte, configError error, upServices ...cluster.Service) {
+func serve(mongo native.DB, sched scheduler.Scheduler, s3rw s3.ReadWriter, notifiers map[string]cms.Notifier, blist blacklist.Blacklist, verifier verify.Verifier, api []byte, configError error, clusterMonitor *scheduler.ClusterMonitor) {
 	r := vestigo.NewRouter()
 
-	healthService := resources.NewHealthService(appSystemCode, appName, description, mongo, s3rw, notifier, sched, configError, upServices...)
+	healthService := resources.NewHealthService(appSystemCode, appName, description, mongo, s3rw, notifiers, blist, verifier, sched, configError, clusterMonitor)
 
 	r.Get("/__api", resources.API(api))
 	r.Post("/__log", resources.LogLevel)
@@ -315,9 +1054,15 @@ func serve(mongo native.DB, sched scheduler.Scheduler, s3rw s3.ReadWriter, notif
 	r.Get(httphandlers.GTGPath, httphandlers.NewGoodToGoHandler(healthService.GTG))
 	r.Get("/__health", healthService.Health())
 
+	r.Get("/blacklist", resources.GetBlacklist(blist))
+	r.Post("/blacklist", resources.AddToBlacklist(blist))
+	r.Delete("/blacklist/:uuid", resources.RemoveFromBlacklist(blist))
+
 	r.Get("/cycles", resources.GetCycles(sched))
 	r.Post("/cycles", resources.CreateCycle(sched))
 
+	r.Get("/cycle-types", resources.GetCycleTypes())
+
 	r.Get("/cycles/:id", resources.GetCycleForID(sched))
 	r.Delete("/cycles/:id", resources.DeleteCycle(sched))
 
@@ -330,6 +1075,9 @@ func serve(mongo native.DB, sched scheduler.Scheduler, s3rw s3.ReadWriter, notif
 
 	r.Post("/cycles/:id/reset", resources.ResetCycle(sched))
 
+	r.Get("/cycles/:id/checkpoints", resources.GetCycleCheckpoints(sched))
+	r.Post("/cycles/:id/checkpoints/:key/restore", resources.RestoreCycleCheckpoint(sched))
+
 	r.Post("/scheduler/start", resources.StartScheduler(sched))
 
 	r.Post("/scheduler/shutdown", resources.ShutdownScheduler(sched))
@@ -14,6 +14,9 @@ type InMemoryUUIDCollection struct {
 	uuids      []string
 	collection string
 	skip       int
+	manifest   string
+	consumed   int
+	last       string
 }
 
 type InMemoryCollectionBuilder struct {
@@ -24,24 +27,40 @@ func NewInMemoryCollectionBuilder(s3ReadWriter s3.ReadWriter) *InMemoryCollectio
 	return &InMemoryCollectionBuilder{s3ReadWriter: s3ReadWriter}
 }
 
-func (b *InMemoryCollectionBuilder) LoadIntoMemory(ctx context.Context, uuidCollection UUIDCollection, collection string, skip int, blist blacklist.IsBlacklisted) (UUIDCollection, error) {
+// LoadIntoMemory loads all uuids for the collection into memory, resuming from a manifest in S3 if possible
+func (b *InMemoryCollectionBuilder) LoadIntoMemory(ctx context.Context, uuidCollection UUIDCollection, collection string, skip int, position *Position, iteration int, blist blacklist.IsBlacklisted) (UUIDCollection, error) {
 	defer uuidCollection.Close()
 
+	if position != nil && position.Manifest != "" && b.s3ReadWriter != nil {
+		log.WithField("collection", collection).WithField("manifest", position.Manifest).Info("Attempting to resume from position in S3 manifest")
+		uuids, start, err := readManifestFromS3(b.s3ReadWriter, position.Manifest, position.Offset)
+		if err != nil {
+			log.WithError(err).WithField("collection", collection).WithField("manifest", position.Manifest).Warn("Failed to retrieve manifest for position from S3")
+		} else if i, ok := resumeIndex(uuids, start, position); ok {
+			return newInMemoryUUIDCollection(collection, uuids[i-start:], i, position.Manifest), nil
+		} else {
+			log.WithField("collection", collection).WithField("uuid", position.UUID).Warn("Position not found in manifest, falling back to skip.")
+		}
+	}
+
 	if skip > 0 && b.s3ReadWriter != nil {
 		log.WithField("collection", collection).Info("Attempting to retrieve uuids from S3")
-		uuids, err := readFromS3(b.s3ReadWriter, collection)
+		uuids, start, key, err := readFromS3(b.s3ReadWriter, collection, skip)
 		if err != nil {
 			log.WithError(err).WithField("collection", collection).Warn("Failed to retrieve persisted file from S3")
-		} else if len(uuids) > 0 {
-			if skip < len(uuids) {
-				return &InMemoryUUIDCollection{collection: collection, skip: skip, uuids: uuids[skip:]}, nil
+		} else if total := start + len(uuids); total > 0 {
+			if i, ok := resumeIndex(uuids, start, position); ok {
+				return newInMemoryUUIDCollection(collection, uuids[i-start:], i, key), nil
 			}
-			log.WithField("skip", skip).WithField("uuids", len(uuids)).Info("Unexpected value for skip! It's greater than the total number of uuids to process. Restarting from zero.")
+			if skip < total {
+				return newInMemoryUUIDCollection(collection, uuids[skip-start:], skip, key), nil
+			}
+			log.WithField("skip", skip).WithField("uuids", total).Info("Unexpected value for skip! It's greater than the total number of uuids to process. Restarting from zero.")
 			skip = 0
 		}
 	}
 
-	it := &InMemoryUUIDCollection{collection: collection, skip: skip, uuids: make([]string, 0)}
+	it := &InMemoryUUIDCollection{collection: collection, uuids: make([]string, 0)}
 
 	if uuidCollection.Length() == 0 {
 		log.WithField("collection", collection).Warn("No data in mongo cursor for this collection.")
@@ -83,10 +102,6 @@ func (b *InMemoryCollectionBuilder) LoadIntoMemory(ctx context.Context, uuidColl
 			start = end
 		}
 
-		if i <= skip {
-			continue
-		}
-
 		if strings.TrimSpace(uuid) == "" {
 			blank++
 			continue
@@ -101,10 +116,11 @@ func (b *InMemoryCollectionBuilder) LoadIntoMemory(ctx context.Context, uuidColl
 	}
 
 	if b.s3ReadWriter != nil {
-		err := persistInS3(b.s3ReadWriter, it)
+		key, err := persistInS3(b.s3ReadWriter, it, iteration)
 		if err != nil {
 			log.WithError(err).Warn("Failed to persist collection uuids to bucket")
 		}
+		it.manifest = key
 	}
 
 	end = time.Now()
@@ -113,14 +129,57 @@ func (b *InMemoryCollectionBuilder) LoadIntoMemory(ctx context.Context, uuidColl
 	log.WithField("collection", collection).WithField("duration", diff.String()).Infof("Finished loading %v records from DB", len(it.uuids))
 	log.WithField("collection", collection).WithField("blacklisted", blacklisted).WithField("blank", blank).Info("Number of records blacklisted or blank.")
 
+	if index, ok := resumeIndex(it.uuids, 0, position); ok {
+		skip = index
+	} else if skip > len(it.uuids) {
+		skip = len(it.uuids)
+	}
+
+	it.skip = skip
+	it.uuids = it.uuids[skip:]
 	return it, nil
 }
 
+func newInMemoryUUIDCollection(collection string, remaining []string, skip int, manifest string) *InMemoryUUIDCollection {
+	return &InMemoryUUIDCollection{collection: collection, skip: skip, uuids: remaining, manifest: manifest}
+}
+
+// resumeIndex returns the index of the uuid following the position, where the uuids begin at the start offset
+func resumeIndex(uuids []string, start int, position *Position) (int, bool) {
+	if position == nil || position.UUID == "" {
+		return 0, false
+	}
+
+	i := position.Offset - start
+	if i >= 0 && i < len(uuids) && uuids[i] == position.UUID {
+		return position.Offset + 1, true
+	}
+
+	for i, uuid := range uuids {
+		if uuid == position.UUID {
+			return start + i + 1, true
+		}
+	}
+
+	return 0, false
+}
+
 func (i *InMemoryUUIDCollection) Next() (bool, string, error) {
 	if i.Done() {
 		return true, "", nil
 	}
-	return false, i.shift(), nil
+
+	i.last = i.shift()
+	i.consumed++
+	return false, i.last, nil
+}
+
+// Position returns the last uuid returned by Next, and its offset within the manifest
+func (i *InMemoryUUIDCollection) Position() *Position {
+	if i.consumed == 0 {
+		return nil
+	}
+	return &Position{UUID: i.last, Manifest: i.manifest, Offset: i.skip + i.consumed - 1}
 }
 
 func (i *InMemoryUUIDCollection) Length() int {
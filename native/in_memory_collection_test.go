@@ -2,7 +2,6 @@ package native
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"strings"
@@ -60,7 +59,7 @@ func TestLoadIntoMemory(t *testing.T) {
 
 	builder := &InMemoryCollectionBuilder{nil}
 
-	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 0, noopBlacklist)
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 0, nil, 1, noopBlacklist)
 	assert.NoError(t, err)
 	assert.Equal(t, 3, it.Length())
 }
@@ -73,7 +72,7 @@ func TestLoadIntoMemoryWithSkip(t *testing.T) {
 
 	builder := &InMemoryCollectionBuilder{nil}
 
-	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 1, noopBlacklist)
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 1, nil, 1, noopBlacklist)
 	assert.NoError(t, err)
 	assert.Equal(t, 3, it.Length())
 
@@ -91,7 +90,7 @@ func TestLoadIntoMemoryIgnoresBlanks(t *testing.T) {
 
 	builder := &InMemoryCollectionBuilder{nil}
 
-	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 1, noopBlacklist)
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 1, nil, 1, noopBlacklist)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, it.Length())
 
@@ -109,7 +108,7 @@ func TestLoadIntoMemoryBlacklisted(t *testing.T) {
 
 	builder := &InMemoryCollectionBuilder{nil}
 
-	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 0, func(uuid string) (bool, error) {
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 0, nil, 1, func(uuid string) (bool, error) {
 		if uuid == "1" {
 			return true, nil
 		}
@@ -143,7 +142,7 @@ func TestLoadIntoMemoryWithContextInterrupt(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		builder := &InMemoryCollectionBuilder{nil}
-		_, err := builder.LoadIntoMemory(ctx, uuidCollection, "collection", 0, noopBlacklist)
+		_, err := builder.LoadIntoMemory(ctx, uuidCollection, "collection", 0, nil, 1, noopBlacklist)
 		assert.NoError(t, err)
 
 		completed = true
@@ -161,7 +160,7 @@ func TestLoadIntoMemoryErrors(t *testing.T) {
 	uuidCollection.On("Length").Return(3)
 
 	builder := &InMemoryCollectionBuilder{nil}
-	_, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 0, noopBlacklist)
+	_, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 0, nil, 1, noopBlacklist)
 	assert.Error(t, err)
 }
 
@@ -173,7 +172,7 @@ func TestLoadIntoMemoryEmptyCollection(t *testing.T) {
 
 	builder := &InMemoryCollectionBuilder{nil}
 
-	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 0, noopBlacklist)
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 0, nil, 1, noopBlacklist)
 	assert.NoError(t, err)
 	assert.Equal(t, 0, it.Length())
 }
@@ -184,14 +183,13 @@ func TestPersistToS3DuringInMemoryStartup(t *testing.T) {
 	uuidCollection.On("Next").Return(nil)
 	uuidCollection.On("Length").Return(3)
 
-	expectedJSON, _ := json.Marshal(uuidCollection.uuids)
-
 	mockS3RW := new(s3.MockReadWriter)
-	mockS3RW.On("Write", "collection-uuids", mock.AnythingOfType("string"), expectedJSON, "application/json").Return(nil)
+	mockS3RW.On("Write", "collection-uuids-chunks", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), manifestChunkContentType).Return(nil)
+	mockS3RW.On("Write", "collection-uuids", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), "application/json").Return(nil)
 
 	builder := &InMemoryCollectionBuilder{mockS3RW}
 
-	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 0, noopBlacklist)
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 0, nil, 1, noopBlacklist)
 	assert.NoError(t, err)
 	assert.Equal(t, 3, it.Length())
 }
@@ -209,7 +207,7 @@ func TestReadFromS3DuringInMemoryStartup(t *testing.T) {
 
 	builder := &InMemoryCollectionBuilder{rw}
 
-	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 1, noopBlacklist)
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 1, nil, 1, noopBlacklist)
 	assert.NoError(t, err)
 	assert.Equal(t, 4, it.Length())
 
@@ -223,11 +221,10 @@ func TestReadFromS3DuringInMemoryStartupInvalidSkip(t *testing.T) {
 	uuidCollection.On("Next").Return(nil)
 	uuidCollection.On("Length").Return(1)
 
-	expectedJSON, _ := json.Marshal(uuidCollection.uuids)
-
 	rw := new(s3.MockReadWriter)
 	rw.On("GetLatestKeyForID", "collection-uuids").Return("key", nil)
-	rw.On("Write", "collection-uuids", mock.AnythingOfType("string"), expectedJSON, "application/json").Return(nil)
+	rw.On("Write", "collection-uuids-chunks", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), manifestChunkContentType).Return(nil)
+	rw.On("Write", "collection-uuids", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), "application/json").Return(nil)
 
 	contentType := "application/json"
 
@@ -235,7 +232,7 @@ func TestReadFromS3DuringInMemoryStartupInvalidSkip(t *testing.T) {
 
 	builder := &InMemoryCollectionBuilder{rw}
 
-	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 9, noopBlacklist)
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 9, nil, 1, noopBlacklist)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, it.Length())
 
@@ -251,17 +248,124 @@ func TestReadFromS3DuringInMemoryStartupFails(t *testing.T) {
 
 	rw := new(s3.MockReadWriter)
 	rw.On("GetLatestKeyForID", "collection-uuids").Return("key", nil)
-	rw.On("Write", "collection-uuids", mock.AnythingOfType("string"), []byte(`["2","3"]`), "application/json").Return(nil)
+	rw.On("Write", "collection-uuids-chunks", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), manifestChunkContentType).Return(nil)
+	rw.On("Write", "collection-uuids", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), "application/json").Return(nil)
 
 	contentType := "application/json"
 	rw.On("Read", "key").Return(false, ioutil.NopCloser(strings.NewReader("")), &contentType, errors.New("no s3 for you"))
 
 	builder := &InMemoryCollectionBuilder{rw}
 
-	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 1, noopBlacklist)
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 1, nil, 1, noopBlacklist)
 	assert.NoError(t, err)
 	assert.Equal(t, 3, it.Length())
 
 	rw.AssertExpectations(t)
 	uuidCollection.AssertExpectations(t)
 }
+
+func TestInMemoryIteratorPosition(t *testing.T) {
+	it := newInMemoryUUIDCollection("collection", []string{"2", "3"}, 1, "collection-uuids/key.json")
+	assert.Nil(t, it.Position())
+
+	_, val, _ := it.Next()
+	assert.Equal(t, "2", val)
+	assert.Equal(t, &Position{UUID: "2", Manifest: "collection-uuids/key.json", Offset: 1}, it.Position())
+
+	_, val, _ = it.Next()
+	assert.Equal(t, "3", val)
+	assert.Equal(t, &Position{UUID: "3", Manifest: "collection-uuids/key.json", Offset: 2}, it.Position())
+}
+
+func TestLoadIntoMemoryResumesFromPositionManifest(t *testing.T) {
+	uuidCollection := &MockUUIDCollection{uuids: []string{}}
+	uuidCollection.On("Close").Return(nil)
+
+	rw := new(s3.MockReadWriter)
+	contentType := "application/json"
+	rw.On("Read", "collection-uuids/old.json").Return(true, ioutil.NopCloser(strings.NewReader(`["0","1","2","3","4"]`)), &contentType, nil)
+
+	builder := &InMemoryCollectionBuilder{rw}
+
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 1, &Position{UUID: "2", Manifest: "collection-uuids/old.json", Offset: 2}, 1, noopBlacklist)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, it.Length())
+
+	done, val, err := it.Next()
+	assert.False(t, done)
+	assert.Equal(t, "3", val)
+	assert.NoError(t, err)
+
+	rw.AssertExpectations(t)
+	uuidCollection.AssertExpectations(t)
+}
+
+func TestLoadIntoMemoryResumesFromMovedPosition(t *testing.T) {
+	uuidCollection := &MockUUIDCollection{uuids: []string{}}
+	uuidCollection.On("Close").Return(nil)
+
+	rw := new(s3.MockReadWriter)
+	contentType := "application/json"
+	rw.On("Read", "collection-uuids/old.json").Return(true, ioutil.NopCloser(strings.NewReader(`["new","0","1","2","3"]`)), &contentType, nil)
+
+	builder := &InMemoryCollectionBuilder{rw}
+
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 3, &Position{UUID: "1", Manifest: "collection-uuids/old.json", Offset: 1}, 1, noopBlacklist)
+	assert.NoError(t, err)
+
+	_, val, _ := it.Next()
+	assert.Equal(t, "2", val)
+	rw.AssertExpectations(t)
+}
+
+func TestLoadIntoMemoryResumesFromPositionInFreshLoad(t *testing.T) {
+	uuidCollection := &MockUUIDCollection{uuids: []string{"new", "1", "2", "3"}}
+	uuidCollection.On("Close").Return(nil)
+	uuidCollection.On("Next").Return(nil)
+	uuidCollection.On("Length").Return(4)
+
+	builder := &InMemoryCollectionBuilder{nil}
+
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 1, &Position{UUID: "1"}, 1, noopBlacklist)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, it.Length())
+
+	_, val, _ := it.Next()
+	assert.Equal(t, "2", val)
+}
+
+func TestLoadIntoMemoryFallsBackToSkipWhenPositionMissing(t *testing.T) {
+	uuidCollection := &MockUUIDCollection{uuids: []string{"1", "2", "3"}}
+	uuidCollection.On("Close").Return(nil)
+	uuidCollection.On("Next").Return(nil)
+	uuidCollection.On("Length").Return(3)
+
+	builder := &InMemoryCollectionBuilder{nil}
+
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", 2, &Position{UUID: "deleted"}, 1, noopBlacklist)
+	assert.NoError(t, err)
+
+	_, val, _ := it.Next()
+	assert.Equal(t, "3", val)
+}
+
+func TestLoadIntoMemoryResumesFromChunkedManifest(t *testing.T) {
+	uuidCollection := &MockUUIDCollection{uuids: []string{}}
+	uuidCollection.On("Close").Return(nil)
+
+	rw := newMemoryReadWriter()
+	uuids := testUUIDs(manifestChunkSize + 10)
+	key, err := persistInS3(rw, &InMemoryUUIDCollection{collection: "collection", uuids: uuids}, 1)
+	assert.NoError(t, err)
+
+	builder := &InMemoryCollectionBuilder{rw}
+
+	position := &Position{UUID: uuids[manifestChunkSize+2], Manifest: key, Offset: manifestChunkSize + 2}
+	it, err := builder.LoadIntoMemory(context.Background(), uuidCollection, "collection", manifestChunkSize+3, position, 1, noopBlacklist)
+	assert.NoError(t, err)
+	assert.Equal(t, manifestChunkSize+10, it.Length())
+
+	_, val, _ := it.Next()
+	assert.Equal(t, uuids[manifestChunkSize+3], val)
+	assert.Equal(t, manifestChunkSize+3, it.(ResumableUUIDCollection).Position().Offset)
+}
@@ -39,6 +39,16 @@ func (t *MockTX) FindUUIDs(collectionID string, skip int, batchsize int) (DBIter
 	return args.Get(0).(DBIter), args.Int(1), args.Error(2)
 }
 
+func (t *MockTX) FindUUIDsAfterID(collectionID string, afterID string, limit int) (DBIter, error) {
+	args := t.Called(collectionID, afterID, limit)
+	return args.Get(0).(DBIter), args.Error(1)
+}
+
+func (t *MockTX) CountUUIDs(collectionID string) (int, error) {
+	args := t.Called(collectionID)
+	return args.Int(0), args.Error(1)
+}
+
 func (t *MockTX) Ping(ctx context.Context) error {
 	args := t.Called(ctx)
 	return args.Error(0)
@@ -18,11 +18,14 @@ var expectedConnections = 1
 var connections = 0
 
 const sortByDate = "-content.lastModified"
+const sortByID = "_id"
 
 type Content struct {
 	Body           map[string]interface{} `bson:"content"`
 	ContentType    string                 `bson:"content-type"`
 	OriginSystemID string                 `bson:"origin-system-id"`
+	Transforms     []string               `bson:"-"` // describes the transforms applied to the body since it was read
+	Cycle          string                 `bson:"-"` // the name of the cycle publishing the content
 }
 
 // DB contains database functions
@@ -36,6 +39,8 @@ type TX interface {
 	ReadNativeContent(collectionId string, uuid string) (*Content, error)
 	FindUUIDsInTimeWindow(collectionId string, start time.Time, end time.Time, batchsize int) (DBIter, int, error)
 	FindUUIDs(collectionId string, skip int, batchsize int) (DBIter, int, error)
+	FindUUIDsAfterID(collectionId string, afterID string, limit int) (DBIter, error)
+	CountUUIDs(collectionId string) (int, error)
 	Ping(ctx context.Context) error
 	Close()
 }
@@ -105,6 +110,25 @@ func (tx *MongoTX) FindUUIDs(collectionID string, skip int, batchsize int) (DBIt
 	return find.Iter(), count + skip, err // add count to skip as this correctly computes the total size of the cursor
 }
 
+// FindUUIDsAfterID returns a page of at most limit uuids, sorted by _id, after the provided (hex) _id
+func (tx *MongoTX) FindUUIDsAfterID(collectionID string, afterID string, limit int) (DBIter, error) {
+	collection := tx.session.DB("native-store").C(collectionID)
+
+	query, projection, err := findUUIDsAfterIDQueryElements(afterID)
+	if err != nil {
+		return nil, err
+	}
+
+	find := collection.Find(query).Select(projection).Sort(sortByID).Batch(limit).Limit(limit)
+	return find.Iter(), nil
+}
+
+// CountUUIDs returns the number of documents in the collection which have a uuid
+func (tx *MongoTX) CountUUIDs(collectionID string) (int, error) {
+	collection := tx.session.DB("native-store").C(collectionID)
+	return collection.Find(countUUIDsQuery()).Count()
+}
+
 // ReadNativeContent queries mongo for a uuid and returns the native document
 func (tx *MongoTX) ReadNativeContent(collectionID string, uuid string) (*Content, error) {
 	collection := tx.session.DB("native-store").C(collectionID)
@@ -25,6 +25,20 @@ type UUIDCollection interface {
 	Done() bool
 }
 
+// Position is a stable point within a UUIDCollection, from which an iteration can be resumed
+type Position struct {
+	ID       string `json:"id,omitempty"`
+	UUID     string `json:"uuid,omitempty"`
+	Manifest string `json:"manifest,omitempty"`
+	Offset   int    `json:"offset,omitempty"`
+}
+
+// ResumableUUIDCollection is a UUIDCollection which can report the position of the last uuid returned by Next()
+type ResumableUUIDCollection interface {
+	UUIDCollection
+	Position() *Position
+}
+
 type NativeUUIDCollection struct {
 	collection string
 	iter       DBIter
@@ -41,6 +55,20 @@ func NewNativeUUIDCollectionBuilder(mongo DB, rw s3.ReadWriter, isBlacklisted bl
 	return &NativeUUIDCollectionBuilder{db: mongo, isBlacklisted: isBlacklisted, inMemory: NewInMemoryCollectionBuilder(rw)}
 }
 
+// PruneManifests deletes the uuid manifests for the collection which are not retained or in use
+func (b *NativeUUIDCollectionBuilder) PruneManifests(collection string, policy s3.RetentionPolicy, inUse ...string) (int, error) {
+	if b.inMemory == nil || b.inMemory.s3ReadWriter == nil {
+		return 0, nil
+	}
+
+	keep := make(map[string]bool)
+	for _, key := range inUse {
+		keep[key] = true
+	}
+
+	return pruneManifestsInS3(b.inMemory.s3ReadWriter, collection, policy, keep, time.Now())
+}
+
 func (b *NativeUUIDCollectionBuilder) NewNativeUUIDCollectionForTimeWindow(collection string, start time.Time, end time.Time, maximumThrottle time.Duration) (UUIDCollection, error) {
 	tx, err := b.db.Open()
 	if err != nil {
@@ -79,7 +107,8 @@ func computeBatchsize(interval time.Duration) (int, error) {
 	return int(size - 1), nil
 }
 
-func (b *NativeUUIDCollectionBuilder) NewNativeUUIDCollection(ctx context.Context, collection string, skip int) (UUIDCollection, error) {
+// NewNativeUUIDCollection loads the whole collection into memory, resuming after the provided position
+func (b *NativeUUIDCollectionBuilder) NewNativeUUIDCollection(ctx context.Context, collection string, skip int, position *Position, iteration int) (UUIDCollection, error) {
 	tx, err := b.db.Open()
 	if err != nil {
 		return nil, err
@@ -92,10 +121,32 @@ func (b *NativeUUIDCollectionBuilder) NewNativeUUIDCollection(ctx context.Contex
 
 	cursor := &NativeUUIDCollection{collection: collection, iter: iter, length: length}
 
-	inMemory, err := b.inMemory.LoadIntoMemory(ctx, cursor, collection, skip, b.isBlacklisted)
+	inMemory, err := b.inMemory.LoadIntoMemory(ctx, cursor, collection, skip, position, iteration, b.isBlacklisted)
 	return inMemory, err
 }
 
+// NewStreamingUUIDCollection pages through the whole collection in _id order, resuming after the provided position
+func (b *NativeUUIDCollectionBuilder) NewStreamingUUIDCollection(collection string, position *Position) (UUIDCollection, error) {
+	tx, err := b.db.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := tx.CountUUIDs(collection)
+	if err != nil {
+		tx.Close()
+		return nil, err
+	}
+
+	afterID := ""
+	if position != nil {
+		afterID = position.ID
+	}
+
+	log.WithField("collection", collection).WithField("after", afterID).WithField("total", length).Info("Streaming collection from mongo.")
+	return newStreamingUUIDCollection(tx, collection, afterID, length, streamingPageSize, b.isBlacklisted), nil
+}
+
 func (n *NativeUUIDCollection) Next() (bool, string, error) {
 	result := map[string]interface{}{}
 
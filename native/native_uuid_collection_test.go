@@ -77,7 +77,7 @@ func TestNewNativeUUIDCollection(t *testing.T) {
 
 	builder := NewNativeUUIDCollectionBuilder(mockDb, nil, noopBlacklist)
 
-	actual, err := builder.NewNativeUUIDCollection(context.Background(), testCollection, 0)
+	actual, err := builder.NewNativeUUIDCollection(context.Background(), testCollection, 0, nil, 1)
 	assert.NoError(t, err)
 	assert.Equal(t, 0, actual.Length())
 
@@ -94,7 +94,7 @@ func TestNewNativeUUIDCollectionOpenFails(t *testing.T) {
 
 	builder := NewNativeUUIDCollectionBuilder(mockDb, nil, noopBlacklist)
 
-	_, err := builder.NewNativeUUIDCollection(context.Background(), testCollection, 0)
+	_, err := builder.NewNativeUUIDCollection(context.Background(), testCollection, 0, nil, 1)
 	assert.Error(t, err)
 
 	mockDb.AssertExpectations(t)
@@ -115,7 +115,7 @@ func TestNewNativeUUIDCollectionFindFails(t *testing.T) {
 
 	builder := NewNativeUUIDCollectionBuilder(mockDb, nil, noopBlacklist)
 
-	_, err := builder.NewNativeUUIDCollection(context.Background(), testCollection, 0)
+	_, err := builder.NewNativeUUIDCollection(context.Background(), testCollection, 0, nil, 1)
 	assert.Error(t, err)
 
 	mockDb.AssertExpectations(t)
@@ -202,7 +202,7 @@ func TestNativeUUIDCollection(t *testing.T) {
 	t.Log(testUUID)
 	builder := NewNativeUUIDCollectionBuilder(db, nil, noopBlacklist)
 
-	uuidCollection, err := builder.NewNativeUUIDCollection(context.Background(), "methode", 0)
+	uuidCollection, err := builder.NewNativeUUIDCollection(context.Background(), "methode", 0, nil, 1)
 	assert.NoError(t, err)
 
 	found := false
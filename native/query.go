@@ -1,6 +1,7 @@
 package native
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/pborman/uuid"
@@ -38,3 +39,25 @@ func findUUIDsForTimeWindowQueryElements(start time.Time, end time.Time) (bson.M
 func findUUIDsQueryElements() (bson.M, bson.M) {
 	return bson.M{}, uuidProjection
 }
+
+func findUUIDsAfterIDQueryElements(afterID string) (bson.M, bson.M, error) {
+	if afterID == "" {
+		return bson.M{}, uuidProjection, nil
+	}
+
+	if !bson.IsObjectIdHex(afterID) {
+		return nil, nil, fmt.Errorf(`Invalid _id cursor "%v", expected a hex encoded ObjectId`, afterID)
+	}
+
+	query := bson.M{
+		"_id": bson.M{
+			"$gt": bson.ObjectIdHex(afterID),
+		},
+	}
+
+	return query, uuidProjection, nil
+}
+
+func countUUIDsQuery() bson.M {
+	return bson.M{"uuid": bson.M{"$exists": true}}
+}
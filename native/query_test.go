@@ -36,3 +36,21 @@ func TestFindUUIDsForTimeWindowQueryElements(t *testing.T) {
 	assert.Equal(t, `{"$and":[{"content.lastModified":{"$gte":"2017-03-15T23:59:00Z"}},{"content.lastModified":{"$lt":"2017-03-16T00:00:00Z"}}]}`, strings.TrimSpace(string(data)))
 	assert.Equal(t, uuidProjection, projection)
 }
+
+func TestFindUUIDsAfterIDQueryElements(t *testing.T) {
+	query, projection, err := findUUIDsAfterIDQueryElements("")
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{}, query)
+	assert.Equal(t, uuidProjection, projection)
+
+	query, projection, err = findUUIDsAfterIDQueryElements("58d2b5e9b3a6c4f1e0a1b2c3")
+	assert.NoError(t, err)
+
+	data, err := bson.MarshalJSON(query)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"_id":{"$gt":{"$oid":"58d2b5e9b3a6c4f1e0a1b2c3"}}}`, strings.TrimSpace(string(data)))
+	assert.Equal(t, uuidProjection, projection)
+
+	_, _, err = findUUIDsAfterIDQueryElements("not-an-id")
+	assert.Error(t, err)
+}
@@ -1,54 +1,352 @@
 package native
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
 	"time"
 
 	"github.com/Financial-Times/publish-carousel/s3"
+	log "github.com/sirupsen/logrus"
 )
 
 const persistedUUIDsSuffix = "-uuids"
+const persistedChunksSuffix = "-uuids-chunks"
 
-func persistInS3(rw s3.ReadWriter, collection *InMemoryUUIDCollection) error {
-	key := time.Now().UTC().Format(`20060102T15040599`) + ".json"
+const manifestVersion = 2
+const manifestChunkSize = 10000
+const manifestChunkContentType = "application/gzip"
 
-	b, err := json.Marshal(collection.uuids)
+// manifestIndex describes a uuid manifest, stored as gzip compressed chunks
+type manifestIndex struct {
+	Version    int             `json:"version"`
+	Collection string          `json:"collection"`
+	Count      int             `json:"count"`
+	Checksum   string          `json:"checksum"`
+	Created    time.Time       `json:"created"`
+	Iteration  int             `json:"iteration"`
+	Chunks     []manifestChunk `json:"chunks"`
+}
+
+type manifestChunk struct {
+	Key      string `json:"key"`
+	Offset   int    `json:"offset"`
+	Count    int    `json:"count"`
+	Checksum string `json:"checksum"`
+}
+
+// persistInS3 writes the uuids as a chunked manifest for the collection, and returns the key of its index
+func persistInS3(rw s3.ReadWriter, collection *InMemoryUUIDCollection, iteration int) (manifest string, err error) {
+	created := time.Now().UTC()
+	timestamp := created.Format(`20060102T15040599`)
+
+	index := manifestIndex{
+		Version:    manifestVersion,
+		Collection: collection.collection,
+		Count:      len(collection.uuids),
+		Created:    created,
+		Iteration:  iteration,
+		Chunks:     make([]manifestChunk, 0),
+	}
+
+	defer func() {
+		if err != nil {
+			deleteChunksInS3(rw, index.Chunks)
+		}
+	}()
+
+	chunksID := collection.collection + persistedChunksSuffix
+	for offset := 0; offset < len(collection.uuids); offset += manifestChunkSize {
+		end := offset + manifestChunkSize
+		if end > len(collection.uuids) {
+			end = len(collection.uuids)
+		}
+
+		data := ndjson(collection.uuids[offset:end])
+		checksum, err := Hash(data)
+		if err != nil {
+			return "", err
+		}
+
+		compressed, err := compress(data)
+		if err != nil {
+			return "", err
+		}
+
+		key := fmt.Sprintf("%v-%06d.ndjson.gz", timestamp, len(index.Chunks))
+		err = rw.Write(chunksID, key, compressed, manifestChunkContentType)
+		if err != nil {
+			return "", err
+		}
+
+		index.Chunks = append(index.Chunks, manifestChunk{Key: chunksID + "/" + key, Offset: offset, Count: end - offset, Checksum: checksum})
+	}
+
+	index.Checksum, err = Hash(ndjson(collection.uuids))
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return rw.Write(collection.collection+persistedUUIDsSuffix, key, b, "application/json")
+	b, err := json.Marshal(index)
+	if err != nil {
+		return "", err
+	}
+
+	id := collection.collection + persistedUUIDsSuffix
+	key := timestamp + ".json"
+
+	err = rw.Write(id, key, b, "application/json")
+	if err != nil {
+		return "", err
+	}
+
+	return id + "/" + key, nil
 }
 
-func readFromS3(rw s3.ReadWriter, collection string) ([]string, error) {
+// deleteChunksInS3 deletes the chunks of a manifest which could not be persisted
+func deleteChunksInS3(rw s3.ReadWriter, chunks []manifestChunk) {
+	for _, chunk := range chunks {
+		if err := rw.Delete(chunk.Key); err != nil {
+			log.WithError(err).WithField("chunk", chunk.Key).Warn("Failed to delete the chunk of a manifest which could not be persisted")
+		}
+	}
+}
+
+// readFromS3 reads the latest manifest for the collection from the chunk containing the given offset onwards
+func readFromS3(rw s3.ReadWriter, collection string, from int) ([]string, int, string, error) {
 	key, err := rw.GetLatestKeyForID(collection + persistedUUIDsSuffix)
 	if err != nil {
-		return nil, err
+		return nil, 0, "", err
+	}
+
+	uuids, start, err := readManifestFromS3(rw, key, from)
+	if err != nil {
+		return nil, 0, "", err
 	}
 
+	return uuids, start, key, nil
+}
+
+// readManifestFromS3 reads the uuids in the manifest from the chunk containing the given offset onwards
+func readManifestFromS3(rw s3.ReadWriter, key string, from int) ([]string, int, error) {
 	found, data, contentType, err := rw.Read(key)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if !found {
-		return nil, errors.New("Key not found, has it recently been deleted?")
+		return nil, 0, errors.New("Key not found, has it recently been deleted?")
 	}
 
 	if contentType == nil || *contentType != "application/json" {
-		return nil, errors.New("Unexpected or nil content type")
+		return nil, 0, errors.New("Unexpected or nil content type")
 	}
 
 	defer data.Close()
 
-	dec := json.NewDecoder(data)
-	var uuids []string
-	err = dec.Decode(&uuids)
+	body := bufio.NewReader(data)
+	if isLegacyManifest(body) {
+		dec := json.NewDecoder(body)
+		var uuids []string
+		err = dec.Decode(&uuids)
+		if err != nil {
+			return nil, 0, err
+		}
+		return uuids, 0, nil
+	}
+
+	index := manifestIndex{}
+	err = json.NewDecoder(body).Decode(&index)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if index.Version != manifestVersion {
+		return nil, 0, fmt.Errorf("Unsupported manifest version %v", index.Version)
+	}
+
+	uuids := make([]string, 0)
+	start := -1
+	for _, chunk := range index.Chunks {
+		if chunk.Offset+chunk.Count <= from {
+			continue
+		}
+
+		if start == -1 {
+			start = chunk.Offset
+		}
+
+		chunkUUIDs, err := readChunkFromS3(rw, chunk)
+		if err != nil {
+			return nil, 0, err
+		}
+		uuids = append(uuids, chunkUUIDs...)
+	}
+
+	if start == -1 {
+		start = index.Count
+	}
+
+	if start == 0 {
+		checksum, err := Hash(ndjson(uuids))
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(uuids) != index.Count || checksum != index.Checksum {
+			return nil, 0, fmt.Errorf(`Manifest "%v" failed verification`, key)
+		}
+	}
 
+	return uuids, start, nil
+}
+
+// pruneManifestsInS3 deletes the expired manifests for the collection, and returns how many were deleted
+func pruneManifestsInS3(rw s3.ReadWriter, collection string, policy s3.RetentionPolicy, inUse map[string]bool, now time.Time) (int, error) {
+	objects, err := rw.List(collection + persistedUUIDsSuffix)
 	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, obj := range policy.Expired(objects, now) {
+		if inUse[obj.Key] {
+			continue
+		}
+
+		chunks, err := readManifestChunkKeys(rw, obj.Key)
+		if err != nil {
+			log.WithError(err).WithField("manifest", obj.Key).Warn("Failed to read manifest index, its chunks will not be pruned")
+		}
+
+		for _, chunk := range chunks {
+			if err := rw.Delete(chunk); err != nil {
+				return pruned, err
+			}
+		}
+
+		if err := rw.Delete(obj.Key); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+func readManifestChunkKeys(rw s3.ReadWriter, key string) ([]string, error) {
+	found, data, _, err := rw.Read(key)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	defer data.Close()
+
+	body := bufio.NewReader(data)
+	if isLegacyManifest(body) {
+		return nil, nil
+	}
+
+	index := manifestIndex{}
+	if err := json.NewDecoder(body).Decode(&index); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, chunk := range index.Chunks {
+		keys = append(keys, chunk.Key)
+	}
+	return keys, nil
+}
+
+func readChunkFromS3(rw s3.ReadWriter, chunk manifestChunk) ([]string, error) {
+	found, data, contentType, err := rw.Read(chunk.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, fmt.Errorf(`Manifest chunk "%v" not found`, chunk.Key)
+	}
+
+	defer data.Close()
+
+	if contentType == nil || *contentType != manifestChunkContentType {
+		return nil, fmt.Errorf(`Unexpected or nil content type for manifest chunk "%v"`, chunk.Key)
+	}
+
+	gz, err := gzip.NewReader(data)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	b, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, err := Hash(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if checksum != chunk.Checksum {
+		return nil, fmt.Errorf(`Manifest chunk "%v" failed checksum verification`, chunk.Key)
+	}
+
+	uuids := make([]string, 0, chunk.Count)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		uuids = append(uuids, scanner.Text())
+	}
+
+	if len(uuids) != chunk.Count {
+		return nil, fmt.Errorf(`Manifest chunk "%v" contained %v uuids, expected %v`, chunk.Key, len(uuids), chunk.Count)
+	}
+
+	return uuids, scanner.Err()
+}
+
+func isLegacyManifest(r *bufio.Reader) bool {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return false
+		}
+
+		if strings.TrimSpace(string(b)) != "" {
+			return b[0] == '['
+		}
+		r.ReadByte()
+	}
+}
+
+func ndjson(uuids []string) []byte {
+	buf := &bytes.Buffer{}
+	for _, uuid := range uuids {
+		buf.WriteString(uuid)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func compress(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+
+	if _, err := io.Copy(gz, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
 		return nil, err
 	}
 
-	return uuids, nil
+	return buf.Bytes(), nil
 }
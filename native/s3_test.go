@@ -1,10 +1,16 @@
 package native
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/publish-carousel/cluster"
 	"github.com/Financial-Times/publish-carousel/s3"
@@ -12,28 +18,206 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+type memoryReadWriter struct {
+	objects      map[string][]byte
+	contentTypes map[string]string
+	latest       map[string]string
+	modified     map[string]time.Time
+}
+
+func newMemoryReadWriter() *memoryReadWriter {
+	return &memoryReadWriter{objects: make(map[string][]byte), contentTypes: make(map[string]string), latest: make(map[string]string), modified: make(map[string]time.Time)}
+}
+
+func (m *memoryReadWriter) Write(id string, key string, b []byte, contentType string) error {
+	m.objects[id+"/"+key] = b
+	m.contentTypes[id+"/"+key] = contentType
+	m.latest[id] = id + "/" + key
+	m.modified[id+"/"+key] = time.Now()
+	return nil
+}
+
+func (m *memoryReadWriter) Read(key string) (bool, io.ReadCloser, *string, error) {
+	b, ok := m.objects[key]
+	if !ok {
+		return false, nil, nil, nil
+	}
+	contentType := m.contentTypes[key]
+	return true, ioutil.NopCloser(bytes.NewReader(b)), &contentType, nil
+}
+
+func (m *memoryReadWriter) GetLatestKeyForID(id string) (string, error) {
+	return m.latest[id], nil
+}
+
+func (m *memoryReadWriter) List(id string) ([]s3.Object, error) {
+	var objects []s3.Object
+	for key, modified := range m.modified {
+		if strings.HasPrefix(key, id+"/") {
+			objects = append(objects, s3.Object{Key: key, LastModified: modified})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].LastModified.Before(objects[j].LastModified) })
+	return objects, nil
+}
+
+func (m *memoryReadWriter) Delete(key string) error {
+	delete(m.objects, key)
+	delete(m.contentTypes, key)
+	delete(m.modified, key)
+	return nil
+}
+
+func (m *memoryReadWriter) Ping() error {
+	return nil
+}
+
+func testUUIDs(n int) []string {
+	uuids := make([]string, n)
+	for i := range uuids {
+		uuids[i] = fmt.Sprintf("uuid-%v", i)
+	}
+	return uuids
+}
+
 func TestPersistToS3(t *testing.T) {
-	rw := new(s3.MockReadWriter)
+	rw := newMemoryReadWriter()
+	cursor := &InMemoryUUIDCollection{collection: "collection", uuids: testUUIDs(manifestChunkSize*2 + 5)}
+
+	key, err := persistInS3(rw, cursor, 3)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "collection-uuids/"))
+
+	index := manifestIndex{}
+	err = json.Unmarshal(rw.objects[key], &index)
+	assert.NoError(t, err)
+
+	assert.Equal(t, manifestVersion, index.Version)
+	assert.Equal(t, manifestChunkSize*2+5, index.Count)
+	assert.Equal(t, 3, index.Iteration)
+	assert.NotEmpty(t, index.Checksum)
+	assert.Len(t, index.Chunks, 3)
+	assert.Equal(t, manifestChunkSize*2, index.Chunks[2].Offset)
+	assert.Equal(t, 5, index.Chunks[2].Count)
+
+	for _, chunk := range index.Chunks {
+		assert.True(t, strings.HasPrefix(chunk.Key, "collection-uuids-chunks/"))
+		assert.Equal(t, manifestChunkContentType, rw.contentTypes[chunk.Key])
+	}
+}
+
+func TestPersistToS3Empty(t *testing.T) {
+	rw := newMemoryReadWriter()
 	cursor := &InMemoryUUIDCollection{collection: "collection", uuids: make([]string, 0)}
 
-	rw.On("Write", "collection-uuids", mock.AnythingOfType("string"), []byte("[]"), "application/json").Return(nil)
+	key, err := persistInS3(rw, cursor, 1)
+	assert.NoError(t, err)
 
-	err := persistInS3(rw, cursor)
+	uuids, start, err := readManifestFromS3(rw, key, 0)
 	assert.NoError(t, err)
-	mock.AssertExpectationsForObjects(t, rw)
+	assert.Equal(t, 0, start)
+	assert.Empty(t, uuids)
 }
 
 func TestPersistToS3Fails(t *testing.T) {
 	rw := new(s3.MockReadWriter)
 	cursor := &InMemoryUUIDCollection{collection: "collection", uuids: make([]string, 0)}
 
-	rw.On("Write", "collection-uuids", mock.AnythingOfType("string"), []byte("[]"), "application/json").Return(errors.New("oh no"))
+	rw.On("Write", "collection-uuids", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), "application/json").Return(errors.New("oh no"))
+
+	_, err := persistInS3(rw, cursor, 1)
+	assert.Error(t, err)
+	mock.AssertExpectationsForObjects(t, rw)
+}
+
+func TestPersistToS3ChunkFails(t *testing.T) {
+	rw := new(s3.MockReadWriter)
+	cursor := &InMemoryUUIDCollection{collection: "collection", uuids: testUUIDs(3)}
+
+	rw.On("Write", "collection-uuids-chunks", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), manifestChunkContentType).Return(errors.New("oh no"))
+
+	_, err := persistInS3(rw, cursor, 1)
+	assert.Error(t, err)
+	mock.AssertExpectationsForObjects(t, rw)
+}
+
+func TestPersistToS3DeletesChunksWhenIndexFails(t *testing.T) {
+	rw := new(s3.MockReadWriter)
+	cursor := &InMemoryUUIDCollection{collection: "collection", uuids: testUUIDs(manifestChunkSize + 1)}
+
+	rw.On("Write", "collection-uuids-chunks", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), manifestChunkContentType).Return(nil).Twice()
+	rw.On("Write", "collection-uuids", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), "application/json").Return(errors.New("oh no"))
+	rw.On("Delete", mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "collection-uuids-chunks/") })).Return(nil).Twice()
 
-	err := persistInS3(rw, cursor)
+	_, err := persistInS3(rw, cursor, 1)
 	assert.Error(t, err)
 	mock.AssertExpectationsForObjects(t, rw)
 }
 
+func TestPersistToS3DeletesChunksWhenAChunkFails(t *testing.T) {
+	rw := newMemoryReadWriter()
+	failing := &failingChunkWriter{memoryReadWriter: rw, failAfter: 1}
+	cursor := &InMemoryUUIDCollection{collection: "collection", uuids: testUUIDs(manifestChunkSize*2 + 1)}
+
+	_, err := persistInS3(failing, cursor, 1)
+	assert.Error(t, err)
+	assert.Empty(t, rw.objects, "the chunk written before the failure should be deleted")
+}
+
+type failingChunkWriter struct {
+	*memoryReadWriter
+	failAfter int
+}
+
+func (f *failingChunkWriter) Write(id string, key string, b []byte, contentType string) error {
+	if f.failAfter == 0 {
+		return errors.New("oh no")
+	}
+	f.failAfter--
+	return f.memoryReadWriter.Write(id, key, b, contentType)
+}
+
+func TestReadChunkedManifestFromS3(t *testing.T) {
+	rw := newMemoryReadWriter()
+	expected := testUUIDs(manifestChunkSize*2 + 5)
+	key, err := persistInS3(rw, &InMemoryUUIDCollection{collection: "collection", uuids: expected}, 1)
+	assert.NoError(t, err)
+
+	uuids, start, latest, err := readFromS3(rw, "collection", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, key, latest)
+	assert.Equal(t, 0, start)
+	assert.Equal(t, expected, uuids)
+}
+
+func TestReadChunkedManifestFromS3FromOffset(t *testing.T) {
+	rw := newMemoryReadWriter()
+	expected := testUUIDs(manifestChunkSize*2 + 5)
+	key, err := persistInS3(rw, &InMemoryUUIDCollection{collection: "collection", uuids: expected}, 1)
+	assert.NoError(t, err)
+
+	firstChunk := rw.latest["collection-uuids-chunks"][:len(rw.latest["collection-uuids-chunks"])-len("000002.ndjson.gz")] + "000000.ndjson.gz"
+	delete(rw.objects, firstChunk) // only the chunks from the offset onwards should be read
+
+	uuids, start, err := readManifestFromS3(rw, key, manifestChunkSize+10)
+	assert.NoError(t, err)
+	assert.Equal(t, manifestChunkSize, start)
+	assert.Equal(t, expected[manifestChunkSize:], uuids)
+}
+
+func TestReadChunkedManifestFromS3FailsVerification(t *testing.T) {
+	rw := newMemoryReadWriter()
+	key, err := persistInS3(rw, &InMemoryUUIDCollection{collection: "collection", uuids: testUUIDs(5)}, 1)
+	assert.NoError(t, err)
+
+	tampered, _ := compress(ndjson([]string{"1", "2", "3", "4", "5"}))
+	rw.objects[rw.latest["collection-uuids-chunks"]] = tampered
+
+	_, _, err = readManifestFromS3(rw, key, 0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed checksum verification")
+}
+
 func TestReadFromS3(t *testing.T) {
 	rw := new(s3.MockReadWriter)
 	rw.On("GetLatestKeyForID", "collection-uuids").Return("key", nil)
@@ -42,9 +226,10 @@ func TestReadFromS3(t *testing.T) {
 
 	rw.On("Read", "key").Return(true, ioutil.NopCloser(strings.NewReader(`["a-uuid"]`)), &contentType, nil)
 
-	uuids, err := readFromS3(rw, "collection")
+	uuids, _, key, err := readFromS3(rw, "collection", 0)
 	assert.NotNil(t, uuids)
 	assert.NoError(t, err)
+	assert.Equal(t, "key", key)
 
 	assert.Len(t, uuids, 1)
 	assert.Equal(t, uuids[0], "a-uuid")
@@ -55,7 +240,7 @@ func TestReadFromS3NoPreviousSave(t *testing.T) {
 	rw := new(s3.MockReadWriter)
 	rw.On("GetLatestKeyForID", "collection-uuids").Return("", errors.New("nooo"))
 
-	uuids, err := readFromS3(rw, "collection")
+	uuids, _, _, err := readFromS3(rw, "collection", 0)
 	assert.Nil(t, uuids)
 	assert.EqualError(t, err, "nooo")
 	mock.AssertExpectationsForObjects(t, rw)
@@ -68,7 +253,7 @@ func TestReadFromS3ReadFails(t *testing.T) {
 	contentType := "application/json"
 	rw.On("Read", "key").Return(false, ioutil.NopCloser(strings.NewReader("[]]")), &contentType, errors.New("something failed"))
 
-	uuids, err := readFromS3(rw, "collection")
+	uuids, _, _, err := readFromS3(rw, "collection", 0)
 	assert.Nil(t, uuids)
 	assert.EqualError(t, err, "something failed")
 	mock.AssertExpectationsForObjects(t, rw)
@@ -81,7 +266,7 @@ func TestReadFromS3NotFound(t *testing.T) {
 	contentType := "application/json"
 	rw.On("Read", "key").Return(false, ioutil.NopCloser(strings.NewReader("[]]")), &contentType, nil)
 
-	uuids, err := readFromS3(rw, "collection")
+	uuids, _, _, err := readFromS3(rw, "collection", 0)
 	assert.Nil(t, uuids)
 	assert.EqualError(t, err, "Key not found, has it recently been deleted?")
 	mock.AssertExpectationsForObjects(t, rw)
@@ -94,7 +279,7 @@ func TestReadFromS3UnsupportMediaType(t *testing.T) {
 	contentType := "application/something-else"
 	rw.On("Read", "key").Return(true, ioutil.NopCloser(strings.NewReader("[]]")), &contentType, nil)
 
-	uuids, err := readFromS3(rw, "collection")
+	uuids, _, _, err := readFromS3(rw, "collection", 0)
 	assert.Nil(t, uuids)
 	assert.EqualError(t, err, "Unexpected or nil content type")
 	mock.AssertExpectationsForObjects(t, rw)
@@ -108,7 +293,7 @@ func TestReadFromS3NilMediaType(t *testing.T) {
 
 	rw.On("Read", "key").Return(true, ioutil.NopCloser(strings.NewReader("[]]")), contentType, nil)
 
-	uuids, err := readFromS3(rw, "collection")
+	uuids, _, _, err := readFromS3(rw, "collection", 0)
 	assert.Nil(t, uuids)
 	assert.EqualError(t, err, "Unexpected or nil content type")
 	mock.AssertExpectationsForObjects(t, rw)
@@ -122,9 +307,9 @@ func TestReadFromS3InvalidDataType(t *testing.T) {
 
 	rw.On("Read", "key").Return(true, ioutil.NopCloser(strings.NewReader("{}")), &contentType, nil)
 
-	uuids, err := readFromS3(rw, "collection")
+	uuids, _, _, err := readFromS3(rw, "collection", 0)
 	assert.Nil(t, uuids)
-	assert.EqualError(t, err, "json: cannot unmarshal object into Go value of type []string")
+	assert.EqualError(t, err, "Unsupported manifest version 0")
 	mock.AssertExpectationsForObjects(t, rw)
 }
 
@@ -141,9 +326,63 @@ func TestReadFromS3ClosesResponseBody(t *testing.T) {
 
 	rw.On("Read", "key").Return(true, body, &contentType, nil)
 
-	uuids, err := readFromS3(rw, "collection")
+	uuids, _, _, err := readFromS3(rw, "collection", 0)
 	assert.Nil(t, uuids)
-	assert.EqualError(t, err, "json: cannot unmarshal object into Go value of type []string")
+	assert.EqualError(t, err, "Unsupported manifest version 0")
 
 	mock.AssertExpectationsForObjects(t, rw, body)
 }
+
+func writeTestManifest(t *testing.T, rw *memoryReadWriter, name string, modified time.Time) (string, string) {
+	uuids := testUUIDs(3)
+	checksum, err := Hash(ndjson(uuids))
+	assert.NoError(t, err)
+
+	compressed, err := compress(ndjson(uuids))
+	assert.NoError(t, err)
+
+	chunk := manifestChunk{Key: "collection-uuids-chunks/" + name + "-000000.ndjson.gz", Count: len(uuids), Checksum: checksum}
+	index, err := json.Marshal(manifestIndex{Version: manifestVersion, Collection: "collection", Count: len(uuids), Checksum: checksum, Chunks: []manifestChunk{chunk}})
+	assert.NoError(t, err)
+
+	rw.Write("collection-uuids-chunks", name+"-000000.ndjson.gz", compressed, manifestChunkContentType)
+	rw.Write("collection-uuids", name+".json", index, "application/json")
+	rw.modified[chunk.Key] = modified
+	rw.modified["collection-uuids/"+name+".json"] = modified
+	return "collection-uuids/" + name + ".json", chunk.Key
+}
+
+func TestPruneManifestsInS3(t *testing.T) {
+	rw := newMemoryReadWriter()
+
+	var keys, chunks []string
+	for i := 0; i < 4; i++ {
+		key, chunk := writeTestManifest(t, rw, fmt.Sprintf("manifest-%v", i), time.Now().Add(time.Duration(i-4)*time.Hour))
+		keys = append(keys, key)
+		chunks = append(chunks, chunk)
+	}
+
+	pruned, err := pruneManifestsInS3(rw, "collection", s3.RetentionPolicy{KeepLast: 2}, map[string]bool{keys[1]: true}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+
+	assert.NotContains(t, rw.objects, keys[0])
+	assert.NotContains(t, rw.objects, chunks[0])
+	for _, key := range keys[1:] {
+		_, _, err := readManifestFromS3(rw, key, 0)
+		assert.NoError(t, err, "retained manifests should still be readable")
+	}
+}
+
+func TestPruneLegacyManifestsInS3(t *testing.T) {
+	rw := newMemoryReadWriter()
+	rw.Write("collection-uuids", "old", []byte(`["a-uuid"]`), "application/json")
+	rw.modified["collection-uuids/old"] = time.Now().Add(-48 * time.Hour)
+	rw.Write("collection-uuids", "new", []byte(`["a-uuid"]`), "application/json")
+
+	pruned, err := pruneManifestsInS3(rw, "collection", s3.RetentionPolicy{MaxAge: 24 * time.Hour}, nil, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+	assert.NotContains(t, rw.objects, "collection-uuids/old")
+	assert.Contains(t, rw.objects, "collection-uuids/new")
+}
@@ -0,0 +1,136 @@
+package native
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Financial-Times/publish-carousel/blacklist"
+	log "github.com/sirupsen/logrus"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const streamingPageSize = 1000
+
+// StreamingUUIDCollection reads uuids from mongo one page at a time, without holding a cursor open
+type StreamingUUIDCollection struct {
+	tx            TX
+	collection    string
+	length        int
+	pageSize      int
+	isBlacklisted blacklist.IsBlacklisted
+
+	iter      DBIter
+	pageCount int
+	lastID    string
+	finished  bool
+}
+
+func newStreamingUUIDCollection(tx TX, collection string, afterID string, length int, pageSize int, isBlacklisted blacklist.IsBlacklisted) *StreamingUUIDCollection {
+	return &StreamingUUIDCollection{
+		tx:            tx,
+		collection:    collection,
+		length:        length,
+		pageSize:      pageSize,
+		isBlacklisted: isBlacklisted,
+		lastID:        afterID,
+	}
+}
+
+// Next returns the next non-blacklisted uuid, querying mongo for the next page when needed
+func (s *StreamingUUIDCollection) Next() (bool, string, error) {
+	for {
+		if s.finished {
+			return true, "", nil
+		}
+
+		if s.iter == nil {
+			iter, err := s.tx.FindUUIDsAfterID(s.collection, s.lastID, s.pageSize)
+			if err != nil {
+				return true, "", err
+			}
+			s.iter = iter
+			s.pageCount = 0
+		}
+
+		result := map[string]interface{}{}
+		if !s.iter.Next(&result) {
+			if err := s.closePage(); err != nil {
+				return true, "", err
+			}
+
+			if s.pageCount < s.pageSize {
+				s.finished = true
+			}
+			continue
+		}
+
+		s.pageCount++
+
+		id, ok := result["_id"].(bson.ObjectId)
+		if !ok {
+			return true, "", fmt.Errorf(`Unsupported _id type "%T" in collection "%v", cannot page through collection`, result["_id"], s.collection)
+		}
+		s.lastID = id.Hex()
+
+		val, ok := result["uuid"]
+		if !ok {
+			continue // this document has no uuid
+		}
+
+		uuid := parseBinaryUUID(val)
+		if strings.TrimSpace(uuid) == "" {
+			continue
+		}
+
+		if blacklisted, err := s.isBlacklisted(uuid); err != nil || blacklisted {
+			log.WithField("uuid", uuid).WithField("collection", s.collection).Debug("Skipping blacklisted uuid.")
+			continue
+		}
+
+		return false, uuid, nil
+	}
+}
+
+func (s *StreamingUUIDCollection) closePage() error {
+	iter := s.iter
+	s.iter = nil
+
+	if iter.Timeout() {
+		iter.Close()
+		return errors.New("Mongo timeout detected")
+	}
+
+	if err := iter.Err(); err != nil {
+		iter.Close()
+		return err
+	}
+
+	return iter.Close()
+}
+
+// Position returns the _id of the last document read from the collection
+func (s *StreamingUUIDCollection) Position() *Position {
+	return &Position{ID: s.lastID}
+}
+
+// Length returns the number of documents with a uuid in the collection, as counted when the collection was opened
+func (s *StreamingUUIDCollection) Length() int {
+	return s.length
+}
+
+func (s *StreamingUUIDCollection) Done() bool {
+	return s.finished
+}
+
+func (s *StreamingUUIDCollection) Close() error {
+	defer s.tx.Close()
+
+	if s.iter != nil {
+		iter := s.iter
+		s.iter = nil
+		return iter.Close()
+	}
+	return nil
+}
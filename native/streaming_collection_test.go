@@ -0,0 +1,182 @@
+package native
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type testDoc struct {
+	id   bson.ObjectId
+	uuid string
+}
+
+type pageIter struct {
+	docs   []testDoc
+	closed bool
+}
+
+func (p *pageIter) Next(result interface{}) bool {
+	if len(p.docs) == 0 {
+		return false
+	}
+
+	m := *result.(*map[string]interface{})
+	m["_id"] = p.docs[0].id
+	if p.docs[0].uuid != "" {
+		m["uuid"] = bson.Binary{Kind: 0x04, Data: []byte(uuid.Parse(p.docs[0].uuid))}
+	}
+	p.docs = p.docs[1:]
+	return true
+}
+
+func (p *pageIter) Done() bool    { return len(p.docs) == 0 }
+func (p *pageIter) Err() error    { return nil }
+func (p *pageIter) Timeout() bool { return false }
+func (p *pageIter) Close() error {
+	p.closed = true
+	return nil
+}
+
+func mockPage(docs ...testDoc) *pageIter {
+	return &pageIter{docs: docs}
+}
+
+func TestStreamingUUIDCollectionPages(t *testing.T) {
+	docs := []testDoc{
+		{bson.NewObjectId(), uuid.New()},
+		{bson.NewObjectId(), uuid.New()},
+		{bson.NewObjectId(), ""},
+	}
+
+	tx := new(MockTX)
+	tx.On("FindUUIDsAfterID", "collection", "", 2).Return(mockPage(docs[0], docs[1]), nil)
+	tx.On("FindUUIDsAfterID", "collection", docs[1].id.Hex(), 2).Return(mockPage(docs[2]), nil)
+	tx.On("Close").Return()
+
+	c := newStreamingUUIDCollection(tx, "collection", "", 2, 2, noopBlacklist)
+	assert.Equal(t, 2, c.Length())
+
+	finished, val, err := c.Next()
+	assert.NoError(t, err)
+	assert.False(t, finished)
+	assert.Equal(t, docs[0].uuid, val)
+	assert.Equal(t, docs[0].id.Hex(), c.Position().ID)
+
+	finished, val, err = c.Next()
+	assert.NoError(t, err)
+	assert.False(t, finished)
+	assert.Equal(t, docs[1].uuid, val)
+	assert.Equal(t, docs[1].id.Hex(), c.Position().ID)
+
+	finished, _, err = c.Next()
+	assert.NoError(t, err)
+	assert.True(t, finished)
+	assert.True(t, c.Done())
+	assert.Equal(t, docs[2].id.Hex(), c.Position().ID)
+
+	assert.NoError(t, c.Close())
+	tx.AssertExpectations(t)
+}
+
+func TestStreamingUUIDCollectionResumesAfterID(t *testing.T) {
+	resumeFrom := bson.NewObjectId().Hex()
+	doc := testDoc{bson.NewObjectId(), uuid.New()}
+
+	tx := new(MockTX)
+	tx.On("FindUUIDsAfterID", "collection", resumeFrom, 10).Return(mockPage(doc), nil)
+
+	c := newStreamingUUIDCollection(tx, "collection", resumeFrom, 1, 10, noopBlacklist)
+
+	finished, val, err := c.Next()
+	assert.NoError(t, err)
+	assert.False(t, finished)
+	assert.Equal(t, doc.uuid, val)
+
+	finished, _, err = c.Next()
+	assert.NoError(t, err)
+	assert.True(t, finished)
+	tx.AssertExpectations(t)
+}
+
+func TestStreamingUUIDCollectionSkipsBlacklisted(t *testing.T) {
+	docs := []testDoc{
+		{bson.NewObjectId(), uuid.New()},
+		{bson.NewObjectId(), uuid.New()},
+	}
+
+	tx := new(MockTX)
+	tx.On("FindUUIDsAfterID", "collection", "", 10).Return(mockPage(docs...), nil)
+
+	c := newStreamingUUIDCollection(tx, "collection", "", 2, 10, func(uuid string) (bool, error) {
+		return uuid == docs[0].uuid, nil
+	})
+
+	finished, val, err := c.Next()
+	assert.NoError(t, err)
+	assert.False(t, finished)
+	assert.Equal(t, docs[1].uuid, val)
+}
+
+func TestStreamingUUIDCollectionQueryFails(t *testing.T) {
+	tx := new(MockTX)
+	tx.On("FindUUIDsAfterID", "collection", "", 10).Return(new(MockDBIter), errors.New("oh no"))
+
+	c := newStreamingUUIDCollection(tx, "collection", "", 2, 10, noopBlacklist)
+
+	finished, _, err := c.Next()
+	assert.True(t, finished)
+	assert.EqualError(t, err, "oh no")
+}
+
+func TestStreamingUUIDCollectionIterFails(t *testing.T) {
+	iter := new(MockDBIter)
+	iter.On("Next", mock.AnythingOfType("*map[string]interface {}")).Return(false)
+	iter.On("Timeout").Return(false)
+	iter.On("Err").Return(errors.New("cursor broke"))
+	iter.On("Close").Return(nil)
+
+	tx := new(MockTX)
+	tx.On("FindUUIDsAfterID", "collection", "", 10).Return(iter, nil)
+
+	c := newStreamingUUIDCollection(tx, "collection", "", 2, 10, noopBlacklist)
+
+	finished, _, err := c.Next()
+	assert.True(t, finished)
+	assert.EqualError(t, err, "cursor broke")
+	iter.AssertExpectations(t)
+}
+
+func TestNewStreamingUUIDCollection(t *testing.T) {
+	tx := new(MockTX)
+	tx.On("CountUUIDs", "collection").Return(42, nil)
+
+	db := new(MockDB)
+	db.On("Open").Return(tx, nil)
+
+	builder := NewNativeUUIDCollectionBuilder(db, nil, noopBlacklist)
+	c, err := builder.NewStreamingUUIDCollection("collection", &Position{ID: "58d2b5e9b3a6c4f1e0a1b2c3"})
+	assert.NoError(t, err)
+	assert.Equal(t, 42, c.Length())
+	assert.Equal(t, "58d2b5e9b3a6c4f1e0a1b2c3", c.(ResumableUUIDCollection).Position().ID)
+	mock.AssertExpectationsForObjects(t, db, tx)
+}
+
+func TestNewStreamingUUIDCollectionCountFails(t *testing.T) {
+	tx := new(MockTX)
+	tx.On("CountUUIDs", "collection").Return(0, errors.New("nope"))
+	tx.On("Close").Return()
+
+	db := new(MockDB)
+	db.On("Open").Return(tx, nil)
+
+	builder := NewNativeUUIDCollectionBuilder(db, nil, noopBlacklist)
+	_, err := builder.NewStreamingUUIDCollection("collection", nil)
+	assert.EqualError(t, err, "nope")
+	mock.AssertExpectationsForObjects(t, db, tx)
+}
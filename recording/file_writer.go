@@ -0,0 +1,78 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type fileWriter struct {
+	dir      string
+	maxBytes int64
+	lock     *sync.Mutex
+	file     *os.File
+	written  int64
+}
+
+// NewFileWriter returns a writer which appends records to JSONL files of up to maxBytes in the given directory
+func NewFileWriter(dir string, maxBytes int64) (Writer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileWriter{dir: dir, maxBytes: maxBytes, lock: &sync.Mutex{}}, nil
+}
+
+func (f *fileWriter) Write(record *Record) error {
+	line, err := marshalLine(record)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil || (f.written > 0 && f.written+int64(len(line)) > f.maxBytes) {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(line)
+	f.written += int64(n)
+	return err
+}
+
+func (f *fileWriter) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			log.WithError(err).WithField("file", f.file.Name()).Warn("Failed to close recording file.")
+		}
+	}
+
+	file, err := os.OpenFile(filepath.Join(f.dir, recordingName(time.Now())), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		f.file = nil
+		return err
+	}
+
+	log.WithField("file", file.Name()).Info("Recording publishes to a new file.")
+	f.file = file
+	f.written = 0
+	return nil
+}
+
+func (f *fileWriter) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,75 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readRecords(t *testing.T, path string) []*Record {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var records []*Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := &Record{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestFileWriterRotates(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "recordings")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	line, err := marshalLine(&Record{TransactionID: "tid_1"})
+	require.NoError(t, err)
+
+	writer, err := NewFileWriter(filepath.Join(dir, "nested"), int64(len(line)*2))
+	require.NoError(t, err)
+
+	for _, tid := range []string{"tid_1", "tid_2", "tid_3"} {
+		require.NoError(t, writer.Write(&Record{TransactionID: tid}))
+	}
+	require.NoError(t, writer.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "nested", "recording-*.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	first := readRecords(t, files[0])
+	require.Len(t, first, 2)
+	assert.Equal(t, "tid_1", first[0].TransactionID)
+	assert.Equal(t, "tid_2", first[1].TransactionID)
+
+	second := readRecords(t, files[1])
+	require.Len(t, second, 1)
+	assert.Equal(t, "tid_3", second[0].TransactionID)
+}
+
+func TestFileWriterWritesLargeRecords(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "recordings")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writer, err := NewFileWriter(dir, 1)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Write(&Record{TransactionID: "tid_1"}))
+	require.NoError(t, writer.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "recording-*.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, files, 1, "a record larger than the maximum size should still be written")
+	assert.Len(t, readRecords(t, files[0]), 1)
+}
@@ -0,0 +1,19 @@
+package recording
+
+import (
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWriter struct {
+	mock.Mock
+}
+
+func (m *MockWriter) Write(record *Record) error {
+	args := m.Called(record)
+	return args.Error(0)
+}
+
+func (m *MockWriter) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
@@ -0,0 +1,129 @@
+package recording
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/native"
+	log "github.com/sirupsen/logrus"
+)
+
+// Record is a single publish to a notifier, as written to the recording
+type Record struct {
+	Time          time.Time         `json:"time"`
+	Notifier      string            `json:"notifier"`
+	Cycle         string            `json:"cycle,omitempty"`
+	UUID          string            `json:"uuid,omitempty"`
+	TransactionID string            `json:"transactionId"`
+	Headers       map[string]string `json:"headers"`
+	Body          json.RawMessage   `json:"body"`
+	Success       bool              `json:"success"`
+	Status        int               `json:"status,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	LatencyMillis int64             `json:"latencyMs"`
+}
+
+// Writer saves recorded publishes. Writers must be safe to use from several notifiers at once.
+type Writer interface {
+	Write(record *Record) error
+	Close() error
+}
+
+type recordingNotifier struct {
+	cms.Notifier
+	name   string
+	writer Writer
+}
+
+// NewNotifier returns a notifier which records every publish to the given notifier
+func NewNotifier(name string, notifier cms.Notifier, writer Writer) cms.Notifier {
+	return &recordingNotifier{Notifier: notifier, name: name, writer: writer}
+}
+
+const uuidAttr = "uuid"
+
+func (r *recordingNotifier) Notify(ctx context.Context, origin string, tid string, content *native.Content, hash string) error {
+	start := time.Now()
+	status, err := r.notify(ctx, origin, tid, content, hash)
+
+	record, recordErr := newRecord(r.name, origin, tid, content, hash, status, err)
+	if recordErr == nil {
+		record.Time = start
+		record.LatencyMillis = time.Since(start).Nanoseconds() / int64(time.Millisecond)
+		recordErr = r.writer.Write(record)
+	}
+
+	if recordErr != nil {
+		log.WithField("notifier", r.name).WithField("transaction_id", tid).WithError(recordErr).Warn("Failed to record publish.")
+	}
+	return err
+}
+
+// notify publishes the content, returning the response status if the notifier reports it
+func (r *recordingNotifier) notify(ctx context.Context, origin string, tid string, content *native.Content, hash string) (int, error) {
+	if statusNotifier, ok := r.Notifier.(cms.StatusNotifier); ok {
+		return statusNotifier.NotifyWithStatus(ctx, origin, tid, content, hash)
+	}
+
+	err := r.Notifier.Notify(ctx, origin, tid, content, hash)
+
+	notifierErr := &cms.NotifierError{}
+	if errors.As(err, &notifierErr) {
+		return notifierErr.StatusCode, err
+	}
+	return 0, err
+}
+
+// Close closes the underlying notifier, if it needs to be closed
+func (r *recordingNotifier) Close() error {
+	if closer, ok := r.Notifier.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func newRecord(name string, origin string, tid string, content *native.Content, hash string, status int, err error) (*Record, error) {
+	body, marshalErr := json.Marshal(content.Body)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+
+	record := &Record{
+		Notifier:      name,
+		Cycle:         content.Cycle,
+		TransactionID: tid,
+		Headers:       cms.Headers(origin, tid, content, hash),
+		Body:          body,
+		Success:       err == nil,
+		Status:        status,
+	}
+
+	if uuid, ok := content.Body[uuidAttr].(string); ok {
+		record.UUID = uuid
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+		record.Class = cms.ErrorClass(err)
+	}
+	return record, nil
+}
+
+func marshalLine(record *Record) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// recordingName returns the name of a new recording file, which sorts by the time it was started
+func recordingName(t time.Time) string {
+	return fmt.Sprintf("recording-%v.jsonl", t.UTC().Format("20060102T150405.000000000"))
+}
@@ -0,0 +1,99 @@
+package recording
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testContent() *native.Content {
+	return &native.Content{
+		Body:           map[string]interface{}{"uuid": "a-uuid", "title": "A title"},
+		ContentType:    "application/json",
+		OriginSystemID: "methode-web-pub",
+		Cycle:          "methode-whole-archive",
+	}
+}
+
+func TestRecordsSuccessfulPublish(t *testing.T) {
+	notifier := new(cms.MockStatusNotifier)
+	notifier.On("NotifyWithStatus", mock.Anything, "origin", "tid_1234", mock.Anything, "hash").Return(202, nil)
+
+	var record *Record
+	writer := new(MockWriter)
+	writer.On("Write", mock.AnythingOfType("*recording.Record")).Run(func(args mock.Arguments) {
+		record = args.Get(0).(*Record)
+	}).Return(nil)
+
+	err := NewNotifier("cms", notifier, writer).Notify(context.Background(), "origin", "tid_1234", testContent(), "hash")
+	assert.NoError(t, err)
+
+	require.NotNil(t, record)
+	assert.Equal(t, "cms", record.Notifier)
+	assert.Equal(t, "methode-whole-archive", record.Cycle)
+	assert.Equal(t, "a-uuid", record.UUID)
+	assert.Equal(t, "tid_1234", record.TransactionID)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json", "X-Request-Id": "tid_1234", "X-Native-Hash": "hash", "X-Origin-System-Id": "methode-web-pub"}, record.Headers)
+	assert.JSONEq(t, `{"uuid":"a-uuid","title":"A title"}`, string(record.Body))
+	assert.True(t, record.Success)
+	assert.Equal(t, 202, record.Status)
+	assert.False(t, record.Time.IsZero())
+}
+
+func TestRecordsPublishWithoutStatus(t *testing.T) {
+	notifier := new(cms.MockNotifier)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", mock.Anything, "hash").Return(nil)
+
+	var record *Record
+	writer := new(MockWriter)
+	writer.On("Write", mock.AnythingOfType("*recording.Record")).Run(func(args mock.Arguments) {
+		record = args.Get(0).(*Record)
+	}).Return(nil)
+
+	err := NewNotifier("kafka", notifier, writer).Notify(context.Background(), "origin", "tid_1234", testContent(), "hash")
+	assert.NoError(t, err)
+
+	require.NotNil(t, record)
+	assert.True(t, record.Success)
+	assert.Zero(t, record.Status)
+}
+
+func TestRecordsFailedPublish(t *testing.T) {
+	failure := &cms.NotifierError{Class: cms.TransientError, StatusCode: 503}
+
+	notifier := new(cms.MockNotifier)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", mock.Anything, "hash").Return(failure)
+
+	var record *Record
+	writer := new(MockWriter)
+	writer.On("Write", mock.AnythingOfType("*recording.Record")).Run(func(args mock.Arguments) {
+		record = args.Get(0).(*Record)
+	}).Return(nil)
+
+	err := NewNotifier("cms", notifier, writer).Notify(context.Background(), "origin", "tid_1234", testContent(), "hash")
+	assert.Equal(t, error(failure), err)
+
+	require.NotNil(t, record)
+	assert.False(t, record.Success)
+	assert.Equal(t, 503, record.Status)
+	assert.Equal(t, cms.TransientError, record.Class)
+	assert.Equal(t, failure.Error(), record.Error)
+}
+
+func TestRecordingFailureDoesNotFailPublish(t *testing.T) {
+	notifier := new(cms.MockNotifier)
+	notifier.On("Notify", mock.Anything, "origin", "tid_1234", mock.Anything, "hash").Return(nil)
+
+	writer := new(MockWriter)
+	writer.On("Write", mock.Anything).Return(errors.New("disk full"))
+
+	err := NewNotifier("cms", notifier, writer).Notify(context.Background(), "origin", "tid_1234", testContent(), "hash")
+	assert.NoError(t, err)
+	writer.AssertExpectations(t)
+}
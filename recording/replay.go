@@ -0,0 +1,113 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/native"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// Filter selects the recorded publishes to replay. Empty fields match every record.
+type Filter struct {
+	UUIDs  []string
+	Cycles []string
+	From   time.Time
+	To     time.Time
+}
+
+// Matches returns whether the record has one of the uuids and cycles, and was published within the given times
+func (f Filter) Matches(record *Record) bool {
+	if len(f.UUIDs) > 0 && !contains(f.UUIDs, record.UUID) {
+		return false
+	}
+
+	if len(f.Cycles) > 0 && !contains(f.Cycles, record.Cycle) {
+		return false
+	}
+
+	if !f.From.IsZero() && record.Time.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && !record.Time.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplayStats counts the records read from a recording, and the outcome of replaying the matching records
+type ReplayStats struct {
+	Read     int
+	Replayed int
+	Failed   int
+}
+
+// Replay re-sends every matching record in the recording to the notifier, at the limiter's rate
+func Replay(ctx context.Context, recording io.Reader, notifier cms.Notifier, filter Filter, limiter *rate.Limiter) (ReplayStats, error) {
+	stats := ReplayStats{}
+	dec := json.NewDecoder(recording)
+
+	for {
+		record := &Record{}
+		err := dec.Decode(record)
+		if err == io.EOF {
+			return stats, nil
+		}
+
+		if err != nil {
+			return stats, fmt.Errorf("Invalid record %v in recording: %v", stats.Read+1, err)
+		}
+
+		stats.Read++
+		if !filter.Matches(record) {
+			continue
+		}
+
+		if err := limiter.Wait(ctx); err != nil {
+			return stats, err
+		}
+
+		content, err := record.content()
+		if err != nil {
+			return stats, fmt.Errorf("Invalid body for record %v in recording: %v", stats.Read, err)
+		}
+
+		err = notifier.Notify(ctx, content.OriginSystemID, record.TransactionID, content, record.Headers["X-Native-Hash"])
+		if err != nil {
+			log.WithField("transaction_id", record.TransactionID).WithField("uuid", record.UUID).WithError(err).Warn("Failed to replay publish.")
+			stats.Failed++
+			continue
+		}
+
+		log.WithField("transaction_id", record.TransactionID).WithField("uuid", record.UUID).Info("Replayed publish.")
+		stats.Replayed++
+	}
+}
+
+func (r *Record) content() (*native.Content, error) {
+	body := make(map[string]interface{})
+
+	dec := json.NewDecoder(bytes.NewReader(r.Body))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return &native.Content{Body: body, ContentType: r.Headers["Content-Type"], OriginSystemID: r.Headers["X-Origin-System-Id"], Cycle: r.Cycle}, nil
+}
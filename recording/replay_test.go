@@ -0,0 +1,109 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+var recordingStart = time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func testRecording(t *testing.T) *bytes.Buffer {
+	records := []*Record{
+		{Time: recordingStart, Cycle: "methode", UUID: "uuid-1", TransactionID: "tid_1", Headers: map[string]string{"Content-Type": "application/json", "X-Native-Hash": "hash-1", "X-Origin-System-Id": "methode-web-pub"}, Body: json.RawMessage(`{"uuid":"uuid-1","count":12345678901234567890}`)},
+		{Time: recordingStart.Add(time.Minute), Cycle: "wordpress", UUID: "uuid-2", TransactionID: "tid_2", Headers: map[string]string{"Content-Type": "application/json", "X-Native-Hash": "hash-2", "X-Origin-System-Id": "wordpress"}, Body: json.RawMessage(`{"uuid":"uuid-2"}`)},
+		{Time: recordingStart.Add(2 * time.Minute), Cycle: "methode", UUID: "uuid-3", TransactionID: "tid_3", Headers: map[string]string{"Content-Type": "application/json", "X-Native-Hash": "hash-3", "X-Origin-System-Id": "methode-web-pub"}, Body: json.RawMessage(`{"uuid":"uuid-3"}`)},
+	}
+
+	b := new(bytes.Buffer)
+	for _, record := range records {
+		line, err := marshalLine(record)
+		require.NoError(t, err)
+		b.Write(line)
+	}
+	return b
+}
+
+func TestReplay(t *testing.T) {
+	notifier := new(cms.MockNotifier)
+	notifier.On("Notify", mock.Anything, "methode-web-pub", "tid_1", mock.MatchedBy(func(content *native.Content) bool {
+		return content.ContentType == "application/json" && content.OriginSystemID == "methode-web-pub" && content.Body["count"] == json.Number("12345678901234567890")
+	}), "hash-1").Return(nil)
+	notifier.On("Notify", mock.Anything, "wordpress", "tid_2", mock.Anything, "hash-2").Return(nil)
+	notifier.On("Notify", mock.Anything, "methode-web-pub", "tid_3", mock.Anything, "hash-3").Return(&cms.NotifierError{Class: cms.PermanentError, StatusCode: 400})
+
+	stats, err := Replay(context.Background(), testRecording(t), notifier, Filter{}, rate.NewLimiter(rate.Inf, 1))
+	require.NoError(t, err)
+	assert.Equal(t, ReplayStats{Read: 3, Replayed: 2, Failed: 1}, stats)
+	notifier.AssertExpectations(t)
+}
+
+func TestReplayFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		tids   []string
+	}{
+		{name: "uuid", filter: Filter{UUIDs: []string{"uuid-2", "uuid-3"}}, tids: []string{"tid_2", "tid_3"}},
+		{name: "cycle", filter: Filter{Cycles: []string{"methode"}}, tids: []string{"tid_1", "tid_3"}},
+		{name: "from", filter: Filter{From: recordingStart.Add(time.Minute)}, tids: []string{"tid_2", "tid_3"}},
+		{name: "to", filter: Filter{To: recordingStart.Add(time.Minute)}, tids: []string{"tid_1"}},
+		{name: "combined", filter: Filter{Cycles: []string{"methode"}, From: recordingStart.Add(time.Second)}, tids: []string{"tid_3"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tids []string
+			notifier := new(cms.MockNotifier)
+			notifier.On("Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				tids = append(tids, args.String(2))
+			}).Return(nil)
+
+			stats, err := Replay(context.Background(), testRecording(t), notifier, test.filter, rate.NewLimiter(rate.Inf, 1))
+			require.NoError(t, err)
+			assert.Equal(t, test.tids, tids)
+			assert.Equal(t, 3, stats.Read)
+			assert.Equal(t, len(test.tids), stats.Replayed)
+		})
+	}
+}
+
+func TestReplayIsRateLimited(t *testing.T) {
+	notifier := new(cms.MockNotifier)
+	notifier.On("Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	start := time.Now()
+	_, err := Replay(context.Background(), testRecording(t), notifier, Filter{}, rate.NewLimiter(rate.Every(20*time.Millisecond), 1))
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) >= 40*time.Millisecond, "three publishes should take at least two intervals")
+}
+
+func TestReplayInvalidRecording(t *testing.T) {
+	recording := testRecording(t)
+	recording.WriteString("not json\n")
+
+	notifier := new(cms.MockNotifier)
+	notifier.On("Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	stats, err := Replay(context.Background(), recording, notifier, Filter{}, rate.NewLimiter(rate.Inf, 1))
+	assert.True(t, strings.HasPrefix(err.Error(), "Invalid record 4 in recording: "))
+	assert.Equal(t, 3, stats.Replayed)
+}
+
+func TestReplayIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Replay(ctx, testRecording(t), new(cms.MockNotifier), Filter{}, rate.NewLimiter(rate.Every(time.Hour), 1))
+	assert.Error(t, err)
+}
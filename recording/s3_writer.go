@@ -0,0 +1,93 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+const recordingContentType = "application/x-ndjson"
+
+type s3Writer struct {
+	rw       s3.ReadWriter
+	id       string
+	maxBytes int
+	lock     *sync.Mutex
+	buffer   *bytes.Buffer
+	started  time.Time
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+}
+
+// NewS3Writer returns a writer which buffers records, and saves them as JSONL objects in the folder for the given id
+func NewS3Writer(rw s3.ReadWriter, id string, maxBytes int, flushInterval time.Duration) Writer {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &s3Writer{rw: rw, id: id, maxBytes: maxBytes, lock: &sync.Mutex{}, buffer: new(bytes.Buffer), cancel: cancel, stopped: make(chan struct{})}
+
+	go w.flushEvery(ctx, flushInterval)
+	return w
+}
+
+func (w *s3Writer) Write(record *Record) error {
+	line, err := marshalLine(record)
+	if err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.buffer.Len() == 0 {
+		w.started = time.Now()
+	}
+
+	w.buffer.Write(line)
+	if w.buffer.Len() >= w.maxBytes {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *s3Writer) flushEvery(ctx context.Context, interval time.Duration) {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.lock.Lock()
+			if err := w.flush(); err != nil {
+				log.WithError(err).WithField("id", w.id).Warn("Failed to save recorded publishes.")
+			}
+			w.lock.Unlock()
+		}
+	}
+}
+
+// flush saves and empties the buffer. The lock must be held.
+func (w *s3Writer) flush() error {
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+
+	data := w.buffer.Bytes()
+	w.buffer = new(bytes.Buffer)
+	return w.rw.Write(w.id, recordingName(w.started), data, recordingContentType)
+}
+
+func (w *s3Writer) Close() error {
+	w.cancel()
+	<-w.stopped
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.flush()
+}
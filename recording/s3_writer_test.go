@@ -0,0 +1,68 @@
+package recording
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3WriterFlushesWhenFull(t *testing.T) {
+	line, err := marshalLine(&Record{TransactionID: "tid_1"})
+	require.NoError(t, err)
+
+	rw := new(s3.MockReadWriter)
+	rw.On("Write", "recordings", mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "recording-") && strings.HasSuffix(key, ".jsonl")
+	}), mock.MatchedBy(func(b []byte) bool {
+		return strings.Count(string(b), "\n") == 2
+	}), recordingContentType).Return(nil).Once()
+
+	writer := NewS3Writer(rw, "recordings", len(line)*2, time.Hour)
+
+	require.NoError(t, writer.Write(&Record{TransactionID: "tid_1"}))
+	rw.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	require.NoError(t, writer.Write(&Record{TransactionID: "tid_2"}))
+	rw.AssertExpectations(t)
+
+	require.NoError(t, writer.Close(), "the buffer is empty, so nothing should be written")
+	rw.AssertNumberOfCalls(t, "Write", 1)
+}
+
+func TestS3WriterFlushesOnClose(t *testing.T) {
+	rw := new(s3.MockReadWriter)
+	rw.On("Write", "recordings", mock.Anything, mock.MatchedBy(func(b []byte) bool {
+		return strings.Contains(string(b), `"transactionId":"tid_1"`)
+	}), recordingContentType).Return(nil).Once()
+
+	writer := NewS3Writer(rw, "recordings", 1<<20, time.Hour)
+
+	require.NoError(t, writer.Write(&Record{TransactionID: "tid_1"}))
+	require.NoError(t, writer.Close())
+	rw.AssertExpectations(t)
+}
+
+func TestS3WriterFlushesPeriodically(t *testing.T) {
+	flushed := make(chan struct{})
+
+	rw := new(s3.MockReadWriter)
+	rw.On("Write", "recordings", mock.Anything, mock.Anything, recordingContentType).Run(func(args mock.Arguments) {
+		close(flushed)
+	}).Return(nil).Once()
+
+	writer := NewS3Writer(rw, "recordings", 1<<20, 10*time.Millisecond)
+	require.NoError(t, writer.Write(&Record{TransactionID: "tid_1"}))
+
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		assert.Fail(t, "the buffer should have been flushed")
+	}
+
+	require.NoError(t, writer.Close())
+}
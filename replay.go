@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/recording"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func replayCommand() cli.Command {
+	return cli.Command{
+		Name:      "replay",
+		Usage:     "Re-send the publishes in recording files to a CMS Notifier.",
+		ArgsUsage: "<recording file>...",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:   "notifier-url",
+				Value:  "http://localhost:8080/__cms-notifier",
+				EnvVar: "CMS_NOTIFIER_URL",
+				Usage:  "The CMS Notifier instance to POST the recorded publishes to.",
+			},
+			cli.Float64Flag{
+				Name:  "rate",
+				Value: 1,
+				Usage: "The number of publishes to replay per second.",
+			},
+			cli.StringFlag{
+				Name:  "uuid",
+				Usage: "Comma separated list of uuids to replay. All uuids are replayed if this is empty.",
+			},
+			cli.StringFlag{
+				Name:  "cycle",
+				Usage: "Comma separated list of cycles to replay the publishes of. All cycles are replayed if this is empty.",
+			},
+			cli.StringFlag{
+				Name:  "from",
+				Usage: "Only replay publishes recorded at or after this RFC3339 time.",
+			},
+			cli.StringFlag{
+				Name:  "to",
+				Usage: "Only replay publishes recorded before this RFC3339 time.",
+			},
+		},
+		Action: replay,
+	}
+}
+
+func replay(ctx *cli.Context) error {
+	if ctx.NArg() == 0 {
+		return cli.NewExitError("Please provide at least one recording file to replay", 1)
+	}
+
+	if ctx.Float64("rate") <= 0 {
+		return cli.NewExitError("The replay rate must be greater than zero", 1)
+	}
+
+	filter, err := parseReplayFilter(ctx)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	notifier, err := cms.NewNotifier(ctx.String("notifier-url"), &http.Client{Timeout: time.Second * 30})
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Error in CMS Notifier configuration: %v", err), 1)
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(ctx.Float64("rate")), 1)
+	for _, path := range ctx.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		stats, err := recording.Replay(context.Background(), file, notifier, filter, limiter)
+		file.Close()
+
+		log.WithField("file", path).WithField("read", stats.Read).WithField("replayed", stats.Replayed).WithField("failed", stats.Failed).Info("Finished replaying recording.")
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("Failed to replay %v: %v", path, err), 1)
+		}
+	}
+	return nil
+}
+
+func parseReplayFilter(ctx *cli.Context) (recording.Filter, error) {
+	filter := recording.Filter{UUIDs: splitList(ctx.String("uuid")), Cycles: splitList(ctx.String("cycle"))}
+
+	var err error
+	if ctx.String("from") != "" {
+		if filter.From, err = time.Parse(time.RFC3339, ctx.String("from")); err != nil {
+			return filter, fmt.Errorf("Invalid from time %v, please use RFC3339", ctx.String("from"))
+		}
+	}
+
+	if ctx.String("to") != "" {
+		if filter.To, err = time.Parse(time.RFC3339, ctx.String("to")); err != nil {
+			return filter, fmt.Errorf("Invalid to time %v, please use RFC3339", ctx.String("to"))
+		}
+	}
+	return filter, nil
+}
+
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
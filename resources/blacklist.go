@@ -0,0 +1,83 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Financial-Times/publish-carousel/blacklist"
+	"github.com/husobee/vestigo"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetBlacklist returns every entry in the blacklist
+func GetBlacklist(blist blacklist.Blacklist) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		data, err := json.Marshal(blist.Entries())
+		if err != nil {
+			log.WithError(err).Info("Failed to marshal blacklist.")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Write(data)
+	}
+}
+
+// AddToBlacklist blacklists the uuid in the request body, with an optional reason and expiry
+func AddToBlacklist(blist blacklist.Blacklist) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var entry blacklist.Entry
+
+		dec := json.NewDecoder(r.Body)
+		err := dec.Decode(&entry)
+		if err != nil {
+			log.Warn("failed to decode body")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = blist.Add(entry)
+		if err != nil {
+			http.Error(w, err.Error(), blacklistErrorStatus(err))
+			return
+		}
+
+		log.WithField("uuid", entry.UUID).WithField("reason", entry.Reason).Info("Added uuid to the blacklist.")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// RemoveFromBlacklist removes the given uuid from the blacklist
+func RemoveFromBlacklist(blist blacklist.Blacklist) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := vestigo.Param(r, "uuid")
+
+		removed, err := blist.Remove(uuid)
+		if err != nil {
+			http.Error(w, err.Error(), blacklistErrorStatus(err))
+			return
+		}
+
+		if !removed {
+			http.Error(w, "UUID is not blacklisted", http.StatusNotFound)
+			return
+		}
+
+		log.WithField("uuid", uuid).Info("Removed uuid from the blacklist.")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func blacklistErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, blacklist.ErrInvalidEntry):
+		return http.StatusBadRequest
+	case errors.Is(err, blacklist.ErrConcurrentModification):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,102 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/blacklist"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBlacklistRouter(blist blacklist.Blacklist, req *http.Request) *httptest.ResponseRecorder {
+	r := vestigo.NewRouter()
+	r.Get("/blacklist", GetBlacklist(blist))
+	r.Post("/blacklist", AddToBlacklist(blist))
+	r.Delete("/blacklist/:uuid", RemoveFromBlacklist(blist))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetBlacklist(t *testing.T) {
+	expires := time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)
+	blist := blacklist.NewIndexedBlacklist([]blacklist.Entry{
+		{UUID: "335a60b8-3092-11e0-9de3-00144feabdc0", Reason: "Legal request", Expires: &expires},
+		{UUID: "271f1e94-cd71-11df-9c82-00144feab49a"},
+	})
+
+	w := setupBlacklistRouter(blist, httptest.NewRequest("GET", "/blacklist", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `[{"uuid":"271f1e94-cd71-11df-9c82-00144feab49a"},{"uuid":"335a60b8-3092-11e0-9de3-00144feabdc0","reason":"Legal request","expires":"2017-01-02T00:00:00Z"}]`, w.Body.String())
+}
+
+func TestAddToBlacklist(t *testing.T) {
+	blist := blacklist.NewIndexedBlacklist(nil)
+
+	body := `{"uuid":"335A60B8-3092-11E0-9DE3-00144FEABDC0","reason":"Legal request","expires":"2117-01-02T00:00:00Z"}`
+	w := setupBlacklistRouter(blist, httptest.NewRequest("POST", "/blacklist", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	blacklisted, err := blist.IsBlacklisted("335a60b8-3092-11e0-9de3-00144feabdc0")
+	assert.NoError(t, err)
+	assert.True(t, blacklisted, "the change should take effect immediately")
+
+	entries := blist.Entries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Legal request", entries[0].Reason)
+}
+
+func TestAddInvalidEntryToBlacklist(t *testing.T) {
+	blist := blacklist.NewIndexedBlacklist(nil)
+
+	for _, body := range []string{`{"uuid":"not-a-uuid"}`, `{"uuid":"335a60b8-3092-11e0-9de3-00144feabdc0","reason":"two\nlines"}`, `not json`} {
+		w := setupBlacklistRouter(blist, httptest.NewRequest("POST", "/blacklist", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	assert.Empty(t, blist.Entries())
+}
+
+func TestAddToBlacklistStoreFails(t *testing.T) {
+	blist := new(blacklist.MockBlacklist)
+	blist.On("Add", blacklist.Entry{UUID: "335a60b8-3092-11e0-9de3-00144feabdc0"}).Return(blacklist.ErrConcurrentModification)
+
+	w := setupBlacklistRouter(blist, httptest.NewRequest("POST", "/blacklist", strings.NewReader(`{"uuid":"335a60b8-3092-11e0-9de3-00144feabdc0"}`)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	blist = new(blacklist.MockBlacklist)
+	blist.On("Add", blacklist.Entry{UUID: "335a60b8-3092-11e0-9de3-00144feabdc0"}).Return(fmt.Errorf("oh no"))
+
+	w = setupBlacklistRouter(blist, httptest.NewRequest("POST", "/blacklist", strings.NewReader(`{"uuid":"335a60b8-3092-11e0-9de3-00144feabdc0"}`)))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	blist.AssertExpectations(t)
+}
+
+func TestRemoveFromBlacklist(t *testing.T) {
+	blist := blacklist.NewIndexedBlacklist([]blacklist.Entry{{UUID: "335a60b8-3092-11e0-9de3-00144feabdc0"}})
+
+	w := setupBlacklistRouter(blist, httptest.NewRequest("DELETE", "/blacklist/335a60b8-3092-11e0-9de3-00144feabdc0", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	blacklisted, err := blist.IsBlacklisted("335a60b8-3092-11e0-9de3-00144feabdc0")
+	assert.NoError(t, err)
+	assert.False(t, blacklisted, "the change should take effect immediately")
+
+	w = setupBlacklistRouter(blist, httptest.NewRequest("DELETE", "/blacklist/335a60b8-3092-11e0-9de3-00144feabdc0", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var entries []blacklist.Entry
+	w = setupBlacklistRouter(blist, httptest.NewRequest("GET", "/blacklist", nil))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Empty(t, entries)
+}
@@ -2,6 +2,7 @@ package resources
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -33,6 +34,33 @@ func GetCycles(sched scheduler.Scheduler) func(w http.ResponseWriter, r *http.Re
 	}
 }
 
+type cycleType struct {
+	Type         string                  `json:"type"`
+	Checkpointed bool                    `json:"checkpointed"`
+	Fields       []scheduler.ConfigField `json:"fields"`
+}
+
+// GetCycleTypes lists the registered cycle types, and the configuration fields which are specific to each of them
+func GetCycleTypes() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		types := make([]cycleType, 0)
+		for _, factory := range scheduler.CycleFactories() {
+			types = append(types, cycleType{Type: factory.Type(), Checkpointed: factory.Checkpointed(), Fields: factory.Schema()})
+		}
+
+		data, err := json.Marshal(types)
+		if err != nil {
+			log.WithError(err).Warn("Error in marshalling cycle types")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Write(data)
+	}
+}
+
 // GetCycleForID returns the individual cycle
 func GetCycleForID(sched scheduler.Scheduler) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -158,31 +186,35 @@ func GetCycleThrottle(sched scheduler.Scheduler) func(w http.ResponseWriter, r *
 			return
 		}
 
-		switch cycle.Type() {
-		case scheduler.ThrottledWholeCollectionType:
-			throttledCycle, ok := cycle.(*scheduler.ThrottledWholeCollectionCycle)
-			if !ok {
-				log.WithError(err).Info("Failed to cast cycle.")
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			data, err := json.Marshal(throttledCycle.Throttle)
-			if err != nil {
-				log.WithError(err).Info("Failed to marshal cycle throttle.")
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			w.Write(data)
-
-		default:
+		_, throttle, ok := cycleThrottle(cycle)
+		if !ok {
 			log.WithField("cycleID", cycle.ID()).Info("cycle is not throttled")
 			http.Error(w, fmt.Sprintf("Cycle is not throttled: %v", cycle.ID()), http.StatusNotFound)
+			return
 		}
+
+		data, err := json.Marshal(throttle)
+		if err != nil {
+			log.WithError(err).Info("Failed to marshal cycle throttle.")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Write(data)
 	}
 }
 
+// cycleThrottle returns the factory of the cycle's type, and the fixed throttle of the cycle, if its type has one
+func cycleThrottle(cycle scheduler.Cycle) (scheduler.CycleFactory, scheduler.Throttle, bool) {
+	factory, ok := scheduler.LookupCycleFactory(cycle.Type())
+	if !ok {
+		return nil, nil, false
+	}
+
+	throttle, ok := factory.Throttle(cycle)
+	return factory, throttle, ok
+}
+
 // Set a cycle throttle
 func SetCycleThrottle(sched scheduler.Scheduler) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -193,7 +225,7 @@ func SetCycleThrottle(sched scheduler.Scheduler) func(w http.ResponseWriter, r *
 		}
 
 		cycleID := cycle.ID()
-		throttledCycle, ok := cycle.(*scheduler.ThrottledWholeCollectionCycle)
+		factory, _, ok := cycleThrottle(cycle)
 		if !ok {
 			log.WithField("cycleID", cycleID).Info("cycle is not throttled")
 			http.Error(w, fmt.Sprintf("Cycle is not throttled: %v", cycleID), http.StatusBadRequest)
@@ -215,8 +247,7 @@ func SetCycleThrottle(sched scheduler.Scheduler) func(w http.ResponseWriter, r *
 
 		sched.DeleteCycle(cycleID)
 
-		config := throttledCycle.TransformToConfig()
-		config.Throttle = newThrottle.Interval().String()
+		config := factory.WithThrottle(cycle.TransformToConfig(), newThrottle.Interval())
 
 		newCycle, err := createCycle(sched, config, &metadata)
 		if err != nil {
@@ -228,6 +259,66 @@ func SetCycleThrottle(sched scheduler.Scheduler) func(w http.ResponseWriter, r *
 	}
 }
 
+// GetCycleCheckpoints lists the saved checkpoints for the given cycle, from the most recent to the oldest
+func GetCycleCheckpoints(sched scheduler.Scheduler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		cycle, err := findCycle(sched, w, r)
+		if err != nil {
+			return
+		}
+
+		checkpoints, err := sched.Checkpoints(cycle.ID())
+		if err != nil {
+			log.WithError(err).WithField("cycleID", cycle.ID()).Warn("Failed to list cycle checkpoints.")
+			http.Error(w, err.Error(), checkpointErrorStatus(err))
+			return
+		}
+
+		data, err := json.Marshal(checkpoints)
+		if err != nil {
+			log.WithError(err).Info("Failed to marshal cycle checkpoints.")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Write(data)
+	}
+}
+
+// RestoreCycleCheckpoint rolls the given cycle back to one of its saved checkpoints
+func RestoreCycleCheckpoint(sched scheduler.Scheduler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cycle, err := findCycle(sched, w, r)
+		if err != nil {
+			return
+		}
+
+		key := vestigo.Param(r, "key")
+		err = sched.RestoreCheckpoint(cycle.ID(), key)
+		if err != nil {
+			log.WithError(err).WithField("cycleID", cycle.ID()).WithField("checkpoint", key).Warn("Failed to restore cycle checkpoint.")
+			http.Error(w, err.Error(), checkpointErrorStatus(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func checkpointErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, scheduler.ErrCheckpointNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, scheduler.ErrCheckpointHistoryUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, scheduler.ErrCheckpointIncompatible):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func findCycle(sched scheduler.Scheduler, w http.ResponseWriter, r *http.Request) (scheduler.Cycle, error) {
 	cycles := sched.Cycles()
 	cycleID := vestigo.Param(r, "id")
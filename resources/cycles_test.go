@@ -219,6 +219,31 @@ func TestStopCycleNotFound(t *testing.T) {
 	sched.AssertExpectations(t)
 }
 
+func TestGetCycleTypes(t *testing.T) {
+	req := httptest.NewRequest("GET", "/cycle-types", nil)
+	w := setupRouter(new(scheduler.MockScheduler), req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"type":"ScalingWindow","checkpointed":false,"fields":[{"name":"timeWindow","type":"duration","required":true},{"name":"minimumThrottle","type":"duration","required":true},{"name":"maximumThrottle","type":"duration","required":true}]},
+		{"type":"ThrottledWholeCollection","checkpointed":true,"fields":[{"name":"throttle","type":"duration","required":false},{"name":"streaming","type":"boolean","required":false}]}
+	]`, w.Body.String())
+}
+
+func TestGetScalingWindowCycleThrottle(t *testing.T) {
+	sched := new(scheduler.MockScheduler)
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(nil, nil, blacklist.NoOpBlacklist)
+
+	cycle := scheduler.NewScalingWindowCycle("test-cycle", uuidCollectionBuilder, "test-collection", "methode", time.Hour, time.Minute, time.Second, time.Minute, nil)
+	sched.On("Cycles").Return(map[string]scheduler.Cycle{"123": cycle})
+
+	req := httptest.NewRequest("GET", "/cycles/123/throttle", nil)
+	w := setupRouter(sched, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "Cycle is not throttled: "+cycle.ID()+"\n", w.Body.String())
+}
+
 func TestGetCycleThrottle(t *testing.T) {
 	sched := new(scheduler.MockScheduler)
 	cycles := make(map[string]scheduler.Cycle)
@@ -363,3 +388,107 @@ func TestSetCycleThrottleRedirectUsesOriginalRequestURL(t *testing.T) {
 	assert.Equal(t, "https://www.example.com/__test/"+fmt.Sprintf("/cycles/%s", cycleID), w.Header().Get("Location"), "Location header")
 	assert.Equal(t, newCycle.Metadata(), metadata)
 }
+
+func TestGetCycleCheckpoints(t *testing.T) {
+	sched := new(scheduler.MockScheduler)
+	cycle := new(scheduler.MockCycle)
+
+	cycles := make(map[string]scheduler.Cycle)
+	cycles["hello"] = cycle
+
+	saved := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	sched.On("Cycles").Return(cycles)
+	sched.On("Checkpoints", "hello").Return([]scheduler.Checkpoint{{Key: "20170102T03040599", Saved: saved}}, nil)
+	cycle.On("ID").Return("hello")
+
+	req := httptest.NewRequest("GET", "/cycles/hello/checkpoints", nil)
+	w := setupRouter(sched, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"key":"20170102T03040599","saved":"2017-01-02T03:04:05Z"}]`, w.Body.String())
+	sched.AssertExpectations(t)
+}
+
+func TestGetCycleCheckpointsUnsupported(t *testing.T) {
+	sched := new(scheduler.MockScheduler)
+	cycle := new(scheduler.MockCycle)
+
+	cycles := make(map[string]scheduler.Cycle)
+	cycles["hello"] = cycle
+
+	sched.On("Cycles").Return(cycles)
+	sched.On("Checkpoints", "hello").Return([]scheduler.Checkpoint{}, scheduler.ErrCheckpointHistoryUnsupported)
+	cycle.On("ID").Return("hello")
+
+	req := httptest.NewRequest("GET", "/cycles/hello/checkpoints", nil)
+	w := setupRouter(sched, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	sched.AssertExpectations(t)
+}
+
+func TestGetCycleCheckpointsCycleNotFound(t *testing.T) {
+	sched := new(scheduler.MockScheduler)
+	sched.On("Cycles").Return(make(map[string]scheduler.Cycle))
+
+	req := httptest.NewRequest("GET", "/cycles/hello/checkpoints", nil)
+	w := setupRouter(sched, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	sched.AssertExpectations(t)
+}
+
+func TestRestoreCycleCheckpoint(t *testing.T) {
+	sched := new(scheduler.MockScheduler)
+	cycle := new(scheduler.MockCycle)
+
+	cycles := make(map[string]scheduler.Cycle)
+	cycles["hello"] = cycle
+
+	sched.On("Cycles").Return(cycles)
+	sched.On("RestoreCheckpoint", "hello", "20170102T03040599").Return(nil)
+	cycle.On("ID").Return("hello")
+
+	req := httptest.NewRequest("POST", "/cycles/hello/checkpoints/20170102T03040599/restore", nil)
+	w := setupRouter(sched, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	sched.AssertExpectations(t)
+}
+
+func TestRestoreCycleCheckpointNotFound(t *testing.T) {
+	sched := new(scheduler.MockScheduler)
+	cycle := new(scheduler.MockCycle)
+
+	cycles := make(map[string]scheduler.Cycle)
+	cycles["hello"] = cycle
+
+	sched.On("Cycles").Return(cycles)
+	sched.On("RestoreCheckpoint", "hello", "missing").Return(scheduler.ErrCheckpointNotFound)
+	cycle.On("ID").Return("hello")
+
+	req := httptest.NewRequest("POST", "/cycles/hello/checkpoints/missing/restore", nil)
+	w := setupRouter(sched, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	sched.AssertExpectations(t)
+}
+
+func TestRestoreIncompatibleCycleCheckpoint(t *testing.T) {
+	sched := new(scheduler.MockScheduler)
+	cycle := new(scheduler.MockCycle)
+
+	cycles := make(map[string]scheduler.Cycle)
+	cycles["hello"] = cycle
+
+	sched.On("Cycles").Return(cycles)
+	sched.On("RestoreCheckpoint", "hello", "20170102T03040599").Return(fmt.Errorf("%w: collection changed from content to annotations", scheduler.ErrCheckpointIncompatible))
+	cycle.On("ID").Return("hello")
+
+	req := httptest.NewRequest("POST", "/cycles/hello/checkpoints/20170102T03040599/restore", nil)
+	w := setupRouter(sched, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "collection changed from content to annotations")
+	sched.AssertExpectations(t)
+}
@@ -6,24 +6,25 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"sync"
+	"sort"
+	"strings"
 	"time"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
-	"github.com/Financial-Times/publish-carousel/cluster"
+	"github.com/Financial-Times/publish-carousel/blacklist"
 	"github.com/Financial-Times/publish-carousel/cms"
 	"github.com/Financial-Times/publish-carousel/native"
 	"github.com/Financial-Times/publish-carousel/s3"
 	"github.com/Financial-Times/publish-carousel/scheduler"
+	"github.com/Financial-Times/publish-carousel/verify"
 	"github.com/Financial-Times/service-status-go/gtg"
-	log "github.com/sirupsen/logrus"
 )
 
 type HealthService struct {
 	healthCheck fthealth.HealthCheck
 }
 
-func NewHealthService(appSystemCode string, appName string, description string, db native.DB, s3Service s3.ReadWriter, notifier cms.Notifier, sched scheduler.Scheduler, configError error, upServices ...cluster.Service) *HealthService {
+func NewHealthService(appSystemCode string, appName string, description string, db native.DB, s3Service s3.ReadWriter, notifiers map[string]cms.Notifier, blist blacklist.Blacklist, verifier verify.Verifier, sched scheduler.Scheduler, configError error, clusterMonitor *scheduler.ClusterMonitor) *HealthService {
 	service := &HealthService{
 		healthCheck: fthealth.HealthCheck{
 			SystemCode:  appSystemCode,
@@ -31,7 +32,7 @@ func NewHealthService(appSystemCode string, appName string, description string,
 			Description: description,
 		},
 	}
-	service.healthCheck.Checks = service.getHealthchecks(db, s3Service, notifier, sched, configError, upServices...)
+	service.healthCheck.Checks = service.getHealthchecks(db, s3Service, notifiers, blist, verifier, sched, configError, clusterMonitor)
 	return service
 }
 
@@ -65,8 +66,8 @@ func (healthService *HealthService) GTG() gtg.Status {
 	return gtg.FailFastParallelCheck(checks)()
 }
 
-func (healthService *HealthService) getHealthchecks(db native.DB, s3Service s3.ReadWriter, notifier cms.Notifier, sched scheduler.Scheduler, configError error, upServices ...cluster.Service) []fthealth.Check {
-	return []fthealth.Check{
+func (healthService *HealthService) getHealthchecks(db native.DB, s3Service s3.ReadWriter, notifiers map[string]cms.Notifier, blist blacklist.Blacklist, verifier verify.Verifier, sched scheduler.Scheduler, configError error, clusterMonitor *scheduler.ClusterMonitor) []fthealth.Check {
+	checks := []fthealth.Check{
 		{
 			Name:             "CheckConnectivityToNativeDatabase",
 			BusinessImpact:   "No Business Impact.",
@@ -89,7 +90,23 @@ func (healthService *HealthService) getHealthchecks(db native.DB, s3Service s3.R
 			TechnicalSummary: "The CMS Notifier service is unhealthy. Carousel publishes may fail, and will not be retried until the next cycle. ",
 			Severity:         1,
 			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
-			Checker:          cmsNotifierGTG(notifier),
+			Checker:          cmsNotifierGTG(notifiers[cms.CMSNotifierName]),
+		},
+		{
+			Name:             "NotifierCircuitBreakers",
+			BusinessImpact:   "No Business Impact.",
+			TechnicalSummary: "The circuit breaker of at least one notifier is open, because too many publishes to it have failed. Cycles using the notifier will wait, rather than publishing, until it recovers.",
+			Severity:         2,
+			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
+			Checker:          circuitBreakersHealthcheck(notifiers),
+		},
+		{
+			Name:             "MissingPublishes",
+			BusinessImpact:   "No Business Impact.",
+			TechnicalSummary: "Too many of the recent publishes of at least one cycle have not reached the read environments with the carousel's transaction id. Content published by the cycle may be missing or out of date in delivery.",
+			Severity:         2,
+			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
+			Checker:          missingPublishesHealthcheck(verifier),
 		},
 		{
 			Name:             "UnhealthyCycles",
@@ -99,6 +116,38 @@ func (healthService *HealthService) getHealthchecks(db native.DB, s3Service s3.R
 			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
 			Checker:          unhealthyCycles(sched),
 		},
+		{
+			Name:             "StalledCycles",
+			BusinessImpact:   "No Business Impact.",
+			TechnicalSummary: "At least one of the Carousel cycles is running, but has not made progress for much longer than its throttle, i.e. because it is blocked on a call to MongoDB. Content will not be republished by the cycle until it recovers or is restarted.",
+			Severity:         2,
+			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
+			Checker:          stalledCycles(sched),
+		},
+		{
+			Name:             "SuspendedCycles",
+			BusinessImpact:   "No Business Impact.",
+			TechnicalSummary: "At least one of the Carousel cycles has suspended itself, because too many of its recent publishes failed. The cycle will resume after its cool-off, or when it is resumed through the API. The reason for the suspension should be investigated.",
+			Severity:         2,
+			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
+			Checker:          suspendedCycles(sched),
+		},
+		{
+			Name:             "IncompatibleCycleCheckpoints",
+			BusinessImpact:   "No Business Impact.",
+			TechnicalSummary: "The saved state of at least one cycle was incompatible with its current configuration, so the cycle was restarted from its initial state. This should be investigated.",
+			Severity:         2,
+			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
+			Checker:          abandonedCheckpoints(sched),
+		},
+		{
+			Name:             "FailedCycleCheckpoints",
+			BusinessImpact:   "No Business Impact.",
+			TechnicalSummary: "The state of at least one cycle could not be saved at the last checkpoint, i.e. because another instance of the Carousel is further ahead with the same cycle, or the state store is unavailable. The cycle will resume from an older position if the Carousel restarts. This should be investigated.",
+			Severity:         2,
+			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
+			Checker:          failedCheckpoints(sched),
+		},
 		{
 			Name:             "InvalidCycleConfiguration",
 			BusinessImpact:   "No Business Impact.",
@@ -107,13 +156,21 @@ func (healthService *HealthService) getHealthchecks(db native.DB, s3Service s3.R
 			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
 			Checker:          configHealthcheck(configError),
 		},
+		{
+			Name:             "BlacklistReload",
+			BusinessImpact:   "No Business Impact.",
+			TechnicalSummary: "The blacklist file has changed, but could not be reloaded. The previous blacklist is still in use, so recent changes to it have not been applied.",
+			Severity:         2,
+			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
+			Checker:          blacklistHealthcheck(blist),
+		},
 		{
 			Name:             "UnhealthyCluster",
 			BusinessImpact:   "No Business Impact.",
-			TechnicalSummary: `If the cluster is unhealthy, the Carousel scheduler will shutdown until the system has stabilised.`,
+			TechnicalSummary: `If the cluster is unhealthy, the Carousel scheduler will shutdown until the system has stabilised. The cluster is checked in the background, and this healthcheck reports the result of the last check.`,
 			Severity:         1,
 			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
-			Checker:          unhealthyClusters(sched, upServices...),
+			Checker:          unhealthyClusters(clusterMonitor),
 		},
 		{
 			Name:             "ActivePublishingCluster",
@@ -124,6 +181,41 @@ func (healthService *HealthService) getHealthchecks(db native.DB, s3Service s3.R
 			Checker:          clusterFailoverHealthcheck(sched),
 		},
 	}
+
+	return append(checks, notifierHealthchecks(notifiers)...)
+}
+
+// notifierHealthchecks checks every notifier other than the cms-notifier, which has its own check
+func notifierHealthchecks(notifiers map[string]cms.Notifier) []fthealth.Check {
+	var names []string
+	for name := range notifiers {
+		if name != cms.CMSNotifierName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var checks []fthealth.Check
+	for _, name := range names {
+		checks = append(checks, fthealth.Check{
+			Name:             fmt.Sprintf("Check%vNotifierHealth", camelCase(name)),
+			BusinessImpact:   "No Business Impact.",
+			TechnicalSummary: fmt.Sprintf(`The %v notifier is unhealthy. Publishes from cycles using the "%v" notifier may fail, and will not be retried until the next cycle.`, name, name),
+			Severity:         1,
+			PanicGuide:       "https://runbooks.in.ft.com/publish-carousel",
+			Checker:          cmsNotifierGTG(notifiers[name]),
+		})
+	}
+	return checks
+}
+
+// camelCase turns a notifier name such as cms-us into CmsUs, for use in a healthcheck name
+func camelCase(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == '.' || r == ' ' })
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, "")
 }
 
 func pingMongo(db native.DB) func() (string, error) {
@@ -177,85 +269,153 @@ func unhealthyCycles(sched scheduler.Scheduler) func() (string, error) {
 	}
 }
 
-func cmsNotifierGTG(notifier cms.Notifier) func() (string, error) {
+func stalledCycles(sched scheduler.Scheduler) func() (string, error) {
 	return func() (string, error) {
-		err := notifier.Check()
-		if err != nil {
-			return "", err
+		stalled := make(map[string]*time.Time)
+		for _, cycle := range sched.Cycles() {
+			metadata := cycle.Metadata()
+			for _, state := range metadata.State {
+				if state == "stalled" {
+					stalled[cycle.ID()] = metadata.LastProgress
+				}
+			}
 		}
 
-		return "OK", nil
+		if len(stalled) > 0 {
+			return "", errors.New("The following cycles have stalled since their last progress! " + toJSON(stalled))
+		}
+
+		return "No stalled cycles.", nil
 	}
 }
 
-func toJSON(data interface{}) string {
-	b, _ := json.Marshal(data)
-	return string(b)
+func suspendedCycles(sched scheduler.Scheduler) func() (string, error) {
+	return func() (string, error) {
+		suspended := make(map[string]string)
+		for _, cycle := range sched.Cycles() {
+			metadata := cycle.Metadata()
+			for _, state := range metadata.State {
+				if state == "suspended" && metadata.Suspension != nil {
+					suspended[cycle.ID()] = metadata.Suspension.Reason
+				}
+			}
+		}
+
+		if len(suspended) > 0 {
+			return "", errors.New("The following cycles are suspended! " + toJSON(suspended))
+		}
+
+		return "No suspended cycles.", nil
+	}
 }
 
-type checkResult struct {
-	serviceName string
-	err         error
+func circuitBreakersHealthcheck(notifiers map[string]cms.Notifier) func() (string, error) {
+	return func() (string, error) {
+		notClosed := make(map[string]string)
+		for name, notifier := range notifiers {
+			if breaker, ok := notifier.(cms.Breaker); ok {
+				if state := breaker.State(); state != cms.BreakerClosed {
+					notClosed[name] = state
+				}
+			}
+		}
+
+		if len(notClosed) > 0 {
+			return "", errors.New("The circuit breakers for the following notifiers are not closed! " + toJSON(notClosed))
+		}
+
+		return "All circuit breakers are closed.", nil
+	}
 }
 
-func unhealthyClusters(sched scheduler.Scheduler, upServices ...cluster.Service) func() (string, error) {
+func missingPublishesHealthcheck(verifier verify.Verifier) func() (string, error) {
 	return func() (string, error) {
-		results := make(chan checkResult, 1)
+		if verifier == nil {
+			return "Publish verification is disabled.", nil
+		}
 
-		checkServicesGTG(upServices, results)
-		unhealthyServices, msg := getUnhealthyServices(len(upServices), results)
+		if err := verifier.Check(); err != nil {
+			return "", err
+		}
 
-		if len(*unhealthyServices) > 0 {
-			if sched.IsRunning() {
-				log.WithFields(log.Fields{"services": *unhealthyServices}).Info("Shutting down scheduler due to unhealthy cluster service(s)")
-				sched.Shutdown()
+		return "Recent publishes have reached the read environments. Missing rates: " + toJSON(verifier.MissingRates()), nil
+	}
+}
+
+func abandonedCheckpoints(sched scheduler.Scheduler) func() (string, error) {
+	return func() (string, error) {
+		abandoned := make(map[string]string)
+		for _, cycle := range sched.Cycles() {
+			if restore := cycle.Metadata().Restore; restore.Abandoned() {
+				abandoned[cycle.ID()] = restore.Reason
 			}
-			return fmt.Sprintf("One or more dependent services are unhealthy: %v", toJSON(*unhealthyServices)), errors.New(msg)
 		}
 
-		if !sched.IsRunning() && sched.IsEnabled() && !sched.WasAutomaticallyDisabled() {
-			log.Info("Cluster health back to normal; restarting scheduler.")
-			sched.Start()
+		if len(abandoned) > 0 {
+			return "", errors.New("The saved state of the following cycles was abandoned! " + toJSON(abandoned))
 		}
 
-		return "Cluster is healthy", nil
+		return "No abandoned cycle checkpoints.", nil
 	}
 }
 
-func checkServicesGTG(upServices []cluster.Service, results chan<- checkResult) {
-	for _, service := range upServices {
-		go func(svc cluster.Service) {
-			err := svc.Check()
-			if err != nil {
-				results <- checkResult{serviceName: svc.Name(), err: err}
-			} else {
-				results <- checkResult{}
-			}
-		}(service)
+func failedCheckpoints(sched scheduler.Scheduler) func() (string, error) {
+	return func() (string, error) {
+		failed := sched.FailedCheckpoints()
+		if len(failed) > 0 {
+			return "", errors.New("The state of the following cycles could not be saved! " + toJSON(failed))
+		}
+
+		return "No failed cycle checkpoints.", nil
 	}
 }
 
-func getUnhealthyServices(serviceCount int, results chan checkResult) (*[]string, string) {
-	var unhealthyServices []string
-	var errMsg string
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		var checkedServices int
-		for result := range results {
-			checkedServices++
-			if result.serviceName != "" {
-				unhealthyServices = append(unhealthyServices, result.serviceName)
-				errMsg += result.err.Error() + ". "
-			}
-			if checkedServices == serviceCount {
-				close(results)
+func blacklistHealthcheck(blist blacklist.Blacklist) func() (string, error) {
+	return func() (string, error) {
+		if err := blist.Check(); err != nil {
+			return "", err
+		}
+
+		stats := blist.Stats()
+		return fmt.Sprintf("Blacklist contains %v entries (%v expired), with %v hits since startup.", stats.Entries, stats.Expired, stats.Hits), nil
+	}
+}
+
+func cmsNotifierGTG(notifier cms.Notifier) func() (string, error) {
+	return func() (string, error) {
+		err := notifier.Check()
+		if err != nil {
+			return "", err
+		}
+
+		return "OK", nil
+	}
+}
+
+func toJSON(data interface{}) string {
+	b, _ := json.Marshal(data)
+	return string(b)
+}
+
+func unhealthyClusters(clusterMonitor *scheduler.ClusterMonitor) func() (string, error) {
+	return func() (string, error) {
+		status := clusterMonitor.Status()
+		if status.LastCheck.IsZero() {
+			return "Cluster has not been checked yet", nil
+		}
+
+		if !status.Healthy {
+			if len(status.UnhealthyServices) == 0 {
+				return "Cluster services are healthy again, waiting for the cluster to stabilise", fmt.Errorf("Cluster has been unhealthy since %v", status.Since.Format(time.RFC3339))
 			}
+			return fmt.Sprintf("One or more dependent services are unhealthy: %v", toJSON(status.UnhealthyServices)), errors.New(strings.Join(status.Errors, ". "))
 		}
-	}()
-	wg.Wait()
-	return &unhealthyServices, errMsg
+
+		if len(status.UnhealthyServices) > 0 {
+			return fmt.Sprintf("Cluster is healthy, although some services failed the last check: %v", toJSON(status.UnhealthyServices)), nil
+		}
+		return "Cluster is healthy", nil
+	}
 }
 
 func configHealthcheck(err error) func() (string, error) {
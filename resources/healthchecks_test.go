@@ -6,15 +6,19 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+	"github.com/Financial-Times/publish-carousel/blacklist"
 	"github.com/Financial-Times/publish-carousel/cluster"
 	"github.com/Financial-Times/publish-carousel/cms"
 	"github.com/Financial-Times/publish-carousel/native"
 	"github.com/Financial-Times/publish-carousel/s3"
 	"github.com/Financial-Times/publish-carousel/scheduler"
+	"github.com/Financial-Times/publish-carousel/verify"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/Financial-Times/service-status-go/httphandlers"
 )
 
@@ -38,7 +42,7 @@ func setupHappyMocks() map[string]interface{} {
 	}
 
 	sched.On("Cycles").Return(mockCycles)
-	sched.On("IsRunning").Return(true)
+	sched.On("FailedCheckpoints").Return(map[string]string{})
 	sched.On("IsEnabled").Return(true)
 	sched.On("IsAutomaticallyDisabled").Return(false)
 	sched.On("WasAutomaticallyDisabled").Return(false)
@@ -60,6 +64,10 @@ func setupHappyMocks() map[string]interface{} {
 	cmsNotifier := new(cms.MockNotifier)
 	cmsNotifier.On("Check").Return(nil)
 
+	blist := new(blacklist.MockBlacklist)
+	blist.On("Check").Return(nil)
+	blist.On("Stats").Return(blacklist.Stats{Entries: 2, Hits: 1})
+
 	mocks := map[string]interface{}{
 		"scheduler":   sched,
 		"cycle1":      c1,
@@ -70,26 +78,61 @@ func setupHappyMocks() map[string]interface{} {
 		"tx":          mockTx,
 		"s3RW":        s3RW,
 		"cmsNotifier": cmsNotifier,
+		"blacklist":   blist,
 	}
 	return mocks
 }
 
+func setupTestClusterMonitor(mocks map[string]interface{}) *scheduler.ClusterMonitor {
+	clusterMonitor := scheduler.NewClusterMonitor(mocks["scheduler"].(scheduler.Scheduler), 1, 1, 0, 0, time.Minute, mocks["service1"].(cluster.Service), mocks["service2"].(cluster.Service))
+	clusterMonitor.Check()
+	return clusterMonitor
+}
+
 func setupTestHealthcheckEndpoint(configError error) (func(w http.ResponseWriter, r *http.Request), map[string]interface{}) {
+	endpoint, mocks, _ := setupTestHealthcheckEndpointWithClusterMonitor(configError)
+	return endpoint, mocks
+}
+
+func setupTestHealthcheckEndpointWithClusterMonitor(configError error) (func(w http.ResponseWriter, r *http.Request), map[string]interface{}, *scheduler.ClusterMonitor) {
 	mocks := setupHappyMocks()
+	clusterMonitor := setupTestClusterMonitor(mocks)
 
 	healthService := NewHealthService(appSystemCode, appName, description,
-		mocks["db"].(native.DB), mocks["s3RW"].(s3.ReadWriter), mocks["cmsNotifier"].(cms.Notifier),
-			mocks["scheduler"].(scheduler.Scheduler), configError, mocks["service1"].(cluster.Service), mocks["service2"].(cluster.Service))
+		mocks["db"].(native.DB), mocks["s3RW"].(s3.ReadWriter), map[string]cms.Notifier{cms.CMSNotifierName: mocks["cmsNotifier"].(cms.Notifier)}, mocks["blacklist"].(blacklist.Blacklist), nil,
+		mocks["scheduler"].(scheduler.Scheduler), configError, clusterMonitor)
+
+	endpoint := healthService.Health()
+	return func(w http.ResponseWriter, r *http.Request) {
+		endpoint(w, r)
+		waitForMongoClose(mocks)
+	}, mocks, clusterMonitor
+}
+
+type quietT struct{}
+
+func (quietT) Logf(format string, args ...interface{})   {}
+func (quietT) Errorf(format string, args ...interface{}) {}
+func (quietT) FailNow()                                  {}
 
-	return healthService.Health(), mocks
+// waitForMongoClose waits for the mongo healthcheck to close its transaction, which it does in the background
+func waitForMongoClose(mocks map[string]interface{}) {
+	tx, ok := mocks["tx"].(*native.MockTX)
+	if !ok {
+		return
+	}
+
+	for i := 0; i < 100 && !tx.AssertCalled(quietT{}, "Close"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
 }
 
 func setupTestGTGEndpoint(configError error) (func(w http.ResponseWriter, r *http.Request), map[string]interface{}) {
 	mocks := setupHappyMocks()
 
 	healthService := NewHealthService(appSystemCode, appName, description,
-		mocks["db"].(native.DB), mocks["s3RW"].(s3.ReadWriter), mocks["cmsNotifier"].(cms.Notifier),
-		mocks["scheduler"].(scheduler.Scheduler), configError, mocks["service1"].(cluster.Service), mocks["service2"].(cluster.Service))
+		mocks["db"].(native.DB), mocks["s3RW"].(s3.ReadWriter), map[string]cms.Notifier{cms.CMSNotifierName: mocks["cmsNotifier"].(cms.Notifier)}, mocks["blacklist"].(blacklist.Blacklist), nil,
+		mocks["scheduler"].(scheduler.Scheduler), configError, setupTestClusterMonitor(mocks))
 
 	return httphandlers.NewGoodToGoHandler(healthService.GTG), mocks
 }
@@ -241,6 +284,64 @@ func TestUnhappyCMSNotifierHealthcheck(t *testing.T) {
 	}
 }
 
+func TestNotifierHealthchecks(t *testing.T) {
+	kafka := new(cms.MockNotifier)
+	kafka.On("Check").Return(errors.New("no writable partitions"))
+
+	shadow := new(cms.MockNotifier)
+	shadow.On("Check").Return(nil)
+
+	notifiers := map[string]cms.Notifier{cms.CMSNotifierName: new(cms.MockNotifier), cms.KafkaNotifierName: kafka, "cms-shadow": shadow}
+
+	checks := notifierHealthchecks(notifiers)
+	require.Len(t, checks, 2, "the cms-notifier has its own healthcheck")
+	assert.Equal(t, "CheckCmsShadowNotifierHealth", checks[0].Name)
+	assert.Equal(t, "CheckKafkaNotifierHealth", checks[1].Name)
+
+	_, err := checks[0].Checker()
+	assert.NoError(t, err)
+
+	_, err = checks[1].Checker()
+	assert.EqualError(t, err, "no writable partitions")
+	kafka.AssertExpectations(t)
+}
+
+func TestCircuitBreakersHealthcheck(t *testing.T) {
+	open := new(cms.MockBreaker)
+	open.On("State").Return(cms.BreakerOpen)
+
+	closed := new(cms.MockBreaker)
+	closed.On("State").Return(cms.BreakerClosed)
+
+	notifiers := map[string]cms.Notifier{cms.CMSNotifierName: closed, cms.KafkaNotifierName: new(cms.MockNotifier)}
+
+	msg, err := circuitBreakersHealthcheck(notifiers)()
+	assert.NoError(t, err)
+	assert.Equal(t, "All circuit breakers are closed.", msg)
+
+	notifiers["cms-shadow"] = open
+	_, err = circuitBreakersHealthcheck(notifiers)()
+	assert.EqualError(t, err, `The circuit breakers for the following notifiers are not closed! {"cms-shadow":"open"}`)
+}
+
+func TestMissingPublishesHealthcheck(t *testing.T) {
+	msg, err := missingPublishesHealthcheck(nil)()
+	assert.NoError(t, err)
+	assert.Equal(t, "Publish verification is disabled.", msg)
+
+	verifier := new(verify.MockVerifier)
+	verifier.On("Check").Return(nil).Once()
+	verifier.On("MissingRates").Return(map[string]float64{"methode": 0.02})
+
+	msg, err = missingPublishesHealthcheck(verifier)()
+	assert.NoError(t, err)
+	assert.Equal(t, `Recent publishes have reached the read environments. Missing rates: {"methode":0.02}`, msg)
+
+	verifier.On("Check").Return(errors.New("Too many recent publishes are missing"))
+	_, err = missingPublishesHealthcheck(verifier)()
+	assert.EqualError(t, err, "Too many recent publishes are missing")
+}
+
 func TestUnhappyCyclesHealthcheck(t *testing.T) {
 	endpoint, mocks := setupTestHealthcheckEndpoint(nil)
 	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
@@ -271,11 +372,17 @@ func TestUnhappyCyclesHealthcheck(t *testing.T) {
 	}
 }
 
-func TestUnhappyCycleConfigHealthcheck(t *testing.T) {
-	endpoint, mocks := setupTestHealthcheckEndpoint(errors.New("something wrong happened"))
+func TestAbandonedCheckpointsHealthcheck(t *testing.T) {
+	endpoint, mocks := setupTestHealthcheckEndpoint(nil)
 	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
 	w := httptest.NewRecorder()
 
+	c1 := mocks["cycle1"].(*scheduler.MockCycle)
+	c1.ExpectedCalls = make([]*mock.Call, 0)
+
+	c1.On("Metadata").Return(scheduler.CycleMetadata{State: []string{"stopped"}, Restore: &scheduler.RestoreDecision{Action: "abandoned", Reason: "collection changed from content to annotations"}})
+	c1.On("ID").Return("c1")
+
 	endpoint(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code, "Healthcheck should return 200")
@@ -283,8 +390,9 @@ func TestUnhappyCycleConfigHealthcheck(t *testing.T) {
 	assert.NoError(t, err)
 
 	for _, check := range checks {
-		if check.Name == "InvalidCycleConfiguration" {
+		if check.Name == "IncompatibleCycleCheckpoints" {
 			assert.False(t, check.Ok)
+			assert.Contains(t, check.CheckOutput, "collection changed from content to annotations")
 		} else {
 			assert.True(t, check.Ok)
 		}
@@ -293,22 +401,135 @@ func TestUnhappyCycleConfigHealthcheck(t *testing.T) {
 	for _, m := range mocks {
 		mock.AssertExpectationsForObjects(t, m)
 	}
+}
 
+func TestStalledCyclesHealthcheck(t *testing.T) {
+	endpoint, mocks := setupTestHealthcheckEndpoint(nil)
+	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
+	w := httptest.NewRecorder()
+
+	c1 := mocks["cycle1"].(*scheduler.MockCycle)
+	c1.ExpectedCalls = make([]*mock.Call, 0)
+
+	lastProgress := time.Date(2017, time.January, 2, 3, 4, 5, 0, time.UTC)
+	c1.On("Metadata").Return(scheduler.CycleMetadata{State: []string{"running", "stalled"}, LastProgress: &lastProgress})
+	c1.On("ID").Return("c1")
+
+	endpoint(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, "Healthcheck should return 200")
+	checks, err := parseHealthcheck(w.Body.String())
+	assert.NoError(t, err)
+
+	for _, check := range checks {
+		if check.Name == "StalledCycles" {
+			assert.False(t, check.Ok)
+			assert.Contains(t, check.CheckOutput, `{"c1":"2017-01-02T03:04:05Z"}`)
+		} else {
+			assert.True(t, check.Ok)
+		}
+	}
+
+	for _, m := range mocks {
+		mock.AssertExpectationsForObjects(t, m)
+	}
 }
 
-func TestUnhappyClusterHealthcheckWithSchedulerShutdown(t *testing.T) {
+func TestSuspendedCyclesHealthcheck(t *testing.T) {
 	endpoint, mocks := setupTestHealthcheckEndpoint(nil)
 	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
 	w := httptest.NewRecorder()
 
-	upService2 := mocks["service2"].(*cluster.MockService)
-	upService2.ExpectedCalls = make([]*mock.Call, 0)
+	c1 := mocks["cycle1"].(*scheduler.MockCycle)
+	c1.ExpectedCalls = make([]*mock.Call, 0)
 
-	upService2.On("Check").Return(errors.New("not good to go"))
-	upService2.On("Name").Return("An UPP service")
+	c1.On("Metadata").Return(scheduler.CycleMetadata{State: []string{"suspended"}, Suspension: &scheduler.SuspensionMetadata{Reason: "Unsupported content type (9 of the last 10 publishes failed)", ErrorRate: 0.9}})
+	c1.On("ID").Return("c1")
+
+	endpoint(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, "Healthcheck should return 200")
+	checks, err := parseHealthcheck(w.Body.String())
+	assert.NoError(t, err)
+
+	for _, check := range checks {
+		if check.Name == "SuspendedCycles" {
+			assert.False(t, check.Ok)
+			assert.Contains(t, check.CheckOutput, "Unsupported content type (9 of the last 10 publishes failed)")
+		} else {
+			assert.True(t, check.Ok)
+		}
+	}
+
+	for _, m := range mocks {
+		mock.AssertExpectationsForObjects(t, m)
+	}
+}
+
+func TestFailedCheckpointsHealthcheck(t *testing.T) {
+	endpoint, mocks := setupTestHealthcheckEndpoint(nil)
+	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
+	w := httptest.NewRecorder()
 
 	sched := mocks["scheduler"].(*scheduler.MockScheduler)
-	sched.On("Shutdown").Return(nil)
+	for _, call := range sched.ExpectedCalls {
+		if call.Method == "FailedCheckpoints" {
+			call.ReturnArguments = mock.Arguments{map[string]string{"c1": `State for "c1" has been modified by another instance`}}
+		}
+	}
+
+	endpoint(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, "Healthcheck should return 200")
+	checks, err := parseHealthcheck(w.Body.String())
+	assert.NoError(t, err)
+
+	for _, check := range checks {
+		if check.Name == "FailedCycleCheckpoints" {
+			assert.False(t, check.Ok)
+			assert.Contains(t, check.CheckOutput, "has been modified by another instance")
+		} else {
+			assert.True(t, check.Ok)
+		}
+	}
+
+	for _, m := range mocks {
+		mock.AssertExpectationsForObjects(t, m)
+	}
+}
+
+func TestBlacklistReloadHealthcheck(t *testing.T) {
+	endpoint, mocks := setupTestHealthcheckEndpoint(nil)
+	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
+	w := httptest.NewRecorder()
+
+	blist := mocks["blacklist"].(*blacklist.MockBlacklist)
+	blist.ExpectedCalls = make([]*mock.Call, 0)
+	blist.On("Check").Return(errors.New("Invalid uuid 335a60b8 on line 2 of the blacklist"))
+
+	endpoint(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, "Healthcheck should return 200")
+	checks, err := parseHealthcheck(w.Body.String())
+	assert.NoError(t, err)
+
+	for _, check := range checks {
+		if check.Name == "BlacklistReload" {
+			assert.False(t, check.Ok)
+		} else {
+			assert.True(t, check.Ok)
+		}
+	}
+
+	for _, m := range mocks {
+		mock.AssertExpectationsForObjects(t, m)
+	}
+}
+
+func TestUnhappyCycleConfigHealthcheck(t *testing.T) {
+	endpoint, mocks := setupTestHealthcheckEndpoint(errors.New("something wrong happened"))
+	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
+	w := httptest.NewRecorder()
 
 	endpoint(w, req)
 
@@ -317,7 +538,7 @@ func TestUnhappyClusterHealthcheckWithSchedulerShutdown(t *testing.T) {
 	assert.NoError(t, err)
 
 	for _, check := range checks {
-		if check.Name == "UnhealthyCluster" {
+		if check.Name == "InvalidCycleConfiguration" {
 			assert.False(t, check.Ok)
 		} else {
 			assert.True(t, check.Ok)
@@ -327,15 +548,59 @@ func TestUnhappyClusterHealthcheckWithSchedulerShutdown(t *testing.T) {
 	for _, m := range mocks {
 		mock.AssertExpectationsForObjects(t, m)
 	}
+
 }
 
-func TestSchedulerRestartWhenClusterReturnHealthy(t *testing.T) {
+func TestUnhappyClusterHealthcheckWithSchedulerShutdown(t *testing.T) {
+	endpoint, mocks, clusterMonitor := setupTestHealthcheckEndpointWithClusterMonitor(nil)
+
+	upService2 := mocks["service2"].(*cluster.MockService)
+	upService2.ExpectedCalls = make([]*mock.Call, 0)
+
+	upService2.On("Check").Return(errors.New("not good to go"))
+	upService2.On("Name").Return("An UPP service")
+
+	sched := mocks["scheduler"].(*scheduler.MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Shutdown").Return(nil).Once()
+
+	clusterMonitor.Check()
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "http://example.com/__health", nil)
+		w := httptest.NewRecorder()
+		endpoint(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, "Healthcheck should return 200")
+		checks, err := parseHealthcheck(w.Body.String())
+		assert.NoError(t, err)
+
+		for _, check := range checks {
+			if check.Name == "UnhealthyCluster" {
+				assert.False(t, check.Ok)
+				assert.Equal(t, "not good to go", check.CheckOutput)
+			} else {
+				assert.True(t, check.Ok)
+			}
+		}
+	}
+
+	for _, m := range mocks {
+		mock.AssertExpectationsForObjects(t, m)
+	}
+	sched.AssertNumberOfCalls(t, "Shutdown", 1)
+	// the services are only checked by the cluster monitor, never by the endpoint
+	upService2.AssertNumberOfCalls(t, "Check", 2)
+}
+
+func TestClusterHealthcheckDoesNotRestartScheduler(t *testing.T) {
 	endpoint, mocks := setupTestHealthcheckEndpoint(nil)
 	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
 	w := httptest.NewRecorder()
 
 	sched := mocks["scheduler"].(*scheduler.MockScheduler)
 	sched.ExpectedCalls = make([]*mock.Call, 0)
+	sched.On("FailedCheckpoints").Return(map[string]string{})
 
 	c1 := mocks["cycle1"].(*scheduler.MockCycle)
 	c1.ExpectedCalls = make([]*mock.Call, 0)
@@ -354,7 +619,6 @@ func TestSchedulerRestartWhenClusterReturnHealthy(t *testing.T) {
 		"c1": c1,
 		"c2": c2,
 	})
-	sched.On("Start").Return(nil).Once()
 
 	endpoint(w, req)
 
@@ -366,9 +630,16 @@ func TestSchedulerRestartWhenClusterReturnHealthy(t *testing.T) {
 		assert.True(t, check.Ok)
 	}
 
-	for _, m := range mocks {
-		mock.AssertExpectationsForObjects(t, m)
-	}
+	sched.AssertNotCalled(t, "Start")
+	mocks["service1"].(*cluster.MockService).AssertNumberOfCalls(t, "Check", 1)
+}
+
+func TestClusterHealthcheckBeforeFirstCheck(t *testing.T) {
+	check := unhealthyClusters(scheduler.NewClusterMonitor(nil, 1, 1, 0, 0, time.Minute))
+
+	msg, err := check()
+	assert.NoError(t, err)
+	assert.Equal(t, "Cluster has not been checked yet", msg)
 }
 
 func TestHappyHealthcheckIfManualToggleIsDisabled(t *testing.T) {
@@ -378,6 +649,7 @@ func TestHappyHealthcheckIfManualToggleIsDisabled(t *testing.T) {
 
 	sched := mocks["scheduler"].(*scheduler.MockScheduler)
 	sched.ExpectedCalls = make([]*mock.Call, 0)
+	sched.On("FailedCheckpoints").Return(map[string]string{})
 
 	c1 := mocks["cycle1"].(*scheduler.MockCycle)
 	c1.ExpectedCalls = make([]*mock.Call, 0)
@@ -388,7 +660,6 @@ func TestHappyHealthcheckIfManualToggleIsDisabled(t *testing.T) {
 	c1.On("Metadata").Return(scheduler.CycleMetadata{State: []string{"stopped"}})
 	c2.On("Metadata").Return(scheduler.CycleMetadata{State: []string{"stopped"}})
 
-	sched.On("IsRunning").Return(false)
 	sched.On("IsEnabled").Return(false)
 	sched.On("IsAutomaticallyDisabled").Return(false)
 	sched.On("WasAutomaticallyDisabled").Return(false)
@@ -418,6 +689,7 @@ func TestUnhappyHealthcheckBecauseOfCurrentFailover(t *testing.T) {
 
 	sched := mocks["scheduler"].(*scheduler.MockScheduler)
 	sched.ExpectedCalls = make([]*mock.Call, 0)
+	sched.On("FailedCheckpoints").Return(map[string]string{})
 
 	c1 := mocks["cycle1"].(*scheduler.MockCycle)
 	c1.ExpectedCalls = make([]*mock.Call, 0)
@@ -428,8 +700,6 @@ func TestUnhappyHealthcheckBecauseOfCurrentFailover(t *testing.T) {
 	c1.On("Metadata").Return(scheduler.CycleMetadata{State: []string{"stopped"}})
 	c2.On("Metadata").Return(scheduler.CycleMetadata{State: []string{"stopped"}})
 
-	sched.On("IsRunning").Return(false)
-	sched.On("IsEnabled").Return(false)
 	sched.On("IsAutomaticallyDisabled").Return(true)
 	sched.On("Cycles").Return(map[string]scheduler.Cycle{
 		"c1": c1,
@@ -461,6 +731,7 @@ func TestUnhappyHealthcheckBecauseOfNotRestartAfterFailback(t *testing.T) {
 
 	sched := mocks["scheduler"].(*scheduler.MockScheduler)
 	sched.ExpectedCalls = make([]*mock.Call, 0)
+	sched.On("FailedCheckpoints").Return(map[string]string{})
 
 	c1 := mocks["cycle1"].(*scheduler.MockCycle)
 	c1.ExpectedCalls = make([]*mock.Call, 0)
@@ -471,8 +742,6 @@ func TestUnhappyHealthcheckBecauseOfNotRestartAfterFailback(t *testing.T) {
 	c1.On("Metadata").Return(scheduler.CycleMetadata{State: []string{"stopped"}})
 	c2.On("Metadata").Return(scheduler.CycleMetadata{State: []string{"stopped"}})
 
-	sched.On("IsRunning").Return(false)
-	sched.On("IsEnabled").Return(false)
 	sched.On("IsAutomaticallyDisabled").Return(false)
 	sched.On("WasAutomaticallyDisabled").Return(true)
 	sched.On("Cycles").Return(map[string]scheduler.Cycle{
@@ -505,6 +774,7 @@ func TestSchedulerNotStartAfterFailoverByClusterHeatlhcheck(t *testing.T) {
 
 	sched := mocks["scheduler"].(*scheduler.MockScheduler)
 	sched.ExpectedCalls = make([]*mock.Call, 0)
+	sched.On("FailedCheckpoints").Return(map[string]string{})
 
 	c1 := mocks["cycle1"].(*scheduler.MockCycle)
 	c1.ExpectedCalls = make([]*mock.Call, 0)
@@ -515,8 +785,6 @@ func TestSchedulerNotStartAfterFailoverByClusterHeatlhcheck(t *testing.T) {
 	c1.On("Metadata").Return(scheduler.CycleMetadata{State: []string{"stopped"}})
 	c2.On("Metadata").Return(scheduler.CycleMetadata{State: []string{"stopped"}})
 
-	sched.On("IsRunning").Return(false)
-	sched.On("IsEnabled").Return(true)
 	sched.On("IsAutomaticallyDisabled").Return(false)
 	sched.On("WasAutomaticallyDisabled").Return(true)
 	sched.On("Cycles").Return(map[string]scheduler.Cycle{
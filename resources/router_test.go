@@ -13,6 +13,8 @@ func setupRouter(sched scheduler.Scheduler, req *http.Request) *httptest.Respons
 	r.Get("/cycles", GetCycles(sched))
 	r.Post("/cycles", CreateCycle(sched))
 
+	r.Get("/cycle-types", GetCycleTypes())
+
 	r.Get("/cycles/:id", GetCycleForID(sched))
 	r.Delete("/cycles/:id", DeleteCycle(sched))
 
@@ -25,6 +27,9 @@ func setupRouter(sched scheduler.Scheduler, req *http.Request) *httptest.Respons
 
 	r.Post("/cycles/:id/reset", ResetCycle(sched))
 
+	r.Get("/cycles/:id/checkpoints", GetCycleCheckpoints(sched))
+	r.Post("/cycles/:id/checkpoints/:key/restore", RestoreCycleCheckpoint(sched))
+
 	r.Post("/scheduler/start", StartScheduler(sched))
 
 	r.Post("/scheduler/shutdown", ShutdownScheduler(sched))
@@ -0,0 +1,294 @@
+package s3
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryObject struct {
+	body         []byte
+	contentType  *string
+	lastModified time.Time
+}
+
+// memoryS3PageSize matches the maximum number of keys S3 returns from a single ListObjects call
+const memoryS3PageSize = 1000
+
+// memoryS3API an in-memory fake of the subset of the S3 API used by the DefaultReadWriter
+type memoryS3API struct {
+	s3iface.S3API
+	lock    sync.Mutex
+	objects map[string]memoryObject
+}
+
+func newMemoryS3API() *memoryS3API {
+	return &memoryS3API{objects: make(map[string]memoryObject)}
+}
+
+func (m *memoryS3API) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	b, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.objects[*input.Key] = memoryObject{body: b, contentType: input.ContentType, lastModified: time.Now()}
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *memoryS3API) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	obj, ok := m.objects[*input.Key]
+	if !ok {
+		return nil, awserr.New("NoSuchKey", "The specified key does not exist.", nil)
+	}
+
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(obj.body)), ContentType: obj.contentType}, nil
+}
+
+func (m *memoryS3API) ListObjects(input *s3.ListObjectsInput) (*s3.ListObjectsOutput, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, *input.Prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if input.Marker != nil {
+		i := sort.SearchStrings(keys, *input.Marker)
+		if i < len(keys) && keys[i] == *input.Marker {
+			i++
+		}
+		keys = keys[i:]
+	}
+
+	output := &s3.ListObjectsOutput{IsTruncated: aws.Bool(len(keys) > memoryS3PageSize)}
+	if len(keys) > memoryS3PageSize {
+		keys = keys[:memoryS3PageSize]
+	}
+
+	for _, key := range keys {
+		output.Contents = append(output.Contents, &s3.Object{Key: aws.String(key), LastModified: aws.Time(m.objects[key].lastModified)})
+	}
+	return output, nil
+}
+
+func (m *memoryS3API) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.objects, *input.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestS3ReadWriterConformance(t *testing.T) {
+	testReadWriterConformance(t, func(t *testing.T) ReadWriter {
+		return &DefaultReadWriter{bucketName: "test", session: newMemoryS3API(), lock: &sync.Mutex{}}
+	})
+}
+
+func TestFileReadWriterConformance(t *testing.T) {
+	testReadWriterConformance(t, func(t *testing.T) ReadWriter {
+		dir, err := ioutil.TempDir("", "publish-carousel-state")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return NewFileReadWriter(dir)
+	})
+}
+
+// testReadWriterConformance checks the behaviour every ReadWriter implementation must share
+func testReadWriterConformance(t *testing.T, newReadWriter func(t *testing.T) ReadWriter) {
+	t.Run("Ping", func(t *testing.T) {
+		rw := newReadWriter(t)
+		assert.NoError(t, rw.Ping())
+	})
+
+	t.Run("WriteThenRead", func(t *testing.T) {
+		rw := newReadWriter(t)
+		require.NoError(t, rw.Write("fake-id", "fake-key", []byte(`hi`), "application/json"))
+
+		found, body, contentType, err := rw.Read("fake-id/fake-key")
+		require.NoError(t, err)
+		require.True(t, found)
+		defer body.Close()
+
+		actual, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "hi", string(actual))
+		require.NotNil(t, contentType)
+		assert.Equal(t, "application/json", *contentType)
+	})
+
+	t.Run("ReadMissingKey", func(t *testing.T) {
+		rw := newReadWriter(t)
+		found, body, contentType, err := rw.Read("fake-id/missing")
+		assert.NoError(t, err)
+		assert.False(t, found)
+		assert.Nil(t, body)
+		assert.Nil(t, contentType)
+	})
+
+	t.Run("WriteWithoutContentType", func(t *testing.T) {
+		rw := newReadWriter(t)
+		require.NoError(t, rw.Write("fake-id", "fake-key", []byte(`hi`), "application/json"))
+		require.NoError(t, rw.Write("fake-id", "fake-key", []byte(`hi again`), " "))
+
+		found, body, contentType, err := rw.Read("fake-id/fake-key")
+		require.NoError(t, err)
+		require.True(t, found)
+		defer body.Close()
+
+		actual, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "hi again", string(actual))
+		assert.Nil(t, contentType)
+	})
+
+	t.Run("OverwriteReplacesObject", func(t *testing.T) {
+		rw := newReadWriter(t)
+		require.NoError(t, rw.Write("fake-id", "fake-key", []byte(`a much longer first version`), "application/json"))
+		require.NoError(t, rw.Write("fake-id", "fake-key", []byte(`second`), "application/gzip"))
+
+		found, body, contentType, err := rw.Read("fake-id/fake-key")
+		require.NoError(t, err)
+		require.True(t, found)
+		defer body.Close()
+
+		actual, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "second", string(actual))
+		assert.Equal(t, "application/gzip", *contentType)
+	})
+
+	t.Run("NestedKeys", func(t *testing.T) {
+		rw := newReadWriter(t)
+		require.NoError(t, rw.Write("fake-id", "nested/fake-key", []byte(`hi`), "application/json"))
+
+		found, body, _, err := rw.Read("fake-id/nested/fake-key")
+		require.NoError(t, err)
+		require.True(t, found)
+		body.Close()
+
+		key, err := rw.GetLatestKeyForID("fake-id")
+		assert.NoError(t, err)
+		assert.Equal(t, "fake-id/nested/fake-key", key)
+	})
+
+	t.Run("LatestKeyForUnknownID", func(t *testing.T) {
+		rw := newReadWriter(t)
+		key, err := rw.GetLatestKeyForID("fake-id")
+		assert.NoError(t, err)
+		assert.Equal(t, "", key)
+	})
+
+	t.Run("LatestKeyIsMostRecentlyWritten", func(t *testing.T) {
+		rw := newReadWriter(t)
+		for _, key := range []string{"b-key", "c-key", "a-key"} {
+			require.NoError(t, rw.Write("fake-id", key, []byte(key), "application/json"))
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		key, err := rw.GetLatestKeyForID("fake-id")
+		assert.NoError(t, err)
+		assert.Equal(t, "fake-id/a-key", key)
+
+		require.NoError(t, rw.Write("fake-id", "b-key", []byte(`rewritten`), "application/json"))
+
+		key, err = rw.GetLatestKeyForID("fake-id")
+		assert.NoError(t, err)
+		assert.Equal(t, "fake-id/b-key", key)
+	})
+
+	t.Run("LatestKeyIsScopedToID", func(t *testing.T) {
+		rw := newReadWriter(t)
+		require.NoError(t, rw.Write("fake-id", "fake-key", []byte(`hi`), "application/json"))
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, rw.Write("fake-id2", "fake-key2", []byte(`hi`), "application/json"))
+
+		key, err := rw.GetLatestKeyForID("fake-id")
+		assert.NoError(t, err)
+		assert.Equal(t, "fake-id/fake-key", key)
+	})
+
+	t.Run("LatestKeyBeyondFirstPage", func(t *testing.T) {
+		rw := newReadWriter(t)
+		for i := 0; i < memoryS3PageSize+5; i++ {
+			require.NoError(t, rw.Write("fake-id", fmt.Sprintf("key-%04d", i), []byte(`hi`), "application/json"))
+		}
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, rw.Write("fake-id", "a-key", []byte(`hi`), "application/json"))
+
+		key, err := rw.GetLatestKeyForID("fake-id")
+		assert.NoError(t, err)
+		assert.Equal(t, "fake-id/a-key", key)
+
+		objects, err := rw.List("fake-id")
+		assert.NoError(t, err)
+		assert.Len(t, objects, memoryS3PageSize+6)
+	})
+
+	t.Run("ListIsOrderedByLastModified", func(t *testing.T) {
+		rw := newReadWriter(t)
+		for _, key := range []string{"b-key", "c-key", "a-key"} {
+			require.NoError(t, rw.Write("fake-id", key, []byte(key), "application/json"))
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.NoError(t, rw.Write("fake-id2", "fake-key", []byte(`hi`), "application/json"))
+
+		objects, err := rw.List("fake-id")
+		require.NoError(t, err)
+		require.Len(t, objects, 3)
+
+		var keys []string
+		for _, obj := range objects {
+			keys = append(keys, obj.Key)
+		}
+		assert.Equal(t, []string{"fake-id/b-key", "fake-id/c-key", "fake-id/a-key"}, keys)
+		assert.True(t, objects[0].LastModified.Before(objects[2].LastModified))
+	})
+
+	t.Run("ListUnknownID", func(t *testing.T) {
+		rw := newReadWriter(t)
+		objects, err := rw.List("fake-id")
+		assert.NoError(t, err)
+		assert.Empty(t, objects)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		rw := newReadWriter(t)
+		require.NoError(t, rw.Write("fake-id", "fake-key", []byte(`hi`), "application/json"))
+		require.NoError(t, rw.Delete("fake-id/fake-key"))
+
+		found, _, _, err := rw.Read("fake-id/fake-key")
+		assert.NoError(t, err)
+		assert.False(t, found)
+
+		key, err := rw.GetLatestKeyForID("fake-id")
+		assert.NoError(t, err)
+		assert.Equal(t, "", key)
+
+		assert.NoError(t, rw.Delete("fake-id/fake-key"), "deleting a missing key should not fail")
+	})
+}
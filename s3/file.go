@@ -0,0 +1,212 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const contentTypesDir = ".content-types"
+
+// FileReadWriter a ReadWriter which stores its objects on the local filesystem, using the same key layout as S3
+type FileReadWriter struct {
+	dir  string
+	lock *sync.RWMutex
+}
+
+// NewFileReadWriter create a new filesystem R/W which stores all objects under the given directory
+func NewFileReadWriter(dir string) ReadWriter {
+	return &FileReadWriter{dir: dir, lock: &sync.RWMutex{}}
+}
+
+// Ping checks whether the state directory exists (creating it if necessary) and is a directory
+func (f *FileReadWriter) Ping() error {
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(f.dir)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("State path %v is not a directory!", f.dir)
+	}
+	return nil
+}
+
+// Write writes the given ID and key to the filesystem, replacing any existing object atomically
+func (f *FileReadWriter) Write(id string, key string, b []byte, contentType string) error {
+	path, err := f.path(id + "/" + key)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := writeAtomically(path, b); err != nil {
+		log.WithError(err).WithField("path", path).Info("Failed to write object to the filesystem.")
+		return err
+	}
+
+	contentTypePath := f.contentTypePath(id + "/" + key)
+	if strings.TrimSpace(contentType) == "" {
+		err = os.Remove(contentTypePath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return writeAtomically(contentTypePath, []byte(contentType))
+}
+
+// GetLatestKeyForID finds the most recently modified object for the given ID
+func (f *FileReadWriter) GetLatestKeyForID(id string) (string, error) {
+	objects, err := f.List(id)
+	if err != nil {
+		return "", err
+	}
+
+	return latestKey(objects), nil
+}
+
+// List lists every object for the given ID, ordered from the oldest to the most recently modified
+func (f *FileReadWriter) List(id string) ([]Object, error) {
+	root, err := f.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	var objects []Object
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(f.dir, path)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, Object{Key: filepath.ToSlash(rel), LastModified: info.ModTime()})
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sortObjects(objects)
+	return objects, nil
+}
+
+// Delete deletes the provided key, if it exists
+func (f *FileReadWriter) Delete(key string) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, p := range []string{path, f.contentTypePath(key)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read reads the provided key and returns a reader etc.
+func (f *FileReadWriter) Read(key string) (bool, io.ReadCloser, *string, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return false, nil, nil, err
+	}
+
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	log.WithField("key", key).Info("Reading object from the filesystem.")
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil, nil, nil
+	}
+
+	if err != nil {
+		return false, nil, nil, err
+	}
+
+	b, err := ioutil.ReadFile(f.contentTypePath(key))
+	if os.IsNotExist(err) {
+		return true, file, nil, nil
+	}
+
+	if err != nil {
+		file.Close()
+		return false, nil, nil, err
+	}
+
+	contentType := string(b)
+	return true, file, &contentType, nil
+}
+
+func (f *FileReadWriter) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if strings.Trim(cleaned, "/") == "" || strings.HasPrefix(filepath.Base(cleaned), ".") {
+		return "", fmt.Errorf("Invalid key %v", key)
+	}
+	return filepath.Join(f.dir, cleaned), nil
+}
+
+func (f *FileReadWriter) contentTypePath(key string) string {
+	return filepath.Join(f.dir, contentTypesDir, filepath.Clean("/"+key))
+}
+
+func writeAtomically(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return errors.New("Failed to move object into place: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package s3
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileReadWriterRejectsInvalidKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "publish-carousel-state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rw := NewFileReadWriter(filepath.Join(dir, "state"))
+
+	err = rw.Write("fake-id", ".hidden", []byte(`hi`), "application/json")
+	assert.Error(t, err)
+
+	err = rw.Write("..", "..", []byte(`hi`), "application/json")
+	assert.Error(t, err)
+
+	err = rw.Write("../../fake-id", "fake-key", []byte(`hi`), "application/json")
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "state", "fake-id", "fake-key"))
+	assert.NoError(t, err, "keys should never escape the state directory")
+}
+
+func TestFileReadWriterPingFailsForFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "publish-carousel-state")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	rw := NewFileReadWriter(f.Name())
+	assert.Error(t, rw.Ping())
+}
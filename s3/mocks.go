@@ -25,6 +25,16 @@ func (m *MockReadWriter) GetLatestKeyForID(id string) (string, error) {
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockReadWriter) List(id string) ([]Object, error) {
+	args := m.Called(id)
+	return args.Get(0).([]Object), args.Error(1)
+}
+
+func (m *MockReadWriter) Delete(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
 func (m *MockReadWriter) Ping() error {
 	args := m.Called()
 	return args.Error(0)
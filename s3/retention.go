@@ -0,0 +1,40 @@
+package s3
+
+import "time"
+
+// RetentionPolicy retains the KeepLast most recently modified objects, and the objects younger than MaxAge
+type RetentionPolicy struct {
+	KeepLast int
+	MaxAge   time.Duration
+}
+
+// Enabled returns true if the policy will ever prune anything
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepLast > 0 || p.MaxAge > 0
+}
+
+// Expired returns the objects, ordered from the oldest, which are not retained by the policy
+func (p RetentionPolicy) Expired(objects []Object, now time.Time) []Object {
+	var expired []Object
+	if !p.Enabled() {
+		return expired
+	}
+
+	for i, obj := range objects {
+		newer := len(objects) - 1 - i
+		if newer == 0 {
+			break
+		}
+
+		if p.KeepLast > 0 && newer < p.KeepLast {
+			continue
+		}
+
+		if p.MaxAge > 0 && now.Sub(obj.LastModified) < p.MaxAge {
+			continue
+		}
+
+		expired = append(expired, obj)
+	}
+	return expired
+}
@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testObjects(now time.Time, ages ...time.Duration) []Object {
+	var objects []Object
+	for i, age := range ages {
+		objects = append(objects, Object{Key: string(rune('a' + i)), LastModified: now.Add(-age)})
+	}
+	return objects
+}
+
+func keys(objects []Object) []string {
+	var keys []string
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+	return keys
+}
+
+func TestRetentionPolicyDisabled(t *testing.T) {
+	now := time.Now()
+	objects := testObjects(now, 72*time.Hour, 48*time.Hour, 24*time.Hour)
+
+	assert.False(t, RetentionPolicy{}.Enabled())
+	assert.Empty(t, RetentionPolicy{}.Expired(objects, now))
+}
+
+func TestRetentionPolicyKeepLast(t *testing.T) {
+	now := time.Now()
+	objects := testObjects(now, 4*time.Hour, 3*time.Hour, 2*time.Hour, time.Hour)
+
+	assert.Equal(t, []string{"a", "b"}, keys(RetentionPolicy{KeepLast: 2}.Expired(objects, now)))
+	assert.Empty(t, RetentionPolicy{KeepLast: 4}.Expired(objects, now))
+}
+
+func TestRetentionPolicyMaxAge(t *testing.T) {
+	now := time.Now()
+	objects := testObjects(now, 4*time.Hour, 3*time.Hour, 2*time.Hour, time.Hour)
+
+	assert.Equal(t, []string{"a", "b"}, keys(RetentionPolicy{MaxAge: 150 * time.Minute}.Expired(objects, now)))
+}
+
+func TestRetentionPolicyAlwaysKeepsLatest(t *testing.T) {
+	now := time.Now()
+	objects := testObjects(now, 4*time.Hour, 3*time.Hour)
+
+	assert.Equal(t, []string{"a"}, keys(RetentionPolicy{MaxAge: time.Minute}.Expired(objects, now)))
+}
+
+func TestRetentionPolicyKeepLastOrYounger(t *testing.T) {
+	now := time.Now()
+	objects := testObjects(now, 4*time.Hour, 3*time.Hour, 2*time.Hour, time.Hour)
+
+	assert.Equal(t, []string{"a"}, keys(RetentionPolicy{KeepLast: 1, MaxAge: 210 * time.Minute}.Expired(objects, now)))
+	assert.Equal(t, []string{"a", "b"}, keys(RetentionPolicy{KeepLast: 2, MaxAge: time.Minute}.Expired(objects, now)))
+}
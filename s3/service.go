@@ -7,6 +7,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -24,9 +25,30 @@ type ReadWriter interface {
 	Write(id string, key string, b []byte, contentType string) error
 	Read(key string) (bool, io.ReadCloser, *string, error)
 	GetLatestKeyForID(id string) (string, error)
+	List(id string) ([]Object, error)
+	Delete(key string) error
 	Ping() error
 }
 
+// Object describes a stored object, as returned by List
+type Object struct {
+	Key          string
+	LastModified time.Time
+}
+
+func sortObjects(objects []Object) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		return objects[i].LastModified.Before(objects[j].LastModified)
+	})
+}
+
+func latestKey(objects []Object) string {
+	if len(objects) == 0 {
+		return ""
+	}
+	return objects[len(objects)-1].Key
+}
+
 // DefaultReadWriter the default S3ReadWrite implementation
 type DefaultReadWriter struct {
 	bucketName string
@@ -118,32 +140,66 @@ func (s *DefaultReadWriter) Write(id string, key string, b []byte, contentType s
 	return nil
 }
 
-// GetLatestKeyForID lists s3 objects in the folder for the given ID
+// GetLatestKeyForID lists s3 objects in the folder for the given ID, and returns the most recently modified key
 func (s *DefaultReadWriter) GetLatestKeyForID(id string) (string, error) {
-	s3api, err := s.open()
+	objects, err := s.List(id)
 	if err != nil {
 		return "", err
 	}
 
-	output, err := s3api.ListObjects(&s3.ListObjectsInput{
-		Bucket: aws.String(s.bucketName),
-		Prefix: aws.String(id + "/"),
-	})
+	return latestKey(objects), nil
+}
 
+// List lists every s3 object in the folder for the given ID, from the oldest to the most recently modified
+func (s *DefaultReadWriter) List(id string) ([]Object, error) {
+	s3api, err := s.open()
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	input := &s3.ListObjectsInput{
+		Bucket: aws.String(s.bucketName),
+		Prefix: aws.String(id + "/"),
 	}
 
-	var latestKey string
-	var latestTimestamp *time.Time
-	for _, obj := range output.Contents {
-		if latestTimestamp == nil || latestTimestamp.Before(*obj.LastModified) {
-			latestTimestamp = obj.LastModified
-			latestKey = *obj.Key
+	var objects []Object
+	for {
+		output, err := s3api.ListObjects(input)
+		if err != nil {
+			return nil, err
 		}
+
+		for _, obj := range output.Contents {
+			objects = append(objects, Object{Key: *obj.Key, LastModified: *obj.LastModified})
+		}
+
+		if output.IsTruncated == nil || !*output.IsTruncated || len(output.Contents) == 0 {
+			break
+		}
+
+		marker := output.NextMarker
+		if marker == nil {
+			marker = output.Contents[len(output.Contents)-1].Key
+		}
+		input.Marker = marker
+	}
+
+	sortObjects(objects)
+	return objects, nil
+}
+
+// Delete deletes the provided key
+func (s *DefaultReadWriter) Delete(key string) error {
+	s3api, err := s.open()
+	if err != nil {
+		return err
 	}
 
-	return latestKey, nil
+	_, err = s3api.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+	return err
 }
 
 // Read reads the provided key and returns a reader etc.
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// legacyCheckpointVersion is the version of checkpoints saved without a version
+	legacyCheckpointVersion = 1
+	// checkpointVersion is the version of the checkpoints saved by this version of the carousel
+	checkpointVersion = 2
+)
+
+const (
+	restoredCheckpoint  = "restored"
+	migratedCheckpoint  = "migrated"
+	abandonedCheckpoint = "abandoned"
+)
+
+// ErrCheckpointIncompatible is returned when a checkpoint does not match the cycle's current config
+var ErrCheckpointIncompatible = errors.New("Checkpoint is incompatible with the current cycle configuration")
+
+// RestoreDecision records what was done with a cycle's checkpoint when it was restored
+type RestoreDecision struct {
+	Action  string    `json:"action"`
+	Reason  string    `json:"reason,omitempty"`
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+}
+
+// Abandoned returns true if the checkpoint was discarded, and the cycle started from its initial state
+func (d *RestoreDecision) Abandoned() bool {
+	return d != nil && d.Action == abandonedCheckpoint
+}
+
+// restoreSavedCycle returns the metadata to restore, and whether the checkpoint was restored, migrated or abandoned
+func restoreSavedCycle(saved SavedCycle, current CycleConfig) (CycleMetadata, *RestoreDecision) {
+	decision := &RestoreDecision{Action: restoredCheckpoint, Version: saved.Version, Time: time.Now().UTC()}
+
+	var incompatible []string
+	if saved.Version > checkpointVersion {
+		incompatible = append(incompatible, fmt.Sprintf("checkpoint version %v is newer than the supported version %v", saved.Version, checkpointVersion))
+	}
+
+	if saved.Config.Type != "" && !strings.EqualFold(saved.Config.Type, current.Type) {
+		incompatible = append(incompatible, fmt.Sprintf("type changed from %v to %v", saved.Config.Type, current.Type))
+	}
+
+	if saved.Config.Collection != "" && saved.Config.Collection != current.Collection {
+		incompatible = append(incompatible, fmt.Sprintf("collection changed from %v to %v", saved.Config.Collection, current.Collection))
+	}
+
+	if len(incompatible) > 0 {
+		decision.Action = abandonedCheckpoint
+		decision.Reason = strings.Join(incompatible, "; ")
+		return CycleMetadata{State: []string{stoppedState}, Restore: decision}, decision
+	}
+
+	metadata := saved.Metadata
+	var migrations []string
+	if saved.Version < checkpointVersion {
+		migrations = append(migrations, fmt.Sprintf("upgraded checkpoint from version %v to %v", saved.Version, checkpointVersion))
+	}
+
+	if saved.Config.Streaming != current.Streaming {
+		migrations = append(migrations, fmt.Sprintf("streaming changed from %v to %v, restarting the current iteration", saved.Config.Streaming, current.Streaming))
+		metadata.Completed = 0
+		metadata.Progress = 0
+		metadata.Position = nil
+	}
+
+	if len(migrations) > 0 {
+		decision.Action = migratedCheckpoint
+		decision.Reason = strings.Join(migrations, "; ")
+	}
+
+	metadata.Restore = decision
+	return metadata, decision
+}
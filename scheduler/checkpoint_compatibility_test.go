@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoreCompatibleCheckpoint(t *testing.T) {
+	cfg := CycleConfig{Name: "test-cycle", Type: ThrottledWholeCollectionType, Collection: "test-collection", Throttle: "1s"}
+	saved := SavedCycle{
+		Version:  checkpointVersion,
+		Config:   CycleConfig{Name: "test-cycle", Type: ThrottledWholeCollectionType, Collection: "test-collection", Throttle: "1m"},
+		Metadata: CycleMetadata{Completed: 2, Iteration: 4, Position: &native.Position{Manifest: "test-manifest", Offset: 2}},
+	}
+
+	md, decision := restoreSavedCycle(saved, cfg)
+	assert.Equal(t, restoredCheckpoint, decision.Action, "a throttle change is compatible")
+	assert.Empty(t, decision.Reason)
+	assert.Equal(t, checkpointVersion, decision.Version)
+	assert.Equal(t, 2, md.Completed)
+	assert.Equal(t, 4, md.Iteration)
+	assert.Equal(t, saved.Metadata.Position, md.Position)
+	assert.Equal(t, decision, md.Restore)
+}
+
+func TestRestoreLegacyCheckpoint(t *testing.T) {
+	cfg := CycleConfig{Name: "test-cycle", Type: ThrottledWholeCollectionType, Collection: "test-collection"}
+	saved := SavedCycle{Version: legacyCheckpointVersion, Config: cfg, Metadata: CycleMetadata{Completed: 2, Iteration: 4}}
+
+	md, decision := restoreSavedCycle(saved, cfg)
+	assert.Equal(t, migratedCheckpoint, decision.Action)
+	assert.Equal(t, "upgraded checkpoint from version 1 to 2", decision.Reason)
+	assert.Equal(t, 2, md.Completed)
+	assert.Equal(t, 4, md.Iteration)
+}
+
+func TestRestoreCheckpointAfterStreamingChanged(t *testing.T) {
+	cfg := CycleConfig{Name: "test-cycle", Type: ThrottledWholeCollectionType, Collection: "test-collection", Streaming: true}
+	saved := SavedCycle{
+		Version:  checkpointVersion,
+		Config:   CycleConfig{Name: "test-cycle", Type: ThrottledWholeCollectionType, Collection: "test-collection"},
+		Metadata: CycleMetadata{Completed: 2, Progress: 0.5, Iteration: 4, Position: &native.Position{Manifest: "test-manifest", Offset: 2}},
+	}
+
+	md, decision := restoreSavedCycle(saved, cfg)
+	assert.Equal(t, migratedCheckpoint, decision.Action)
+	assert.Contains(t, decision.Reason, "streaming changed from false to true")
+	assert.Equal(t, 0, md.Completed, "the current iteration should be restarted")
+	assert.Equal(t, 0.0, md.Progress)
+	assert.Nil(t, md.Position)
+	assert.Equal(t, 4, md.Iteration, "the iteration count is still meaningful")
+}
+
+func TestAbandonIncompatibleCheckpoints(t *testing.T) {
+	cfg := CycleConfig{Name: "test-cycle", Type: ThrottledWholeCollectionType, Collection: "test-collection"}
+
+	testCases := []struct {
+		name   string
+		saved  SavedCycle
+		reason string
+	}{
+		{
+			name:   "collection changed",
+			saved:  SavedCycle{Version: checkpointVersion, Config: CycleConfig{Type: ThrottledWholeCollectionType, Collection: "another-collection"}},
+			reason: "collection changed from another-collection to test-collection",
+		},
+		{
+			name:   "type changed",
+			saved:  SavedCycle{Version: checkpointVersion, Config: CycleConfig{Type: "FixedWindow", Collection: "test-collection"}},
+			reason: "type changed from FixedWindow to ThrottledWholeCollection",
+		},
+		{
+			name:   "newer version",
+			saved:  SavedCycle{Version: checkpointVersion + 1, Config: cfg},
+			reason: "checkpoint version 3 is newer than the supported version 2",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			test.saved.Metadata = CycleMetadata{Completed: 2, Iteration: 4}
+
+			md, decision := restoreSavedCycle(test.saved, cfg)
+			assert.True(t, decision.Abandoned())
+			assert.Equal(t, test.reason, decision.Reason)
+			assert.Equal(t, CycleMetadata{State: []string{stoppedState}, Restore: decision}, md, "the cycle should start from its initial state")
+		})
+	}
+}
@@ -40,6 +40,7 @@ func TestCheckpointSaveCycleMetadata(t *testing.T) {
 	c1.On("Start").Return()
 	c1.On("Stop").Return()
 	c1.On("TransformToConfig").Return(CycleConfig{Type: "test"})
+	c1.On("Type").Return("test")
 
 	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(db, nil, blacklist.NoOpBlacklist)
 
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/cluster"
+	log "github.com/sirupsen/logrus"
+)
+
+// SchedulerControl is the part of the scheduler which the cluster monitor drives
+type SchedulerControl interface {
+	Start() error
+	Shutdown() error
+	IsRunning() bool
+	IsEnabled() bool
+	WasAutomaticallyDisabled() bool
+}
+
+// ClusterStatus is the cluster monitor's view of the cluster, as of its last check
+type ClusterStatus struct {
+	Healthy bool
+	// Since is when the cluster was last found to be healthy or unhealthy, which is zero until its health first changes
+	Since time.Time
+	// LastCheck is when the services were last checked, which is zero until the first check
+	LastCheck time.Time
+	// UnhealthyServices are the services which failed the last check, with the reasons they failed in Errors
+	UnhealthyServices []string
+	Errors            []string
+}
+
+// ClusterMonitor shuts the scheduler down while the services the carousel depends on are unhealthy
+type ClusterMonitor struct {
+	sync.RWMutex
+	sched              SchedulerControl
+	services           []cluster.Service
+	unhealthyThreshold int
+	healthyThreshold   int
+	minimumHealthy     time.Duration
+	minimumUnhealthy   time.Duration
+	runner             *periodicRunner
+	status             ClusterStatus
+	failures           int
+	successes          int
+	now                func() time.Time
+}
+
+// NewClusterMonitor returns a monitor which checks the services every interval once started
+func NewClusterMonitor(sched SchedulerControl, unhealthyThreshold int, healthyThreshold int, minimumHealthy time.Duration, minimumUnhealthy time.Duration, interval time.Duration, services ...cluster.Service) *ClusterMonitor {
+	return &ClusterMonitor{
+		sched:              sched,
+		services:           services,
+		unhealthyThreshold: unhealthyThreshold,
+		healthyThreshold:   healthyThreshold,
+		minimumHealthy:     minimumHealthy,
+		minimumUnhealthy:   minimumUnhealthy,
+		runner:             newPeriodicRunner(interval),
+		status:             ClusterStatus{Healthy: true},
+		now:                time.Now,
+	}
+}
+
+// Start checks the cluster straight away, and then in the background until stopped
+func (m *ClusterMonitor) Start() {
+	m.Check()
+	m.runner.start(m.Check)
+}
+
+// Stop stops checking the cluster
+func (m *ClusterMonitor) Stop() {
+	m.runner.stop()
+}
+
+// Status returns the cluster's health as of the last check
+func (m *ClusterMonitor) Status() ClusterStatus {
+	m.RLock()
+	defer m.RUnlock()
+
+	status := m.status
+	status.UnhealthyServices = append([]string(nil), m.status.UnhealthyServices...)
+	status.Errors = append([]string(nil), m.status.Errors...)
+	return status
+}
+
+// Check checks every service, and shuts down or restarts the scheduler when the cluster's health changes
+func (m *ClusterMonitor) Check() {
+	unhealthyServices, errs := m.checkServices()
+
+	m.Lock()
+	now := m.now()
+	m.status.LastCheck = now
+	m.status.UnhealthyServices = unhealthyServices
+	m.status.Errors = errs
+
+	if len(unhealthyServices) > 0 {
+		m.failures++
+		m.successes = 0
+	} else {
+		m.successes++
+		m.failures = 0
+	}
+
+	becameUnhealthy := m.status.Healthy && m.failures >= m.unhealthyThreshold && !now.Before(m.status.Since.Add(m.minimumHealthy))
+	becameHealthy := !m.status.Healthy && m.successes >= m.healthyThreshold && !now.Before(m.status.Since.Add(m.minimumUnhealthy))
+	if becameUnhealthy || becameHealthy {
+		m.status.Healthy = becameHealthy
+		m.status.Since = now
+	}
+	m.Unlock()
+
+	if becameUnhealthy {
+		log.WithField("services", unhealthyServices).WithField("errors", errs).Warn("Cluster is unhealthy.")
+		if m.sched.IsRunning() {
+			log.WithField("services", unhealthyServices).Info("Shutting down scheduler due to unhealthy cluster service(s)")
+			if err := m.sched.Shutdown(); err != nil {
+				log.WithError(err).Error("Error in stopping scheduler")
+			}
+		}
+	}
+
+	if becameHealthy {
+		log.Info("Cluster health back to normal.")
+		if !m.sched.IsRunning() && m.sched.IsEnabled() && !m.sched.WasAutomaticallyDisabled() {
+			log.Info("Restarting scheduler.")
+			if err := m.sched.Start(); err != nil {
+				log.WithError(err).Error("Error in starting scheduler")
+			}
+		}
+	}
+}
+
+// checkServices checks the services in parallel, and returns the names of those which failed, and why
+func (m *ClusterMonitor) checkServices() ([]string, []string) {
+	errs := make([]error, len(m.services))
+
+	var wg sync.WaitGroup
+	for i, service := range m.services {
+		wg.Add(1)
+		go func(i int, service cluster.Service) {
+			defer wg.Done()
+			errs[i] = service.Check()
+		}(i, service)
+	}
+	wg.Wait()
+
+	var unhealthyServices, reasons []string
+	for i, err := range errs {
+		if err != nil {
+			unhealthyServices = append(unhealthyServices, m.services[i].Name())
+			reasons = append(reasons, strings.TrimSpace(err.Error()))
+		}
+	}
+	return unhealthyServices, reasons
+}
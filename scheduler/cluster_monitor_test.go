@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestService(name string, err error) *cluster.MockService {
+	service := new(cluster.MockService)
+	service.On("Name").Return(name)
+	service.On("Check").Return(err)
+	return service
+}
+
+func setCheckResult(service *cluster.MockService, err error) {
+	calls := make([]*mock.Call, 0)
+	for _, call := range service.ExpectedCalls {
+		if call.Method != "Check" {
+			calls = append(calls, call)
+		}
+	}
+	service.ExpectedCalls = calls
+	service.On("Check").Return(err)
+}
+
+func TestClusterMonitorHysteresis(t *testing.T) {
+	now := time.Now()
+	pam := newTestService("publish-availability-monitor", nil)
+	lagcheck := newTestService("kafka-lagcheck", errors.New("Lag is too high"))
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true).Once()
+	sched.On("Shutdown").Return(nil).Once()
+
+	monitor := NewClusterMonitor(sched, 2, 2, 0, 0, time.Minute, pam, lagcheck)
+	monitor.now = func() time.Time { return now }
+
+	assert.True(t, monitor.Status().Healthy)
+	assert.True(t, monitor.Status().LastCheck.IsZero())
+
+	monitor.Check()
+	status := monitor.Status()
+	assert.True(t, status.Healthy, "a single failed check should not make the cluster unhealthy")
+	assert.Equal(t, []string{"kafka-lagcheck"}, status.UnhealthyServices)
+	assert.Equal(t, []string{"Lag is too high"}, status.Errors)
+	assert.Equal(t, now, status.LastCheck)
+	sched.AssertNotCalled(t, "Shutdown")
+
+	monitor.Check()
+	status = monitor.Status()
+	assert.False(t, status.Healthy)
+	assert.Equal(t, now, status.Since)
+	sched.AssertNumberOfCalls(t, "Shutdown", 1)
+
+	monitor.Check()
+	sched.AssertNumberOfCalls(t, "Shutdown", 1)
+
+	setCheckResult(lagcheck, nil)
+	monitor.Check()
+	status = monitor.Status()
+	assert.False(t, status.Healthy, "a single successful check should not make the cluster healthy")
+	assert.Empty(t, status.UnhealthyServices)
+
+	sched.On("IsRunning").Return(false)
+	sched.On("IsEnabled").Return(true)
+	sched.On("WasAutomaticallyDisabled").Return(false)
+	sched.On("Start").Return(nil).Once()
+
+	monitor.Check()
+	assert.True(t, monitor.Status().Healthy)
+	sched.AssertNumberOfCalls(t, "Start", 1)
+}
+
+func TestClusterMonitorMinimumDwell(t *testing.T) {
+	now := time.Now()
+	lagcheck := newTestService("kafka-lagcheck", errors.New("Lag is too high"))
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true).Once()
+	sched.On("Shutdown").Return(nil).Once()
+
+	monitor := NewClusterMonitor(sched, 1, 1, time.Minute, 5*time.Minute, time.Minute, lagcheck)
+	check := func(at time.Duration) {
+		monitor.now = func() time.Time { return now.Add(at) }
+		monitor.Check()
+	}
+
+	check(0)
+	assert.False(t, monitor.Status().Healthy, "the cluster should become unhealthy straight away, as it has not changed yet")
+
+	setCheckResult(lagcheck, nil)
+	check(time.Minute)
+	check(4 * time.Minute)
+	assert.False(t, monitor.Status().Healthy, "the cluster should stay unhealthy for the minimum unhealthy time")
+
+	sched.On("IsRunning").Return(false).Once()
+	sched.On("IsEnabled").Return(true)
+	sched.On("WasAutomaticallyDisabled").Return(false)
+	sched.On("Start").Return(nil).Once()
+
+	check(5 * time.Minute)
+	assert.True(t, monitor.Status().Healthy)
+	assert.Equal(t, now.Add(5*time.Minute), monitor.Status().Since)
+	sched.AssertNumberOfCalls(t, "Start", 1)
+
+	setCheckResult(lagcheck, errors.New("Lag is too high"))
+	check(5*time.Minute + 30*time.Second)
+	assert.True(t, monitor.Status().Healthy, "the cluster should stay healthy for the minimum healthy time")
+
+	sched.On("IsRunning").Return(true).Once()
+	sched.On("Shutdown").Return(nil).Once()
+
+	check(6 * time.Minute)
+	assert.False(t, monitor.Status().Healthy)
+	sched.AssertNumberOfCalls(t, "Shutdown", 2)
+}
+
+func TestClusterMonitorDoesNotStartDisabledScheduler(t *testing.T) {
+	lagcheck := newTestService("kafka-lagcheck", errors.New("Lag is too high"))
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(false)
+	sched.On("IsEnabled").Return(true)
+	sched.On("WasAutomaticallyDisabled").Return(true)
+
+	monitor := NewClusterMonitor(sched, 1, 1, 0, 0, time.Minute, lagcheck)
+	monitor.Check()
+	assert.False(t, monitor.Status().Healthy)
+
+	setCheckResult(lagcheck, nil)
+	monitor.Check()
+	assert.True(t, monitor.Status().Healthy)
+
+	sched.AssertNotCalled(t, "Shutdown")
+	sched.AssertNotCalled(t, "Start")
+}
@@ -9,8 +9,12 @@ import (
 
 	yaml "gopkg.in/yaml.v2"
 
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/filter"
 	"github.com/Financial-Times/publish-carousel/native"
 	"github.com/Financial-Times/publish-carousel/tasks"
+	"github.com/Financial-Times/publish-carousel/transform"
+	"github.com/Financial-Times/publish-carousel/verify"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -19,15 +23,21 @@ type cycleSetupConfig struct {
 }
 
 type CycleConfig struct {
-	Name            string `yaml:"name" json:"name"`
-	Type            string `yaml:"type" json:"type"`
-	Origin          string `yaml:"origin" json:"origin"`
-	Collection      string `yaml:"collection" json:"collection"`
-	CoolDown        string `yaml:"coolDown" json:"coolDown"`
-	Throttle        string `yaml:"throttle" json:"throttle,omitempty"`
-	TimeWindow      string `yaml:"timeWindow" json:"timeWindow,omitempty"`
-	MinimumThrottle string `yaml:"minimumThrottle" json:"minimumThrottle,omitempty"`
-	MaximumThrottle string `yaml:"maximumThrottle" json:"maximumThrottle,omitempty"`
+	Name            string            `yaml:"name" json:"name"`
+	Type            string            `yaml:"type" json:"type"`
+	Origin          string            `yaml:"origin" json:"origin"`
+	Collection      string            `yaml:"collection" json:"collection"`
+	CoolDown        string            `yaml:"coolDown" json:"coolDown"`
+	Throttle        string            `yaml:"throttle" json:"throttle,omitempty"`
+	TimeWindow      string            `yaml:"timeWindow" json:"timeWindow,omitempty"`
+	MinimumThrottle string            `yaml:"minimumThrottle" json:"minimumThrottle,omitempty"`
+	MaximumThrottle string            `yaml:"maximumThrottle" json:"maximumThrottle,omitempty"`
+	Streaming       bool              `yaml:"streaming" json:"streaming,omitempty"`
+	Notifier        string            `yaml:"notifier" json:"notifier,omitempty"`
+	Notifiers       []string          `yaml:"notifiers" json:"notifiers,omitempty"`
+	FanOut          string            `yaml:"fanOut" json:"fanOut,omitempty"`
+	Task            string            `yaml:"task" json:"task,omitempty"`
+	ErrorGuard      *ErrorGuardConfig `yaml:"errorGuard" json:"errorGuard,omitempty"`
 }
 
 // Validate checks the provided config for errors
@@ -48,20 +58,31 @@ func (c CycleConfig) Validate() error {
 		return err
 	}
 
-	switch strings.ToLower(c.Type) {
-	case "throttledwholecollection":
-		if err := checkDurations(c.Name, c.Throttle); c.Throttle != "" && err != nil {
-			return err
-		}
-	case "scalingwindow":
-		if err := checkDurations(c.Name, c.TimeWindow, c.MinimumThrottle, c.MaximumThrottle); err != nil {
+	if c.Notifier != "" && len(c.Notifiers) > 0 {
+		return fmt.Errorf("Please provide either a notifier or fan-out notifiers for cycle %v, not both", c.Name)
+	}
+
+	if c.FanOut != "" && len(c.Notifiers) == 0 {
+		return fmt.Errorf("Please provide the fan-out notifiers for cycle %v", c.Name)
+	}
+
+	switch strings.ToLower(c.FanOut) {
+	case "", cms.FanOutAll, cms.FanOutAny, cms.FanOutPrimary:
+	default:
+		return fmt.Errorf("Please provide a valid fan-out mode for cycle %v, i.e. %v, %v or %v", c.Name, cms.FanOutAll, cms.FanOutAny, cms.FanOutPrimary)
+	}
+
+	if c.ErrorGuard != nil {
+		if err := c.ErrorGuard.Validate(c.Name); err != nil {
 			return err
 		}
-	default:
-		return fmt.Errorf("Please provide a valid type for cycle %v", c.Name)
 	}
 
-	return nil
+	factory, ok := LookupCycleFactory(c.Type)
+	if !ok {
+		return fmt.Errorf("Please provide a valid type for cycle %v", c.Name)
+	}
+	return factory.Validate(c)
 }
 
 func checkDurations(name string, durations ...string) error {
@@ -74,8 +95,18 @@ func checkDurations(name string, durations ...string) error {
 }
 
 // LoadSchedulerFromFile loads cycles and throttles from the provided yaml config file
-func LoadSchedulerFromFile(configFile string, uuidCollectionBuilder *native.NativeUUIDCollectionBuilder, publishTask tasks.Task, rw MetadataReadWriter, defaultThrottle time.Duration, checkpointInterval time.Duration) (Scheduler, error) {
-	scheduler := NewScheduler(uuidCollectionBuilder, publishTask, rw, defaultThrottle, checkpointInterval)
+func LoadSchedulerFromFile(configFile string, uuidCollectionBuilder *native.NativeUUIDCollectionBuilder, registry tasks.Registry, notifiers map[string]cms.Notifier, filters *filter.Config, transforms *transform.Config, verifier verify.Verifier, rw MetadataReadWriter, defaultThrottle time.Duration, checkpointInterval time.Duration) (Scheduler, error) {
+	publishTask, err := registry.NewTask(tasks.ContentTaskName, notifiers[cms.CMSNotifierName])
+	if err != nil {
+		return nil, err
+	}
+
+	scheduler := NewScheduler(uuidCollectionBuilder, publishTask, rw, defaultThrottle, checkpointInterval).(*defaultScheduler)
+	scheduler.registry = registry
+	scheduler.notifiers = notifiers
+	scheduler.filters = filters
+	scheduler.transforms = transforms
+	scheduler.verifier = verifier
 	fileData, err := ioutil.ReadFile(configFile)
 	if err != nil {
 		return scheduler, err
@@ -107,9 +138,50 @@ func LoadSchedulerFromFile(configFile string, uuidCollectionBuilder *native.Nati
 		}
 	}
 
+	if filters != nil {
+		for name := range filters.Cycles {
+			if !hasCycleNamed(setup.Cycles, name) {
+				log.WithField("cycleName", name).Warn("Filter rules are configured for a cycle which does not exist.")
+			}
+		}
+	}
+
+	if transforms != nil {
+		for name := range transforms.Cycles {
+			if !hasCycleNamed(setup.Cycles, name) {
+				log.WithField("cycleName", name).Warn("Transforms are configured for a cycle which does not exist.")
+			}
+		}
+	}
+
+	if filters != nil {
+		for name := range filters.Tasks {
+			if _, ok := registry.Lookup(name); !ok {
+				log.WithField("task", name).Warn("Filter rules are configured for a task which does not exist.")
+			}
+		}
+	}
+
+	if transforms != nil {
+		for name := range transforms.Tasks {
+			if _, ok := registry.Lookup(name); !ok {
+				log.WithField("task", name).Warn("Transforms are configured for a task which does not exist.")
+			}
+		}
+	}
+
 	return scheduler, combineConfigErrors(errs)
 }
 
+func hasCycleNamed(configs []CycleConfig, name string) bool {
+	for _, config := range configs {
+		if config.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func combineConfigErrors(errs []error) error {
 	if len(errs) == 0 {
 		return nil
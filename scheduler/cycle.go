@@ -10,8 +10,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Financial-Times/publish-carousel/cms"
 	"github.com/Financial-Times/publish-carousel/native"
 	"github.com/Financial-Times/publish-carousel/tasks"
+	"github.com/Financial-Times/publish-carousel/verify"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -24,23 +26,62 @@ type Cycle interface {
 	Reset()
 	Metadata() CycleMetadata
 	SetMetadata(state CycleMetadata)
+	SetRecovery(recovery *RecoveryMetadata)
 	TransformToConfig() CycleConfig
 	State() []string
 }
 
 type CycleMetadata struct {
-	CurrentPublishUUID  string     `json:"currentPublishUuid"`
-	CurrentPublishRef   string     `json:"currentPublishReference"`
-	CurrentPublishError string     `json:"currentPublishError,omitempty"`
-	Errors              int        `json:"errors"`
-	Progress            float64    `json:"progress"`
-	State               []string   `json:"state"`
-	Completed           int        `json:"completed"`
-	Total               int        `json:"total"`
-	Iteration           int        `json:"iteration"`
-	Attempts            int        `json:"attempts"`
-	Start               *time.Time `json:"windowStart,omitempty"`
-	End                 *time.Time `json:"windowEnd,omitempty"`
+	CurrentPublishUUID  string                    `json:"currentPublishUuid"`
+	CurrentPublishRef   string                    `json:"currentPublishReference"`
+	CurrentPublishError string                    `json:"currentPublishError,omitempty"`
+	Errors              int                       `json:"errors"`
+	ErrorClasses        map[string]int            `json:"errorClasses,omitempty"`
+	Skipped             int                       `json:"skipped"`
+	CurrentSkipReason   string                    `json:"currentSkipReason,omitempty"`
+	Progress            float64                   `json:"progress"`
+	State               []string                  `json:"state"`
+	Completed           int                       `json:"completed"`
+	Total               int                       `json:"total"`
+	Iteration           int                       `json:"iteration"`
+	Attempts            int                       `json:"attempts"`
+	Position            *native.Position          `json:"position,omitempty"`
+	Start               *time.Time                `json:"windowStart,omitempty"`
+	End                 *time.Time                `json:"windowEnd,omitempty"`
+	Restore             *RestoreDecision          `json:"restore,omitempty"`
+	Targets             map[string]TargetMetadata `json:"targets,omitempty"`
+	Verification        *VerificationMetadata     `json:"verification,omitempty"`
+	LastProgress        *time.Time                `json:"lastProgress,omitempty"`
+	UnhealthyReason     string                    `json:"unhealthyReason,omitempty"`
+	Recovery            *RecoveryMetadata         `json:"recovery,omitempty"`
+	Suspension          *SuspensionMetadata       `json:"suspension,omitempty"`
+}
+
+// TargetMetadata tracks the errors for one of the targets of a fan-out cycle
+type TargetMetadata struct {
+	Errors            int    `json:"errors"`
+	CurrentError      string `json:"currentError,omitempty"`
+	CurrentErrorClass string `json:"currentErrorClass,omitempty"`
+}
+
+// VerificationMetadata counts whether the content published by the cycle reached the read environments
+type VerificationMetadata struct {
+	Verified int `json:"verified"`
+	Late     int `json:"late"`
+	Missing  int `json:"missing"`
+}
+
+// RecoveryMetadata records the supervisor's attempts to restart the cycle since it last became unhealthy
+type RecoveryMetadata struct {
+	Attempts    []RecoveryAttempt `json:"attempts"`
+	NextAttempt *time.Time        `json:"nextAttempt,omitempty"`
+	GaveUp      bool              `json:"gaveUp,omitempty"`
+}
+
+// RecoveryAttempt is a restart of an unhealthy cycle, and the reason it was unhealthy
+type RecoveryAttempt struct {
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason,omitempty"`
 }
 
 func newCycleID(name string, dbcollection string) string {
@@ -70,19 +111,31 @@ func newAbstractCycle(name string, cycleType string, uuidCollectionBuilder *nati
 }
 
 type abstractCycle struct {
-	CycleID       string        `json:"id"`
-	CycleName     string        `json:"name"`
-	CycleType     string        `json:"type"`
-	CycleMetadata CycleMetadata `json:"metadata"`
-	DBCollection  string        `json:"collection"`
-	Origin        string        `json:"origin"`
-	CoolDown      string        `json:"coolDown"`
+	CycleID       string            `json:"id"`
+	CycleName     string            `json:"name"`
+	CycleType     string            `json:"type"`
+	CycleMetadata CycleMetadata     `json:"metadata"`
+	DBCollection  string            `json:"collection"`
+	Origin        string            `json:"origin"`
+	CoolDown      string            `json:"coolDown"`
+	Notifier      string            `json:"notifier,omitempty"`
+	Notifiers     []string          `json:"notifiers,omitempty"`
+	FanOut        string            `json:"fanOut,omitempty"`
+	Task          string            `json:"task"`
+	ErrorGuard    *ErrorGuardConfig `json:"errorGuard,omitempty"`
 
 	coolDown              time.Duration
 	metadataLock          *sync.RWMutex
 	cancel                context.CancelFunc
+	done                  chan struct{}
 	uuidCollectionBuilder *native.NativeUUIDCollectionBuilder
 	publishTask           tasks.Task
+	breakerMode           string
+	breakers              map[string]cms.Breaker
+	verifier              verify.Verifier
+	errorGuard            *errorGuard
+	resume                func()
+	resumeTimer           *time.Timer
 }
 
 func (a *abstractCycle) publishCollection(ctx context.Context, collection native.UUIDCollection, t Throttle) (bool, error) {
@@ -93,6 +146,10 @@ func (a *abstractCycle) publishCollection(ctx context.Context, collection native
 			return true, err
 		}
 
+		if err := a.waitForBreakers(ctx); err != nil {
+			return true, err
+		}
+
 		finished, uuid, err := collection.Next()
 		if finished {
 			log.WithField("id", a.CycleID).WithField("name", a.Name()).WithField("collection", a.DBCollection).Info("Finished publishing collection.")
@@ -110,29 +167,159 @@ func (a *abstractCycle) publishCollection(ctx context.Context, collection native
 		content, txID, err := a.publishTask.Prepare(a.DBCollection, uuid)
 
 		if err == nil {
-			err = a.publishTask.Execute(uuid, content, a.Origin, txID)
-			if err != nil {
+			content.Cycle = a.CycleName
+			err = a.execute(ctx, uuid, content, txID)
+			// a publish cancelled by stopping the cycle is neither a failure nor progress
+			if ctxErr := ctx.Err(); ctxErr != nil && err != nil {
+				return true, ctxErr
+			}
+
+			if fanOut := (*cms.FanOutError)(nil); errors.As(err, &fanOut) && !fanOut.Failed {
+				log.WithField("id", a.CycleID).WithField("name", a.Name()).WithField("collection", a.DBCollection).WithField("uuid", uuid).WithError(err).Warn("Published, but failed to publish to some best-effort targets.")
+				a.verify(uuid, txID)
+			} else if err == nil {
+				a.verify(uuid, txID)
+			} else if skip := (*tasks.SkipError)(nil); errors.As(err, &skip) {
+				log.WithField("id", a.CycleID).WithField("name", a.Name()).WithField("collection", a.DBCollection).WithField("uuid", uuid).WithField("reason", skip.Reason).Info("Skipping content.")
+			} else {
 				log.WithField("id", a.CycleID).WithField("name", a.Name()).WithField("collection", a.DBCollection).WithField("uuid", uuid).WithError(err).Warn("Failed to publish!")
 			}
+		} else if skip := (*tasks.SkipError)(nil); errors.As(err, &skip) {
+			log.WithField("id", a.CycleID).WithField("name", a.Name()).WithField("collection", a.DBCollection).WithField("uuid", uuid).WithField("reason", skip.Reason).Info("Skipping content.")
 		} else {
 			log.WithField("id", a.CycleID).WithField("name", a.Name()).WithField("collection", a.DBCollection).WithField("uuid", uuid).WithError(err).Warn("Failed to prepare content!")
 		}
 
 		a.updateProgress(uuid, txID, err)
+		a.updatePosition(collection)
+
+		if a.checkErrorRate(err) {
+			return true, errCycleSuspended
+		}
+	}
+}
+
+// execute publishes the content, and publishes it again if a circuit breaker rejected it
+func (a *abstractCycle) execute(ctx context.Context, uuid string, content *native.Content, txID string) error {
+	for {
+		err := a.publishTask.Execute(ctx, uuid, content, a.Origin, txID)
+		if len(a.breakers) == 0 || !errors.Is(err, cms.ErrCircuitOpen) {
+			return err
+		}
+
+		if fanOut := (*cms.FanOutError)(nil); errors.As(err, &fanOut) && !fanOut.Failed {
+			return err
+		}
+
+		if waitErr := a.waitForBreakers(ctx); waitErr != nil {
+			return err
+		}
+	}
+}
+
+// waitForBreakers blocks while the circuit breakers of the cycle's notifiers are open
+func (a *abstractCycle) waitForBreakers(ctx context.Context) error {
+	if len(a.breakers) == 0 {
+		return nil
+	}
+
+	if states := a.withBreakerStates(nil); len(states) > 0 {
+		log.WithField("id", a.CycleID).WithField("name", a.Name()).WithField("collection", a.DBCollection).WithField("breakers", states).Info("Waiting for the notifier circuit breakers.")
+	}
+
+	if a.breakerMode == cms.FanOutAny {
+		return waitForAnyBreaker(ctx, a.breakers)
+	}
+
+	for _, breaker := range a.breakers {
+		if err := breaker.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func waitForAnyBreaker(ctx context.Context, breakers map[string]cms.Breaker) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, len(breakers))
+	for _, breaker := range breakers {
+		go func(breaker cms.Breaker) {
+			done <- breaker.Wait(ctx)
+		}(breaker)
+	}
+	return <-done
+}
+
+// withBreakerStates replaces any circuit breaker states with the current states of the cycle's breakers
+func (a *abstractCycle) withBreakerStates(states []string) []string {
+	if len(a.breakers) == 0 {
+		return states
+	}
+
+	set := make(map[string]struct{})
+	for _, state := range states {
+		if !strings.HasPrefix(state, circuitStatePrefix) {
+			set[state] = struct{}{}
+		}
+	}
+
+	for _, breaker := range a.breakers {
+		if state := breaker.State(); state != cms.BreakerClosed {
+			set[circuitStatePrefix+state] = struct{}{}
+		}
+	}
+
+	var result []string
+	for state := range set {
+		result = append(result, state)
 	}
+	sort.Strings(result)
+	return result
+}
+
+func (a *abstractCycle) updatePosition(collection native.UUIDCollection) {
+	resumable, ok := collection.(native.ResumableUUIDCollection)
+	if !ok {
+		return
+	}
+
+	position := resumable.Position()
+
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+	a.CycleMetadata.Position = position
 }
 
 func (a *abstractCycle) updateProgress(uuid string, txId string, err error) {
 	a.metadataLock.Lock()
 	defer a.metadataLock.Unlock()
 
-	if err == nil {
+	skip := &tasks.SkipError{}
+	fanOut := &cms.FanOutError{}
+	switch {
+	case err == nil:
 		a.CycleMetadata.CurrentPublishError = ""
-	} else {
+		a.updateTargets(nil)
+	case errors.As(err, &skip):
+		a.CycleMetadata.Skipped++
+		a.CycleMetadata.CurrentSkipReason = skip.Reason
+		a.CycleMetadata.CurrentPublishError = ""
+	case errors.As(err, &fanOut) && !fanOut.Failed:
+		a.CycleMetadata.CurrentPublishError = ""
+		a.updateTargets(fanOut.Errors)
+	case errors.As(err, &fanOut):
 		a.CycleMetadata.Errors++
 		a.CycleMetadata.CurrentPublishError = err.Error()
+		a.updateTargets(fanOut.Errors)
+	default:
+		a.CycleMetadata.Errors++
+		a.CycleMetadata.CurrentPublishError = err.Error()
+		a.countErrorClass(err)
 	}
 
+	a.recordProgress()
 	a.CycleMetadata.Completed++
 	a.CycleMetadata.CurrentPublishUUID = uuid
 	a.CycleMetadata.CurrentPublishRef = txId
@@ -144,6 +331,212 @@ func (a *abstractCycle) updateProgress(uuid string, txId string, err error) {
 	}
 }
 
+// recordProgress records progress and clears the stalled state. The metadata lock must be held.
+func (a *abstractCycle) recordProgress() {
+	now := time.Now()
+	a.CycleMetadata.LastProgress = &now
+
+	var states []string
+	for _, state := range a.CycleMetadata.State {
+		if state != stalledState {
+			states = append(states, state)
+		}
+	}
+	a.CycleMetadata.State = states
+}
+
+// LastProgress returns when the cycle last made progress, or the zero time if it has not started
+func (a *abstractCycle) LastProgress() time.Time {
+	a.metadataLock.RLock()
+	defer a.metadataLock.RUnlock()
+
+	if a.CycleMetadata.LastProgress == nil {
+		return time.Time{}
+	}
+	return *a.CycleMetadata.LastProgress
+}
+
+// MarkStalled adds the stalled state to the cycle's current states, until it makes progress again
+func (a *abstractCycle) MarkStalled() {
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	for _, state := range a.CycleMetadata.State {
+		if state == stalledState {
+			return
+		}
+	}
+
+	states := append([]string{stalledState}, a.CycleMetadata.State...)
+	sort.Strings(states)
+	a.CycleMetadata.State = states
+}
+
+// setNotifiers records the task run by the cycle, and the notifiers it selected, if they are not the default
+func (a *abstractCycle) setNotifiers(config CycleConfig) {
+	a.Task = config.Task
+	a.Notifier = config.Notifier
+	a.Notifiers = config.Notifiers
+	a.FanOut = config.FanOut
+}
+
+// setBreakers sets the circuit breakers which the cycle waits for before publishing
+func (a *abstractCycle) setBreakers(mode string, breakers map[string]cms.Breaker) {
+	a.breakerMode = mode
+	a.breakers = breakers
+}
+
+// setErrorGuard suspends the cycle when the error rate of its recent publishes exceeds the configured maximum
+func (a *abstractCycle) setErrorGuard(config *ErrorGuardConfig) {
+	a.ErrorGuard = config
+	a.errorGuard = newErrorGuard(*config)
+}
+
+// checkErrorRate suspends the cycle if too many of its recent publishes have failed
+func (a *abstractCycle) checkErrorRate(err error) bool {
+	if a.errorGuard == nil {
+		return false
+	}
+
+	reason, rate, exceeded := a.errorGuard.add(err)
+	if !exceeded {
+		return false
+	}
+
+	a.errorGuard.reset()
+	a.suspend(reason, rate, a.errorGuard.coolOff)
+	return true
+}
+
+// suspend suspends the cycle, and resumes it once the cool-off has elapsed
+func (a *abstractCycle) suspend(reason string, rate float64, coolOff time.Duration) {
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	now := time.Now()
+	until := now.Add(coolOff)
+
+	a.CycleMetadata.State = []string{suspendedState}
+	a.CycleMetadata.Suspension = &SuspensionMetadata{Reason: reason, ErrorRate: rate, Since: now, Until: &until}
+
+	log.WithField("id", a.CycleID).WithField("name", a.CycleName).WithField("collection", a.DBCollection).WithField("reason", reason).WithField("until", until).Warn("Too many publishes have failed, suspending cycle.")
+
+	a.stopResumeTimer()
+	a.resumeTimer = time.AfterFunc(coolOff, func() {
+		if !a.isSuspended() || a.resume == nil {
+			return
+		}
+
+		log.WithField("id", a.CycleID).WithField("name", a.CycleName).WithField("collection", a.DBCollection).Info("Cool-off has elapsed, resuming suspended cycle.")
+		a.resume()
+	})
+}
+
+func (a *abstractCycle) isSuspended() bool {
+	a.metadataLock.RLock()
+	defer a.metadataLock.RUnlock()
+
+	for _, state := range a.CycleMetadata.State {
+		if state == suspendedState {
+			return true
+		}
+	}
+	return false
+}
+
+// stopResumeTimer cancels the automatic resume of a suspended cycle. The metadata lock must be held.
+func (a *abstractCycle) stopResumeTimer() {
+	if a.resumeTimer != nil {
+		a.resumeTimer.Stop()
+		a.resumeTimer = nil
+	}
+}
+
+// starting moves the cycle into the starting state, cancelling any pending resume
+func (a *abstractCycle) starting() {
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	a.stopResumeTimer()
+	a.CycleMetadata.State = []string{startingState}
+	a.CycleMetadata.Suspension = nil
+	a.recordProgress()
+}
+
+// setVerifier sets the verifier which checks that the cycle's publishes reach the read environments
+func (a *abstractCycle) setVerifier(verifier verify.Verifier) {
+	a.verifier = verifier
+}
+
+// verify checks that the published content reaches the read environments, and counts the result
+func (a *abstractCycle) verify(uuid string, txID string) {
+	if a.verifier == nil {
+		return
+	}
+	a.verifier.Verify(a.CycleName, uuid, txID, a.countVerification)
+}
+
+func (a *abstractCycle) countVerification(result string) {
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	verification := VerificationMetadata{}
+	if a.CycleMetadata.Verification != nil {
+		verification = *a.CycleMetadata.Verification
+	}
+
+	switch result {
+	case verify.Verified:
+		verification.Verified++
+	case verify.Late:
+		verification.Late++
+	case verify.Missing:
+		verification.Missing++
+	}
+
+	a.CycleMetadata.Verification = &verification
+}
+
+// updateTargets counts the errors of the failed fan-out targets. The metadata lock must be held.
+func (a *abstractCycle) updateTargets(errs map[string]error) {
+	if len(errs) == 0 && len(a.CycleMetadata.Targets) == 0 {
+		return
+	}
+
+	targets := make(map[string]TargetMetadata)
+	for name, target := range a.CycleMetadata.Targets {
+		target.CurrentError = ""
+		target.CurrentErrorClass = ""
+		targets[name] = target
+	}
+
+	for name, err := range errs {
+		target := targets[name]
+		target.Errors++
+		target.CurrentError = err.Error()
+		target.CurrentErrorClass = cms.ErrorClass(err)
+		targets[name] = target
+	}
+
+	a.CycleMetadata.Targets = targets
+}
+
+// countErrorClass counts permanent and transient notifier failures. The metadata lock must be held.
+func (a *abstractCycle) countErrorClass(err error) {
+	class := cms.ErrorClass(err)
+	if class == "" {
+		return
+	}
+
+	classes := make(map[string]int)
+	for c, count := range a.CycleMetadata.ErrorClasses {
+		classes[c] = count
+	}
+	classes[class]++
+
+	a.CycleMetadata.ErrorClasses = classes
+}
+
 func (a *abstractCycle) ID() string {
 	return a.CycleID
 }
@@ -156,10 +549,45 @@ func (a *abstractCycle) Type() string {
 	return a.CycleType
 }
 
-func (a *abstractCycle) Stop() {
-	if a.cancel != nil {
-		a.cancel()
+// run stops any running publish loop, and starts the given loop in the background
+func (a *abstractCycle) run(loop func(ctx context.Context)) {
+	a.stopLoop()
+	a.starting()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	a.metadataLock.Lock()
+	a.cancel = cancel
+	a.done = done
+	a.metadataLock.Unlock()
+
+	go func() {
+		defer close(done)
+		loop(ctx)
+	}()
+}
+
+// stopLoop cancels the cycle's publish loop, and waits for it to exit
+func (a *abstractCycle) stopLoop() {
+	a.metadataLock.Lock()
+	cancel, done := a.cancel, a.done
+	a.stopResumeTimer()
+	a.metadataLock.Unlock()
+
+	if cancel != nil {
+		cancel()
 	}
+
+	if done != nil {
+		<-done
+	}
+}
+
+// Stop stops the cycle, and only returns once its publish loop has exited
+func (a *abstractCycle) Stop() {
+	a.stopLoop()
+
 	log.WithField("id", a.CycleID).WithField("name", a.CycleName).WithField("collection", a.DBCollection).Info("Cycle stopped.")
 	a.UpdateState(stoppedState)
 }
@@ -174,7 +602,9 @@ func (a *abstractCycle) Metadata() CycleMetadata {
 	a.metadataLock.Lock()
 	defer a.metadataLock.Unlock()
 
-	return a.CycleMetadata
+	metadata := a.CycleMetadata
+	metadata.State = a.withBreakerStates(metadata.State)
+	return metadata
 }
 
 func (a *abstractCycle) SetMetadata(metadata CycleMetadata) {
@@ -184,6 +614,14 @@ func (a *abstractCycle) SetMetadata(metadata CycleMetadata) {
 	a.CycleMetadata = metadata
 }
 
+// SetRecovery replaces the recovery field of the cycle's metadata, and leaves the rest of it untouched
+func (a *abstractCycle) SetRecovery(recovery *RecoveryMetadata) {
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	a.CycleMetadata.Recovery = recovery
+}
+
 func (a *abstractCycle) UpdateState(states ...string) {
 	a.metadataLock.Lock()
 	defer a.metadataLock.Unlock()
@@ -192,6 +630,15 @@ func (a *abstractCycle) UpdateState(states ...string) {
 	a.CycleMetadata.State = states
 }
 
+// markUnhealthy stops the cycle's processing with the unhealthy state, and records the reason
+func (a *abstractCycle) markUnhealthy(reason string) {
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	a.CycleMetadata.State = []string{stoppedState, unhealthyState}
+	a.CycleMetadata.UnhealthyReason = reason
+}
+
 func (a *abstractCycle) PublishedItems() int {
 	a.metadataLock.RLock()
 	defer a.metadataLock.RUnlock()
@@ -201,5 +648,5 @@ func (a *abstractCycle) PublishedItems() int {
 func (a *abstractCycle) State() []string {
 	a.metadataLock.RLock()
 	defer a.metadataLock.RUnlock()
-	return a.CycleMetadata.State
+	return a.withBreakerStates(a.CycleMetadata.State)
 }
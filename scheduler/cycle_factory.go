@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/tasks"
+)
+
+// CycleDependencies are the dependencies which the scheduler provides to every cycle it builds
+type CycleDependencies struct {
+	UUIDCollectionBuilder *native.NativeUUIDCollectionBuilder
+	PublishTask           tasks.Task
+	DefaultThrottle       time.Duration
+}
+
+// ConfigField describes a field of the cycle configuration which is specific to a cycle type
+type ConfigField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+// CycleFactory validates and builds the cycles of one type
+type CycleFactory interface {
+	// Type is the name of the cycle type, which cycles select with the type field of their configuration
+	Type() string
+	// Schema describes the configuration fields which are specific to the cycle type
+	Schema() []ConfigField
+	// Validate checks the configuration fields which are specific to the cycle type
+	Validate(config CycleConfig) error
+	// New builds a cycle from a valid configuration
+	New(config CycleConfig, deps CycleDependencies) Cycle
+	// Checkpointed returns whether the metadata of the cycles is saved in checkpoints
+	Checkpointed() bool
+	// Throttle returns the fixed throttle the cycle publishes with, or false if the cycle type does not have one
+	Throttle(cycle Cycle) (Throttle, bool)
+	// WithThrottle returns the configuration of a cycle with a fixed throttle, changed to publish at the given interval
+	WithThrottle(config CycleConfig, interval time.Duration) CycleConfig
+}
+
+var cycleFactories = struct {
+	sync.RWMutex
+	factories map[string]CycleFactory
+}{factories: make(map[string]CycleFactory)}
+
+// RegisterCycleFactory adds a cycle type, unless a type with the same name is already registered
+func RegisterCycleFactory(factory CycleFactory) error {
+	if strings.TrimSpace(factory.Type()) == "" {
+		return errors.New("Please provide a name for every cycle type")
+	}
+
+	cycleFactories.Lock()
+	defer cycleFactories.Unlock()
+
+	key := strings.ToLower(factory.Type())
+	if _, ok := cycleFactories.factories[key]; ok {
+		return fmt.Errorf("Cycle type %v is already registered", factory.Type())
+	}
+
+	cycleFactories.factories[key] = factory
+	return nil
+}
+
+func mustRegisterCycleFactory(factory CycleFactory) {
+	if err := RegisterCycleFactory(factory); err != nil {
+		panic(err)
+	}
+}
+
+// LookupCycleFactory returns the factory of the (case insensitive) cycle type, if it is registered
+func LookupCycleFactory(cycleType string) (CycleFactory, bool) {
+	cycleFactories.RLock()
+	defer cycleFactories.RUnlock()
+
+	factory, ok := cycleFactories.factories[strings.ToLower(cycleType)]
+	return factory, ok
+}
+
+// CycleFactories returns the factory of every registered cycle type, ordered by type
+func CycleFactories() []CycleFactory {
+	cycleFactories.RLock()
+	defer cycleFactories.RUnlock()
+
+	factories := make([]CycleFactory, 0, len(cycleFactories.factories))
+	for _, factory := range cycleFactories.factories {
+		factories = append(factories, factory)
+	}
+
+	sort.Slice(factories, func(i, j int) bool { return factories[i].Type() < factories[j].Type() })
+	return factories
+}
+
+// isCheckpointed returns whether the cycle's metadata is saved in checkpoints
+func isCheckpointed(cycle Cycle) bool {
+	factory, ok := LookupCycleFactory(cycle.Type())
+	return ok && factory.Checkpointed()
+}
+
+// cycleThrottle returns the fixed throttle of the cycle, if its type has one
+func cycleThrottle(cycle Cycle) (Throttle, bool) {
+	factory, ok := LookupCycleFactory(cycle.Type())
+	if !ok {
+		return nil, false
+	}
+	return factory.Throttle(cycle)
+}
@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/blacklist"
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/tasks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCycleFactory struct {
+	cycleType string
+	deps      CycleDependencies
+}
+
+func (f *testCycleFactory) Type() string {
+	return f.cycleType
+}
+
+func (f *testCycleFactory) Schema() []ConfigField {
+	return []ConfigField{{Name: "timeWindow", Type: "duration", Required: true}}
+}
+
+func (f *testCycleFactory) Validate(config CycleConfig) error {
+	if config.TimeWindow == "" {
+		return errors.New("Please provide a time window")
+	}
+	return nil
+}
+
+func (f *testCycleFactory) New(config CycleConfig, deps CycleDependencies) Cycle {
+	f.deps = deps
+
+	cycle := new(MockCycle)
+	cycle.On("ID").Return(config.Name)
+	cycle.On("Type").Return(f.cycleType)
+	cycle.On("TransformToConfig").Return(config)
+	return cycle
+}
+
+func (f *testCycleFactory) Checkpointed() bool {
+	return false
+}
+
+func (f *testCycleFactory) Throttle(cycle Cycle) (Throttle, bool) {
+	return nil, false
+}
+
+func (f *testCycleFactory) WithThrottle(config CycleConfig, interval time.Duration) CycleConfig {
+	return config
+}
+
+func unregisterCycleFactory(cycleType string) {
+	cycleFactories.Lock()
+	defer cycleFactories.Unlock()
+	delete(cycleFactories.factories, cycleType)
+}
+
+func TestRegisterCycleFactory(t *testing.T) {
+	factory := &testCycleFactory{cycleType: "TestWindow"}
+	require.NoError(t, RegisterCycleFactory(factory))
+	defer unregisterCycleFactory("testwindow")
+
+	actual, ok := LookupCycleFactory("testwindow")
+	assert.True(t, ok)
+	assert.True(t, actual == factory)
+
+	err := RegisterCycleFactory(&testCycleFactory{cycleType: "TESTWINDOW"})
+	assert.EqualError(t, err, "Cycle type TESTWINDOW is already registered")
+
+	err = RegisterCycleFactory(&testCycleFactory{cycleType: " "})
+	assert.EqualError(t, err, "Please provide a name for every cycle type")
+
+	_, ok = LookupCycleFactory("pigeon")
+	assert.False(t, ok)
+}
+
+func TestCycleFactoriesAreOrderedByType(t *testing.T) {
+	var types []string
+	for _, factory := range CycleFactories() {
+		types = append(types, factory.Type())
+	}
+	assert.Equal(t, []string{ScalingWindowType, ThrottledWholeCollectionType}, types)
+}
+
+func TestNewCycleWithRegisteredFactory(t *testing.T) {
+	factory := &testCycleFactory{cycleType: "TestWindow"}
+	require.NoError(t, RegisterCycleFactory(factory))
+	defer unregisterCycleFactory("testwindow")
+
+	publishTask := &tasks.MockTask{}
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(nil, nil, blacklist.NoOpBlacklist)
+	s := NewScheduler(uuidCollectionBuilder, publishTask, &MockMetadataRW{}, time.Minute, time.Hour).(*defaultScheduler)
+
+	config := CycleConfig{Name: "test", Type: "TestWindow", Collection: "methode", Origin: "methode-web-pub", CoolDown: "5m"}
+	assert.EqualError(t, config.Validate(), "Please provide a time window")
+
+	config.TimeWindow = "1h"
+	require.NoError(t, config.Validate())
+
+	c, err := s.NewCycle(config)
+	require.NoError(t, err)
+	assert.Equal(t, "test", c.ID())
+	assert.True(t, factory.deps.PublishTask == publishTask)
+	assert.True(t, factory.deps.UUIDCollectionBuilder == uuidCollectionBuilder)
+	assert.Equal(t, time.Minute, factory.deps.DefaultThrottle)
+
+	assert.False(t, isCheckpointed(c))
+	_, ok := cycleThrottle(c)
+	assert.False(t, ok)
+}
+
+func TestCheckpointedCycleTypes(t *testing.T) {
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(nil, nil, blacklist.NoOpBlacklist)
+	throttle, _ := NewThrottle(time.Second, 1)
+
+	whole := NewThrottledWholeCollectionCycle("whole", uuidCollectionBuilder, "methode", "methode-web-pub", time.Minute, throttle, nil)
+	assert.True(t, isCheckpointed(whole))
+
+	actual, ok := cycleThrottle(whole)
+	assert.True(t, ok)
+	assert.True(t, actual == throttle)
+
+	window := NewScalingWindowCycle("window", uuidCollectionBuilder, "methode", "methode-web-pub", time.Hour, time.Minute, time.Second, time.Minute, nil)
+	assert.False(t, isCheckpointed(window))
+
+	_, ok = cycleThrottle(window)
+	assert.False(t, ok)
+
+	unknown := new(MockCycle)
+	unknown.On("Type").Return("test")
+	assert.False(t, isCheckpointed(unknown))
+}
+
+func TestThrottledWholeCollectionFactoryWithThrottle(t *testing.T) {
+	factory, ok := LookupCycleFactory(ThrottledWholeCollectionType)
+	require.True(t, ok)
+
+	config := CycleConfig{Name: "test", Type: ThrottledWholeCollectionType, Throttle: "1s"}
+	assert.Equal(t, "2m0s", factory.WithThrottle(config, 2*time.Minute).Throttle)
+
+	config.Throttle = "pigeon"
+	assert.Error(t, factory.Validate(config))
+}
@@ -11,6 +11,11 @@ const runningState = "running"
 const stoppedState = "stopped"
 const unhealthyState = "unhealthy"
 const coolDownState = "cooldown"
+const stalledState = "stalled"
+const suspendedState = "suspended"
+
+// circuitStatePrefix is prepended to the state of a notifier's circuit breaker
+const circuitStatePrefix = "circuit-"
 
 type State struct {
 	states []string
@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/tasks"
+)
+
+// errCycleSuspended is returned when a cycle stops publishing because too many of its recent publishes failed
+var errCycleSuspended = errors.New("Cycle suspended")
+
+// ErrorGuardConfig suspends a cycle when too many of its recent publishes fail
+type ErrorGuardConfig struct {
+	// MaxErrorRate is the fraction of the recent publishes, between 0 and 1, which can fail before the cycle is suspended
+	MaxErrorRate float64 `yaml:"maxErrorRate" json:"maxErrorRate"`
+	// Window is the number of recent publishes which the error rate is calculated over
+	Window int `yaml:"window" json:"window"`
+	// CoolOff is how long the cycle stays suspended before it resumes
+	CoolOff string `yaml:"coolOff" json:"coolOff"`
+}
+
+// Validate checks the error guard of the named cycle
+func (c ErrorGuardConfig) Validate(name string) error {
+	if c.MaxErrorRate <= 0 || c.MaxErrorRate > 1 {
+		return fmt.Errorf("Invalid maximum error rate %v for cycle %v, please use a value greater than 0 and at most 1", c.MaxErrorRate, name)
+	}
+
+	if c.Window < 1 {
+		return fmt.Errorf("Invalid error window %v for cycle %v, please use at least 1 publish", c.Window, name)
+	}
+
+	return checkDurations(name, c.CoolOff)
+}
+
+// SuspensionMetadata records why, and until when, a cycle is suspended
+type SuspensionMetadata struct {
+	Reason    string     `json:"reason"`
+	ErrorRate float64    `json:"errorRate"`
+	Since     time.Time  `json:"since"`
+	Until     *time.Time `json:"until,omitempty"`
+}
+
+// errorGuard is a ring buffer of the errors of a cycle's recent publishes, where an empty error is a successful publish
+type errorGuard struct {
+	maxErrorRate float64
+	coolOff      time.Duration
+	errors       []string
+	next         int
+	count        int
+}
+
+func newErrorGuard(config ErrorGuardConfig) *errorGuard {
+	coolOff, _ := time.ParseDuration(config.CoolOff)
+	return &errorGuard{maxErrorRate: config.MaxErrorRate, coolOff: coolOff, errors: make([]string, config.Window)}
+}
+
+// add records the result of a publish, and returns the dominant error and the error rate once the rate is too high
+func (g *errorGuard) add(err error) (string, float64, bool) {
+	g.errors[g.next] = failedPublish(err)
+	g.next = (g.next + 1) % len(g.errors)
+	if g.count < len(g.errors) {
+		g.count++
+	}
+
+	if g.count < len(g.errors) {
+		return "", 0, false
+	}
+
+	counts := make(map[string]int)
+	failed := 0
+	for _, e := range g.errors {
+		if e != "" {
+			counts[e]++
+			failed++
+		}
+	}
+
+	rate := float64(failed) / float64(g.count)
+	if failed == 0 || rate <= g.maxErrorRate {
+		return "", rate, false
+	}
+
+	dominant := ""
+	for e, count := range counts {
+		if count > counts[dominant] || (count == counts[dominant] && e < dominant) {
+			dominant = e
+		}
+	}
+	return fmt.Sprintf("%v (%v of the last %v publishes failed)", dominant, failed, g.count), rate, true
+}
+
+// reset forgets the recent publishes, so that a resumed cycle is not suspended again straight away
+func (g *errorGuard) reset() {
+	g.errors = make([]string, len(g.errors))
+	g.next = 0
+	g.count = 0
+}
+
+func failedPublish(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if skip := (*tasks.SkipError)(nil); errors.As(err, &skip) {
+		return ""
+	}
+
+	if fanOut := (*cms.FanOutError)(nil); errors.As(err, &fanOut) && !fanOut.Failed {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/blacklist"
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/tasks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type sliceCollection struct {
+	uuids []string
+	next  int
+}
+
+func (c *sliceCollection) Next() (bool, string, error) {
+	if c.next == len(c.uuids) {
+		return true, "", nil
+	}
+	c.next++
+	return false, c.uuids[c.next-1], nil
+}
+
+func (c *sliceCollection) Length() int {
+	return len(c.uuids)
+}
+
+func (c *sliceCollection) Done() bool {
+	return c.next == len(c.uuids)
+}
+
+func (c *sliceCollection) Close() error {
+	return nil
+}
+
+func TestErrorGuardConfigValidate(t *testing.T) {
+	assert.NoError(t, ErrorGuardConfig{MaxErrorRate: 0.5, Window: 10, CoolOff: "10m"}.Validate("test"))
+	assert.EqualError(t, ErrorGuardConfig{MaxErrorRate: 0, Window: 10, CoolOff: "10m"}.Validate("test"), "Invalid maximum error rate 0 for cycle test, please use a value greater than 0 and at most 1")
+	assert.EqualError(t, ErrorGuardConfig{MaxErrorRate: 1.5, Window: 10, CoolOff: "10m"}.Validate("test"), "Invalid maximum error rate 1.5 for cycle test, please use a value greater than 0 and at most 1")
+	assert.EqualError(t, ErrorGuardConfig{MaxErrorRate: 0.5, Window: 0, CoolOff: "10m"}.Validate("test"), "Invalid error window 0 for cycle test, please use at least 1 publish")
+	assert.Error(t, ErrorGuardConfig{MaxErrorRate: 0.5, Window: 10, CoolOff: "pigeon"}.Validate("test"))
+
+	config := CycleConfig{Name: "test", Type: ThrottledWholeCollectionType, Collection: "methode", Origin: "methode-web-pub", CoolDown: "5m", ErrorGuard: &ErrorGuardConfig{Window: 10, CoolOff: "10m"}}
+	assert.EqualError(t, config.Validate(), "Invalid maximum error rate 0 for cycle test, please use a value greater than 0 and at most 1")
+}
+
+func TestErrorGuardDominantError(t *testing.T) {
+	guard := newErrorGuard(ErrorGuardConfig{MaxErrorRate: 0.5, Window: 4, CoolOff: "1m"})
+
+	_, _, exceeded := guard.add(&tasks.SkipError{Reason: "filtered"})
+	assert.False(t, exceeded, "the window is not full yet")
+
+	_, _, exceeded = guard.add(&cms.FanOutError{Errors: map[string]error{"kafka": errors.New("timeout")}})
+	assert.False(t, exceeded)
+
+	_, _, exceeded = guard.add(errors.New("Unsupported content type"))
+	assert.False(t, exceeded)
+
+	_, rate, exceeded := guard.add(errors.New("Unsupported content type"))
+	assert.False(t, exceeded, "half of the publishes failed, which does not exceed the maximum")
+	assert.Equal(t, 0.5, rate)
+
+	reason, rate, exceeded := guard.add(errors.New("Bad origin"))
+	assert.True(t, exceeded)
+	assert.Equal(t, 0.75, rate)
+	assert.Equal(t, "Unsupported content type (3 of the last 4 publishes failed)", reason)
+
+	guard.reset()
+	_, _, exceeded = guard.add(errors.New("Bad origin"))
+	assert.False(t, exceeded)
+}
+
+func TestCycleSuspendsAndResumesAfterCoolOff(t *testing.T) {
+	task := new(tasks.MockTask)
+	task.On("Prepare", "collection", mock.Anything).Return(&native.Content{}, "tid", nil)
+	task.On("Execute", mock.Anything, mock.Anything, mock.AnythingOfType("*native.Content"), "origin", "tid").Return(errors.New("Unsupported content type"))
+
+	throttle := new(MockThrottle)
+	throttle.On("Queue").Return(nil)
+
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(nil, nil, blacklist.NoOpBlacklist)
+	c := newThrottledWholeCollectionCycle("name", uuidCollectionBuilder, "collection", "origin", time.Minute, throttle, task, false)
+	c.setErrorGuard(&ErrorGuardConfig{MaxErrorRate: 0.5, Window: 4, CoolOff: "50ms"})
+
+	resumed := make(chan struct{}, 1)
+	c.resume = func() { resumed <- struct{}{} }
+
+	collection := &sliceCollection{}
+	for i := 0; i < 10; i++ {
+		collection.uuids = append(collection.uuids, fmt.Sprintf("uuid-%v", i))
+	}
+
+	stopped, err := c.publishCollection(context.Background(), collection, throttle)
+	assert.True(t, stopped)
+	assert.True(t, errors.Is(err, errCycleSuspended))
+	assert.Equal(t, 4, collection.next, "the cycle should stop publishing once the window is full")
+
+	assert.Equal(t, []string{suspendedState}, c.State())
+	suspension := c.Metadata().Suspension
+	require.NotNil(t, suspension)
+	assert.Equal(t, "Unsupported content type (4 of the last 4 publishes failed)", suspension.Reason)
+	assert.Equal(t, 1.0, suspension.ErrorRate)
+	require.NotNil(t, suspension.Until)
+
+	select {
+	case <-resumed:
+	case <-time.After(time.Second):
+		assert.Fail(t, "the cycle should resume after the cool-off")
+	}
+
+	c.starting()
+	assert.Equal(t, []string{startingState}, c.State())
+	assert.Nil(t, c.Metadata().Suspension)
+}
+
+func TestStoppedCycleDoesNotResume(t *testing.T) {
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(nil, nil, blacklist.NoOpBlacklist)
+	c := newThrottledWholeCollectionCycle("name", uuidCollectionBuilder, "collection", "origin", time.Minute, new(MockThrottle), new(tasks.MockTask), false)
+
+	resumed := make(chan struct{}, 1)
+	c.resume = func() { resumed <- struct{}{} }
+
+	c.suspend("Unsupported content type", 1, 20*time.Millisecond)
+	c.Stop()
+
+	select {
+	case <-resumed:
+		assert.Fail(t, "a stopped cycle should not resume")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, []string{stoppedState}, c.State())
+}
+
+func TestNewCycleWithErrorGuard(t *testing.T) {
+	s := NewScheduler(nil, &tasks.MockTask{}, &MockMetadataRW{}, time.Minute, time.Hour).(*defaultScheduler)
+
+	guard := &ErrorGuardConfig{MaxErrorRate: 0.5, Window: 100, CoolOff: "10m"}
+	config := CycleConfig{Name: "test", Type: ThrottledWholeCollectionType, Collection: "methode", Origin: "methode-web-pub", CoolDown: "5m", Throttle: "1s", ErrorGuard: guard}
+
+	c, err := s.NewCycle(config)
+	require.NoError(t, err)
+	assert.NotNil(t, c.(*ThrottledWholeCollectionCycle).errorGuard)
+	assert.Equal(t, guard, c.TransformToConfig().ErrorGuard)
+}
@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Financial-Times/publish-carousel/etcd/keys"
+)
+
+type etcdMetadataReadWriter struct {
+	store    keys.VersionedStore
+	prefix   string
+	lock     *sync.Mutex
+	versions map[string]uint64
+}
+
+// NewEtcdMetadataReadWriter returns a MetadataReadWriter which stores each cycle's state in etcd, under the given key prefix
+func NewEtcdMetadataReadWriter(store keys.VersionedStore, prefix string) MetadataReadWriter {
+	return &etcdMetadataReadWriter{store: store, prefix: strings.TrimSuffix(prefix, "/"), lock: &sync.Mutex{}, versions: make(map[string]uint64)}
+}
+
+func (e *etcdMetadataReadWriter) key(id string) string {
+	return e.prefix + "/" + id
+}
+
+func (e *etcdMetadataReadWriter) LoadMetadata(id string) (SavedCycle, error) {
+	value, version, found, err := e.store.Get(e.key(id))
+	if err != nil {
+		return SavedCycle{}, err
+	}
+
+	if !found {
+		return SavedCycle{}, fmt.Errorf(`No state found for "%v"`, id)
+	}
+
+	e.lock.Lock()
+	e.versions[id] = version
+	e.lock.Unlock()
+
+	return decodeSavedCycle(strings.NewReader(value))
+}
+
+func (e *etcdMetadataReadWriter) WriteMetadata(id string, config CycleConfig, metadata CycleMetadata) error {
+	b, err := json.Marshal(newSavedCycle(config, metadata))
+	if err != nil {
+		return err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	version, swapped, err := e.store.CompareAndSwap(e.key(id), string(b), e.versions[id])
+	if err != nil {
+		return err
+	}
+
+	if !swapped {
+		return fmt.Errorf(`State for "%v" has been modified by another instance`, id)
+	}
+
+	e.versions[id] = version
+	return nil
+}
@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeVersionedStore struct {
+	values   map[string]string
+	versions map[string]uint64
+	version  uint64
+}
+
+func newFakeVersionedStore() *fakeVersionedStore {
+	return &fakeVersionedStore{values: make(map[string]string), versions: make(map[string]uint64)}
+}
+
+func (f *fakeVersionedStore) Get(key string) (string, uint64, bool, error) {
+	value, ok := f.values[key]
+	return value, f.versions[key], ok, nil
+}
+
+func (f *fakeVersionedStore) CompareAndSwap(key string, value string, version uint64) (uint64, bool, error) {
+	if f.versions[key] != version {
+		return 0, false, nil
+	}
+
+	f.version++
+	f.values[key] = value
+	f.versions[key] = f.version
+	return f.version, true, nil
+}
+
+func TestEtcdWriteThenLoadMetadata(t *testing.T) {
+	api := newFakeVersionedStore()
+	rw := NewEtcdMetadataReadWriter(api, "/ft/publish-carousel/cycles/")
+
+	cfg := CycleConfig{Name: "test-cycle", Type: ThrottledWholeCollectionType, Collection: "test-collection"}
+	md := CycleMetadata{Completed: 3, Total: 4, Iteration: 5, State: []string{}}
+
+	err := rw.WriteMetadata("test-cycle-id", cfg, md)
+	require.NoError(t, err)
+
+	value, ok := api.values["/ft/publish-carousel/cycles/test-cycle-id"]
+	require.True(t, ok)
+
+	stored := SavedCycle{}
+	require.NoError(t, json.Unmarshal([]byte(value), &stored))
+	assert.Equal(t, cfg, stored.Config)
+
+	err = rw.WriteMetadata("test-cycle-id", cfg, md)
+	assert.NoError(t, err, "subsequent writes should compare against our own last write")
+
+	actual, err := NewEtcdMetadataReadWriter(api, "/ft/publish-carousel/cycles").LoadMetadata("test-cycle-id")
+	assert.NoError(t, err)
+	assert.Equal(t, md, actual.Metadata)
+}
+
+func TestEtcdWriteMetadataFails(t *testing.T) {
+	store := new(MockVersionedStore)
+	store.On("CompareAndSwap", "/cycles/test-cycle-id", mock.AnythingOfType("string"), uint64(0)).Return(uint64(0), false, errors.New("oh no"))
+
+	err := NewEtcdMetadataReadWriter(store, "/cycles").WriteMetadata("test-cycle-id", CycleConfig{}, CycleMetadata{})
+	assert.EqualError(t, err, "oh no")
+	store.AssertExpectations(t)
+}
+
+func TestEtcdLoadMetadataNotFound(t *testing.T) {
+	rw := NewEtcdMetadataReadWriter(newFakeVersionedStore(), "/ft/publish-carousel/cycles")
+
+	_, err := rw.LoadMetadata("test-cycle-id")
+	assert.EqualError(t, err, `No state found for "test-cycle-id"`)
+}
+
+func TestEtcdWriteMetadataRefusesToOverwriteAnotherInstance(t *testing.T) {
+	api := newFakeVersionedStore()
+	cfg := CycleConfig{Name: "test-cycle"}
+
+	first := NewEtcdMetadataReadWriter(api, "/cycles")
+	require.NoError(t, first.WriteMetadata("test-cycle-id", cfg, CycleMetadata{Iteration: 1, Completed: 5}))
+
+	second := NewEtcdMetadataReadWriter(api, "/cycles")
+	_, err := second.LoadMetadata("test-cycle-id")
+	require.NoError(t, err)
+	require.NoError(t, second.WriteMetadata("test-cycle-id", cfg, CycleMetadata{Iteration: 1, Completed: 6}))
+
+	err = first.WriteMetadata("test-cycle-id", cfg, CycleMetadata{Iteration: 2, Completed: 1})
+	assert.EqualError(t, err, `State for "test-cycle-id" has been modified by another instance`, "even a state which is further ahead shouldn't overwrite another instance's")
+
+	err = first.WriteMetadata("test-cycle-id", cfg, CycleMetadata{Iteration: 2, Completed: 2})
+	assert.EqualError(t, err, `State for "test-cycle-id" has been modified by another instance`)
+
+	require.NoError(t, second.WriteMetadata("test-cycle-id", cfg, CycleMetadata{Iteration: 1, Completed: 7}), "the other instance should keep writing its own state")
+
+	actual, err := NewEtcdMetadataReadWriter(api, "/cycles").LoadMetadata("test-cycle-id")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, actual.Metadata.Iteration)
+	assert.Equal(t, 7, actual.Metadata.Completed)
+
+	_, err = first.LoadMetadata("test-cycle-id")
+	require.NoError(t, err)
+	assert.NoError(t, first.WriteMetadata("test-cycle-id", cfg, CycleMetadata{Iteration: 1, Completed: 8}), "the state can be written again once it has been reloaded")
+}
+
+func TestDualMetadataReadWriter(t *testing.T) {
+	cfg := CycleConfig{Name: "test-cycle"}
+	md := CycleMetadata{Completed: 3}
+
+	primary := new(MockMetadataRW)
+	secondary := new(MockMetadataRW)
+	primary.On("WriteMetadata", "test-cycle-id", cfg, md).Return(nil)
+	secondary.On("WriteMetadata", "test-cycle-id", cfg, md).Return(errors.New("oh no"))
+	primary.On("LoadMetadata", "test-cycle-id").Return(SavedCycle{}, errors.New("not found"))
+	secondary.On("LoadMetadata", "test-cycle-id").Return(SavedCycle{Config: cfg, Metadata: md}, nil)
+
+	rw := NewDualMetadataReadWriter(primary, secondary)
+
+	err := rw.WriteMetadata("test-cycle-id", cfg, md)
+	assert.NoError(t, err, "secondary write failures should only be logged")
+
+	actual, err := rw.LoadMetadata("test-cycle-id")
+	assert.NoError(t, err)
+	assert.Equal(t, md, actual.Metadata)
+
+	primary.AssertExpectations(t)
+	secondary.AssertExpectations(t)
+}
+
+func TestDualMetadataReadWriterPrimaryWriteFails(t *testing.T) {
+	cfg := CycleConfig{Name: "test-cycle"}
+	md := CycleMetadata{Completed: 3}
+
+	primary := new(MockMetadataRW)
+	secondary := new(MockMetadataRW)
+	primary.On("WriteMetadata", "test-cycle-id", cfg, md).Return(errors.New("oh no"))
+
+	rw := NewDualMetadataReadWriter(primary, secondary)
+
+	err := rw.WriteMetadata("test-cycle-id", cfg, md)
+	assert.EqualError(t, err, "oh no")
+	secondary.AssertNotCalled(t, "WriteMetadata", "test-cycle-id", cfg, md)
+}
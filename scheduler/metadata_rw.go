@@ -4,64 +4,158 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
 	"github.com/Financial-Times/publish-carousel/s3"
+	log "github.com/sirupsen/logrus"
 )
 
 const defaultContentType = "application/json"
 
 type MetadataReadWriter interface {
-	LoadMetadata(id string) (CycleMetadata, error)
+	LoadMetadata(id string) (SavedCycle, error)
 	WriteMetadata(id string, config CycleConfig, metadata CycleMetadata) error
 }
 
+// CheckpointHistory is implemented by MetadataReadWriters which keep every checkpoint of a cycle
+type CheckpointHistory interface {
+	Checkpoints(id string) ([]Checkpoint, error)
+	LoadCheckpoint(id string, key string) (SavedCycle, error)
+	PruneCheckpoints(id string, policy s3.RetentionPolicy) (int, error)
+}
+
+// Checkpoint describes a previously saved checkpoint of a cycle's metadata
+type Checkpoint struct {
+	Key   string    `json:"key"`
+	Saved time.Time `json:"saved"`
+}
+
+var (
+	// ErrCheckpointHistoryUnsupported is returned when only the latest checkpoint is kept
+	ErrCheckpointHistoryUnsupported = errors.New("Checkpoint history is not supported by the cycle metadata store")
+	// ErrCheckpointNotFound is returned when a requested checkpoint does not exist
+	ErrCheckpointNotFound = errors.New("Checkpoint not found")
+)
+
 type s3MetadataReadWriter struct {
 	s3rw s3.ReadWriter
 }
 
-type s3Metadata struct {
+// SavedCycle is a checkpoint of a cycle's config and metadata
+type SavedCycle struct {
+	Version  int           `json:"version,omitempty"`
 	Config   CycleConfig   `json:"config"`
 	Metadata CycleMetadata `json:"metadata"`
 }
 
+func newSavedCycle(config CycleConfig, metadata CycleMetadata) *SavedCycle {
+	return &SavedCycle{Version: checkpointVersion, Config: config, Metadata: metadata}
+}
+
+func decodeSavedCycle(r io.Reader) (SavedCycle, error) {
+	saved := SavedCycle{}
+	err := json.NewDecoder(r).Decode(&saved)
+	if saved.Version == 0 {
+		saved.Version = legacyCheckpointVersion
+	}
+	return saved, err
+}
+
 func NewS3MetadataReadWriter(rw s3.ReadWriter) MetadataReadWriter {
 	return &s3MetadataReadWriter{s3rw: rw}
 }
 
-func (s *s3MetadataReadWriter) LoadMetadata(id string) (CycleMetadata, error) {
+func (s *s3MetadataReadWriter) LoadMetadata(id string) (SavedCycle, error) {
 	key, err := s.s3rw.GetLatestKeyForID(id)
 	if err != nil {
-		return CycleMetadata{}, err
+		return SavedCycle{}, err
 	}
 
 	if strings.TrimSpace(key) == "" {
-		return CycleMetadata{}, errors.New(`No key found for id "` + id + `"`)
+		return SavedCycle{}, errors.New(`No key found for id "` + id + `"`)
 	}
 
-	found, body, contentType, err := s.s3rw.Read(key)
+	found, saved, err := s.read(id, key)
 	if err != nil {
-		return CycleMetadata{}, err
+		return SavedCycle{}, err
 	}
 
 	if !found {
-		return CycleMetadata{}, fmt.Errorf(`No state found for "%v"`, id)
+		return SavedCycle{}, fmt.Errorf(`No state found for "%v"`, id)
 	}
 
+	return saved, nil
+}
+
+func (s *s3MetadataReadWriter) read(id string, key string) (bool, SavedCycle, error) {
+	found, body, contentType, err := s.s3rw.Read(key)
+	if err != nil || !found {
+		return false, SavedCycle{}, err
+	}
+
+	defer body.Close()
+
 	if contentType == nil || strings.TrimSpace(*contentType) != "application/json" {
-		return CycleMetadata{}, fmt.Errorf(`Failed to load state for "%v". Content was in an unexpected Content-Type "%v"`, id, contentType)
+		return false, SavedCycle{}, fmt.Errorf(`Failed to load state for "%v". Content was in an unexpected Content-Type "%v"`, id, contentType)
 	}
 
-	fromS3 := &s3Metadata{}
-	dec := json.NewDecoder(body)
-	err = dec.Decode(fromS3)
+	saved, err := decodeSavedCycle(body)
+	return true, saved, err
+}
 
-	return fromS3.Metadata, err
+// Checkpoints lists every checkpoint saved for the cycle, from the most recent to the oldest
+func (s *s3MetadataReadWriter) Checkpoints(id string) ([]Checkpoint, error) {
+	objects, err := s.s3rw.List(id)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints := make([]Checkpoint, 0, len(objects))
+	for i := len(objects) - 1; i >= 0; i-- {
+		checkpoints = append(checkpoints, Checkpoint{Key: strings.TrimPrefix(objects[i].Key, id+"/"), Saved: objects[i].LastModified})
+	}
+	return checkpoints, nil
+}
+
+// LoadCheckpoint loads the given checkpoint for the cycle
+func (s *s3MetadataReadWriter) LoadCheckpoint(id string, key string) (SavedCycle, error) {
+	if strings.TrimSpace(key) == "" || strings.Contains(key, "/") {
+		return SavedCycle{}, ErrCheckpointNotFound
+	}
+
+	found, saved, err := s.read(id, id+"/"+key)
+	if err != nil {
+		return SavedCycle{}, err
+	}
+
+	if !found {
+		return SavedCycle{}, ErrCheckpointNotFound
+	}
+
+	return saved, nil
+}
+
+// PruneCheckpoints deletes the checkpoints for the cycle which are not retained by the policy
+func (s *s3MetadataReadWriter) PruneCheckpoints(id string, policy s3.RetentionPolicy) (int, error) {
+	objects, err := s.s3rw.List(id)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, obj := range policy.Expired(objects, time.Now()) {
+		if err := s.s3rw.Delete(obj.Key); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
 }
 
 func (s *s3MetadataReadWriter) WriteMetadata(id string, config CycleConfig, metadata CycleMetadata) error {
-	b, err := json.Marshal(&s3Metadata{config, metadata})
+	b, err := json.Marshal(newSavedCycle(config, metadata))
 	if err != nil {
 		return err
 	}
@@ -69,3 +163,68 @@ func (s *s3MetadataReadWriter) WriteMetadata(id string, config CycleConfig, meta
 	key := time.Now().UTC().Format(`20060102T15040599`)
 	return s.s3rw.Write(id, key, b, defaultContentType)
 }
+
+type dualMetadataReadWriter struct {
+	primary   MetadataReadWriter
+	secondary MetadataReadWriter
+}
+
+// NewDualMetadataReadWriter returns a MetadataReadWriter which migrates cycle state from the secondary store to the primary
+func NewDualMetadataReadWriter(primary MetadataReadWriter, secondary MetadataReadWriter) MetadataReadWriter {
+	return &dualMetadataReadWriter{primary: primary, secondary: secondary}
+}
+
+func (d *dualMetadataReadWriter) LoadMetadata(id string) (SavedCycle, error) {
+	saved, err := d.primary.LoadMetadata(id)
+	if err == nil {
+		return saved, nil
+	}
+
+	log.WithError(err).WithField("cycle", id).Info("Failed to load cycle state from the primary store, falling back to the secondary store.")
+	return d.secondary.LoadMetadata(id)
+}
+
+func (d *dualMetadataReadWriter) WriteMetadata(id string, config CycleConfig, metadata CycleMetadata) error {
+	err := d.primary.WriteMetadata(id, config, metadata)
+	if err != nil {
+		return err
+	}
+
+	if err := d.secondary.WriteMetadata(id, config, metadata); err != nil {
+		log.WithError(err).WithField("cycle", id).Warn("Failed to write cycle state to the secondary store.")
+	}
+	return nil
+}
+
+func (d *dualMetadataReadWriter) history() (CheckpointHistory, error) {
+	for _, rw := range []MetadataReadWriter{d.primary, d.secondary} {
+		if history, ok := rw.(CheckpointHistory); ok {
+			return history, nil
+		}
+	}
+	return nil, ErrCheckpointHistoryUnsupported
+}
+
+func (d *dualMetadataReadWriter) Checkpoints(id string) ([]Checkpoint, error) {
+	history, err := d.history()
+	if err != nil {
+		return nil, err
+	}
+	return history.Checkpoints(id)
+}
+
+func (d *dualMetadataReadWriter) LoadCheckpoint(id string, key string) (SavedCycle, error) {
+	history, err := d.history()
+	if err != nil {
+		return SavedCycle{}, err
+	}
+	return history.LoadCheckpoint(id, key)
+}
+
+func (d *dualMetadataReadWriter) PruneCheckpoints(id string, policy s3.RetentionPolicy) (int, error) {
+	history, err := d.history()
+	if err != nil {
+		return 0, err
+	}
+	return history.PruneCheckpoints(id, policy)
+}
@@ -1,11 +1,14 @@
 package scheduler
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/publish-carousel/s3"
 
@@ -43,7 +46,10 @@ func TestWriteMetadata(t *testing.T) {
 	s3rw.On("Write",
 		id,
 		mock.MatchedBy(func(actual string) bool { return regexp.MustCompile(`\d{8}T\d{8}`).MatchString(actual) }),
-		mock.MatchedBy(func(actual []byte) bool { return true }),
+		mock.MatchedBy(func(actual []byte) bool {
+			saved, err := decodeSavedCycle(bytes.NewReader(actual))
+			return err == nil && saved.Version == checkpointVersion && reflect.DeepEqual(saved.Config, cfg)
+		}),
 		"application/json").Return(nil)
 
 	rw := s3MetadataReadWriter{s3rw}
@@ -92,12 +98,12 @@ func TestRead(t *testing.T) {
 
 	assert.NoError(t, err)
 
-	assert.Equal(t, uuid, md.CurrentPublishUUID, "current publish UUID")
-	assert.Equal(t, errors, md.Errors, "errors")
-	assert.Equal(t, progress, md.Progress, "progress")
-	assert.Equal(t, completed, md.Completed, "completed")
-	assert.Equal(t, total, md.Total, "total")
-	assert.Equal(t, iteration, md.Iteration, "iteration")
+	assert.Equal(t, uuid, md.Metadata.CurrentPublishUUID, "current publish UUID")
+	assert.Equal(t, errors, md.Metadata.Errors, "errors")
+	assert.Equal(t, progress, md.Metadata.Progress, "progress")
+	assert.Equal(t, completed, md.Metadata.Completed, "completed")
+	assert.Equal(t, total, md.Metadata.Total, "total")
+	assert.Equal(t, iteration, md.Metadata.Iteration, "iteration")
 
 	s3rw.AssertExpectations(t)
 }
@@ -107,3 +113,74 @@ type nopCloser struct {
 }
 
 func (nopCloser) Close() error { return nil }
+
+func TestCheckpoints(t *testing.T) {
+	id := "test-cycle-id"
+	older := time.Now().Add(-time.Hour)
+	newer := time.Now()
+
+	s3rw := new(s3.MockReadWriter)
+	s3rw.On("List", id).Return([]s3.Object{{Key: id + "/20170102T03040599", LastModified: older}, {Key: id + "/20170102T04040599", LastModified: newer}}, nil)
+
+	rw := s3MetadataReadWriter{s3rw}
+	checkpoints, err := rw.Checkpoints(id)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Checkpoint{{Key: "20170102T04040599", Saved: newer}, {Key: "20170102T03040599", Saved: older}}, checkpoints)
+	s3rw.AssertExpectations(t)
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	id := "test-cycle-id"
+	contentType := "application/json"
+
+	s3rw := new(s3.MockReadWriter)
+	s3rw.On("Read", id+"/20170102T03040599").Return(true, nopCloser{strings.NewReader(`{"metadata":{"completed":2,"iteration":4}}`)}, &contentType, nil)
+
+	rw := s3MetadataReadWriter{s3rw}
+	md, err := rw.LoadCheckpoint(id, "20170102T03040599")
+
+	assert.NoError(t, err)
+	assert.Equal(t, legacyCheckpointVersion, md.Version, "checkpoints without a version are legacy checkpoints")
+	assert.Equal(t, 2, md.Metadata.Completed)
+	assert.Equal(t, 4, md.Metadata.Iteration)
+	s3rw.AssertExpectations(t)
+}
+
+func TestLoadCheckpointNotFound(t *testing.T) {
+	id := "test-cycle-id"
+	var contentType *string
+
+	s3rw := new(s3.MockReadWriter)
+	s3rw.On("Read", id+"/missing").Return(false, nopCloser{strings.NewReader("")}, contentType, nil)
+
+	rw := s3MetadataReadWriter{s3rw}
+
+	_, err := rw.LoadCheckpoint(id, "missing")
+	assert.Equal(t, ErrCheckpointNotFound, err)
+
+	_, err = rw.LoadCheckpoint(id, "../another-cycle-id/20170102T03040599")
+	assert.Equal(t, ErrCheckpointNotFound, err, "checkpoints of other cycles should not be readable")
+
+	s3rw.AssertExpectations(t)
+}
+
+func TestPruneCheckpoints(t *testing.T) {
+	id := "test-cycle-id"
+	now := time.Now()
+
+	s3rw := new(s3.MockReadWriter)
+	s3rw.On("List", id).Return([]s3.Object{
+		{Key: id + "/1", LastModified: now.Add(-3 * time.Hour)},
+		{Key: id + "/2", LastModified: now.Add(-2 * time.Hour)},
+		{Key: id + "/3", LastModified: now.Add(-time.Hour)},
+	}, nil)
+	s3rw.On("Delete", id+"/1").Return(nil)
+
+	rw := s3MetadataReadWriter{s3rw}
+	pruned, err := rw.PruneCheckpoints(id, s3.RetentionPolicy{KeepLast: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+	s3rw.AssertExpectations(t)
+}
@@ -11,9 +11,9 @@ type MockMetadataRW struct {
 	mock.Mock
 }
 
-func (m *MockMetadataRW) LoadMetadata(id string) (CycleMetadata, error) {
+func (m *MockMetadataRW) LoadMetadata(id string) (SavedCycle, error) {
 	args := m.Called(id)
-	return args.Get(0).(CycleMetadata), args.Error(1)
+	return args.Get(0).(SavedCycle), args.Error(1)
 }
 
 func (m *MockMetadataRW) WriteMetadata(id string, config CycleConfig, metadata CycleMetadata) error {
@@ -63,6 +63,21 @@ func (m *MockScheduler) RestorePreviousState() {
 	m.Called()
 }
 
+func (m *MockScheduler) Checkpoints(cycleID string) ([]Checkpoint, error) {
+	args := m.Called(cycleID)
+	return args.Get(0).([]Checkpoint), args.Error(1)
+}
+
+func (m *MockScheduler) RestoreCheckpoint(cycleID string, key string) error {
+	args := m.Called(cycleID, key)
+	return args.Error(0)
+}
+
+func (m *MockScheduler) FailedCheckpoints() map[string]string {
+	args := m.Called()
+	return args.Get(0).(map[string]string)
+}
+
 func (m *MockScheduler) Start() error {
 	args := m.Called()
 	return args.Error(0)
@@ -141,6 +156,10 @@ func (m *MockCycle) SetMetadata(state CycleMetadata) {
 	m.Called(state)
 }
 
+func (m *MockCycle) SetRecovery(recovery *RecoveryMetadata) {
+	m.Called(recovery)
+}
+
 func (m *MockCycle) TransformToConfig() CycleConfig {
 	args := m.Called()
 	return args.Get(0).(CycleConfig)
@@ -168,3 +187,17 @@ func (m *MockThrottle) Interval() time.Duration {
 	args := m.Called()
 	return args.Get(0).(time.Duration)
 }
+
+type MockVersionedStore struct {
+	mock.Mock
+}
+
+func (m *MockVersionedStore) Get(key string) (string, uint64, bool, error) {
+	args := m.Called(key)
+	return args.String(0), args.Get(1).(uint64), args.Bool(2), args.Error(3)
+}
+
+func (m *MockVersionedStore) CompareAndSwap(key string, value string, version uint64) (uint64, bool, error) {
+	args := m.Called(key, value, version)
+	return args.Get(0).(uint64), args.Bool(1), args.Error(2)
+}
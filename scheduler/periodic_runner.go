@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+// periodicRunner runs a function in the background every interval, until stopped
+type periodicRunner struct {
+	sync.Mutex
+	ticker   *time.Ticker
+	interval time.Duration
+	stopChan chan bool
+}
+
+func newPeriodicRunner(interval time.Duration) *periodicRunner {
+	return &periodicRunner{
+		interval: interval,
+		stopChan: make(chan bool),
+	}
+}
+
+func (r *periodicRunner) start(f func()) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.ticker = time.NewTicker(r.interval)
+
+	go func() {
+		for {
+			select {
+			case <-r.ticker.C:
+				f()
+			case <-r.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+func (r *periodicRunner) stop() {
+	r.Lock()
+	defer r.Unlock()
+	r.stopChan <- true
+	r.ticker.Stop()
+}
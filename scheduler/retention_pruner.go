@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// RetentionPruner periodically deletes expired checkpoints and uuid manifests
+type RetentionPruner struct {
+	sched                 Scheduler
+	metadataReadWriter    MetadataReadWriter
+	uuidCollectionBuilder *native.NativeUUIDCollectionBuilder
+	policy                s3.RetentionPolicy
+	runner                *periodicRunner
+}
+
+// NewRetentionPruner returns a new pruner, which will prune every interval once started
+func NewRetentionPruner(sched Scheduler, metadataReadWriter MetadataReadWriter, uuidCollectionBuilder *native.NativeUUIDCollectionBuilder, policy s3.RetentionPolicy, interval time.Duration) *RetentionPruner {
+	return &RetentionPruner{
+		sched:                 sched,
+		metadataReadWriter:    metadataReadWriter,
+		uuidCollectionBuilder: uuidCollectionBuilder,
+		policy:                policy,
+		runner:                newPeriodicRunner(interval),
+	}
+}
+
+// Start prunes in the background until stopped. Nothing is pruned if the policy is not enabled.
+func (p *RetentionPruner) Start() {
+	if !p.policy.Enabled() {
+		log.Info("No checkpoint retention policy configured, checkpoints and manifests will not be pruned.")
+		return
+	}
+	p.runner.start(p.Prune)
+}
+
+// Stop stops pruning
+func (p *RetentionPruner) Stop() {
+	if p.policy.Enabled() {
+		p.runner.stop()
+	}
+}
+
+// Prune deletes the expired checkpoints and manifests of every cycle, keeping the manifests in use
+func (p *RetentionPruner) Prune() {
+	if !p.sched.IsRunning() {
+		log.Info("Scheduler is not running, skipping checkpoint pruning.")
+		return
+	}
+
+	history, supportsHistory := p.metadataReadWriter.(CheckpointHistory)
+	inUse := make(map[string][]string)
+
+	for id, cycle := range p.sched.Cycles() {
+		if !isCheckpointed(cycle) {
+			continue
+		}
+
+		if supportsHistory {
+			pruned, err := history.PruneCheckpoints(id, p.policy)
+			if err != nil {
+				log.WithError(err).WithField("cycle", id).Warn("Failed to prune cycle checkpoints.")
+			} else if pruned > 0 {
+				log.WithField("cycle", id).WithField("pruned", pruned).Info("Pruned cycle checkpoints.")
+			}
+		}
+
+		collection := cycle.TransformToConfig().Collection
+		if _, ok := inUse[collection]; !ok {
+			inUse[collection] = []string{}
+		}
+
+		if position := cycle.Metadata().Position; position != nil && position.Manifest != "" {
+			inUse[collection] = append(inUse[collection], position.Manifest)
+		}
+	}
+
+	if p.uuidCollectionBuilder == nil {
+		return
+	}
+
+	for collection, manifests := range inUse {
+		pruned, err := p.uuidCollectionBuilder.PruneManifests(collection, p.policy, manifests...)
+		if err != nil {
+			log.WithError(err).WithField("collection", collection).Warn("Failed to prune uuid manifests.")
+		} else if pruned > 0 {
+			log.WithField("collection", collection).WithField("pruned", pruned).Info("Pruned uuid manifests.")
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/blacklist"
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/s3"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRetentionPrunerPrunesCheckpointsAndManifests(t *testing.T) {
+	now := time.Now()
+	policy := s3.RetentionPolicy{KeepLast: 1}
+
+	cycle := new(MockCycle)
+	cycle.On("Type").Return(ThrottledWholeCollectionType)
+	cycle.On("TransformToConfig").Return(CycleConfig{Collection: "collection"})
+	cycle.On("Metadata").Return(CycleMetadata{Position: &native.Position{Manifest: "collection-uuids/2.json"}})
+
+	windowCycle := new(MockCycle)
+	windowCycle.On("Type").Return("ScalingWindow")
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Cycles").Return(map[string]Cycle{"id1": cycle, "id2": windowCycle})
+
+	rw := new(s3.MockReadWriter)
+	rw.On("List", "id1").Return([]s3.Object{{Key: "id1/1", LastModified: now.Add(-time.Hour)}, {Key: "id1/2", LastModified: now}}, nil)
+	rw.On("Delete", "id1/1").Return(nil)
+
+	rw.On("List", "collection-uuids").Return([]s3.Object{
+		{Key: "collection-uuids/1.json", LastModified: now.Add(-2 * time.Hour)},
+		{Key: "collection-uuids/2.json", LastModified: now.Add(-time.Hour)},
+		{Key: "collection-uuids/3.json", LastModified: now},
+	}, nil)
+	var contentType *string
+	rw.On("Read", "collection-uuids/1.json").Return(false, nopCloser{}, contentType, nil)
+	rw.On("Delete", "collection-uuids/1.json").Return(nil)
+
+	builder := native.NewNativeUUIDCollectionBuilder(new(native.MockDB), rw, blacklist.NoOpBlacklist)
+	pruner := NewRetentionPruner(sched, NewS3MetadataReadWriter(rw), builder, policy, time.Hour)
+	pruner.Prune()
+
+	rw.AssertExpectations(t)
+	rw.AssertNotCalled(t, "Delete", "collection-uuids/2.json")
+	rw.AssertNotCalled(t, "List", "id2")
+}
+
+func TestRetentionPrunerSkipsWhenSchedulerIsNotRunning(t *testing.T) {
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(false)
+
+	rw := new(s3.MockReadWriter)
+	pruner := NewRetentionPruner(sched, NewS3MetadataReadWriter(rw), nil, s3.RetentionPolicy{KeepLast: 1}, time.Hour)
+	pruner.Prune()
+
+	sched.AssertNotCalled(t, "Cycles")
+	rw.AssertNotCalled(t, "List", mock.Anything)
+}
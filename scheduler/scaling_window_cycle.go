@@ -9,6 +9,50 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	ScalingWindowType = "ScalingWindow"
+)
+
+func init() {
+	mustRegisterCycleFactory(scalingWindowFactory{})
+}
+
+type scalingWindowFactory struct{}
+
+func (scalingWindowFactory) Type() string {
+	return ScalingWindowType
+}
+
+func (scalingWindowFactory) Schema() []ConfigField {
+	return []ConfigField{{Name: "timeWindow", Type: "duration", Required: true}, {Name: "minimumThrottle", Type: "duration", Required: true}, {Name: "maximumThrottle", Type: "duration", Required: true}}
+}
+
+func (scalingWindowFactory) Validate(config CycleConfig) error {
+	return checkDurations(config.Name, config.TimeWindow, config.MinimumThrottle, config.MaximumThrottle)
+}
+
+func (scalingWindowFactory) New(config CycleConfig, deps CycleDependencies) Cycle {
+	coolDown, _ := time.ParseDuration(config.CoolDown)
+	timeWindow, _ := time.ParseDuration(config.TimeWindow)
+	minimumThrottle, _ := time.ParseDuration(config.MinimumThrottle)
+	maximumThrottle, _ := time.ParseDuration(config.MaximumThrottle)
+	return NewScalingWindowCycle(config.Name, deps.UUIDCollectionBuilder, config.Collection, config.Origin, timeWindow, coolDown, minimumThrottle, maximumThrottle, deps.PublishTask)
+}
+
+// Checkpointed is false, as scaling window cycles always start from the most recent time window
+func (scalingWindowFactory) Checkpointed() bool {
+	return false
+}
+
+// Throttle is false, as scaling window cycles compute their throttle for each time window
+func (scalingWindowFactory) Throttle(cycle Cycle) (Throttle, bool) {
+	return nil, false
+}
+
+func (scalingWindowFactory) WithThrottle(config CycleConfig, interval time.Duration) CycleConfig {
+	return config
+}
+
 type ScalingWindowCycle struct {
 	*abstractTimeWindowedCycle
 	maximumThrottle time.Duration
@@ -27,26 +71,32 @@ func NewScalingWindowCycle(
 	publishTask tasks.Task,
 ) Cycle {
 
-	base := newAbstractCycle(name, "ScalingWindow", uuidCollectionBuilder, dbCollection, origin, coolDown, publishTask)
-	return &ScalingWindowCycle{
+	base := newAbstractCycle(name, ScalingWindowType, uuidCollectionBuilder, dbCollection, origin, coolDown, publishTask)
+	cycle := &ScalingWindowCycle{
 		newAbstractTimeWindowedCycle(base, timeWindow, minimumThrottle, maximumThrottle),
 		maximumThrottle,
 		maximumThrottle.String(),
 	}
+	cycle.resume = cycle.Start
+	return cycle
 }
 
 func (s *ScalingWindowCycle) Start() {
 	log.WithField("id", s.CycleID).WithField("name", s.CycleName).WithField("collection", s.DBCollection).WithField("coolDown", s.CoolDown).WithField("timeWindow", s.TimeWindow).Info("Starting scaling window cycle.")
-	ctx, cancel := context.WithCancel(context.Background())
-	s.cancel = cancel
-	s.UpdateState(startingState)
 
 	throttle := func(publishes int) (Throttle, context.CancelFunc) {
 		return NewCappedDynamicThrottle(s.timeWindow, s.minimumThrottle, s.maximumThrottle, publishes, 1)
 	}
-	go s.start(ctx, throttle)
+	s.run(func(ctx context.Context) {
+		s.start(ctx, throttle)
+	})
+}
+
+// PublishInterval is the maximum throttle, as the cycle never waits longer than it between publishes
+func (s *ScalingWindowCycle) PublishInterval() time.Duration {
+	return s.maximumThrottle
 }
 
 func (s *ScalingWindowCycle) TransformToConfig() CycleConfig {
-	return CycleConfig{Name: s.CycleName, Type: s.CycleType, Collection: s.DBCollection, TimeWindow: s.TimeWindow, CoolDown: s.CoolDown, MinimumThrottle: s.MinimumThrottle, MaximumThrottle: s.MaximumThrottle}
+	return CycleConfig{Name: s.CycleName, Type: s.CycleType, Collection: s.DBCollection, TimeWindow: s.TimeWindow, CoolDown: s.CoolDown, MinimumThrottle: s.MinimumThrottle, MaximumThrottle: s.MaximumThrottle, Notifier: s.Notifier, Notifiers: s.Notifiers, FanOut: s.FanOut, Task: s.Task, ErrorGuard: s.ErrorGuard}
 }
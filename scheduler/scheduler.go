@@ -8,8 +8,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/filter"
 	"github.com/Financial-Times/publish-carousel/native"
 	"github.com/Financial-Times/publish-carousel/tasks"
+	"github.com/Financial-Times/publish-carousel/transform"
+	"github.com/Financial-Times/publish-carousel/verify"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -21,6 +25,9 @@ type Scheduler interface {
 	AddCycle(cycle Cycle) error
 	DeleteCycle(cycleID string) error
 	RestorePreviousState()
+	Checkpoints(cycleID string) ([]Checkpoint, error)
+	RestoreCheckpoint(cycleID string, key string) error
+	FailedCheckpoints() map[string]string
 	Start() error
 	Shutdown() error
 	ManualToggleHandler(toggleValue string)
@@ -34,13 +41,20 @@ type Scheduler interface {
 type defaultScheduler struct {
 	uuidCollectionBuilder *native.NativeUUIDCollectionBuilder
 	publishTask           tasks.Task
+	registry              tasks.Registry
+	notifiers             map[string]cms.Notifier
 	cycles                map[string]Cycle
 	metadataReadWriter    MetadataReadWriter
 	cycleLock             *sync.RWMutex
 	state                 *schedulerState
 	toggleHandlerLock     *sync.Mutex
 	defaultThrottle       time.Duration
-	checkpointHandler     *checkpointHandler
+	checkpointRunner      *periodicRunner
+	filters               *filter.Config
+	transforms            *transform.Config
+	verifier              verify.Verifier
+	failedCheckpoints     map[string]string
+	failedCheckpointsLock *sync.RWMutex
 }
 
 // NewScheduler returns a new instance of the cycles scheduler
@@ -54,7 +68,9 @@ func NewScheduler(uuidCollectionBuilder *native.NativeUUIDCollectionBuilder, pub
 		state:                 newSchedulerState(),
 		toggleHandlerLock:     &sync.Mutex{},
 		defaultThrottle:       defaultThrottle,
-		checkpointHandler:     newCheckpointHandler(checkpointInterval),
+		checkpointRunner:      newPeriodicRunner(checkpointInterval),
+		failedCheckpoints:     map[string]string{},
+		failedCheckpointsLock: &sync.RWMutex{},
 	}
 }
 
@@ -82,30 +98,58 @@ func (s *defaultScheduler) AddCycle(c Cycle) error {
 
 func (s *defaultScheduler) DeleteCycle(cycleID string) error {
 	s.cycleLock.Lock()
-	defer s.cycleLock.Unlock()
-
 	c, ok := s.cycles[cycleID]
+	delete(s.cycles, cycleID)
+	s.cycleLock.Unlock()
+
 	if !ok {
 		return fmt.Errorf("Cannot stop cycle: cycle with id %v not found", cycleID)
 	}
 
 	c.Stop()
-	delete(s.cycles, cycleID)
 	return nil
 }
 
+// cycle returns the cycle with the given id, so that it can be stopped without holding the cycle lock
+func (s *defaultScheduler) cycle(cycleID string) (Cycle, bool) {
+	s.cycleLock.RLock()
+	defer s.cycleLock.RUnlock()
+
+	c, ok := s.cycles[cycleID]
+	return c, ok
+}
+
 func (s *defaultScheduler) saveCycleMetadata() {
-	log.Info("Saving cycle metadata to S3.")
+	log.Info("Saving cycle metadata to the state store.")
 
+	failed := make(map[string]string)
 	for _, cycle := range s.cycles {
-		switch cycle.(type) {
-		case *ThrottledWholeCollectionCycle:
-			err := s.metadataReadWriter.WriteMetadata(cycle.ID(), cycle.TransformToConfig(), cycle.Metadata())
-			if err != nil {
-				log.WithField("cycle", cycle.ID()).WithError(err).Error("cycle metadata not saved")
-			}
+		if !isCheckpointed(cycle) {
+			continue
+		}
+
+		err := s.metadataReadWriter.WriteMetadata(cycle.ID(), cycle.TransformToConfig(), cycle.Metadata())
+		if err != nil {
+			log.WithField("cycle", cycle.ID()).WithError(err).Error("cycle metadata not saved")
+			failed[cycle.ID()] = err.Error()
 		}
 	}
+
+	s.failedCheckpointsLock.Lock()
+	s.failedCheckpoints = failed
+	s.failedCheckpointsLock.Unlock()
+}
+
+// FailedCheckpoints returns the cycles whose metadata could not be saved at the last checkpoint, and why
+func (s *defaultScheduler) FailedCheckpoints() map[string]string {
+	s.failedCheckpointsLock.RLock()
+	defer s.failedCheckpointsLock.RUnlock()
+
+	failed := make(map[string]string)
+	for id, reason := range s.failedCheckpoints {
+		failed[id] = reason
+	}
+	return failed
 }
 
 func (s *defaultScheduler) RestorePreviousState() {
@@ -113,18 +157,72 @@ func (s *defaultScheduler) RestorePreviousState() {
 	defer s.cycleLock.Unlock()
 
 	for id, cycle := range s.cycles {
-		switch cycle.(type) {
-		case *ThrottledWholeCollectionCycle:
-			state, err := s.metadataReadWriter.LoadMetadata(id)
-			if err != nil {
-				log.WithError(err).Warn("Failed to retrieve carousel state from S3 - starting from initial state.")
-				continue
-			}
+		if !isCheckpointed(cycle) {
+			continue
+		}
+
+		saved, err := s.metadataReadWriter.LoadMetadata(id)
+		if err != nil {
+			log.WithError(err).Warn("Failed to retrieve carousel state from the state store - starting from initial state.")
+			continue
+		}
 
-			log.WithField("id", cycle.ID()).WithField("iteration", state.Iteration).WithField("completed", state.Completed).Info("Restoring state for cycle.")
-			cycle.SetMetadata(state)
+		state, decision := restoreSavedCycle(saved, cycle.TransformToConfig())
+		logger := log.WithField("id", cycle.ID()).WithField("version", decision.Version).WithField("decision", decision.Action)
+		if decision.Reason != "" {
+			logger = logger.WithField("reason", decision.Reason)
 		}
+
+		if decision.Abandoned() {
+			logger.Warn("Saved state for cycle is incompatible with its current configuration - starting from initial state.")
+		} else {
+			logger.WithField("iteration", state.Iteration).WithField("completed", state.Completed).Info("Restoring state for cycle.")
+		}
+		cycle.SetMetadata(state)
+	}
+}
+
+func (s *defaultScheduler) Checkpoints(cycleID string) ([]Checkpoint, error) {
+	history, ok := s.metadataReadWriter.(CheckpointHistory)
+	if !ok {
+		return nil, ErrCheckpointHistoryUnsupported
 	}
+
+	return history.Checkpoints(cycleID)
+}
+
+// RestoreCheckpoint stops the cycle, and restarts it from the given checkpoint if the scheduler is running
+func (s *defaultScheduler) RestoreCheckpoint(cycleID string, key string) error {
+	history, ok := s.metadataReadWriter.(CheckpointHistory)
+	if !ok {
+		return ErrCheckpointHistoryUnsupported
+	}
+
+	cycle, ok := s.cycle(cycleID)
+	if !ok {
+		return fmt.Errorf("Cannot restore cycle: cycle with id %v not found", cycleID)
+	}
+
+	saved, err := history.LoadCheckpoint(cycleID, key)
+	if err != nil {
+		return err
+	}
+
+	metadata, decision := restoreSavedCycle(saved, cycle.TransformToConfig())
+	if decision.Abandoned() {
+		return fmt.Errorf("%w: %v", ErrCheckpointIncompatible, decision.Reason)
+	}
+
+	log.WithField("id", cycleID).WithField("checkpoint", key).WithField("decision", decision.Action).WithField("iteration", metadata.Iteration).WithField("completed", metadata.Completed).Info("Restoring cycle from checkpoint.")
+
+	cycle.Stop()
+	metadata.State = []string{stoppedState}
+	cycle.SetMetadata(metadata)
+
+	if s.state.isEnabled() && s.state.isRunning() {
+		cycle.Start()
+	}
+	return nil
 }
 
 func (s *defaultScheduler) Start() error {
@@ -153,7 +251,7 @@ func (s *defaultScheduler) Start() error {
 		time.Sleep(startInterval)
 	}
 
-	s.checkpointHandler.start(func() {
+	s.checkpointRunner.start(func() {
 		s.cycleLock.RLock()
 		defer s.cycleLock.RUnlock()
 
@@ -163,47 +261,53 @@ func (s *defaultScheduler) Start() error {
 	return nil
 }
 
+// archiveCycleStartInterval divides the shortest throttle between the cycles, to stagger their start
 func (s *defaultScheduler) archiveCycleStartInterval() time.Duration {
-	var temp time.Duration
-	var archiveCycles []*ThrottledWholeCollectionCycle
-
+	var throttles []time.Duration
 	for _, cycle := range s.cycles {
-		if "ThrottledWholeCollection" == cycle.TransformToConfig().Type {
-			archiveCycles = append(archiveCycles, cycle.(*ThrottledWholeCollectionCycle))
+		if throttle, ok := cycleThrottle(cycle); ok {
+			throttles = append(throttles, throttle.Interval())
 		}
 	}
-	numArchiveCycles := len(archiveCycles)
 
-	if numArchiveCycles > 1 {
-		minimumStartInterval := archiveCycles[0].Throttle.Interval()
+	if len(throttles) < 2 {
+		return 0
+	}
 
-		for _, cycle := range archiveCycles {
-			temp = cycle.Throttle.Interval()
-			if temp < minimumStartInterval {
-				minimumStartInterval = temp
-			}
+	minimumStartInterval := throttles[0]
+	for _, interval := range throttles {
+		if interval < minimumStartInterval {
+			minimumStartInterval = interval
 		}
-		return minimumStartInterval / time.Duration(numArchiveCycles)
 	}
-	return temp
+	return minimumStartInterval / time.Duration(len(throttles))
 }
 
 func (s *defaultScheduler) Shutdown() error {
-	s.cycleLock.RLock()
-	defer s.cycleLock.RUnlock()
 	log.Info("Scheduler shutdown initiated.")
 
 	if !s.state.isRunning() {
 		return errors.New("Scheduler has already been shut down")
 	}
 
+	s.cycleLock.RLock()
+	cycles := make(map[string]Cycle, len(s.cycles))
 	for id, cycle := range s.cycles {
+		cycles[id] = cycle
+	}
+	s.cycleLock.RUnlock()
+
+	for id, cycle := range cycles {
 		log.WithField("id", id).Info("Stopping cycle.")
 		cycle.Stop()
 	}
 
 	s.state.setState(stopped)
-	s.checkpointHandler.stop()
+	s.checkpointRunner.stop()
+
+	s.cycleLock.RLock()
+	defer s.cycleLock.RUnlock()
+
 	s.saveCycleMetadata()
 	return nil
 }
@@ -332,27 +436,156 @@ func (s *defaultScheduler) NewCycle(config CycleConfig) (Cycle, error) {
 		return nil, err
 	}
 
-	var c Cycle
-	coolDown, _ := time.ParseDuration(config.CoolDown)
+	factory, ok := LookupCycleFactory(config.Type)
+	if !ok {
+		return nil, fmt.Errorf("Please provide a valid type for cycle %v", config.Name)
+	}
 
-	switch strings.ToLower(config.Type) {
-	case "throttledwholecollection":
-		var throttleInterval time.Duration
-		if config.Throttle == "" {
-			log.WithField("cycleName", config.Name).Infof("Throttle configuration not found. Setting default throttle value (%v)", s.defaultThrottle)
-			throttleInterval = s.defaultThrottle
-		} else {
-			throttleInterval, _ = time.ParseDuration(config.Throttle)
-		}
-		t, _ := NewThrottle(throttleInterval, 1)
-		c = NewThrottledWholeCollectionCycle(config.Name, s.uuidCollectionBuilder, config.Collection, config.Origin, coolDown, t, s.publishTask)
+	definition, err := s.taskDefinition(config)
+	if err != nil {
+		return nil, err
+	}
+
+	publishTask, err := s.newCyclePublishTask(config, definition)
+	if err != nil {
+		return nil, err
+	}
+	config.Task = definition.Name
+
+	if s.filters != nil {
+		publishTask = tasks.NewFilteredTask(publishTask, s.filters.ForCycle(config.Name))
+	}
+
+	if s.transforms != nil {
+		publishTask = tasks.NewTransformedTask(publishTask, s.transforms.ForCycle(config.Name))
+	}
+
+	c := factory.New(config, CycleDependencies{UUIDCollectionBuilder: s.uuidCollectionBuilder, PublishTask: publishTask, DefaultThrottle: s.defaultThrottle})
+
+	if n, ok := c.(interface{ setNotifiers(CycleConfig) }); ok {
+		n.setNotifiers(config)
+	}
+
+	if b, ok := c.(interface {
+		setBreakers(string, map[string]cms.Breaker)
+	}); ok {
+		b.setBreakers(s.cycleBreakers(config, definition))
+	}
+
+	if v, ok := c.(interface{ setVerifier(verify.Verifier) }); ok && s.verifier != nil {
+		v.setVerifier(s.verifier)
+	}
 
-	case "scalingwindow":
-		timeWindow, _ := time.ParseDuration(config.TimeWindow)
-		minimumThrottle, _ := time.ParseDuration(config.MinimumThrottle)
-		maximumThrottle, _ := time.ParseDuration(config.MaximumThrottle)
-		c = NewScalingWindowCycle(config.Name, s.uuidCollectionBuilder, config.Collection, config.Origin, timeWindow, coolDown, minimumThrottle, maximumThrottle, s.publishTask)
+	if g, ok := c.(interface{ setErrorGuard(*ErrorGuardConfig) }); ok && config.ErrorGuard != nil {
+		g.setErrorGuard(config.ErrorGuard)
 	}
 
 	return c, nil
 }
+
+// taskDefinition returns the definition of the task selected by the cycle
+func (s *defaultScheduler) taskDefinition(config CycleConfig) (tasks.Definition, error) {
+	if s.registry == nil {
+		if isContentTask(config.Task) {
+			return tasks.Definition{Name: tasks.ContentTaskName}, nil
+		}
+		return tasks.Definition{}, fmt.Errorf("Unknown task %v for cycle %v", config.Task, config.Name)
+	}
+
+	definition, ok := s.registry.Lookup(config.Task)
+	if !ok {
+		return tasks.Definition{}, fmt.Errorf("Unknown task %v for cycle %v, please use one of %v", config.Task, config.Name, strings.Join(s.registry.Names(), ", "))
+	}
+	return definition, nil
+}
+
+func isContentTask(name string) bool {
+	return name == "" || strings.EqualFold(name, tasks.ContentTaskName)
+}
+
+// newCyclePublishTask returns the default publish task, unless the cycle selects another task or notifier
+func (s *defaultScheduler) newCyclePublishTask(config CycleConfig, definition tasks.Definition) (tasks.Task, error) {
+	if isContentTask(definition.Name) && config.Notifier == "" && len(config.Notifiers) == 0 {
+		return s.publishTask, nil
+	}
+
+	if s.registry == nil {
+		return nil, fmt.Errorf("Cannot build task %v for cycle %v without a task registry", definition.Name, config.Name)
+	}
+
+	notifier, err := s.cycleNotifier(config, definition)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.registry.NewTask(definition.Name, notifier)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid task %v for cycle %v: %v", definition.Name, config.Name, err)
+	}
+	return task, nil
+}
+
+// defaultNotifier returns the task's own notifier, or the cms-notifier
+func defaultNotifier(definition tasks.Definition) string {
+	if definition.Notifier != "" {
+		return definition.Notifier
+	}
+	return cms.CMSNotifierName
+}
+
+// cycleNotifier returns the notifier selected by the cycle, or a fan-out notifier if it publishes to several notifiers
+func (s *defaultScheduler) cycleNotifier(config CycleConfig, definition tasks.Definition) (cms.Notifier, error) {
+	if len(config.Notifiers) == 0 {
+		name := config.Notifier
+		if name == "" {
+			name = defaultNotifier(definition)
+		}
+
+		notifier, ok := s.notifiers[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown notifier %v for cycle %v", name, config.Name)
+		}
+		return notifier, nil
+	}
+
+	var targets []cms.Target
+	for _, name := range config.Notifiers {
+		notifier, ok := s.notifiers[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown notifier %v for cycle %v", name, config.Name)
+		}
+		targets = append(targets, cms.Target{Name: name, Notifier: notifier})
+	}
+
+	mode := config.FanOut
+	if mode == "" {
+		mode = cms.FanOutAll
+	}
+	return cms.NewFanOutNotifier(mode, targets)
+}
+
+// cycleBreakers returns the circuit breakers which the cycle has to wait for, and whether it waits for all or any of them
+func (s *defaultScheduler) cycleBreakers(config CycleConfig, definition tasks.Definition) (string, map[string]cms.Breaker) {
+	mode := strings.ToLower(config.FanOut)
+	if mode == "" {
+		mode = cms.FanOutAll
+	}
+
+	names := config.Notifiers
+	switch {
+	case len(names) == 0 && config.Notifier != "":
+		names = []string{config.Notifier}
+	case len(names) == 0:
+		names = []string{defaultNotifier(definition)}
+	case mode == cms.FanOutPrimary:
+		names = names[:1]
+	}
+
+	breakers := make(map[string]cms.Breaker)
+	for _, name := range names {
+		if breaker, ok := s.notifiers[name].(cms.Breaker); ok {
+			breakers[name] = breaker
+		}
+	}
+	return mode, breakers
+}
@@ -1,13 +1,22 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Financial-Times/publish-carousel/blacklist"
+	"github.com/Financial-Times/publish-carousel/cms"
 	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/s3"
 	"github.com/Financial-Times/publish-carousel/tasks"
+	"github.com/Financial-Times/publish-carousel/verify"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSchedulerShouldStartWhenEnabled(t *testing.T) {
@@ -28,8 +37,8 @@ func TestSchedulerShouldStartWhenEnabled(t *testing.T) {
 	c2.On("ID").Return("id2")
 	c1.On("Start").Return()
 	c2.On("Start").Return()
-	c1.On("TransformToConfig").Return(CycleConfig{Type: "test"})
-	c2.On("TransformToConfig").Return(CycleConfig{Type: "test"})
+	c1.On("Type").Return("test")
+	c2.On("Type").Return("test")
 
 	s.AddCycle(c1)
 	s.AddCycle(c2)
@@ -93,8 +102,8 @@ func TestSchedulerResumeAfterDisable(t *testing.T) {
 	c2.On("Start").Return()
 	c1.On("Stop").Return()
 	c2.On("Stop").Return()
-	c1.On("TransformToConfig").Return(CycleConfig{Type: "test"})
-	c2.On("TransformToConfig").Return(CycleConfig{Type: "test"})
+	c1.On("Type").Return("test")
+	c2.On("Type").Return("test")
 
 	s.AddCycle(c1)
 	s.AddCycle(c2)
@@ -187,8 +196,8 @@ func TestAutomaticToggleDisabledAndManualToggleEnabled(t *testing.T) {
 	c2.On("Start").Return()
 	c1.On("Stop").Return()
 	c2.On("Stop").Return()
-	c1.On("TransformToConfig").Return(CycleConfig{})
-	c2.On("TransformToConfig").Return(CycleConfig{})
+	c1.On("Type").Return("test")
+	c2.On("Type").Return("test")
 
 	s.AddCycle(c1)
 	s.AddCycle(c2)
@@ -275,8 +284,8 @@ func TestAutomaticToggleEnabledAndManualToggleDisabled(t *testing.T) {
 	c2.On("Start").Return()
 	c1.On("Stop").Return()
 	c2.On("Stop").Return()
-	c1.On("TransformToConfig").Return(CycleConfig{})
-	c2.On("TransformToConfig").Return(CycleConfig{})
+	c1.On("Type").Return("test")
+	c2.On("Type").Return("test")
 
 	s.AddCycle(c1)
 	s.AddCycle(c2)
@@ -436,6 +445,7 @@ func TestSaveCycleMetadata(t *testing.T) {
 	c1.On("ID").Return(id1)
 	c1.On("Start").Return()
 	c1.On("TransformToConfig").Return(CycleConfig{Type: "test"})
+	c1.On("Type").Return("test")
 
 	db := new(native.MockDB)
 	dbCollection := "testCollection"
@@ -461,6 +471,27 @@ func TestSaveCycleMetadata(t *testing.T) {
 	rw.AssertExpectations(t)
 }
 
+func TestSaveCycleMetadataRecordsFailures(t *testing.T) {
+	throttle, _ := NewThrottle(time.Second, 1)
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(new(native.MockDB), nil, blacklist.NoOpBlacklist)
+	c := NewThrottledWholeCollectionCycle("test", uuidCollectionBuilder, "testCollection", "testOrigin", time.Minute, throttle, nil)
+
+	rw := MockMetadataRW{}
+	rw.On("WriteMetadata", c.ID(), c.TransformToConfig(), c.Metadata()).Return(errors.New(`State for "test" has been modified by another instance`)).Once()
+
+	s := NewScheduler(uuidCollectionBuilder, &tasks.MockTask{}, &rw, time.Minute, time.Minute)
+	s.AddCycle(c)
+
+	assert.Empty(t, s.FailedCheckpoints())
+
+	s.(*defaultScheduler).saveCycleMetadata()
+	assert.Equal(t, map[string]string{c.ID(): `State for "test" has been modified by another instance`}, s.FailedCheckpoints())
+
+	rw.On("WriteMetadata", c.ID(), c.TransformToConfig(), c.Metadata()).Return(nil)
+	s.(*defaultScheduler).saveCycleMetadata()
+	assert.Empty(t, s.FailedCheckpoints(), "the failure should be cleared once the cycle is checkpointed again")
+}
+
 func TestCalculateArchiveCycleStartInterval(t *testing.T) {
 	assert := assert.New(t)
 	id1 := "id1"
@@ -468,7 +499,7 @@ func TestCalculateArchiveCycleStartInterval(t *testing.T) {
 	c1 := new(MockCycle)
 	c1.On("ID").Return(id1)
 	c1.On("Start").Return()
-	c1.On("TransformToConfig").Return(CycleConfig{Type: "test"})
+	c1.On("Type").Return("test")
 
 	db := new(native.MockDB)
 	dbCollection := "testCollection"
@@ -497,3 +528,311 @@ func TestCalculateArchiveCycleStartInterval(t *testing.T) {
 	expected, _ := time.ParseDuration("500ms")
 	assert.Equal(expected, testIterval, "test interval should be 500ms")
 }
+
+func TestRestoreCheckpoint(t *testing.T) {
+	contentType := "application/json"
+	cfg := CycleConfig{Name: "test-cycle", Type: ThrottledWholeCollectionType, Collection: "test-collection"}
+
+	s3rw := new(s3.MockReadWriter)
+	s3rw.On("Read", "id1/20170102T03040599").Return(true, ioutil.NopCloser(strings.NewReader(`{"version":2,"config":{"name":"test-cycle","type":"ThrottledWholeCollection","collection":"test-collection"},"metadata":{"completed":2,"iteration":4,"state":["running"]}}`)), &contentType, nil)
+
+	c1 := new(MockCycle)
+	c1.On("ID").Return("id1")
+	c1.On("TransformToConfig").Return(cfg)
+	c1.On("Stop").Return()
+	c1.On("SetMetadata", mock.MatchedBy(func(md CycleMetadata) bool {
+		return md.Completed == 2 && md.Iteration == 4 && assert.ObjectsAreEqual([]string{stoppedState}, md.State) && md.Restore != nil && md.Restore.Action == restoredCheckpoint
+	})).Return()
+
+	s := NewScheduler(nil, &tasks.MockTask{}, NewS3MetadataReadWriter(s3rw), 1*time.Minute, 1*time.Minute)
+	s.AddCycle(c1)
+
+	err := s.RestoreCheckpoint("id1", "20170102T03040599")
+	assert.NoError(t, err)
+
+	c1.AssertExpectations(t)
+	c1.AssertNotCalled(t, "Start")
+	s3rw.AssertExpectations(t)
+}
+
+func TestRestoreCheckpointIncompatible(t *testing.T) {
+	contentType := "application/json"
+	cfg := CycleConfig{Name: "test-cycle", Type: ThrottledWholeCollectionType, Collection: "another-collection"}
+
+	s3rw := new(s3.MockReadWriter)
+	s3rw.On("Read", "id1/20170102T03040599").Return(true, ioutil.NopCloser(strings.NewReader(`{"version":2,"config":{"name":"test-cycle","type":"ThrottledWholeCollection","collection":"test-collection"},"metadata":{"completed":2,"iteration":4}}`)), &contentType, nil)
+
+	c1 := new(MockCycle)
+	c1.On("ID").Return("id1")
+	c1.On("TransformToConfig").Return(cfg)
+
+	s := NewScheduler(nil, &tasks.MockTask{}, NewS3MetadataReadWriter(s3rw), 1*time.Minute, 1*time.Minute)
+	s.AddCycle(c1)
+
+	err := s.RestoreCheckpoint("id1", "20170102T03040599")
+	assert.True(t, errors.Is(err, ErrCheckpointIncompatible))
+	assert.Contains(t, err.Error(), "collection changed from test-collection to another-collection")
+
+	c1.AssertNotCalled(t, "Stop")
+	c1.AssertNotCalled(t, "SetMetadata", mock.Anything)
+	s3rw.AssertExpectations(t)
+}
+
+func TestRestoreCheckpointUnsupported(t *testing.T) {
+	s := NewScheduler(nil, &tasks.MockTask{}, &MockMetadataRW{}, 1*time.Minute, 1*time.Minute)
+
+	_, err := s.Checkpoints("id1")
+	assert.Equal(t, ErrCheckpointHistoryUnsupported, err)
+
+	err = s.RestoreCheckpoint("id1", "20170102T03040599")
+	assert.Equal(t, ErrCheckpointHistoryUnsupported, err)
+}
+
+func TestSchedulerStopsCyclesWithoutHoldingTheCycleLock(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(s Scheduler) error
+	}{
+		{name: "delete", stop: func(s Scheduler) error { return s.DeleteCycle("id1") }},
+		{name: "shutdown", stop: func(s Scheduler) error { return s.Shutdown() }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stopping := make(chan struct{})
+			release := make(chan struct{})
+
+			c1 := new(MockCycle)
+			c1.On("ID").Return("id1")
+			c1.On("Type").Return("test")
+			c1.On("Start").Return()
+			c1.On("Stop").Run(func(mock.Arguments) {
+				close(stopping)
+				<-release
+			}).Return()
+
+			s := NewScheduler(nil, &tasks.MockTask{}, &MockMetadataRW{}, time.Minute, time.Minute)
+			s.AddCycle(c1)
+			s.ManualToggleHandler("true")
+			s.AutomaticToggleHandler("true")
+			require.NoError(t, s.Start())
+
+			stopped := make(chan error)
+			go func() {
+				stopped <- test.stop(s)
+			}()
+
+			c2 := new(MockCycle)
+			c2.On("ID").Return("id2")
+			c2.On("Type").Return("test")
+			c2.On("Start").Return()
+
+			<-stopping
+			added := make(chan error)
+			go func() {
+				added <- s.AddCycle(c2)
+			}()
+
+			select {
+			case err := <-added:
+				assert.NoError(t, err)
+			case <-time.After(time.Second):
+				t.Fatal("the scheduler should not hold the cycle lock while a cycle is stopping")
+			}
+
+			close(release)
+			assert.NoError(t, <-stopped)
+		})
+	}
+}
+
+func TestNewCycleWithNotifier(t *testing.T) {
+	defaultTask := &tasks.MockTask{}
+	kafka := &cms.MockNotifier{}
+
+	var taskNotifier cms.Notifier
+	s := NewScheduler(nil, defaultTask, &MockMetadataRW{}, time.Minute, time.Hour).(*defaultScheduler)
+	s.notifiers = map[string]cms.Notifier{"kafka": kafka}
+	s.registry = newTestRegistry(t, &taskNotifier, tasks.Definition{Name: "content"})
+
+	config := CycleConfig{Name: "test", Type: "ThrottledWholeCollection", Collection: "methode", Origin: "methode-web-pub", CoolDown: "5m", Throttle: "1s"}
+
+	c, err := s.NewCycle(config)
+	assert.NoError(t, err)
+	assert.True(t, c.(*ThrottledWholeCollectionCycle).publishTask == defaultTask)
+	assert.Empty(t, c.TransformToConfig().Notifier)
+
+	config.Notifier = "kafka"
+	c, err = s.NewCycle(config)
+	assert.NoError(t, err)
+	assert.True(t, taskNotifier == kafka)
+	assert.Equal(t, "kafka", c.TransformToConfig().Notifier)
+
+	config.Notifier = "pigeon"
+	_, err = s.NewCycle(config)
+	assert.EqualError(t, err, "Unknown notifier pigeon for cycle test")
+}
+
+func TestNewCycleWithTask(t *testing.T) {
+	defaultTask := &tasks.MockTask{}
+	consistencyTask := &tasks.MockTask{}
+	cmsNotifier := &cms.MockNotifier{}
+
+	var taskNotifier cms.Notifier
+	s := NewScheduler(nil, defaultTask, &MockMetadataRW{}, time.Minute, time.Hour).(*defaultScheduler)
+	s.notifiers = map[string]cms.Notifier{"cms": cmsNotifier}
+	s.registry = newTestRegistry(t, &taskNotifier, tasks.Definition{Name: "content"}, tasks.Definition{Name: "consistency-check", New: func(n cms.Notifier) (tasks.Task, error) {
+		taskNotifier = n
+		return consistencyTask, nil
+	}})
+
+	config := CycleConfig{Name: "test", Type: "ThrottledWholeCollection", Collection: "methode", Origin: "methode-web-pub", CoolDown: "5m", Throttle: "1s", Task: "consistency-check"}
+
+	c, err := s.NewCycle(config)
+	require.NoError(t, err)
+	assert.True(t, c.(*ThrottledWholeCollectionCycle).publishTask == consistencyTask)
+	assert.True(t, taskNotifier == cmsNotifier, "the task should publish to the cms notifier by default")
+	assert.Equal(t, "consistency-check", c.TransformToConfig().Task)
+
+	config.Task = ""
+	c, err = s.NewCycle(config)
+	require.NoError(t, err)
+	assert.True(t, c.(*ThrottledWholeCollectionCycle).publishTask == defaultTask)
+	assert.Equal(t, "content", c.TransformToConfig().Task, "the cycle should show the task it runs by default")
+
+	config.Task = "telepathy"
+	_, err = s.NewCycle(config)
+	assert.EqualError(t, err, "Unknown task telepathy for cycle test, please use one of consistency-check, content")
+
+	s.registry = nil
+	_, err = s.NewCycle(config)
+	assert.EqualError(t, err, "Unknown task telepathy for cycle test")
+}
+
+func TestNewCyclePublishesToTheTaskNotifier(t *testing.T) {
+	annotationsNotifier := &cms.MockNotifier{}
+
+	var taskNotifier cms.Notifier
+	s := NewScheduler(nil, &tasks.MockTask{}, &MockMetadataRW{}, time.Minute, time.Hour).(*defaultScheduler)
+	s.notifiers = map[string]cms.Notifier{"cms": &cms.MockNotifier{}}
+	s.registry = newTestRegistry(t, &taskNotifier, tasks.Definition{Name: "annotations", Notifier: "annotations"})
+
+	config := CycleConfig{Name: "pac-annotations", Type: "ThrottledWholeCollection", Collection: "pac-metadata", Origin: "pac", CoolDown: "5m", Throttle: "1s", Task: "annotations"}
+
+	_, err := s.NewCycle(config)
+	assert.EqualError(t, err, "Unknown notifier annotations for cycle pac-annotations")
+
+	s.notifiers["annotations"] = annotationsNotifier
+	_, err = s.NewCycle(config)
+	require.NoError(t, err)
+	assert.True(t, taskNotifier == annotationsNotifier, "annotations should be published to the annotations notifier by default")
+}
+
+// newTestRegistry registers the task definitions, with a mock task for any which cannot be built
+func newTestRegistry(t *testing.T, taskNotifier *cms.Notifier, definitions ...tasks.Definition) tasks.Registry {
+	registry := tasks.NewRegistry()
+	for _, definition := range definitions {
+		if definition.New == nil {
+			definition.New = func(n cms.Notifier) (tasks.Task, error) {
+				*taskNotifier = n
+				return &tasks.MockTask{}, nil
+			}
+		}
+		require.NoError(t, registry.Register(definition))
+	}
+	return registry
+}
+
+func TestNewCycleWithFanOut(t *testing.T) {
+	primary := &cms.MockNotifier{}
+	shadow := &cms.MockNotifier{}
+
+	var taskNotifier cms.Notifier
+	s := NewScheduler(nil, &tasks.MockTask{}, &MockMetadataRW{}, time.Minute, time.Hour).(*defaultScheduler)
+	s.notifiers = map[string]cms.Notifier{"cms": primary, "shadow": shadow}
+	s.registry = newTestRegistry(t, &taskNotifier, tasks.Definition{Name: "content"})
+
+	config := CycleConfig{Name: "test", Type: "ThrottledWholeCollection", Collection: "methode", Origin: "methode-web-pub", CoolDown: "5m", Throttle: "1s", Notifiers: []string{"cms", "shadow"}, FanOut: "primary"}
+
+	c, err := s.NewCycle(config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cms", "shadow"}, c.TransformToConfig().Notifiers)
+	assert.Equal(t, "primary", c.TransformToConfig().FanOut)
+
+	primary.On("Notify", mock.Anything, "origin", "tid_1234", mock.Anything, "hash").Return(nil)
+	shadow.On("Notify", mock.Anything, "origin", "tid_1234", mock.Anything, "hash").Return(errors.New("shadow is down"))
+
+	err = taskNotifier.Notify(context.Background(), "origin", "tid_1234", &native.Content{}, "hash")
+	fanOut := &cms.FanOutError{}
+	require.True(t, errors.As(err, &fanOut))
+	assert.False(t, fanOut.Failed, "only the primary target must succeed")
+
+	config.Notifiers = []string{"cms", "pigeon"}
+	_, err = s.NewCycle(config)
+	assert.EqualError(t, err, "Unknown notifier pigeon for cycle test")
+}
+
+func TestNewCycleWithBreakers(t *testing.T) {
+	breaker := &cms.MockBreaker{}
+	s := NewScheduler(nil, &tasks.MockTask{}, &MockMetadataRW{}, time.Minute, time.Hour).(*defaultScheduler)
+	s.notifiers = map[string]cms.Notifier{"cms": breaker, "shadow": &cms.MockNotifier{}}
+	s.registry = newTestRegistry(t, new(cms.Notifier), tasks.Definition{Name: "content"})
+
+	tests := []struct {
+		name      string
+		notifiers []string
+		fanOut    string
+		mode      string
+		breakers  map[string]cms.Breaker
+	}{
+		{name: "default notifier", mode: cms.FanOutAll, breakers: map[string]cms.Breaker{"cms": breaker}},
+		{name: "all", notifiers: []string{"shadow", "cms"}, mode: cms.FanOutAll, breakers: map[string]cms.Breaker{"cms": breaker}},
+		{name: "any", notifiers: []string{"shadow", "cms"}, fanOut: "any", mode: cms.FanOutAny, breakers: map[string]cms.Breaker{"cms": breaker}},
+		{name: "best-effort breaker", notifiers: []string{"shadow", "cms"}, fanOut: "primary", mode: cms.FanOutPrimary, breakers: map[string]cms.Breaker{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := CycleConfig{Name: "test", Type: "ThrottledWholeCollection", Collection: "methode", Origin: "methode-web-pub", CoolDown: "5m", Throttle: "1s", Notifiers: test.notifiers, FanOut: test.fanOut}
+
+			c, err := s.NewCycle(config)
+			require.NoError(t, err)
+
+			cycle := c.(*ThrottledWholeCollectionCycle)
+			assert.Equal(t, test.mode, cycle.breakerMode)
+			assert.Equal(t, test.breakers, cycle.breakers)
+		})
+	}
+}
+
+func TestNewCycleWithVerifier(t *testing.T) {
+	s := NewScheduler(nil, &tasks.MockTask{}, &MockMetadataRW{}, time.Minute, time.Hour).(*defaultScheduler)
+	config := CycleConfig{Name: "test", Type: "ThrottledWholeCollection", Collection: "methode", Origin: "methode-web-pub", CoolDown: "5m", Throttle: "1s"}
+
+	c, err := s.NewCycle(config)
+	require.NoError(t, err)
+	assert.Nil(t, c.(*ThrottledWholeCollectionCycle).verifier)
+
+	verifier := new(verify.MockVerifier)
+	s.verifier = verifier
+
+	c, err = s.NewCycle(config)
+	require.NoError(t, err)
+	assert.Equal(t, verifier, c.(*ThrottledWholeCollectionCycle).verifier)
+}
+
+func TestValidateNotifiers(t *testing.T) {
+	config := CycleConfig{Name: "test", Type: "ThrottledWholeCollection", Collection: "methode", Origin: "methode-web-pub", CoolDown: "5m", Throttle: "1s"}
+
+	config.Notifier = "cms"
+	config.Notifiers = []string{"cms", "shadow"}
+	assert.EqualError(t, config.Validate(), "Please provide either a notifier or fan-out notifiers for cycle test, not both")
+
+	config.Notifier = ""
+	config.FanOut = "some"
+	assert.EqualError(t, config.Validate(), "Please provide a valid fan-out mode for cycle test, i.e. all, any or primary")
+
+	config.Notifiers = nil
+	config.FanOut = "all"
+	assert.EqualError(t, config.Validate(), "Please provide the fan-out notifiers for cycle test")
+}
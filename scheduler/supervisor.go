@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Supervisor periodically restarts unhealthy cycles, backing off exponentially between attempts
+type Supervisor struct {
+	sync.Mutex
+	sched          Scheduler
+	initialBackoff time.Duration
+	maximumBackoff time.Duration
+	maxAttempts    int
+	healthyPeriod  time.Duration
+	runner         *periodicRunner
+	recoveries     map[string]*recovery
+	now            func() time.Time
+}
+
+type recovery struct {
+	attempts     int
+	nextAttempt  time.Time
+	gaveUp       bool
+	iteration    int
+	healthySince time.Time
+}
+
+// NewSupervisor returns a supervisor which checks the cycles every interval once started
+func NewSupervisor(sched Scheduler, initialBackoff time.Duration, maximumBackoff time.Duration, maxAttempts int, healthyPeriod time.Duration, interval time.Duration) *Supervisor {
+	return &Supervisor{
+		sched:          sched,
+		initialBackoff: initialBackoff,
+		maximumBackoff: maximumBackoff,
+		maxAttempts:    maxAttempts,
+		healthyPeriod:  healthyPeriod,
+		runner:         newPeriodicRunner(interval),
+		recoveries:     make(map[string]*recovery),
+		now:            time.Now,
+	}
+}
+
+// Start supervises the cycles in the background until stopped
+func (s *Supervisor) Start() {
+	s.runner.start(s.Check)
+}
+
+// Stop stops supervising the cycles
+func (s *Supervisor) Stop() {
+	s.runner.stop()
+}
+
+// Check restarts every unhealthy cycle which is due another attempt
+func (s *Supervisor) Check() {
+	if !s.sched.IsRunning() {
+		log.Info("Scheduler is not running, skipping the unhealthy cycle check.")
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	cycles := s.sched.Cycles()
+	for id := range s.recoveries {
+		if _, ok := cycles[id]; !ok {
+			delete(s.recoveries, id)
+		}
+	}
+
+	now := s.now()
+	for id, cycle := range cycles {
+		metadata := cycle.Metadata()
+		r, ok := s.recoveries[id]
+		if !isUnhealthy(metadata.State) {
+			if ok && s.recovered(r, metadata, now) {
+				log.WithField("id", id).WithField("name", cycle.Name()).WithField("attempts", r.attempts).Info("Cycle has recovered.")
+				delete(s.recoveries, id)
+			}
+			continue
+		}
+
+		if ok {
+			r.healthySince = time.Time{}
+		} else {
+			r = &recovery{nextAttempt: now.Add(s.backoff(0)), iteration: metadata.Iteration}
+			s.recoveries[id] = r
+			metadata.Recovery = &RecoveryMetadata{Attempts: []RecoveryAttempt{}}
+			log.WithField("id", id).WithField("name", cycle.Name()).WithField("reason", metadata.UnhealthyReason).WithField("nextAttempt", r.nextAttempt).Warn("Cycle is unhealthy, scheduling a restart.")
+		}
+
+		if r.gaveUp {
+			continue
+		}
+
+		recoveryMetadata := RecoveryMetadata{}
+		if metadata.Recovery != nil {
+			recoveryMetadata = *metadata.Recovery
+		}
+
+		if r.attempts >= s.maxAttempts {
+			r.gaveUp = true
+			recoveryMetadata.NextAttempt = nil
+			recoveryMetadata.GaveUp = true
+			cycle.SetRecovery(&recoveryMetadata)
+			log.WithField("id", id).WithField("name", cycle.Name()).WithField("attempts", r.attempts).WithField("reason", metadata.UnhealthyReason).Error("Cycle is still unhealthy after the maximum number of restarts, giving up until it is resumed.")
+			continue
+		}
+
+		if now.Before(r.nextAttempt) {
+			nextAttempt := r.nextAttempt
+			recoveryMetadata.NextAttempt = &nextAttempt
+			cycle.SetRecovery(&recoveryMetadata)
+			continue
+		}
+
+		r.attempts++
+		r.nextAttempt = now.Add(s.backoff(r.attempts))
+
+		recoveryMetadata.Attempts = append(append([]RecoveryAttempt{}, recoveryMetadata.Attempts...), RecoveryAttempt{Time: now, Reason: metadata.UnhealthyReason})
+		recoveryMetadata.NextAttempt = nil
+		cycle.SetRecovery(&recoveryMetadata)
+
+		log.WithField("id", id).WithField("name", cycle.Name()).WithField("attempt", r.attempts).WithField("reason", metadata.UnhealthyReason).Info("Restarting unhealthy cycle.")
+		cycle.Start()
+	}
+}
+
+// recovered is true once a restarted cycle has stayed healthy for the healthy period, finished an iteration or been resumed
+func (s *Supervisor) recovered(r *recovery, metadata CycleMetadata, now time.Time) bool {
+	if r.healthySince.IsZero() {
+		r.healthySince = now
+	}
+	return r.gaveUp || metadata.Iteration > r.iteration || now.Sub(r.healthySince) >= s.healthyPeriod
+}
+
+// backoff is the wait before the next restart of a cycle which has already been restarted the given number of times
+func (s *Supervisor) backoff(attempts int) time.Duration {
+	backoff := s.initialBackoff
+	for i := 0; i < attempts && backoff < s.maximumBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > s.maximumBackoff {
+		return s.maximumBackoff
+	}
+	return backoff
+}
+
+func isUnhealthy(states []string) bool {
+	for _, state := range states {
+		if state == unhealthyState {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type restartableCycle struct {
+	*MockCycle
+	metadata CycleMetadata
+	starts   int
+	fail     bool
+	read     func()
+}
+
+func (c *restartableCycle) Metadata() CycleMetadata {
+	metadata := c.metadata
+	if c.read != nil {
+		c.read()
+	}
+	return metadata
+}
+
+func (c *restartableCycle) SetRecovery(recovery *RecoveryMetadata) {
+	c.metadata.Recovery = recovery
+}
+
+func (c *restartableCycle) Start() {
+	c.starts++
+	if c.fail {
+		c.metadata.State = []string{stoppedState, unhealthyState}
+	} else {
+		c.metadata.State = []string{runningState}
+	}
+}
+
+func newRestartableCycle() *restartableCycle {
+	cycle := &restartableCycle{MockCycle: new(MockCycle), fail: true}
+	cycle.On("Name").Return("test")
+	cycle.metadata = CycleMetadata{State: []string{stoppedState, unhealthyState}, UnhealthyReason: "no reachable servers", Completed: 10}
+	return cycle
+}
+
+func TestSupervisorRestartsWithBackoff(t *testing.T) {
+	now := time.Now()
+	cycle := newRestartableCycle()
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Cycles").Return(map[string]Cycle{"id1": cycle})
+
+	supervisor := NewSupervisor(sched, time.Minute, 3*time.Minute, 3, time.Hour, time.Minute)
+	check := func(at time.Duration) {
+		supervisor.now = func() time.Time { return now.Add(at) }
+		supervisor.Check()
+	}
+
+	check(0)
+	assert.Equal(t, 0, cycle.starts)
+	require.NotNil(t, cycle.metadata.Recovery)
+	assert.Empty(t, cycle.metadata.Recovery.Attempts)
+	assert.Equal(t, now.Add(time.Minute), *cycle.metadata.Recovery.NextAttempt)
+
+	check(time.Minute)
+	assert.Equal(t, 1, cycle.starts)
+	assert.Equal(t, []RecoveryAttempt{{Time: now.Add(time.Minute), Reason: "no reachable servers"}}, cycle.metadata.Recovery.Attempts)
+	assert.Equal(t, 10, cycle.metadata.Completed)
+
+	check(2 * time.Minute)
+	assert.Equal(t, 1, cycle.starts, "the second restart should wait for twice the initial backoff")
+	assert.Equal(t, now.Add(3*time.Minute), *cycle.metadata.Recovery.NextAttempt)
+
+	check(3 * time.Minute)
+	assert.Equal(t, 2, cycle.starts)
+
+	check(6 * time.Minute)
+	assert.Equal(t, 3, cycle.starts, "the backoff should be capped at the maximum")
+	assert.Len(t, cycle.metadata.Recovery.Attempts, 3)
+
+	check(time.Hour)
+	assert.Equal(t, 3, cycle.starts)
+	assert.True(t, cycle.metadata.Recovery.GaveUp)
+	assert.Nil(t, cycle.metadata.Recovery.NextAttempt)
+
+	check(2 * time.Hour)
+	assert.Equal(t, 3, cycle.starts)
+}
+
+func TestSupervisorResetsRecoveredCycles(t *testing.T) {
+	now := time.Now()
+	cycle := newRestartableCycle()
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Cycles").Return(map[string]Cycle{"id1": cycle})
+
+	supervisor := NewSupervisor(sched, time.Minute, time.Hour, 3, time.Hour, time.Minute)
+	check := func(at time.Duration) {
+		supervisor.now = func() time.Time { return now.Add(at) }
+		supervisor.Check()
+	}
+
+	check(0)
+	cycle.fail = false
+	check(time.Minute)
+	assert.Equal(t, 1, cycle.starts)
+
+	check(2 * time.Minute)
+	assert.Contains(t, supervisor.recoveries, "id1", "the attempts should be kept until the cycle has stayed healthy for the healthy period")
+
+	check(time.Hour + 2*time.Minute)
+	assert.Empty(t, supervisor.recoveries)
+	assert.Len(t, cycle.metadata.Recovery.Attempts, 1, "the attempts should remain in the metadata")
+
+	cycle.metadata.State = []string{stoppedState, unhealthyState}
+	check(2 * time.Hour)
+	assert.Empty(t, cycle.metadata.Recovery.Attempts, "a new recovery should start without any attempts")
+	assert.Equal(t, now.Add(2*time.Hour+time.Minute), *cycle.metadata.Recovery.NextAttempt)
+}
+
+func TestSupervisorResetsCyclesWhichFinishAnIteration(t *testing.T) {
+	now := time.Now()
+	cycle := newRestartableCycle()
+	cycle.fail = false
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Cycles").Return(map[string]Cycle{"id1": cycle})
+
+	supervisor := NewSupervisor(sched, time.Minute, time.Hour, 3, time.Hour, time.Minute)
+	supervisor.now = func() time.Time { return now }
+	supervisor.Check()
+
+	supervisor.now = func() time.Time { return now.Add(time.Minute) }
+	supervisor.Check()
+	assert.Equal(t, 1, cycle.starts)
+
+	cycle.metadata.Iteration++
+	supervisor.Check()
+	assert.Empty(t, supervisor.recoveries)
+}
+
+func TestSupervisorBacksOffCyclesWhichFailAfterRunning(t *testing.T) {
+	now := time.Now()
+	cycle := newRestartableCycle()
+	cycle.fail = false
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Cycles").Return(map[string]Cycle{"id1": cycle})
+
+	supervisor := NewSupervisor(sched, time.Minute, time.Hour, 3, time.Hour, time.Minute)
+	check := func(at time.Duration) {
+		supervisor.now = func() time.Time { return now.Add(at) }
+		supervisor.Check()
+	}
+
+	check(0)
+	for i, restartedAt := range []time.Duration{time.Minute, 3 * time.Minute, 7 * time.Minute} {
+		check(restartedAt)
+		require.Equal(t, i+1, cycle.starts)
+		assert.Equal(t, []string{runningState}, cycle.metadata.State)
+
+		check(restartedAt + 30*time.Second)
+		cycle.metadata.State = []string{stoppedState, unhealthyState} // fails after running for a while
+		check(restartedAt + time.Minute)
+		assert.Equal(t, i+1, cycle.starts, "the restart should wait for the doubled backoff")
+		assert.Len(t, cycle.metadata.Recovery.Attempts, i+1, "the attempts should be kept")
+	}
+
+	check(time.Hour)
+	assert.Equal(t, 3, cycle.starts)
+	assert.True(t, cycle.metadata.Recovery.GaveUp)
+}
+
+func TestSupervisorDoesNotOverwriteResumedCycles(t *testing.T) {
+	now := time.Now()
+	cycle := newRestartableCycle()
+	cycle.read = func() {
+		cycle.metadata.State = []string{runningState} // resumed through the API while the supervisor is checking the cycle
+		cycle.metadata.Completed = 20
+	}
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Cycles").Return(map[string]Cycle{"id1": cycle})
+
+	supervisor := NewSupervisor(sched, time.Minute, time.Hour, 3, time.Hour, time.Minute)
+	supervisor.now = func() time.Time { return now }
+	supervisor.Check()
+
+	assert.Equal(t, []string{runningState}, cycle.metadata.State)
+	assert.Equal(t, 20, cycle.metadata.Completed)
+	require.NotNil(t, cycle.metadata.Recovery)
+	assert.Equal(t, now.Add(time.Minute), *cycle.metadata.Recovery.NextAttempt)
+}
+
+func TestCycleSetRecovery(t *testing.T) {
+	cycle := newAbstractCycle("name", ThrottledWholeCollectionType, nil, "collection", "origin", time.Minute, nil)
+	cycle.SetMetadata(CycleMetadata{State: []string{runningState}, Completed: 10})
+
+	cycle.SetRecovery(&RecoveryMetadata{GaveUp: true})
+	assert.Equal(t, []string{runningState}, cycle.Metadata().State)
+	assert.Equal(t, 10, cycle.Metadata().Completed)
+	assert.True(t, cycle.Metadata().Recovery.GaveUp)
+}
+
+func TestSupervisorSkipsWhenSchedulerIsNotRunning(t *testing.T) {
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(false)
+
+	NewSupervisor(sched, time.Minute, time.Hour, 3, time.Hour, time.Minute).Check()
+	sched.AssertNotCalled(t, "Cycles")
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	supervisor := NewSupervisor(nil, time.Second, time.Minute, 10, time.Hour, time.Minute)
+	assert.Equal(t, time.Second, supervisor.backoff(0))
+	assert.Equal(t, 2*time.Second, supervisor.backoff(1))
+	assert.Equal(t, 32*time.Second, supervisor.backoff(5))
+	assert.Equal(t, time.Minute, supervisor.backoff(6))
+	assert.Equal(t, time.Minute, supervisor.backoff(100))
+}
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/Financial-Times/publish-carousel/native"
@@ -15,19 +16,85 @@ const (
 
 type ThrottledWholeCollectionCycle struct {
 	*abstractCycle
-	Throttle Throttle `json:"throttle"`
+	Throttle  Throttle `json:"throttle"`
+	Streaming bool     `json:"streaming"`
 }
 
 func NewThrottledWholeCollectionCycle(name string, uuidCollectionBuilder *native.NativeUUIDCollectionBuilder, dbCollection string, origin string, coolDown time.Duration, throttle Throttle, publishTask tasks.Task) Cycle {
-	return &ThrottledWholeCollectionCycle{newAbstractCycle(name, ThrottledWholeCollectionType, uuidCollectionBuilder, dbCollection, origin, coolDown, publishTask), throttle}
+	return newThrottledWholeCollectionCycle(name, uuidCollectionBuilder, dbCollection, origin, coolDown, throttle, publishTask, false)
+}
+
+// NewStreamingWholeCollectionCycle returns a whole collection cycle which pages through the collection from mongo
+func NewStreamingWholeCollectionCycle(name string, uuidCollectionBuilder *native.NativeUUIDCollectionBuilder, dbCollection string, origin string, coolDown time.Duration, throttle Throttle, publishTask tasks.Task) Cycle {
+	return newThrottledWholeCollectionCycle(name, uuidCollectionBuilder, dbCollection, origin, coolDown, throttle, publishTask, true)
+}
+
+func newThrottledWholeCollectionCycle(name string, uuidCollectionBuilder *native.NativeUUIDCollectionBuilder, dbCollection string, origin string, coolDown time.Duration, throttle Throttle, publishTask tasks.Task, streaming bool) *ThrottledWholeCollectionCycle {
+	cycle := &ThrottledWholeCollectionCycle{newAbstractCycle(name, ThrottledWholeCollectionType, uuidCollectionBuilder, dbCollection, origin, coolDown, publishTask), throttle, streaming}
+	cycle.resume = cycle.Start
+	return cycle
+}
+
+func init() {
+	mustRegisterCycleFactory(throttledWholeCollectionFactory{})
+}
+
+type throttledWholeCollectionFactory struct{}
+
+func (throttledWholeCollectionFactory) Type() string {
+	return ThrottledWholeCollectionType
+}
+
+func (throttledWholeCollectionFactory) Schema() []ConfigField {
+	return []ConfigField{{Name: "throttle", Type: "duration"}, {Name: "streaming", Type: "boolean"}}
+}
+
+func (throttledWholeCollectionFactory) Validate(config CycleConfig) error {
+	if config.Throttle == "" {
+		return nil
+	}
+	return checkDurations(config.Name, config.Throttle)
+}
+
+// New builds a whole collection cycle, using the scheduler's default throttle if none is configured
+func (throttledWholeCollectionFactory) New(config CycleConfig, deps CycleDependencies) Cycle {
+	coolDown, _ := time.ParseDuration(config.CoolDown)
+
+	var throttleInterval time.Duration
+	if config.Throttle == "" {
+		log.WithField("cycleName", config.Name).Infof("Throttle configuration not found. Setting default throttle value (%v)", deps.DefaultThrottle)
+		throttleInterval = deps.DefaultThrottle
+	} else {
+		throttleInterval, _ = time.ParseDuration(config.Throttle)
+	}
+
+	t, _ := NewThrottle(throttleInterval, 1)
+	if config.Streaming {
+		return NewStreamingWholeCollectionCycle(config.Name, deps.UUIDCollectionBuilder, config.Collection, config.Origin, coolDown, t, deps.PublishTask)
+	}
+	return NewThrottledWholeCollectionCycle(config.Name, deps.UUIDCollectionBuilder, config.Collection, config.Origin, coolDown, t, deps.PublishTask)
+}
+
+func (throttledWholeCollectionFactory) Checkpointed() bool {
+	return true
+}
+
+func (throttledWholeCollectionFactory) Throttle(cycle Cycle) (Throttle, bool) {
+	c, ok := cycle.(*ThrottledWholeCollectionCycle)
+	if !ok {
+		return nil, false
+	}
+	return c.Throttle, true
+}
+
+func (throttledWholeCollectionFactory) WithThrottle(config CycleConfig, interval time.Duration) CycleConfig {
+	config.Throttle = interval.String()
+	return config
 }
 
 func (l *ThrottledWholeCollectionCycle) Start() {
 	log.WithField("id", l.CycleID).WithField("name", l.CycleName).WithField("collection", l.DBCollection).Info("Starting throttled whole collection cycle.")
-	ctx, cancel := context.WithCancel(context.Background())
-	l.cancel = cancel
-	l.UpdateState(startingState)
-	go l.start(ctx)
+	l.run(l.start)
 }
 
 func (l *ThrottledWholeCollectionCycle) start(ctx context.Context) {
@@ -40,42 +107,68 @@ func (l *ThrottledWholeCollectionCycle) start(ctx context.Context) {
 }
 
 func (l *ThrottledWholeCollectionCycle) publishCollectionCycle(ctx context.Context, skip int) (int, bool) {
-	uuidCollection, err := l.uuidCollectionBuilder.NewNativeUUIDCollection(ctx, l.DBCollection, skip)
+	var position *native.Position
+	if skip > 0 {
+		position = l.Metadata().Position
+	}
 
-	if err != nil {
-		log.WithField("id", l.CycleID).WithField("name", l.CycleName).WithField("collection", l.DBCollection).WithError(err).Warn("Failed to consume UUIDs from the Native UUID Collection.")
-		l.UpdateState(stoppedState, unhealthyState)
-		return skip, false
+	if l.Streaming && position == nil {
+		skip = 0 // a streaming collection can only resume from a position
 	}
 
-	iteration := l.CycleMetadata.Iteration
+	iteration := l.Metadata().Iteration
 	if skip == 0 {
 		iteration++
 	}
 
-	metadata := CycleMetadata{Completed: skip, State: []string{runningState}, Iteration: iteration, Attempts: l.CycleMetadata.Attempts + 1, Total: uuidCollection.Length()}
+	uuidCollection, err := l.newUUIDCollection(ctx, skip, position, iteration)
+
+	if err != nil {
+		log.WithField("id", l.CycleID).WithField("name", l.CycleName).WithField("collection", l.DBCollection).WithError(err).Warn("Failed to consume UUIDs from the Native UUID Collection.")
+		l.markUnhealthy(err.Error())
+		return skip, false
+	}
+
+	now := time.Now()
+	metadata := CycleMetadata{Completed: skip, State: []string{runningState}, Iteration: iteration, Attempts: l.CycleMetadata.Attempts + 1, Total: uuidCollection.Length(), Position: position, Restore: l.Metadata().Restore, LastProgress: &now, Recovery: l.Metadata().Recovery}
 	l.SetMetadata(metadata)
 
+	defer uuidCollection.Close()
+
 	if uuidCollection.Length() == 0 {
-		l.UpdateState(stoppedState, unhealthyState) // assume unhealthy, as the whole archive should *always* have content
+		l.markUnhealthy("Collection is empty") // assume unhealthy, as the whole archive should *always* have content
 		return skip, false
 	}
 
 	stopped, err := l.publishCollection(ctx, uuidCollection, l.Throttle)
 	if stopped {
-		l.UpdateState(stoppedState)
+		if !errors.Is(err, errCycleSuspended) {
+			l.UpdateState(stoppedState)
+		}
 		return skip, false
 	}
 
 	if err != nil {
 		log.WithField("id", l.CycleID).WithField("name", l.CycleName).WithField("collection", l.DBCollection).WithError(err).Error("Unexpected error occurred while publishing collection.")
-		l.UpdateState(stoppedState, unhealthyState)
+		l.markUnhealthy(err.Error())
 		return skip, false
 	}
 
 	return 0, true
 }
 
+// PublishInterval is the interval of the cycle's throttle
+func (l *ThrottledWholeCollectionCycle) PublishInterval() time.Duration {
+	return l.Throttle.Interval()
+}
+
+func (l *ThrottledWholeCollectionCycle) newUUIDCollection(ctx context.Context, skip int, position *native.Position, iteration int) (native.UUIDCollection, error) {
+	if l.Streaming {
+		return l.uuidCollectionBuilder.NewStreamingUUIDCollection(l.DBCollection, position)
+	}
+	return l.uuidCollectionBuilder.NewNativeUUIDCollection(ctx, l.DBCollection, skip, position, iteration)
+}
+
 func (s *ThrottledWholeCollectionCycle) TransformToConfig() CycleConfig {
-	return CycleConfig{Name: s.CycleName, Type: s.CycleType, Collection: s.DBCollection, CoolDown: s.CoolDown, Origin: s.Origin, Throttle: s.Throttle.Interval().String()}
+	return CycleConfig{Name: s.CycleName, Type: s.CycleType, Collection: s.DBCollection, CoolDown: s.CoolDown, Origin: s.Origin, Throttle: s.Throttle.Interval().String(), Streaming: s.Streaming, Notifier: s.Notifier, Notifiers: s.Notifiers, FanOut: s.FanOut, Task: s.Task, ErrorGuard: s.ErrorGuard}
 }
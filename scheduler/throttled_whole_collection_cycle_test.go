@@ -1,7 +1,9 @@
 package scheduler
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -9,11 +11,14 @@ import (
 	"gopkg.in/mgo.v2/bson"
 
 	"github.com/Financial-Times/publish-carousel/blacklist"
+	"github.com/Financial-Times/publish-carousel/cms"
 	"github.com/Financial-Times/publish-carousel/native"
 	"github.com/Financial-Times/publish-carousel/tasks"
+	"github.com/Financial-Times/publish-carousel/verify"
 	"github.com/pborman/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // Important!
@@ -60,9 +65,7 @@ func TestWholeCollectionCycleRunWithMetadata(t *testing.T) {
 
 	<-throttleCalled
 
-	cycle.Stop()
-
-	<-throttleCalled
+	stopCycle(cycle, throttleCalled)
 
 	assert.Len(t, cycle.State(), 1)
 	assert.Contains(t, cycle.State(), stoppedState)
@@ -102,7 +105,7 @@ func TestWholeCollectionCycleTaskPrepareFails(t *testing.T) {
 
 	<-throttleCalled
 
-	c.Stop()
+	stopCycle(c, throttleCalled)
 
 	assert.Len(t, c.State(), 1)
 	assert.Contains(t, c.State(), stoppedState)
@@ -111,9 +114,10 @@ func TestWholeCollectionCycleTaskPrepareFails(t *testing.T) {
 	assert.Equal(t, 1, c.Metadata().Errors)
 }
 
-func TestWholeCollectionCycleTaskFails(t *testing.T) {
+func TestWholeCollectionCycleTaskPrepareSkips(t *testing.T) {
 	expectedUUID := uuid.NewUUID().String()
-	task := mockTask(expectedUUID, nil, errors.New("i fail soz"))
+
+	task := mockTask(expectedUUID, &tasks.SkipError{UUID: expectedUUID, Reason: "it is denied by filter rule skip-images"}, nil)
 
 	throttleCalled := make(chan struct{}, 1)
 	opened := make(chan struct{}, 1)
@@ -138,10 +142,264 @@ func TestWholeCollectionCycleTaskFails(t *testing.T) {
 
 	<-throttleCalled
 
-	c.Stop()
+	stopCycle(c, throttleCalled)
+
+	mock.AssertExpectationsForObjects(t, throttle, iter, tx, db, task)
+	assert.Equal(t, 0, c.Metadata().Errors)
+	assert.Equal(t, 1, c.Metadata().Skipped)
+	assert.Equal(t, "it is denied by filter rule skip-images", c.Metadata().CurrentSkipReason)
+	assert.Empty(t, c.Metadata().CurrentPublishError)
+}
+
+func TestWholeCollectionCycleFanOutTargetFails(t *testing.T) {
+	tests := []struct {
+		name   string
+		failed bool
+		errors int
+	}{
+		{name: "best-effort target fails", failed: false, errors: 0},
+		{name: "publish fails", failed: true, errors: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectedUUID := uuid.NewUUID().String()
+
+			fanOutErr := &cms.FanOutError{Mode: cms.FanOutPrimary, Failed: test.failed, Errors: map[string]error{"shadow": errors.New("shadow is down")}}
+			task := mockTask(expectedUUID, nil, fanOutErr)
+
+			throttleCalled := make(chan struct{}, 1)
+			opened := make(chan struct{}, 1)
+			closed := make(chan struct{}, 1)
+
+			throttle := mockThrottle(time.Millisecond*50, throttleCalled)
+
+			iter := mockIterWithCollectionSize(expectedUUID, 2000, closed)
+			happyIter(iter)
+
+			tx := mockTx(iter, nil)
+			db := mockDB(opened, tx, nil)
+
+			uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(db, nil, blacklist.NoOpBlacklist)
+
+			c := NewThrottledWholeCollectionCycle("name", uuidCollectionBuilder, "collection", "origin", time.Millisecond*50, throttle, task)
+
+			c.Start()
+
+			<-opened
+			<-closed
+
+			<-throttleCalled
+
+			stopCycle(c, throttleCalled)
+
+			mock.AssertExpectationsForObjects(t, throttle, iter, tx, db, task)
+			assert.Equal(t, test.errors, c.Metadata().Errors)
+			assert.Equal(t, map[string]TargetMetadata{"shadow": {Errors: 1, CurrentError: "shadow is down"}}, c.Metadata().Targets)
+		})
+	}
+}
+
+func TestWholeCollectionCycleWaitsForOpenBreaker(t *testing.T) {
+	expectedUUID := uuid.NewUUID().String()
+	task := new(tasks.MockTask)
+
+	throttleCalled := make(chan struct{}, 1)
+	opened := make(chan struct{}, 1)
+	closed := make(chan struct{}, 1)
+	waiting := make(chan struct{})
+
+	throttle := mockThrottle(time.Millisecond, throttleCalled)
+
+	iter := mockIterWithCollectionSize(expectedUUID, 2000, closed)
+	happyIter(iter)
+
+	tx := mockTx(iter, nil)
+	db := mockDB(opened, tx, nil)
+
+	breaker := new(cms.MockBreaker)
+	breaker.On("State").Return(cms.BreakerOpen)
+	breaker.On("Wait", mock.Anything).Run(func(args mock.Arguments) {
+		close(waiting)
+		<-args.Get(0).(context.Context).Done()
+	}).Return(context.Canceled)
+
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(db, nil, blacklist.NoOpBlacklist)
+
+	c := NewThrottledWholeCollectionCycle("name", uuidCollectionBuilder, "collection", "origin", time.Millisecond*50, throttle, task)
+	c.(*ThrottledWholeCollectionCycle).setBreakers(cms.FanOutAll, map[string]cms.Breaker{"cms": breaker})
+
+	c.Start()
+
+	<-opened
+	<-closed
+	<-waiting
+
+	assert.Equal(t, []string{"circuit-open", runningState}, c.State())
+	assert.Equal(t, []string{"circuit-open", runningState}, c.Metadata().State)
+	assert.Equal(t, 0, c.Metadata().Completed, "no uuids should be consumed while the breaker is open")
+
+	stopCycle(c, throttleCalled)
+
+	task.AssertNotCalled(t, "Prepare", mock.Anything, mock.Anything)
+}
+
+func TestWholeCollectionCycleRepublishesWhenBreakerRejects(t *testing.T) {
+	rejected := fmt.Errorf("%w for notifier cms", cms.ErrCircuitOpen)
+	tests := []struct {
+		name        string
+		err         error
+		republished bool
+	}{
+		{name: "notifier", err: rejected, republished: true},
+		{name: "fan-out", err: &cms.FanOutError{Mode: cms.FanOutAll, Failed: true, Errors: map[string]error{"cms": rejected, "shadow": errors.New("shadow is down")}}, republished: true},
+		{name: "best-effort", err: &cms.FanOutError{Mode: cms.FanOutPrimary, Failed: false, Errors: map[string]error{"shadow": rejected}}, republished: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectedUUID := uuid.NewUUID().String()
+
+			task := new(tasks.MockTask)
+			task.On("Prepare", "collection", expectedUUID).Return(&native.Content{}, "tid_"+expectedUUID, nil)
+			task.On("Execute", mock.Anything, expectedUUID, mock.AnythingOfType("*native.Content"), "origin", "tid_"+expectedUUID).Return(test.err).Once()
+			task.On("Execute", mock.Anything, expectedUUID, mock.AnythingOfType("*native.Content"), "origin", "tid_"+expectedUUID).Return(nil)
+
+			throttleCalled := make(chan struct{}, 1)
+			opened := make(chan struct{}, 1)
+			closed := make(chan struct{}, 1)
+
+			throttle := mockThrottle(time.Millisecond*50, throttleCalled)
+
+			iter := mockIterWithCollectionSize(expectedUUID, 2000, closed)
+			happyIter(iter)
+
+			tx := mockTx(iter, nil)
+			db := mockDB(opened, tx, nil)
+
+			breaker := new(cms.MockBreaker)
+			breaker.On("State").Return(cms.BreakerClosed)
+			breaker.On("Wait", mock.Anything).Return(nil)
+
+			uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(db, nil, blacklist.NoOpBlacklist)
+
+			c := NewThrottledWholeCollectionCycle("name", uuidCollectionBuilder, "collection", "origin", time.Millisecond*50, throttle, task)
+			c.(*ThrottledWholeCollectionCycle).setBreakers(cms.FanOutAll, map[string]cms.Breaker{"cms": breaker, "shadow": breaker})
+
+			c.Start()
+
+			<-opened
+			<-closed
+
+			<-throttleCalled
+			<-throttleCalled
+
+			stopCycle(c, throttleCalled)
+
+			assert.Equal(t, 0, c.Metadata().Errors, "the rejected publish should not be counted as failed")
+			assert.True(t, c.Metadata().Completed >= 1)
+			task.AssertNumberOfCalls(t, "Prepare", c.Metadata().Completed)
+			if test.republished {
+				task.AssertNumberOfCalls(t, "Execute", c.Metadata().Completed+1)
+			} else {
+				task.AssertNumberOfCalls(t, "Execute", c.Metadata().Completed)
+			}
+		})
+	}
+}
+
+func TestWholeCollectionCycleVerifiesPublishes(t *testing.T) {
+	expectedUUID := uuid.NewUUID().String()
+
+	task := new(tasks.MockTask)
+	task.On("Prepare", "collection", expectedUUID).Return(&native.Content{}, "tid_"+expectedUUID, nil)
+	task.On("Execute", mock.Anything, expectedUUID, mock.AnythingOfType("*native.Content"), "origin", "tid_"+expectedUUID).Return(nil)
+
+	throttleCalled := make(chan struct{}, 1)
+	opened := make(chan struct{}, 1)
+	closed := make(chan struct{}, 1)
+
+	throttle := mockThrottle(time.Millisecond*50, throttleCalled)
+
+	iter := mockIterWithCollectionSize(expectedUUID, 2000, closed)
+	happyIter(iter)
+
+	tx := mockTx(iter, nil)
+	db := mockDB(opened, tx, nil)
+
+	verified := make(chan struct{}, 1)
+	verifier := new(verify.MockVerifier)
+	verifier.On("Verify", "name", expectedUUID, "tid_"+expectedUUID, mock.AnythingOfType("func(string)")).Run(func(args mock.Arguments) {
+		args.Get(3).(func(string))(verify.Late)
+		select {
+		case verified <- struct{}{}:
+		default:
+		}
+	})
+
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(db, nil, blacklist.NoOpBlacklist)
+
+	c := NewThrottledWholeCollectionCycle("name", uuidCollectionBuilder, "collection", "origin", time.Millisecond*50, throttle, task)
+	c.(*ThrottledWholeCollectionCycle).setVerifier(verifier)
+
+	c.Start()
+
+	<-opened
+	<-closed
+	<-verified
+
+	stopCycle(c, throttleCalled)
+
+	verification := c.Metadata().Verification
+	require.NotNil(t, verification)
+	assert.True(t, verification.Late >= 1)
+	assert.Equal(t, 0, verification.Verified)
+	assert.Equal(t, 0, verification.Missing)
+}
+
+func TestVerificationsAreCounted(t *testing.T) {
+	c := newAbstractCycle("name", ThrottledWholeCollectionType, nil, "collection", "origin", time.Minute, nil)
+
+	c.countVerification(verify.Verified)
+	before := c.Metadata()
+
+	c.countVerification(verify.Verified)
+	c.countVerification(verify.Late)
+	c.countVerification(verify.Missing)
+
+	assert.Equal(t, &VerificationMetadata{Verified: 1}, before.Verification, "metadata copies should not be changed by later verifications")
+	assert.Equal(t, &VerificationMetadata{Verified: 2, Late: 1, Missing: 1}, c.Metadata().Verification)
+}
+
+func TestWholeCollectionCycleTaskFails(t *testing.T) {
+	expectedUUID := uuid.NewUUID().String()
+	task := mockTask(expectedUUID, nil, errors.New("i fail soz"))
+
+	throttleCalled := make(chan struct{}, 1)
+	opened := make(chan struct{}, 1)
+	closed := make(chan struct{}, 1)
+
+	throttle := mockThrottle(time.Millisecond*50, throttleCalled)
+
+	iter := mockIterWithCollectionSize(expectedUUID, 2000, closed)
+	happyIter(iter)
+
+	tx := mockTx(iter, nil)
+	db := mockDB(opened, tx, nil)
+
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(db, nil, blacklist.NoOpBlacklist)
+
+	c := NewThrottledWholeCollectionCycle("name", uuidCollectionBuilder, "collection", "origin", time.Millisecond*50, throttle, task)
+
+	c.Start()
+
+	<-opened
+	<-closed
 
 	<-throttleCalled
 
+	stopCycle(c, throttleCalled)
+
 	assert.Len(t, c.State(), 1)
 	assert.Contains(t, c.State(), stoppedState)
 
@@ -191,8 +449,7 @@ func TestWholeCollectionCycleRunCompleted(t *testing.T) {
 		<-throttleCalled
 	}
 
-	c.Stop()
-	time.Sleep(50 * time.Millisecond)
+	stopCycle(c, throttleCalled)
 
 	assert.Len(t, c.State(), 1)
 	assert.Contains(t, c.State(), stoppedState)
@@ -259,6 +516,7 @@ func TestWholeCollectionCycleMongoDBConnectionError(t *testing.T) {
 	assert.Len(t, c.State(), 2)
 	assert.Contains(t, c.State(), stoppedState)
 	assert.Contains(t, c.State(), unhealthyState)
+	assert.Equal(t, "nein", c.Metadata().UnhealthyReason)
 
 	mock.AssertExpectationsForObjects(t, throttle, tx, db, task)
 }
@@ -293,6 +551,7 @@ func TestWholeCollectionCycleRunEmptyCollection(t *testing.T) {
 	assert.Len(t, c.State(), 2)
 	assert.Contains(t, c.State(), stoppedState)
 	assert.Contains(t, c.State(), unhealthyState)
+	assert.Equal(t, "Collection is empty", c.Metadata().UnhealthyReason)
 
 	mock.AssertExpectationsForObjects(t, db, tx, task, throttle)
 }
@@ -409,6 +668,23 @@ func mockThrottle(interval time.Duration, called chan struct{}) *MockThrottle {
 	return throttle
 }
 
+// stopCycle stops the cycle, while discarding any further calls to its throttle, so that its publish loop can exit
+func stopCycle(c Cycle, throttleCalled chan struct{}) {
+	stopped := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-throttleCalled:
+			case <-stopped:
+				return
+			}
+		}
+	}()
+
+	c.Stop()
+	close(stopped)
+}
+
 func mockTask(expectedUUID string, prepErr error, execErr error) *tasks.MockTask {
 	task := new(tasks.MockTask)
 
@@ -417,7 +693,7 @@ func mockTask(expectedUUID string, prepErr error, execErr error) *tasks.MockTask
 		return task
 	}
 
-	task.On("Execute", expectedUUID, mock.AnythingOfType("*native.Content"), "origin", "tid_"+expectedUUID).Return(execErr)
+	task.On("Execute", mock.Anything, expectedUUID, mock.AnythingOfType("*native.Content"), "origin", "tid_"+expectedUUID).Return(execErr)
 	return task
 }
 
@@ -433,3 +709,87 @@ func TestWholeCollectionCycleCanBeStoppedEvenIfNotStarted(t *testing.T) {
 
 	mock.AssertExpectationsForObjects(t, db, task, throttle)
 }
+
+func TestStreamingWholeCollectionCycleResumesFromPosition(t *testing.T) {
+	expectedUUID := uuid.NewUUID().String()
+	resumeFrom := bson.NewObjectId()
+	nextID := bson.NewObjectId()
+
+	task := mockTask(expectedUUID, nil, nil)
+
+	throttleCalled := make(chan struct{}, 1)
+	opened := make(chan struct{}, 1)
+	closed := make(chan struct{}, 1)
+
+	throttle := mockThrottle(time.Millisecond*50, throttleCalled)
+
+	iter := new(native.MockDBIter)
+	iter.On("Next", mock.MatchedBy(func(arg *map[string]interface{}) bool {
+		m := *arg
+		m["_id"] = nextID
+		m["uuid"] = bson.Binary{Kind: 0x04, Data: []byte(uuid.Parse(expectedUUID))}
+		return true
+	})).Return(true)
+	iter.On("Close").Return(nil)
+
+	tx := new(native.MockTX)
+	tx.On("CountUUIDs", "collection").Return(2000, nil)
+	tx.On("FindUUIDsAfterID", "collection", resumeFrom.Hex(), 1000).Return(iter, nil)
+	tx.On("Close").Run(func(arg1 mock.Arguments) {
+		closed <- struct{}{}
+	}).Return()
+
+	db := mockDB(opened, tx, nil)
+
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(db, nil, blacklist.NoOpBlacklist)
+
+	cycle := NewStreamingWholeCollectionCycle("name", uuidCollectionBuilder, "collection", "origin", time.Millisecond*50, throttle, task)
+	cycle.SetMetadata(CycleMetadata{Completed: 500, Iteration: 1, Position: &native.Position{ID: resumeFrom.Hex()}})
+
+	cycle.Start()
+
+	<-opened
+	<-throttleCalled
+	<-throttleCalled
+
+	stopCycle(cycle, throttleCalled)
+	<-closed
+
+	assert.Equal(t, 1, cycle.Metadata().Iteration)
+	assert.Equal(t, 2000, cycle.Metadata().Total)
+	assert.True(t, cycle.Metadata().Completed > 500)
+	assert.Equal(t, nextID.Hex(), cycle.Metadata().Position.ID)
+
+	mock.AssertExpectationsForObjects(t, iter, tx, db, task)
+}
+
+func TestFanOutTargetErrorsAreClearedOnSuccess(t *testing.T) {
+	c := newAbstractCycle("name", ThrottledWholeCollectionType, nil, "collection", "origin", time.Minute, nil)
+
+	c.updateProgress("uuid1", "tid_1", &cms.FanOutError{Mode: cms.FanOutAll, Failed: true, Errors: map[string]error{"cms": errors.New("oh dear")}})
+	before := c.Metadata()
+
+	c.updateProgress("uuid2", "tid_2", nil)
+
+	assert.Equal(t, map[string]TargetMetadata{"cms": {Errors: 1, CurrentError: "oh dear"}}, before.Targets, "metadata copies should not be changed by later publishes")
+	assert.Equal(t, map[string]TargetMetadata{"cms": {Errors: 1}}, c.Metadata().Targets)
+	assert.Equal(t, 1, c.Metadata().Errors)
+	assert.Empty(t, c.Metadata().CurrentPublishError)
+}
+
+func TestErrorClassesAreCounted(t *testing.T) {
+	c := newAbstractCycle("name", ThrottledWholeCollectionType, nil, "collection", "origin", time.Minute, nil)
+
+	c.updateProgress("uuid1", "tid_1", &cms.NotifierError{Class: cms.TransientError, StatusCode: 503})
+	before := c.Metadata()
+
+	c.updateProgress("uuid2", "tid_2", fmt.Errorf("wrapped: %w", &cms.NotifierError{Class: cms.PermanentError, StatusCode: 400}))
+	c.updateProgress("uuid3", "tid_3", &cms.NotifierError{Class: cms.TransientError, StatusCode: 429})
+	c.updateProgress("uuid4", "tid_4", errors.New("mongo is down"))
+	c.updateProgress("uuid5", "tid_5", &cms.FanOutError{Mode: cms.FanOutAll, Failed: true, Errors: map[string]error{"shadow": &cms.NotifierError{Class: cms.TransientError, StatusCode: 502}}})
+
+	assert.Equal(t, map[string]int{cms.TransientError: 1}, before.ErrorClasses, "metadata copies should not be changed by later publishes")
+	assert.Equal(t, map[string]int{cms.TransientError: 2, cms.PermanentError: 1}, c.Metadata().ErrorClasses, "unclassified and fan-out errors are not counted")
+	assert.Equal(t, 5, c.Metadata().Errors)
+	assert.Equal(t, cms.TransientError, c.Metadata().Targets["shadow"].CurrentErrorClass)
+}
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -42,19 +43,23 @@ func (s *abstractTimeWindowedCycle) publishCollectionCycle(ctx context.Context,
 	uuidCollection, err := s.uuidCollectionBuilder.NewNativeUUIDCollectionForTimeWindow(s.DBCollection, startTime, endTime, s.batchDuration)
 	if err != nil {
 		log.WithField("id", s.CycleID).WithField("name", s.CycleName).WithField("collection", s.DBCollection).WithField("start", startTime).WithField("end", endTime).WithError(err).Warn("Failed to query native collection for time window.")
-		s.UpdateState(stoppedState, unhealthyState)
+		s.markUnhealthy(err.Error())
 		return endTime, false
 	}
 	defer uuidCollection.Close()
 
 	copiedTime := startTime // Copy so that we don't change the time for the cycle
 
-	metadata := CycleMetadata{State: []string{runningState}, Attempts: s.CycleMetadata.Attempts + 1, Total: uuidCollection.Length(), Start: &copiedTime, End: &endTime}
+	now := time.Now()
+	metadata := CycleMetadata{State: []string{runningState}, Attempts: s.CycleMetadata.Attempts + 1, Total: uuidCollection.Length(), Start: &copiedTime, End: &endTime, LastProgress: &now, Recovery: s.Metadata().Recovery}
 	s.SetMetadata(metadata)
 
 	if uuidCollection.Length() == 0 {
-		endTime = s.performCooldown(coolDownState)
-		return endTime, true
+		if !s.performCooldown(ctx, coolDownState) {
+			s.UpdateState(stoppedState)
+			return endTime, false
+		}
+		return time.Now(), true
 	}
 
 	t, cancel := throttle(uuidCollection.Length() + 1) // add one to the length to increase the wait time
@@ -62,13 +67,15 @@ func (s *abstractTimeWindowedCycle) publishCollectionCycle(ctx context.Context,
 
 	cancel()
 	if stopped {
-		s.UpdateState(stoppedState)
+		if !errors.Is(err, errCycleSuspended) {
+			s.UpdateState(stoppedState)
+		}
 		return endTime, false
 	}
 
 	if err != nil {
 		log.WithField("id", s.CycleID).WithField("name", s.CycleName).WithField("collection", s.DBCollection).WithError(err).Warn("Unexpected error occurred while publishing collection.")
-		s.UpdateState(stoppedState, unhealthyState)
+		s.markUnhealthy(err.Error())
 		return endTime, false
 	}
 
@@ -76,8 +83,17 @@ func (s *abstractTimeWindowedCycle) publishCollectionCycle(ctx context.Context,
 	return time.Now(), true
 }
 
-func (s *abstractTimeWindowedCycle) performCooldown(states ...string) time.Time {
+// performCooldown waits for the cool down, and returns false if the cycle was stopped while it waited
+func (s *abstractTimeWindowedCycle) performCooldown(ctx context.Context, states ...string) bool {
 	s.UpdateState(states...)
-	time.Sleep(s.coolDown)
-	return time.Now()
+
+	timer := time.NewTimer(s.coolDown)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
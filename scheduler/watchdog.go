@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ProgressTracker is implemented by cycles which record when they last made progress
+type ProgressTracker interface {
+	// LastProgress returns when the cycle last published, skipped or failed to publish a uuid, or started an iteration
+	LastProgress() time.Time
+	// PublishInterval is the longest the cycle is expected to wait between publishes
+	PublishInterval() time.Duration
+	// MarkStalled adds the stalled state to the cycle, until it makes progress again
+	MarkStalled()
+}
+
+// Watchdog periodically marks the running cycles which have stopped making progress as stalled
+type Watchdog struct {
+	sync.Mutex
+	sched      Scheduler
+	multiple   int
+	minimum    time.Duration
+	restart    bool
+	restarting map[string]bool
+	runner     *periodicRunner
+	now        func() time.Time
+}
+
+// NewWatchdog returns a watchdog which checks the cycles every interval, and restarts stalled cycles if restart is true
+func NewWatchdog(sched Scheduler, multiple int, minimum time.Duration, restart bool, interval time.Duration) *Watchdog {
+	return &Watchdog{
+		sched:      sched,
+		multiple:   multiple,
+		minimum:    minimum,
+		restart:    restart,
+		restarting: make(map[string]bool),
+		runner:     newPeriodicRunner(interval),
+		now:        time.Now,
+	}
+}
+
+// Start checks the cycles in the background until stopped
+func (w *Watchdog) Start() {
+	w.runner.start(w.Check)
+}
+
+// Stop stops checking the cycles
+func (w *Watchdog) Stop() {
+	w.runner.stop()
+}
+
+// Check marks every running cycle which has stalled, and restarts it if the watchdog is configured to
+func (w *Watchdog) Check() {
+	if !w.sched.IsRunning() {
+		log.Info("Scheduler is not running, skipping the stalled cycle check.")
+		return
+	}
+
+	for id, cycle := range w.sched.Cycles() {
+		tracker, ok := cycle.(ProgressTracker)
+		if !ok || !isExpectedToProgress(cycle.State()) {
+			continue
+		}
+
+		lastProgress := tracker.LastProgress()
+		if lastProgress.IsZero() {
+			continue
+		}
+
+		gap := w.now().Sub(lastProgress)
+		if gap <= w.limit(tracker.PublishInterval()) {
+			continue
+		}
+
+		logger := log.WithField("id", id).WithField("name", cycle.Name()).WithField("lastProgress", lastProgress).WithField("gap", gap.String())
+		tracker.MarkStalled()
+		switch {
+		case !w.restart:
+			logger.Warn("Cycle has stalled.")
+		case w.isRestarting(id):
+			logger.Warn("Cycle has stalled, and is still waiting for its publish loop to exit before it is restarted.")
+		default:
+			logger.Warn("Cycle has stalled, restarting it.")
+			w.restartCycle(id, cycle)
+		}
+	}
+}
+
+// restartCycle restarts the cycle in the background, as its publish loop may be blocked in mongo
+func (w *Watchdog) restartCycle(id string, cycle Cycle) {
+	w.Lock()
+	w.restarting[id] = true
+	w.Unlock()
+
+	go func() {
+		defer func() {
+			w.Lock()
+			delete(w.restarting, id)
+			w.Unlock()
+		}()
+
+		cycle.Stop()
+		if !w.sched.IsRunning() {
+			log.WithField("id", id).WithField("name", cycle.Name()).Info("Scheduler has stopped, not restarting the stalled cycle.")
+			return
+		}
+		cycle.Start()
+	}()
+}
+
+func (w *Watchdog) isRestarting(id string) bool {
+	w.Lock()
+	defer w.Unlock()
+	return w.restarting[id]
+}
+
+// limit is the longest a cycle with the given publish interval may go without making progress
+func (w *Watchdog) limit(interval time.Duration) time.Duration {
+	limit := interval * time.Duration(w.multiple)
+	if limit < w.minimum {
+		return w.minimum
+	}
+	return limit
+}
+
+func isExpectedToProgress(states []string) bool {
+	progressing := false
+	for _, state := range states {
+		switch {
+		case state == runningState || state == startingState:
+			progressing = true
+		case state == stoppedState || state == coolDownState || strings.HasPrefix(state, circuitStatePrefix):
+			return false
+		}
+	}
+	return progressing
+}
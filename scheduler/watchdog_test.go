@@ -0,0 +1,222 @@
+package scheduler
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/blacklist"
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/tasks"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type trackedCycle struct {
+	*MockCycle
+	lastProgress time.Time
+	interval     time.Duration
+	stalled      bool
+}
+
+func (c *trackedCycle) LastProgress() time.Time {
+	return c.lastProgress
+}
+
+func (c *trackedCycle) PublishInterval() time.Duration {
+	return c.interval
+}
+
+func (c *trackedCycle) MarkStalled() {
+	c.stalled = true
+}
+
+func newTrackedCycle(lastProgress time.Time, interval time.Duration, states ...string) *trackedCycle {
+	cycle := &trackedCycle{MockCycle: new(MockCycle), lastProgress: lastProgress, interval: interval}
+	cycle.On("Name").Return("test")
+	cycle.On("State").Return(states)
+	return cycle
+}
+
+func TestWatchdogMarksStalledCycles(t *testing.T) {
+	now := time.Now()
+
+	stalled := newTrackedCycle(now.Add(-11*time.Second), time.Second, runningState)
+	progressing := newTrackedCycle(now.Add(-9*time.Second), time.Second, runningState)
+	coolingDown := newTrackedCycle(now.Add(-time.Hour), time.Second, runningState, coolDownState)
+	waiting := newTrackedCycle(now.Add(-time.Hour), time.Second, circuitStatePrefix+"open", runningState)
+	stopped := newTrackedCycle(now.Add(-time.Hour), time.Second, stoppedState)
+
+	untracked := new(MockCycle)
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Cycles").Return(map[string]Cycle{"id1": stalled, "id2": progressing, "id3": coolingDown, "id4": waiting, "id5": stopped, "id6": untracked})
+
+	watchdog := NewWatchdog(sched, 10, 0, false, time.Minute)
+	watchdog.now = func() time.Time { return now }
+	watchdog.Check()
+
+	assert.True(t, stalled.stalled)
+	assert.False(t, progressing.stalled)
+	assert.False(t, coolingDown.stalled)
+	assert.False(t, waiting.stalled)
+	assert.False(t, stopped.stalled)
+
+	stalled.AssertNotCalled(t, "Stop")
+	stalled.AssertNotCalled(t, "Start")
+	untracked.AssertNotCalled(t, "State")
+}
+
+func TestWatchdogMinimumGap(t *testing.T) {
+	now := time.Now()
+	cycle := newTrackedCycle(now.Add(-time.Minute), time.Second, startingState)
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Cycles").Return(map[string]Cycle{"id1": cycle})
+
+	watchdog := NewWatchdog(sched, 10, 5*time.Minute, false, time.Minute)
+	watchdog.now = func() time.Time { return now }
+	watchdog.Check()
+	assert.False(t, cycle.stalled)
+
+	watchdog.now = func() time.Time { return now.Add(5 * time.Minute) }
+	watchdog.Check()
+	assert.True(t, cycle.stalled)
+}
+
+func TestWatchdogRestartsStalledCycles(t *testing.T) {
+	now := time.Now()
+	cycle := newTrackedCycle(now.Add(-time.Hour), time.Second, runningState)
+	cycle.On("Stop").Return()
+	cycle.On("Start").Return()
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Cycles").Return(map[string]Cycle{"id1": cycle})
+
+	watchdog := NewWatchdog(sched, 10, 0, true, time.Minute)
+	watchdog.now = func() time.Time { return now }
+	watchdog.Check()
+
+	assert.True(t, cycle.stalled)
+	assert.Eventually(t, func() bool { return !watchdog.isRestarting("id1") }, time.Second, time.Millisecond)
+	cycle.AssertExpectations(t)
+}
+
+func TestWatchdogOnlyMarksCyclesWhichAreStillStopping(t *testing.T) {
+	now := time.Now()
+	release := make(chan struct{})
+
+	cycle := newTrackedCycle(now.Add(-time.Hour), time.Second, runningState)
+	cycle.On("Stop").Run(func(mock.Arguments) {
+		<-release
+	}).Return().Once()
+	cycle.On("Start").Return().Once()
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Cycles").Return(map[string]Cycle{"id1": cycle})
+
+	watchdog := NewWatchdog(sched, 10, 0, true, time.Minute)
+	watchdog.now = func() time.Time { return now }
+	watchdog.Check()
+	watchdog.Check()
+
+	assert.True(t, watchdog.isRestarting("id1"))
+	cycle.AssertNotCalled(t, "Start")
+
+	close(release)
+	assert.Eventually(t, func() bool { return !watchdog.isRestarting("id1") }, time.Second, time.Millisecond)
+	cycle.AssertNumberOfCalls(t, "Stop", 1)
+	cycle.AssertNumberOfCalls(t, "Start", 1)
+}
+
+func TestWatchdogRestartWaitsForBlockedPublish(t *testing.T) {
+	expectedUUID := uuid.NewUUID().String()
+	release := make(chan struct{})
+	executing := make(chan struct{}, 1)
+	var publishing, overlapped, executed int32
+
+	task := new(tasks.MockTask)
+	task.On("Prepare", "collection", expectedUUID).Return(&native.Content{}, "tid_"+expectedUUID, nil)
+	task.On("Execute", mock.Anything, expectedUUID, mock.AnythingOfType("*native.Content"), "origin", "tid_"+expectedUUID).Run(func(mock.Arguments) {
+		if atomic.AddInt32(&publishing, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		atomic.AddInt32(&executed, 1)
+
+		select {
+		case executing <- struct{}{}:
+		default:
+		}
+
+		<-release // a publish blocked in mongo, which ignores the cancellation
+		atomic.AddInt32(&publishing, -1)
+	}).Return(nil)
+
+	throttle := new(MockThrottle)
+	throttle.On("Queue").Return(nil)
+	throttle.On("Interval").Return(time.Millisecond)
+
+	iter := mockIterWithCollectionSize(expectedUUID, 2000, make(chan struct{}, 10))
+	happyIter(iter)
+	db := mockDB(make(chan struct{}, 10), mockTx(iter, nil), nil)
+
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(db, nil, blacklist.NoOpBlacklist)
+	c := NewThrottledWholeCollectionCycle("name", uuidCollectionBuilder, "collection", "origin", time.Millisecond*50, throttle, task)
+
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(true)
+	sched.On("Cycles").Return(map[string]Cycle{"id1": c})
+
+	watchdog := NewWatchdog(sched, 10, 0, true, time.Minute)
+	watchdog.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	c.Start()
+	<-executing
+
+	watchdog.Check()
+	time.Sleep(50 * time.Millisecond)
+	watchdog.Check()
+
+	assert.True(t, watchdog.isRestarting("id1"), "the cycle shouldn't be restarted while its publish is blocked")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&executed))
+	assert.Contains(t, c.State(), stalledState)
+
+	close(release)
+	assert.Eventually(t, func() bool { return !watchdog.isRestarting("id1") }, time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&executed) > 1 }, 5*time.Second, time.Millisecond)
+
+	c.Stop()
+	assert.Equal(t, int32(0), atomic.LoadInt32(&overlapped), "the restarted cycle shouldn't publish alongside the blocked one")
+	assert.Equal(t, []string{stoppedState}, c.State())
+}
+
+func TestWatchdogSkipsWhenSchedulerIsNotRunning(t *testing.T) {
+	sched := new(MockScheduler)
+	sched.On("IsRunning").Return(false)
+
+	NewWatchdog(sched, 10, 0, true, time.Minute).Check()
+	sched.AssertNotCalled(t, "Cycles")
+}
+
+func TestCycleStalledUntilProgress(t *testing.T) {
+	uuidCollectionBuilder := native.NewNativeUUIDCollectionBuilder(nil, nil, blacklist.NoOpBlacklist)
+	throttle, _ := NewThrottle(time.Second, 1)
+	cycle := NewThrottledWholeCollectionCycle("test", uuidCollectionBuilder, "methode", "methode-web-pub", time.Minute, throttle, nil).(*ThrottledWholeCollectionCycle)
+
+	assert.True(t, cycle.LastProgress().IsZero())
+	assert.Equal(t, time.Second, cycle.PublishInterval())
+
+	cycle.UpdateState(runningState)
+	cycle.MarkStalled()
+	cycle.MarkStalled()
+	assert.Equal(t, []string{runningState, stalledState}, cycle.State())
+
+	cycle.updateProgress("uuid", "tid", nil)
+	assert.Equal(t, []string{runningState}, cycle.State())
+	assert.False(t, cycle.LastProgress().IsZero())
+}
@@ -0,0 +1,70 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Financial-Times/publish-carousel/blacklist"
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/native"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ContentTaskName is the name of the default task, which publishes native content
+	ContentTaskName = "content"
+	// AnnotationsTaskName is the name of the task which publishes native annotations
+	AnnotationsTaskName = "annotations"
+	// ConsistencyCheckTaskName is the name of the task which republishes content missing from delivery
+	ConsistencyCheckTaskName = "consistency-check"
+)
+
+const defaultAnnotationsContentType = "application/json"
+
+type nativeAnnotationsTask struct {
+	*nativeContentTask
+	origins map[string]string
+}
+
+// NewNativeAnnotationsPublishTask publishes the native annotations from mongo to the annotations notifier, if the uuid has not been blacklisted.
+func NewNativeAnnotationsPublishTask(reader native.Reader, notifier cms.Notifier, isBlacklisted blacklist.IsBlacklisted, origins map[string]string) Task {
+	return &nativeAnnotationsTask{
+		nativeContentTask: &nativeContentTask{nativeReader: reader, cmsNotifier: notifier, isBlacklisted: isBlacklisted},
+		origins:           origins,
+	}
+}
+
+func (t *nativeAnnotationsTask) Execute(ctx context.Context, uuid string, content *native.Content, origin string, tid string) error {
+	data, err := json.Marshal(content.Body)
+	if err != nil {
+		return err
+	}
+
+	hash, err := native.Hash(data)
+	if err != nil {
+		return err
+	}
+
+	if content.OriginSystemID != "" {
+		origin = content.OriginSystemID
+	}
+
+	if mapped, ok := t.origins[origin]; ok {
+		origin = mapped
+	}
+
+	contentType := content.ContentType
+	if contentType == "" {
+		contentType = defaultAnnotationsContentType
+	}
+
+	annotations := &native.Content{Body: content.Body, ContentType: contentType, OriginSystemID: origin, Transforms: content.Transforms, Cycle: content.Cycle}
+
+	err = t.cmsNotifier.Notify(ctx, origin, tid, annotations, hash)
+	if err != nil {
+		log.WithField("uuid", uuid).WithError(err).Warn("Failed to post to annotations notifier")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/publish-carousel/blacklist"
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/native"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var annotationsOrigins = map[string]string{"pac": "http://cmdb.ft.com/systems/pac"}
+
+func TestPublishAnnotations(t *testing.T) {
+	notifier := new(cms.MockNotifier)
+	reader := new(native.MockReader)
+
+	content, hash := mockContent("tid_1234")
+	content.ContentType = ""
+
+	reader.On("Get", "pac-metadata", "a-uuid").Return(content, nil)
+	notifier.On("Notify", mock.Anything, "http://cmdb.ft.com/systems/pac", carouselTidMatcher, mock.MatchedBy(func(annotations *native.Content) bool {
+		return annotations.ContentType == "application/json" && annotations.OriginSystemID == "http://cmdb.ft.com/systems/pac"
+	}), hash).Return(nil)
+
+	task := NewNativeAnnotationsPublishTask(reader, notifier, blacklist.NoOpBlacklist, annotationsOrigins)
+
+	content, txID, err := task.Prepare("pac-metadata", "a-uuid")
+	require.NoError(t, err)
+
+	err = task.Execute(context.Background(), "a-uuid", content, "pac", txID)
+	assert.NoError(t, err)
+	assert.Equal(t, "tid_1234", content.Body[publishReferenceAttr], "the annotations should be published as they are stored")
+
+	reader.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestPublishAnnotationsWithStoredOrigin(t *testing.T) {
+	notifier := new(cms.MockNotifier)
+
+	content, hash := mockContent("")
+	content.OriginSystemID = "pac"
+
+	notifier.On("Notify", mock.Anything, "http://cmdb.ft.com/systems/pac", "tid_1234", mock.Anything, hash).Return(nil)
+
+	task := NewNativeAnnotationsPublishTask(new(native.MockReader), notifier, blacklist.NoOpBlacklist, annotationsOrigins)
+	err := task.Execute(context.Background(), "a-uuid", content, "methode-web-pub", "tid_1234")
+	assert.NoError(t, err)
+	notifier.AssertExpectations(t)
+}
+
+func TestPublishAnnotationsWithUnmappedOrigin(t *testing.T) {
+	notifier := new(cms.MockNotifier)
+
+	content, hash := mockContent("")
+	notifier.On("Notify", mock.Anything, "http://cmdb.ft.com/systems/next-video-editor", "tid_1234", mock.Anything, hash).Return(errors.New("nope"))
+
+	task := NewNativeAnnotationsPublishTask(new(native.MockReader), notifier, blacklist.NoOpBlacklist, annotationsOrigins)
+	err := task.Execute(context.Background(), "a-uuid", content, "http://cmdb.ft.com/systems/next-video-editor", "tid_1234")
+	assert.EqualError(t, err, "nope")
+	notifier.AssertExpectations(t)
+}
+
+func TestPrepareBlacklistedAnnotations(t *testing.T) {
+	reader := new(native.MockReader)
+	task := NewNativeAnnotationsPublishTask(reader, new(cms.MockNotifier), func(uuid string) (bool, error) { return true, nil }, annotationsOrigins)
+
+	_, _, err := task.Prepare("pac-metadata", "a-uuid")
+
+	skip := &SkipError{}
+	assert.True(t, errors.As(err, &skip))
+	reader.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
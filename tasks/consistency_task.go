@@ -0,0 +1,178 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/cluster"
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MissingInDelivery means a read environment has no content for the uuid
+	MissingInDelivery = "missing"
+	// StaleInDelivery means a read environment has an older version of the content than the native store
+	StaleInDelivery = "stale"
+)
+
+const (
+	lastModifiedAttr = "lastModified"
+	uuidPlaceholder  = "{uuid}"
+)
+
+// Discrepancy describes content which is missing or stale in one of the read environments
+type Discrepancy struct {
+	Time                     time.Time `json:"time"`
+	Cycle                    string    `json:"cycle,omitempty"`
+	UUID                     string    `json:"uuid"`
+	Environment              string    `json:"environment"`
+	Reason                   string    `json:"reason"`
+	NativeHash               string    `json:"nativeHash"`
+	NativeLastModified       string    `json:"nativeLastModified,omitempty"`
+	NativePublishReference   string    `json:"nativePublishReference,omitempty"`
+	DeliveryLastModified     string    `json:"deliveryLastModified,omitempty"`
+	DeliveryPublishReference string    `json:"deliveryPublishReference,omitempty"`
+	TransactionID            string    `json:"transactionId"`
+}
+
+// DiscrepancyReport records the discrepancies found for each republished uuid
+type DiscrepancyReport interface {
+	Report(discrepancies []Discrepancy) error
+}
+
+type consistencyCheckTask struct {
+	Task
+	environments cluster.ReadEnvironments
+	client       cluster.HttpClient
+	path         string
+	report       DiscrepancyReport
+}
+
+// NewConsistencyCheckTask returns a task which only executes the given task if the content is missing or stale in delivery
+func NewConsistencyCheckTask(task Task, environments cluster.ReadEnvironments, client cluster.HttpClient, path string, report DiscrepancyReport) (Task, error) {
+	if !strings.Contains(path, uuidPlaceholder) {
+		return nil, fmt.Errorf("Invalid read API path %v, please include %v", path, uuidPlaceholder)
+	}
+	return &consistencyCheckTask{Task: task, environments: environments, client: client, path: path, report: report}, nil
+}
+
+type deliveryContent struct {
+	LastModified     string `json:"lastModified"`
+	PublishReference string `json:"publishReference"`
+}
+
+func (t *consistencyCheckTask) Execute(ctx context.Context, uuid string, content *native.Content, origin string, tid string) error {
+	discrepancies, err := t.compare(uuid, content, tid)
+	if err != nil {
+		log.WithField("uuid", uuid).WithError(err).Warn("Failed to compare content with the read environments.")
+		return err
+	}
+
+	if len(discrepancies) == 0 {
+		log.WithField("uuid", uuid).Info("Content is consistent with the read environments. Skipping republish.")
+		return &SkipError{UUID: uuid, Reason: "it is consistent with the read environments"}
+	}
+
+	if err := t.report.Report(discrepancies); err != nil {
+		log.WithField("uuid", uuid).WithError(err).Warn("Failed to report discrepancies with the read environments.")
+	}
+
+	log.WithField("uuid", uuid).WithField("discrepancies", len(discrepancies)).Info("Content is missing or stale in the read environments. Republishing.")
+	return t.Task.Execute(ctx, uuid, content, origin, tid)
+}
+
+// compare returns a discrepancy for each read environment where the content is missing or stale
+func (t *consistencyCheckTask) compare(uuid string, content *native.Content, tid string) ([]Discrepancy, error) {
+	data, err := json.Marshal(content.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := native.Hash(data)
+	if err != nil {
+		return nil, err
+	}
+
+	nativeLastModified, _ := content.Body[lastModifiedAttr].(string)
+	nativePublishReference, _ := content.Body[publishReferenceAttr].(string)
+
+	environments := t.environments.ReadEnvironments()
+	if len(environments) == 0 {
+		return nil, errors.New("There are no read environments to compare the content with")
+	}
+
+	var discrepancies []Discrepancy
+	for _, env := range environments {
+		delivered := deliveryContent{}
+		found, err := env.Get(t.client, strings.Replace(t.path, uuidPlaceholder, uuid, -1), &delivered)
+		if err != nil {
+			return nil, err
+		}
+
+		reason := MissingInDelivery
+		if found {
+			if !isStale(nativeLastModified, nativePublishReference, delivered) {
+				continue
+			}
+			reason = StaleInDelivery
+		}
+
+		discrepancies = append(discrepancies, Discrepancy{
+			Time:                     time.Now(),
+			Cycle:                    content.Cycle,
+			UUID:                     uuid,
+			Environment:              env.Name,
+			Reason:                   reason,
+			NativeHash:               hash,
+			NativeLastModified:       nativeLastModified,
+			NativePublishReference:   nativePublishReference,
+			DeliveryLastModified:     delivered.LastModified,
+			DeliveryPublishReference: delivered.PublishReference,
+			TransactionID:            tid,
+		})
+	}
+	return discrepancies, nil
+}
+
+// isStale compares the lastModified dates, or the publishReferences, of the native and delivered content
+func isStale(nativeLastModified string, nativePublishReference string, delivered deliveryContent) bool {
+	nativeTime, nativeErr := time.Parse(time.RFC3339Nano, nativeLastModified)
+	deliveryTime, deliveryErr := time.Parse(time.RFC3339Nano, delivered.LastModified)
+	if nativeErr == nil && deliveryErr == nil {
+		return nativeTime.After(deliveryTime)
+	}
+
+	return nativePublishReference == "" || !strings.HasPrefix(delivered.PublishReference, nativePublishReference)
+}
+
+type s3DiscrepancyReport struct {
+	rw s3.ReadWriter
+}
+
+// NewS3DiscrepancyReport returns a report which saves the discrepancies for each uuid under discrepancies/<cycle>/
+func NewS3DiscrepancyReport(rw s3.ReadWriter) DiscrepancyReport {
+	return &s3DiscrepancyReport{rw: rw}
+}
+
+func (r *s3DiscrepancyReport) Report(discrepancies []Discrepancy) error {
+	if len(discrepancies) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(discrepancies)
+	if err != nil {
+		return err
+	}
+
+	cycle := discrepancies[0].Cycle
+	if cycle == "" {
+		cycle = "unknown"
+	}
+	return r.rw.Write("discrepancies/"+cycle, discrepancies[0].UUID+".json", data, "application/json")
+}
@@ -0,0 +1,169 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Financial-Times/publish-carousel/cluster"
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockReport struct {
+	mock.Mock
+}
+
+func (m *mockReport) Report(discrepancies []Discrepancy) error {
+	args := m.Called(discrepancies)
+	return args.Error(0)
+}
+
+// deliveryServer serves the given body for the uuid, or a 404 if the body is empty
+func deliveryServer(t *testing.T, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/content/fake-uuid", r.URL.Path)
+		if body == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+}
+
+func readEnvironments(servers ...*httptest.Server) *cluster.MockReadEnvironments {
+	var envs []cluster.ReadEnvironment
+	for i, server := range servers {
+		readURL, _ := url.Parse(server.URL)
+		envs = append(envs, cluster.ReadEnvironment{Name: fmt.Sprintf("env%v", i+1), ReadURL: readURL})
+	}
+
+	e := new(cluster.MockReadEnvironments)
+	e.On("ReadEnvironments").Return(envs)
+	return e
+}
+
+func nativeContent() *native.Content {
+	return &native.Content{Body: map[string]interface{}{"uuid": "fake-uuid", "lastModified": "2017-06-01T12:00:00.000Z", "publishReference": "tid_native"}, Cycle: "methode-whole-archive"}
+}
+
+func TestConsistencyCheckSkipsConsistentContent(t *testing.T) {
+	server1 := deliveryServer(t, `{"lastModified":"2017-06-01T12:00:00.000Z","publishReference":"tid_other"}`)
+	defer server1.Close()
+	server2 := deliveryServer(t, `{"publishReference":"tid_native_carousel_1496318400"}`)
+	defer server2.Close()
+
+	task := new(MockTask)
+	report := new(mockReport)
+
+	consistency, err := NewConsistencyCheckTask(task, readEnvironments(server1, server2), http.DefaultClient, "/content/{uuid}", report)
+	require.NoError(t, err)
+
+	err = consistency.Execute(context.Background(), "fake-uuid", nativeContent(), "methode-web-pub", "tid_native_carousel_1496318401")
+
+	skip := &SkipError{}
+	require.True(t, errors.As(err, &skip))
+	assert.Equal(t, "it is consistent with the read environments", skip.Reason)
+	task.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	report.AssertNotCalled(t, "Report", mock.Anything)
+}
+
+func TestConsistencyCheckRepublishesMissingAndStaleContent(t *testing.T) {
+	missing := deliveryServer(t, "")
+	defer missing.Close()
+	stale := deliveryServer(t, `{"lastModified":"2017-05-01T12:00:00.000Z","publishReference":"tid_older"}`)
+	defer stale.Close()
+	consistent := deliveryServer(t, `{"lastModified":"2017-06-01T12:00:00.000Z","publishReference":"tid_native"}`)
+	defer consistent.Close()
+
+	content := nativeContent()
+
+	task := new(MockTask)
+	task.On("Execute", mock.Anything, "fake-uuid", content, "methode-web-pub", "tid_native_carousel_1496318401").Return(nil)
+
+	var reported []Discrepancy
+	report := new(mockReport)
+	report.On("Report", mock.Anything).Run(func(args mock.Arguments) {
+		reported = args.Get(0).([]Discrepancy)
+	}).Return(nil)
+
+	consistency, err := NewConsistencyCheckTask(task, readEnvironments(missing, stale, consistent), http.DefaultClient, "/content/{uuid}", report)
+	require.NoError(t, err)
+
+	err = consistency.Execute(context.Background(), "fake-uuid", content, "methode-web-pub", "tid_native_carousel_1496318401")
+	assert.NoError(t, err)
+	task.AssertExpectations(t)
+
+	require.Len(t, reported, 2)
+	assert.Equal(t, "env1", reported[0].Environment)
+	assert.Equal(t, MissingInDelivery, reported[0].Reason)
+	assert.Equal(t, "env2", reported[1].Environment)
+	assert.Equal(t, StaleInDelivery, reported[1].Reason)
+	assert.Equal(t, "2017-05-01T12:00:00.000Z", reported[1].DeliveryLastModified)
+	assert.Equal(t, "tid_older", reported[1].DeliveryPublishReference)
+
+	for _, d := range reported {
+		assert.Equal(t, "methode-whole-archive", d.Cycle)
+		assert.Equal(t, "fake-uuid", d.UUID)
+		assert.Equal(t, "2017-06-01T12:00:00.000Z", d.NativeLastModified)
+		assert.Equal(t, "tid_native", d.NativePublishReference)
+		assert.Equal(t, "tid_native_carousel_1496318401", d.TransactionID)
+		assert.NotEmpty(t, d.NativeHash)
+	}
+}
+
+func TestConsistencyCheckComparesPublishReferencesWithoutDates(t *testing.T) {
+	assert.False(t, isStale("", "tid_native", deliveryContent{PublishReference: "tid_native"}))
+	assert.False(t, isStale("", "tid_native", deliveryContent{PublishReference: "tid_native_carousel_1496318400"}))
+	assert.True(t, isStale("", "tid_native", deliveryContent{PublishReference: "tid_older"}))
+	assert.True(t, isStale("2017-06-01T12:00:00.000Z", "", deliveryContent{PublishReference: "tid_older"}))
+	assert.True(t, isStale("2017-06-01T12:00:00.000Z", "tid_native", deliveryContent{LastModified: "2017-05-01T12:00:00Z", PublishReference: "tid_native"}), "the dates take precedence over the publish references")
+}
+
+func TestConsistencyCheckFailsIfDeliveryCannotBeRead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	task := new(MockTask)
+	consistency, err := NewConsistencyCheckTask(task, readEnvironments(server), http.DefaultClient, "/content/{uuid}", new(mockReport))
+	require.NoError(t, err)
+
+	err = consistency.Execute(context.Background(), "fake-uuid", nativeContent(), "methode-web-pub", "tid_1234")
+	assert.EqualError(t, err, "Read API "+server.URL+"/content/fake-uuid returned a non-200 code: 503")
+	task.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	consistency, err = NewConsistencyCheckTask(task, readEnvironments(), http.DefaultClient, "/content/{uuid}", new(mockReport))
+	require.NoError(t, err)
+
+	err = consistency.Execute(context.Background(), "fake-uuid", nativeContent(), "methode-web-pub", "tid_1234")
+	assert.EqualError(t, err, "There are no read environments to compare the content with")
+}
+
+func TestConsistencyCheckInvalidPath(t *testing.T) {
+	_, err := NewConsistencyCheckTask(new(MockTask), readEnvironments(), http.DefaultClient, "/content", new(mockReport))
+	assert.EqualError(t, err, "Invalid read API path /content, please include {uuid}")
+}
+
+func TestS3DiscrepancyReport(t *testing.T) {
+	discrepancies := []Discrepancy{{Cycle: "methode-whole-archive", UUID: "fake-uuid", Environment: "env1", Reason: MissingInDelivery}}
+
+	rw := new(s3.MockReadWriter)
+	rw.On("Write", "discrepancies/methode-whole-archive", "fake-uuid.json", mock.MatchedBy(func(data []byte) bool {
+		var actual []Discrepancy
+		return json.Unmarshal(data, &actual) == nil && assert.Equal(t, discrepancies, actual)
+	}), "application/json").Return(nil)
+
+	err := NewS3DiscrepancyReport(rw).Report(discrepancies)
+	assert.NoError(t, err)
+	rw.AssertExpectations(t)
+}
@@ -0,0 +1,49 @@
+package tasks
+
+import (
+	"fmt"
+
+	"github.com/Financial-Times/publish-carousel/filter"
+	"github.com/Financial-Times/publish-carousel/native"
+	log "github.com/sirupsen/logrus"
+)
+
+// SkipError is returned by a task when content is deliberately not published
+type SkipError struct {
+	UUID   string
+	Reason string
+}
+
+func (e *SkipError) Error() string {
+	return fmt.Sprintf(`Skipping uuid "%v" as %v`, e.UUID, e.Reason)
+}
+
+type filteredTask struct {
+	Task
+	chain filter.Chain
+}
+
+// NewFilteredTask returns a task which only publishes content allowed by the filter chain
+func NewFilteredTask(task Task, chain filter.Chain) Task {
+	return &filteredTask{Task: task, chain: chain}
+}
+
+func (t *filteredTask) Prepare(collection string, uuid string) (*native.Content, string, error) {
+	content, tid, err := t.Task.Prepare(collection, uuid)
+	if err != nil {
+		return content, tid, err
+	}
+
+	decision, err := t.chain.Check(uuid, content)
+	if err != nil {
+		log.WithField("uuid", uuid).WithField("collection", collection).WithError(err).Warn("Content filter check failed.")
+		return nil, "", err
+	}
+
+	if !decision.Publish {
+		log.WithField("uuid", uuid).WithField("collection", collection).WithField("rule", decision.Rule).Info("This UUID has been denied by a filter rule. Skipping republish.")
+		return nil, "", &SkipError{UUID: uuid, Reason: fmt.Sprintf("it is denied by filter rule %v", decision.Rule)}
+	}
+
+	return content, tid, nil
+}
@@ -0,0 +1,70 @@
+package tasks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/publish-carousel/filter"
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func skipImages(t *testing.T) filter.Chain {
+	config, err := filter.ParseConfig([]byte("global:\n- {name: skip-images, action: deny, type: [Image]}\n"))
+	require.NoError(t, err)
+	return config.ForCycle("test-cycle")
+}
+
+func TestFilteredTaskSkipsDeniedContent(t *testing.T) {
+	content := &native.Content{Body: map[string]interface{}{"type": "Image"}}
+
+	task := new(MockTask)
+	task.On("Prepare", "methode", "fake-uuid").Return(content, "tid_1234", nil)
+
+	filtered := NewFilteredTask(task, skipImages(t))
+
+	_, _, err := filtered.Prepare("methode", "fake-uuid")
+	require.Error(t, err)
+
+	skip := &SkipError{}
+	require.True(t, errors.As(err, &skip))
+	assert.Equal(t, "it is denied by filter rule skip-images", skip.Reason)
+	assert.EqualError(t, err, `Skipping uuid "fake-uuid" as it is denied by filter rule skip-images`)
+	task.AssertExpectations(t)
+}
+
+func TestFilteredTaskPublishesAllowedContent(t *testing.T) {
+	content := &native.Content{Body: map[string]interface{}{"type": "Article"}}
+
+	task := new(MockTask)
+	task.On("Prepare", "methode", "fake-uuid").Return(content, "tid_1234", nil)
+
+	filtered := NewFilteredTask(task, skipImages(t))
+
+	actual, tid, err := filtered.Prepare("methode", "fake-uuid")
+	assert.NoError(t, err)
+	assert.Equal(t, content, actual)
+	assert.Equal(t, "tid_1234", tid)
+	task.AssertExpectations(t)
+}
+
+func TestFilteredTaskPrepareFails(t *testing.T) {
+	task := new(MockTask)
+	task.On("Prepare", "methode", "fake-uuid").Return(&native.Content{}, "", errors.New("oh dear"))
+
+	filtered := NewFilteredTask(task, skipImages(t))
+
+	_, _, err := filtered.Prepare("methode", "fake-uuid")
+	assert.EqualError(t, err, "oh dear")
+}
+
+func TestFilteredTaskFilterFails(t *testing.T) {
+	task := new(MockTask)
+	task.On("Prepare", "methode", "fake-uuid").Return(&native.Content{}, "tid_1234", nil)
+
+	filtered := NewFilteredTask(task, skipImages(t))
+
+	_, _, err := filtered.Prepare("methode", "fake-uuid")
+	assert.EqualError(t, err, "no body found")
+}
@@ -1,6 +1,8 @@
 package tasks
 
 import (
+	"context"
+
 	"github.com/Financial-Times/publish-carousel/native"
 
 	"github.com/stretchr/testify/mock"
@@ -15,7 +17,7 @@ func (m *MockTask) Prepare(collection string, uuid string) (*native.Content, str
 	return args.Get(0).(*native.Content), args.String(1), args.Error(2)
 }
 
-func (m *MockTask) Execute(uuid string, content *native.Content, origin string, txId string) error {
-	args := m.Called(uuid, content, origin, txId)
+func (m *MockTask) Execute(ctx context.Context, uuid string, content *native.Content, origin string, txId string) error {
+	args := m.Called(ctx, uuid, content, origin, txId)
 	return args.Error(0)
 }
@@ -1,14 +1,15 @@
 package tasks
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Financial-Times/publish-carousel/blacklist"
 	"github.com/Financial-Times/publish-carousel/cms"
-	"github.com/Financial-Times/publish-carousel/image"
 	"github.com/Financial-Times/publish-carousel/native"
 	tid "github.com/Financial-Times/transactionid-utils-go"
 	log "github.com/sirupsen/logrus"
@@ -16,43 +17,43 @@ import (
 
 type Task interface {
 	Prepare(collection string, uuid string) (*native.Content, string, error)
-	Execute(uuid string, content *native.Content, origin string, txId string) error
+	Execute(ctx context.Context, uuid string, content *native.Content, origin string, txId string) error
 }
 
 type nativeContentTask struct {
-	nativeReader native.Reader
-	cmsNotifier  cms.Notifier
-	isImage      image.Filter
+	nativeReader  native.Reader
+	cmsNotifier   cms.Notifier
+	isBlacklisted blacklist.IsBlacklisted
 }
 
 // NewNativeContentPublishTask publishes the native content from mongo to the cms notifier, if the uuid has not been blacklisted.
-func NewNativeContentPublishTask(reader native.Reader, notifier cms.Notifier, isImage image.Filter) Task {
-	return &nativeContentTask{nativeReader: reader, cmsNotifier: notifier, isImage: isImage}
+func NewNativeContentPublishTask(reader native.Reader, notifier cms.Notifier, isBlacklisted blacklist.IsBlacklisted) Task {
+	return &nativeContentTask{nativeReader: reader, cmsNotifier: notifier, isBlacklisted: isBlacklisted}
 }
 
 const publishReferenceAttr = "publishReference"
 
 func (t *nativeContentTask) Prepare(collection string, uuid string) (*native.Content, string, error) {
-	content, err := t.nativeReader.Get(collection, uuid)
+	blacklisted, err := t.isBlacklisted(uuid)
 	if err != nil {
-		log.WithField("uuid", uuid).WithError(err).Warn("Failed to read from native reader")
+		log.WithField("uuid", uuid).WithField("collection", collection).WithError(err).Warn("Blacklist check failed.")
 		return nil, "", err
 	}
 
-	if content.Body == nil {
-		log.WithField("uuid", uuid).Warn("No Content found for uuid. Skipping.")
-		return nil, "", fmt.Errorf(`Skipping uuid "%v" as it has no content`, uuid)
+	if blacklisted {
+		log.WithField("uuid", uuid).WithField("collection", collection).Info("This UUID has been blacklisted. Skipping republish.")
+		return nil, "", &SkipError{UUID: uuid, Reason: "it is blacklisted"}
 	}
 
-	invalid, err := t.isImage(uuid, content)
+	content, err := t.nativeReader.Get(collection, uuid)
 	if err != nil {
-		log.WithField("uuid", uuid).WithField("collection", collection).WithError(err).Warn("Blacklist check failed.")
+		log.WithField("uuid", uuid).WithError(err).Warn("Failed to read from native reader")
 		return nil, "", err
 	}
 
-	if invalid {
-		log.WithField("uuid", uuid).WithField("collection", collection).Info("This UUID contains an image. Skipping republish.")
-		return nil, "", fmt.Errorf(`Skipping uuid "%v" as it is an image`, uuid)
+	if content.Body == nil {
+		log.WithField("uuid", uuid).Warn("No Content found for uuid. Skipping.")
+		return nil, "", fmt.Errorf(`Skipping uuid "%v" as it has no content`, uuid)
 	}
 
 	tid, ok := content.Body[publishReferenceAttr].(string)
@@ -65,7 +66,7 @@ func (t *nativeContentTask) Prepare(collection string, uuid string) (*native.Con
 	return content, tid, nil
 }
 
-func (t *nativeContentTask) Execute(uuid string, content *native.Content, origin string, tid string) error {
+func (t *nativeContentTask) Execute(ctx context.Context, uuid string, content *native.Content, origin string, tid string) error {
 	data, err := json.Marshal(content.Body)
 	if err != nil {
 		return err
@@ -78,7 +79,7 @@ func (t *nativeContentTask) Execute(uuid string, content *native.Content, origin
 
 	content.Body[publishReferenceAttr] = tid
 
-	err = t.cmsNotifier.Notify(origin, tid, content, hash)
+	err = t.cmsNotifier.Notify(ctx, origin, tid, content, hash)
 	if err != nil {
 		log.WithField("uuid", uuid).WithError(err).Warn("Failed to post to cms notifier")
 		return err
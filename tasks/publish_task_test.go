@@ -1,14 +1,15 @@
 package tasks
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/Financial-Times/publish-carousel/blacklist"
 	"github.com/Financial-Times/publish-carousel/cms"
-	"github.com/Financial-Times/publish-carousel/image"
 	"github.com/Financial-Times/publish-carousel/native"
 
 	"github.com/stretchr/testify/assert"
@@ -57,14 +58,14 @@ func TestPublishWithTID(t *testing.T) {
 	content, hash := mockContent("tid_1234")
 
 	reader.On("Get", testCollection, testUUID).Return(content, nil)
-	notifier.On("Notify", origin, carouselTidMatcher, content, hash).Return(nil)
+	notifier.On("Notify", mock.Anything, origin, carouselTidMatcher, content, hash).Return(nil)
 
-	task := NewNativeContentPublishTask(reader, notifier, image.NoOpImageFilter)
+	task := NewNativeContentPublishTask(reader, notifier, blacklist.NoOpBlacklist)
 
 	content, txID, err := task.Prepare(testCollection, testUUID)
 	require.NoError(t, err)
 
-	err = task.Execute(testUUID, content, origin, txID)
+	err = task.Execute(context.Background(), testUUID, content, origin, txID)
 	assert.NoError(t, err)
 
 	reader.AssertExpectations(t)
@@ -82,14 +83,14 @@ func TestPublishWithGeneratedTID(t *testing.T) {
 	content, hash := mockContent("")
 
 	reader.On("Get", testCollection, testUUID).Return(content, nil)
-	notifier.On("Notify", origin, carouselGentxTidMatcher, content, hash).Return(nil)
+	notifier.On("Notify", mock.Anything, origin, carouselGentxTidMatcher, content, hash).Return(nil)
 
-	task := NewNativeContentPublishTask(reader, notifier, image.NoOpImageFilter)
+	task := NewNativeContentPublishTask(reader, notifier, blacklist.NoOpBlacklist)
 
 	content, txID, err := task.Prepare(testCollection, testUUID)
 	require.NoError(t, err)
 
-	err = task.Execute(testUUID, content, origin, txID)
+	err = task.Execute(context.Background(), testUUID, content, origin, txID)
 	assert.NoError(t, err)
 
 	reader.AssertExpectations(t)
@@ -108,9 +109,9 @@ func TestPublishJSONMarshalFails(t *testing.T) {
 	testBody["errrr"] = func() {}
 	content := native.Content{Body: testBody, ContentType: "application/vnd.expect-this"}
 
-	task := NewNativeContentPublishTask(reader, notifier, image.NoOpImageFilter)
+	task := NewNativeContentPublishTask(reader, notifier, blacklist.NoOpBlacklist)
 
-	err := task.Execute(testUUID, &content, origin, txID)
+	err := task.Execute(context.Background(), testUUID, &content, origin, txID)
 	assert.Error(t, err)
 }
 
@@ -125,7 +126,7 @@ func TestFailedReader(t *testing.T) {
 
 	reader.On("Get", testCollection, testUUID).Return(content, errors.New("fail"))
 
-	task := NewNativeContentPublishTask(reader, notifier, image.NoOpImageFilter)
+	task := NewNativeContentPublishTask(reader, notifier, blacklist.NoOpBlacklist)
 
 	_, _, err := task.Prepare(testCollection, testUUID)
 	assert.Error(t, err)
@@ -145,7 +146,7 @@ func TestEmptyContent(t *testing.T) {
 
 	reader.On("Get", testCollection, testUUID).Return(content, nil)
 
-	task := NewNativeContentPublishTask(reader, notifier, image.NoOpImageFilter)
+	task := NewNativeContentPublishTask(reader, notifier, blacklist.NoOpBlacklist)
 	_, _, err := task.Prepare(testCollection, testUUID)
 	assert.Error(t, err)
 
@@ -164,89 +165,50 @@ func TestFailedNotify(t *testing.T) {
 	content, hash := mockContent("tid_1234")
 
 	reader.On("Get", testCollection, testUUID).Return(content, nil)
-	notifier.On("Notify", origin, carouselTidMatcher, content, hash).Return(errors.New("fail"))
+	notifier.On("Notify", mock.Anything, origin, carouselTidMatcher, content, hash).Return(errors.New("fail"))
 
-	task := NewNativeContentPublishTask(reader, notifier, image.NoOpImageFilter)
+	task := NewNativeContentPublishTask(reader, notifier, blacklist.NoOpBlacklist)
 
 	content, txID, err := task.Prepare(testCollection, testUUID)
 	assert.NoError(t, err)
 
-	err = task.Execute(testUUID, content, origin, txID)
+	err = task.Execute(context.Background(), testUUID, content, origin, txID)
 	assert.Error(t, err)
 
 	reader.AssertExpectations(t)
 	notifier.AssertExpectations(t)
 }
 
-func TestUUIDIsImage(t *testing.T) {
+func TestBlacklistedAtPublishTime(t *testing.T) {
 	notifier := new(cms.MockNotifier)
 	reader := new(native.MockReader)
 
 	testCollection := "testing123"
-	testUUID := "i am a uuid"
-
-	imageFilterCalled := false
-
-	isImage := func(uuid string, content *native.Content) (bool, error) {
-		imageFilterCalled = true
-		if uuid == testUUID {
-			return true, nil
-		}
-		return false, nil
-	}
+	testUUID := "335a60b8-3092-11e0-9de3-00144feabdc0"
 
-	body := make(map[string]interface{})
-	body[publishReferenceAttr] = "tid_1234"
+	blist := blacklist.NewIndexedBlacklist(nil)
+	task := NewNativeContentPublishTask(reader, notifier, blist.IsBlacklisted)
 
-	testContent := &native.Content{
-		Body:        body,
-		ContentType: "application/json",
-	}
-
-	reader.On("Get", testCollection, testUUID).Return(testContent, nil)
-
-	task := NewNativeContentPublishTask(reader, notifier, isImage)
+	require.NoError(t, blist.Add(blacklist.Entry{UUID: testUUID}))
 
 	_, _, err := task.Prepare(testCollection, testUUID)
-	require.Error(t, err)
+	assert.EqualError(t, err, `Skipping uuid "335a60b8-3092-11e0-9de3-00144feabdc0" as it is blacklisted`)
+	assert.IsType(t, &SkipError{}, err, "blacklisted uuids should be skipped, rather than fail")
 
-	reader.AssertExpectations(t)
-	notifier.AssertExpectations(t)
-	assert.True(t, imageFilterCalled)
+	reader.AssertNotCalled(t, "Get", testCollection, testUUID)
 }
 
-func TestBlacklistFails(t *testing.T) {
+func TestPublishTimeBlacklistCheckFails(t *testing.T) {
 	notifier := new(cms.MockNotifier)
 	reader := new(native.MockReader)
 
-	testCollection := "testing123"
-	testUUID := "i am a uuid"
-
-	imageFilterCalled := false
-
-	isImage := func(uuid string, content *native.Content) (bool, error) {
-		imageFilterCalled = true
-		if uuid == testUUID {
-			return false, errors.New("oh dear")
-		}
-		return false, nil
-	}
-
-	body := make(map[string]interface{})
-	body[publishReferenceAttr] = "tid_1234"
-
-	content := &native.Content{
-		Body:        body,
-		ContentType: "application/json",
+	isBlacklisted := func(uuid string) (bool, error) {
+		return false, errors.New("oh dear")
 	}
 
-	reader.On("Get", testCollection, testUUID).Return(content, nil)
-	task := NewNativeContentPublishTask(reader, notifier, isImage)
+	task := NewNativeContentPublishTask(reader, notifier, isBlacklisted)
 
-	_, _, err := task.Prepare(testCollection, testUUID)
-	assert.Error(t, err)
-
-	reader.AssertExpectations(t)
-	notifier.AssertExpectations(t)
-	assert.True(t, imageFilterCalled)
+	_, _, err := task.Prepare("testing123", "i am a uuid")
+	assert.EqualError(t, err, "oh dear")
+	reader.AssertNotCalled(t, "Get", "testing123", "i am a uuid")
 }
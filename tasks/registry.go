@@ -0,0 +1,106 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/filter"
+	"github.com/Financial-Times/publish-carousel/transform"
+)
+
+// Definition describes a named task which cycles can select
+type Definition struct {
+	Name string
+	// Notifier is the name of the notifier which the task publishes to, defaulting to the cms-notifier
+	Notifier string
+	// Filters are checked for every cycle running the task, before the cycle's own and the global filter rules
+	Filters filter.Chain
+	// Transforms are applied for every cycle running the task, before the global and the cycle's own transforms
+	Transforms transform.Pipeline
+	// New builds the task for a cycle, publishing to the given notifier
+	New func(notifier cms.Notifier) (Task, error)
+}
+
+// Registry holds the named tasks which cycles can select with their task field
+type Registry interface {
+	// Register adds the task, and returns an error if a task with the same name is already registered
+	Register(definition Definition) error
+	// Lookup returns the definition of the named task, if it is registered
+	Lookup(name string) (Definition, bool)
+	// Names returns the names of the registered tasks in alphabetical order
+	Names() []string
+	// NewTask builds the named task, publishing to the given notifier, with the task's own filters and transforms
+	NewTask(name string, notifier cms.Notifier) (Task, error)
+}
+
+type registry struct {
+	definitions map[string]Definition
+}
+
+// NewRegistry returns an empty task registry
+func NewRegistry() Registry {
+	return &registry{definitions: make(map[string]Definition)}
+}
+
+func registryKey(name string) string {
+	if name == "" {
+		return ContentTaskName
+	}
+	return strings.ToLower(name)
+}
+
+func (r *registry) Register(definition Definition) error {
+	if strings.TrimSpace(definition.Name) == "" {
+		return errors.New("Please provide a name for every task")
+	}
+
+	if definition.New == nil {
+		return fmt.Errorf("Task %v cannot be built", definition.Name)
+	}
+
+	key := registryKey(definition.Name)
+	if _, ok := r.definitions[key]; ok {
+		return fmt.Errorf("Task %v is already registered", definition.Name)
+	}
+
+	r.definitions[key] = definition
+	return nil
+}
+
+func (r *registry) Lookup(name string) (Definition, bool) {
+	definition, ok := r.definitions[registryKey(name)]
+	return definition, ok
+}
+
+func (r *registry) Names() []string {
+	names := make([]string, 0, len(r.definitions))
+	for _, definition := range r.definitions {
+		names = append(names, definition.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *registry) NewTask(name string, notifier cms.Notifier) (Task, error) {
+	definition, ok := r.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("Unknown task %v, please use one of %v", name, strings.Join(r.Names(), ", "))
+	}
+
+	task, err := definition.New(notifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(definition.Filters) > 0 {
+		task = NewFilteredTask(task, definition.Filters)
+	}
+
+	if len(definition.Transforms) > 0 {
+		task = NewTransformedTask(task, definition.Transforms)
+	}
+	return task, nil
+}
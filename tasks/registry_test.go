@@ -0,0 +1,86 @@
+package tasks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/filter"
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryNewTask(t *testing.T) {
+	content := new(MockTask)
+	var notified cms.Notifier
+
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(Definition{Name: "content", New: func(n cms.Notifier) (Task, error) {
+		notified = n
+		return content, nil
+	}}))
+	require.NoError(t, registry.Register(Definition{Name: "Annotations", Notifier: cms.AnnotationsNotifierName, New: func(n cms.Notifier) (Task, error) {
+		return nil, errors.New("no annotations notifier")
+	}}))
+
+	notifier := new(cms.MockNotifier)
+	task, err := registry.NewTask("", notifier)
+	require.NoError(t, err)
+	assert.True(t, task == content, "the content task is built for an empty name, without filters or transforms")
+	assert.True(t, notified == notifier)
+
+	definition, ok := registry.Lookup("annotations")
+	require.True(t, ok, "task names are case insensitive")
+	assert.Equal(t, cms.AnnotationsNotifierName, definition.Notifier)
+
+	_, err = registry.NewTask("annotations", notifier)
+	assert.EqualError(t, err, "no annotations notifier")
+
+	_, err = registry.NewTask("telepathy", notifier)
+	assert.EqualError(t, err, "Unknown task telepathy, please use one of Annotations, content")
+}
+
+func TestRegistryRejectsInvalidDefinitions(t *testing.T) {
+	newTask := func(n cms.Notifier) (Task, error) { return new(MockTask), nil }
+
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(Definition{Name: "content", New: newTask}))
+
+	assert.EqualError(t, registry.Register(Definition{Name: "CONTENT", New: newTask}), "Task CONTENT is already registered")
+	assert.EqualError(t, registry.Register(Definition{Name: " ", New: newTask}), "Please provide a name for every task")
+	assert.EqualError(t, registry.Register(Definition{Name: "annotations"}), "Task annotations cannot be built")
+	assert.Equal(t, []string{"content"}, registry.Names())
+}
+
+func TestRegistryAppliesTaskFiltersAndTransforms(t *testing.T) {
+	filters := &filter.Config{Tasks: map[string][]*filter.Rule{"content": {{Name: "skip-lists", Action: filter.Deny, Type: []string{"List"}}}}}
+	require.NoError(t, filters.Validate())
+
+	transforms := &transform.Config{Tasks: map[string][]*transform.Transform{"content": {{Name: "strip", Op: transform.Remove, Path: "/secret"}}}}
+	require.NoError(t, transforms.Validate())
+
+	inner := new(MockTask)
+	inner.On("Prepare", "methode", "list-uuid").Return(&native.Content{Body: map[string]interface{}{"type": "List"}}, "tid_1234", nil)
+	inner.On("Prepare", "methode", "article-uuid").Return(&native.Content{Body: map[string]interface{}{"type": "Article", "secret": true}}, "tid_1234", nil)
+
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(Definition{
+		Name:       "content",
+		Filters:    filters.ForTask("content"),
+		Transforms: transforms.ForTask("content"),
+		New:        func(n cms.Notifier) (Task, error) { return inner, nil },
+	}))
+
+	task, err := registry.NewTask("content", new(cms.MockNotifier))
+	require.NoError(t, err)
+
+	_, _, err = task.Prepare("methode", "list-uuid")
+	skip := &SkipError{}
+	assert.True(t, errors.As(err, &skip))
+
+	content, _, err := task.Prepare("methode", "article-uuid")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"type": "Article"}, content.Body)
+}
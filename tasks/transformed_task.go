@@ -0,0 +1,51 @@
+package tasks
+
+import (
+	"strings"
+
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/transform"
+	log "github.com/sirupsen/logrus"
+)
+
+type transformedTask struct {
+	Task
+	pipeline transform.Pipeline
+}
+
+// NewTransformedTask returns a task which transforms the content prepared by the given task before it is executed
+func NewTransformedTask(task Task, pipeline transform.Pipeline) Task {
+	return &transformedTask{Task: task, pipeline: pipeline}
+}
+
+func (t *transformedTask) Prepare(collection string, uuid string) (*native.Content, string, error) {
+	content, tid, err := t.Task.Prepare(collection, uuid)
+	if err != nil || len(t.pipeline) == 0 {
+		return content, tid, err
+	}
+
+	body, applied, err := t.pipeline.Apply(content.Body)
+	if err != nil {
+		log.WithField("uuid", uuid).WithField("collection", collection).WithError(err).Warn("Failed to transform content.")
+		return nil, "", err
+	}
+
+	if len(applied) == 0 {
+		return content, tid, nil
+	}
+
+	log.WithField("uuid", uuid).WithField("collection", collection).WithField("transforms", applied).Info("Transformed content.")
+
+	original, _ := content.Body[publishReferenceAttr].(string)
+	transformed, _ := body[publishReferenceAttr].(string)
+	if transformed != original {
+		if strings.TrimSpace(transformed) == "" {
+			tid = generateCarouselTXID()
+		} else {
+			tid = toCarouselTXID(transformed)
+		}
+	}
+
+	transforms := append(append([]string{}, content.Transforms...), applied...)
+	return &native.Content{Body: body, ContentType: content.ContentType, OriginSystemID: content.OriginSystemID, Transforms: transforms}, tid, nil
+}
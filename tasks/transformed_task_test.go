@@ -0,0 +1,110 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/publish-carousel/blacklist"
+	"github.com/Financial-Times/publish-carousel/cms"
+	"github.com/Financial-Times/publish-carousel/native"
+	"github.com/Financial-Times/publish-carousel/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func transforms(t *testing.T, transforms ...*transform.Transform) transform.Pipeline {
+	config := &transform.Config{Global: transforms}
+	require.NoError(t, config.Validate())
+	return config.ForCycle("test-cycle")
+}
+
+func TestTransformedTaskHashesTransformedBody(t *testing.T) {
+	notifier := new(cms.MockNotifier)
+	reader := new(native.MockReader)
+
+	original := &native.Content{
+		Body:        map[string]interface{}{"uuid": "fake-uuid", "publishReference": "tid_1234", "deprecated": true},
+		ContentType: "application/json",
+	}
+	reader.On("Get", "methode", "fake-uuid").Return(original, nil)
+
+	data, _ := json.Marshal(map[string]interface{}{"uuid": "fake-uuid", "publishReference": "tid_1234"})
+	expectedHash, _ := native.Hash(data)
+
+	notifier.On("Notify", mock.Anything, "fake-origin", carouselTidMatcher, mock.MatchedBy(func(content *native.Content) bool {
+		_, found := content.Body["deprecated"]
+		return !found && assert.Equal(t, []string{"strip: remove /deprecated"}, content.Transforms)
+	}), expectedHash).Return(nil)
+
+	task := NewTransformedTask(NewNativeContentPublishTask(reader, notifier, blacklist.NoOpBlacklist), transforms(t, &transform.Transform{Name: "strip", Op: transform.Remove, Path: "/deprecated"}))
+
+	content, tid, err := task.Prepare("methode", "fake-uuid")
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", content.ContentType)
+
+	err = task.Execute(context.Background(), "fake-uuid", content, "fake-origin", tid)
+	assert.NoError(t, err)
+
+	assert.Equal(t, true, original.Body["deprecated"], "the native content should not be changed")
+	reader.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestTransformedTaskUsesTransformedPublishReference(t *testing.T) {
+	task := new(MockTask)
+	task.On("Prepare", "methode", "fake-uuid").Return(&native.Content{Body: map[string]interface{}{"publishReference": "null"}}, "null_carousel_1234567890", nil)
+
+	transformed := NewTransformedTask(task, transforms(t, &transform.Transform{Name: "fix", Op: transform.Set, Path: "/publishReference", Value: "tid_1234"}))
+
+	content, tid, err := transformed.Prepare("methode", "fake-uuid")
+	require.NoError(t, err)
+	assert.Equal(t, "tid_1234", content.Body["publishReference"])
+	assert.Regexp(t, carouselTidRegex, tid)
+	assert.Contains(t, tid, "tid_1234_carousel_")
+
+	task = new(MockTask)
+	task.On("Prepare", "methode", "fake-uuid").Return(&native.Content{Body: map[string]interface{}{"publishReference": "null"}}, "null_carousel_1234567890", nil)
+
+	transformed = NewTransformedTask(task, transforms(t, &transform.Transform{Name: "strip", Op: transform.Remove, Path: "/publishReference"}))
+
+	_, tid, err = transformed.Prepare("methode", "fake-uuid")
+	require.NoError(t, err)
+	assert.Regexp(t, carouselGentxTidRegex, tid)
+}
+
+func TestTransformedTaskWithoutChanges(t *testing.T) {
+	content := &native.Content{Body: map[string]interface{}{"uuid": "fake-uuid"}}
+
+	task := new(MockTask)
+	task.On("Prepare", "methode", "fake-uuid").Return(content, "tid_1234", nil)
+
+	transformed := NewTransformedTask(task, transforms(t, &transform.Transform{Name: "strip", Op: transform.Remove, Path: "/deprecated"}))
+
+	actual, tid, err := transformed.Prepare("methode", "fake-uuid")
+	require.NoError(t, err)
+	assert.True(t, content == actual, "unchanged content should be returned as is")
+	assert.Equal(t, "tid_1234", tid)
+}
+
+func TestTransformedTaskFails(t *testing.T) {
+	task := new(MockTask)
+	task.On("Prepare", "methode", "fake-uuid").Return(&native.Content{Body: map[string]interface{}{"title": "A title"}}, "tid_1234", nil)
+
+	transformed := NewTransformedTask(task, transforms(t, &transform.Transform{Name: "flag", Op: transform.Set, Path: "/title/flag", Value: true}))
+
+	_, _, err := transformed.Prepare("methode", "fake-uuid")
+	assert.EqualError(t, err, "Transform flag failed: cannot add a value to a field which is not an object or array")
+}
+
+func TestTransformedTaskPrepareFails(t *testing.T) {
+	task := new(MockTask)
+	task.On("Prepare", "methode", "fake-uuid").Return(&native.Content{}, "", errors.New("oh dear"))
+
+	transformed := NewTransformedTask(task, transforms(t, &transform.Transform{Name: "strip", Op: transform.Remove, Path: "/deprecated"}))
+
+	_, _, err := transformed.Prepare("methode", "fake-uuid")
+	assert.EqualError(t, err, "oh dear")
+}
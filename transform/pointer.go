@@ -0,0 +1,160 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pointer is a parsed JSON pointer (RFC 6901)
+type pointer []string
+
+const appendToken = "-"
+
+func parsePointer(expr string) (pointer, error) {
+	if !strings.HasPrefix(expr, "/") {
+		return nil, fmt.Errorf("Invalid path %v, paths must be JSON pointers such as /publishReference", expr)
+	}
+
+	tokens := strings.Split(expr[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return pointer(tokens), nil
+}
+
+func (p pointer) String() string {
+	escaped := make([]string, len(p))
+	for i, token := range p {
+		escaped[i] = strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+	}
+	return "/" + strings.Join(escaped, "/")
+}
+
+// leafFunc updates the container at the end of a pointer, returning the updated container
+type leafFunc func(container interface{}, token string) (interface{}, error)
+
+// update applies the leaf function to the parent of the last token, creating missing objects if create is set
+func (p pointer) update(doc interface{}, create bool, fn leafFunc) (interface{}, error) {
+	if len(p) == 1 {
+		return fn(doc, p[0])
+	}
+
+	child, found := get(doc, p[0])
+	if !found {
+		obj, isObj := doc.(map[string]interface{})
+		if !create || !isObj {
+			return nil, fmt.Errorf("%v does not exist", p[:1])
+		}
+		child = map[string]interface{}{}
+		obj[p[0]] = child
+	}
+
+	updated, err := p[1:].update(child, create, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		v[p[0]] = updated
+	case []interface{}:
+		i, _ := strconv.Atoi(p[0])
+		v[i] = updated
+	}
+	return doc, nil
+}
+
+func (p pointer) get(doc interface{}) (interface{}, bool) {
+	for _, token := range p {
+		var found bool
+		if doc, found = get(doc, token); !found {
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+func (p pointer) add(doc interface{}, value interface{}, create bool) (interface{}, error) {
+	return p.update(doc, create, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			v[token] = value
+			return v, nil
+		case []interface{}:
+			if token == appendToken {
+				return append(v, value), nil
+			}
+
+			i, err := index(v, token, len(v)+1)
+			if err != nil {
+				return nil, err
+			}
+			v = append(v, nil)
+			copy(v[i+1:], v[i:])
+			v[i] = value
+			return v, nil
+		}
+		return nil, errors.New("cannot add a value to a field which is not an object or array")
+	})
+}
+
+func (p pointer) remove(doc interface{}) (interface{}, error) {
+	return p.update(doc, false, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			if _, ok := v[token]; !ok {
+				return nil, fmt.Errorf("%v does not exist", p)
+			}
+			delete(v, token)
+			return v, nil
+		case []interface{}:
+			i, err := index(v, token, len(v))
+			if err != nil {
+				return nil, err
+			}
+			return append(v[:i], v[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("%v does not exist", p)
+	})
+}
+
+func (p pointer) replace(doc interface{}, value interface{}) (interface{}, error) {
+	if _, found := p.get(doc); !found {
+		return nil, fmt.Errorf("%v does not exist", p)
+	}
+	return p.update(doc, false, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			v[token] = value
+		case []interface{}:
+			i, _ := strconv.Atoi(token)
+			v[i] = value
+		}
+		return container, nil
+	})
+}
+
+func get(doc interface{}, token string) (interface{}, bool) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		child, ok := v[token]
+		return child, ok
+	case []interface{}:
+		i, err := index(v, token, len(v))
+		if err != nil {
+			return nil, false
+		}
+		return v[i], true
+	}
+	return nil, false
+}
+
+func index(arr []interface{}, token string, limit int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i >= limit || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %v", token)
+	}
+	return i, nil
+}
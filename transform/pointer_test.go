@@ -0,0 +1,62 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePointer(t *testing.T) {
+	p, err := parsePointer("/a~1b/m~0n/0")
+	require.NoError(t, err)
+	assert.Equal(t, pointer{"a/b", "m~n", "0"}, p)
+	assert.Equal(t, "/a~1b/m~0n/0", p.String())
+
+	_, err = parsePointer("a/b")
+	assert.Error(t, err)
+}
+
+func TestPointerGet(t *testing.T) {
+	doc := map[string]interface{}{"brands": []interface{}{map[string]interface{}{"id": "ft"}}}
+
+	value, found := pointer{"brands", "0", "id"}.get(doc)
+	assert.True(t, found)
+	assert.Equal(t, "ft", value)
+
+	for _, p := range []pointer{{"brands", "1"}, {"brands", "01"}, {"brands", "-1"}, {"brands", "x"}, {"missing"}, {"brands", "0", "id", "x"}} {
+		_, found := p.get(doc)
+		assert.False(t, found, p.String())
+	}
+}
+
+func TestPointerArrayUpdates(t *testing.T) {
+	doc := map[string]interface{}{"ids": []interface{}{"a", "c"}}
+
+	_, err := pointer{"ids", "1"}.add(doc, "b", false)
+	require.NoError(t, err)
+	_, err = pointer{"ids", "-"}.add(doc, "d", false)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b", "c", "d"}, doc["ids"])
+
+	_, err = pointer{"ids", "0"}.remove(doc)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"b", "c", "d"}, doc["ids"])
+
+	_, err = pointer{"ids", "5"}.add(doc, "x", false)
+	assert.EqualError(t, err, "invalid array index 5")
+
+	_, err = pointer{"ids", "3"}.remove(doc)
+	assert.EqualError(t, err, "invalid array index 3")
+}
+
+func TestPointerAddWithoutParent(t *testing.T) {
+	doc := map[string]interface{}{}
+
+	_, err := pointer{"a", "b"}.add(doc, 1, false)
+	assert.EqualError(t, err, "/a does not exist")
+
+	_, err = pointer{"a", "b"}.add(doc, 1, true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": map[string]interface{}{"b": 1}}, doc)
+}
@@ -0,0 +1,354 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// Remove deletes the field at Path, if it exists
+	Remove = "remove"
+	// Rename moves the field at Path to To, if it exists
+	Rename = "rename"
+	// Set sets the field at Path to Value, creating any missing parent objects. Array elements are replaced, not inserted.
+	Set = "set"
+	// Patch applies a JSON patch (RFC 6902)
+	Patch = "patch"
+)
+
+// Config holds the global transforms, and the transforms for each cycle and task
+type Config struct {
+	Global []*Transform            `yaml:"global"`
+	Cycles map[string][]*Transform `yaml:"cycles"`
+	Tasks  map[string][]*Transform `yaml:"tasks"`
+}
+
+// Transform is a single change to the native content body, at a JSON pointer
+type Transform struct {
+	Name  string            `yaml:"name" json:"name"`
+	Op    string            `yaml:"op" json:"op"`
+	Path  string            `yaml:"path" json:"path,omitempty"`
+	To    string            `yaml:"to" json:"to,omitempty"`
+	Value interface{}       `yaml:"value" json:"value,omitempty"`
+	Patch []*PatchOperation `yaml:"patch" json:"patch,omitempty"`
+
+	path pointer
+	to   pointer
+}
+
+// PatchOperation is a JSON patch (RFC 6902) operation
+type PatchOperation struct {
+	Op    string      `yaml:"op" json:"op"`
+	Path  string      `yaml:"path" json:"path"`
+	From  string      `yaml:"from" json:"from,omitempty"`
+	Value interface{} `yaml:"value" json:"value,omitempty"`
+
+	path pointer
+	from pointer
+}
+
+// Pipeline is an ordered list of transforms
+type Pipeline []*Transform
+
+// LoadConfigFromFile reads and validates the transforms in the given yaml file
+func LoadConfigFromFile(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, err
+	}
+
+	return config, config.Validate()
+}
+
+// Validate checks and compiles every transform in the config
+func (c *Config) Validate() error {
+	for _, transform := range c.Global {
+		if err := transform.compile(); err != nil {
+			return err
+		}
+	}
+
+	for cycle, transforms := range c.Cycles {
+		for _, transform := range transforms {
+			if err := transform.compile(); err != nil {
+				return fmt.Errorf("Invalid transforms for cycle %v: %v", cycle, err)
+			}
+		}
+	}
+
+	for task, transforms := range c.Tasks {
+		for _, transform := range transforms {
+			if err := transform.compile(); err != nil {
+				return fmt.Errorf("Invalid transforms for task %v: %v", task, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ForCycle returns the global transforms, followed by the named cycle's own transforms
+func (c *Config) ForCycle(name string) Pipeline {
+	pipeline := Pipeline{}
+	pipeline = append(pipeline, c.Global...)
+	return append(pipeline, c.Cycles[name]...)
+}
+
+// ForTask returns the transforms which only apply to cycles running the named task
+func (c *Config) ForTask(name string) Pipeline {
+	return append(Pipeline{}, c.Tasks[name]...)
+}
+
+// Apply returns a transformed copy of the body, and a description of each change
+func (p Pipeline) Apply(body map[string]interface{}) (map[string]interface{}, []string, error) {
+	doc, err := deepCopy(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var applied []string
+	for _, transform := range p {
+		changed, err := transform.apply(doc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Transform %v failed: %v", transform.Name, err)
+		}
+
+		if changed {
+			applied = append(applied, transform.String())
+		}
+	}
+
+	return doc, applied, nil
+}
+
+func (t *Transform) String() string {
+	switch t.Op {
+	case Rename:
+		return fmt.Sprintf("%v: %v %v to %v", t.Name, t.Op, t.path, t.to)
+	case Patch:
+		return fmt.Sprintf("%v: %v", t.Name, t.Op)
+	}
+	return fmt.Sprintf("%v: %v %v", t.Name, t.Op, t.path)
+}
+
+func (t *Transform) compile() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return errors.New("Please provide a name for every transform")
+	}
+
+	t.Op = strings.ToLower(t.Op)
+
+	var err error
+	switch t.Op {
+	case Remove, Rename, Set:
+		if t.path, err = parsePointer(t.Path); err != nil {
+			return fmt.Errorf("Invalid transform %v: %v", t.Name, err)
+		}
+	case Patch:
+		if len(t.Patch) == 0 {
+			return fmt.Errorf("Patch transform %v has no operations", t.Name)
+		}
+
+		for _, op := range t.Patch {
+			if err := op.compile(); err != nil {
+				return fmt.Errorf("Invalid patch for transform %v: %v", t.Name, err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("Invalid op %v for transform %v, please use %v, %v, %v or %v", t.Op, t.Name, Remove, Rename, Set, Patch)
+	}
+
+	if t.Op == Rename {
+		if t.to, err = parsePointer(t.To); err != nil {
+			return fmt.Errorf("Invalid transform %v: %v", t.Name, err)
+		}
+	}
+
+	if t.Op == Set {
+		if t.Value, err = jsonValue(t.Value); err != nil {
+			return fmt.Errorf("Invalid value for transform %v: %v", t.Name, err)
+		}
+	}
+	return nil
+}
+
+func (t *Transform) apply(doc map[string]interface{}) (bool, error) {
+	switch t.Op {
+	case Remove:
+		if _, found := t.path.get(doc); !found {
+			return false, nil
+		}
+		_, err := t.path.remove(doc)
+		return err == nil, err
+
+	case Rename:
+		value, found := t.path.get(doc)
+		if !found {
+			return false, nil
+		}
+
+		if _, err := t.path.remove(doc); err != nil {
+			return false, err
+		}
+		_, err := t.to.add(doc, value, true)
+		return err == nil, err
+
+	case Set:
+		current, found := t.path.get(doc)
+		if found && reflect.DeepEqual(current, t.Value) {
+			return false, nil
+		}
+
+		var err error
+		if found {
+			_, err = t.path.replace(doc, t.Value)
+		} else {
+			_, err = t.path.add(doc, t.Value, true)
+		}
+		return err == nil, err
+	}
+
+	return t.applyPatch(doc)
+}
+
+// applyPatch applies the patch operations to the document, only if they all succeed
+func (t *Transform) applyPatch(doc map[string]interface{}) (bool, error) {
+	patched, err := deepCopy(doc)
+	if err != nil {
+		return false, err
+	}
+
+	for _, op := range t.Patch {
+		if op.Op == "test" {
+			actual, found := op.path.get(patched)
+			if !found || !reflect.DeepEqual(actual, op.Value) {
+				return false, nil
+			}
+			continue
+		}
+
+		if err := op.apply(patched); err != nil {
+			return false, err
+		}
+	}
+
+	if reflect.DeepEqual(doc, patched) {
+		return false, nil
+	}
+
+	for k := range doc {
+		delete(doc, k)
+	}
+	for k, v := range patched {
+		doc[k] = v
+	}
+	return true, nil
+}
+
+func (o *PatchOperation) compile() error {
+	o.Op = strings.ToLower(o.Op)
+
+	var err error
+	if o.path, err = parsePointer(o.Path); err != nil {
+		return err
+	}
+
+	switch o.Op {
+	case "add", "replace", "test":
+		o.Value, err = jsonValue(o.Value)
+		return err
+	case "move", "copy":
+		o.from, err = parsePointer(o.From)
+		return err
+	case "remove":
+		return nil
+	}
+	return fmt.Errorf("Invalid patch op %v", o.Op)
+}
+
+func (o *PatchOperation) apply(doc map[string]interface{}) error {
+	var err error
+	switch o.Op {
+	case "add":
+		_, err = o.path.add(doc, o.Value, false)
+	case "remove":
+		_, err = o.path.remove(doc)
+	case "replace":
+		_, err = o.path.replace(doc, o.Value)
+	case "move", "copy":
+		value, found := o.from.get(doc)
+		if !found {
+			return fmt.Errorf("%v does not exist", o.from)
+		}
+
+		if o.Op == "move" {
+			if _, err = o.from.remove(doc); err != nil {
+				return err
+			}
+		} else if value, err = jsonValue(value); err != nil {
+			return err
+		}
+		_, err = o.path.add(doc, value, false)
+	}
+	return err
+}
+
+// deepCopy copies the body through json, which is how it is eventually published
+func deepCopy(body map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := map[string]interface{}{}
+	err = decode(data, &doc)
+	return doc, err
+}
+
+// jsonValue converts a configured value (which may contain yaml maps) to the equivalent json value
+func jsonValue(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(yamlToJSON(value))
+	if err != nil {
+		return nil, err
+	}
+
+	var converted interface{}
+	err = decode(data, &converted)
+	return converted, err
+}
+
+func decode(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func yamlToJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			obj[fmt.Sprintf("%v", key)] = yamlToJSON(child)
+		}
+		return obj
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, child := range v {
+			arr[i] = yamlToJSON(child)
+		}
+		return arr
+	}
+	return value
+}
@@ -0,0 +1,227 @@
+package transform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func compiled(t *testing.T, config *Config) *Config {
+	require.NoError(t, config.Validate())
+	return config
+}
+
+func body(t *testing.T, data string) map[string]interface{} {
+	b := map[string]interface{}{}
+	require.NoError(t, decode([]byte(data), &b))
+	return b
+}
+
+func TestFieldTransforms(t *testing.T) {
+	config := compiled(t, &Config{
+		Global: []*Transform{
+			{Name: "strip", Op: Remove, Path: "/deprecated"},
+			{Name: "rename", Op: Rename, Path: "/summary", To: "/standfirst"},
+			{Name: "flag", Op: Set, Path: "/flags/canonical", Value: true},
+		},
+	})
+
+	original := body(t, `{"uuid":"fake-uuid","deprecated":"x","summary":"A summary","count":12345678901234567890}`)
+	transformed, applied, err := config.ForCycle("test").Apply(original)
+	require.NoError(t, err)
+
+	expected := body(t, `{"uuid":"fake-uuid","standfirst":"A summary","flags":{"canonical":true},"count":12345678901234567890}`)
+	assert.Equal(t, expected, transformed)
+	assert.Equal(t, []string{"strip: remove /deprecated", "rename: rename /summary to /standfirst", "flag: set /flags/canonical"}, applied)
+
+	assert.Equal(t, "x", original["deprecated"], "the original body should not be changed")
+}
+
+func TestFieldTransformsWhichChangeNothing(t *testing.T) {
+	config := compiled(t, &Config{
+		Global: []*Transform{
+			{Name: "strip", Op: Remove, Path: "/deprecated"},
+			{Name: "rename", Op: Rename, Path: "/summary", To: "/standfirst"},
+			{Name: "type", Op: Set, Path: "/type", Value: "Article"},
+		},
+	})
+
+	original := body(t, `{"uuid":"fake-uuid","type":"Article"}`)
+	transformed, applied, err := config.ForCycle("test").Apply(original)
+	require.NoError(t, err)
+
+	assert.Equal(t, original, transformed)
+	assert.Empty(t, applied)
+}
+
+func TestSetFailsOnNonObjectParent(t *testing.T) {
+	config := compiled(t, &Config{Global: []*Transform{{Name: "flag", Op: Set, Path: "/title/canonical", Value: true}}})
+
+	_, _, err := config.ForCycle("test").Apply(body(t, `{"title":"A title"}`))
+	assert.EqualError(t, err, "Transform flag failed: cannot add a value to a field which is not an object or array")
+}
+
+func TestSetYAMLValue(t *testing.T) {
+	config := &Config{}
+	require.NoError(t, yaml.Unmarshal([]byte("global:\n- name: brand\n  op: set\n  path: /brands/0\n  value:\n    id: ft\n    primary: true\n"), config))
+	require.NoError(t, config.Validate())
+
+	transformed, _, err := config.ForCycle("test").Apply(body(t, `{"brands":[{"id":"other"}]}`))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(transformed)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"brands":[{"id":"ft","primary":true}]}`, string(data))
+}
+
+func TestApplyConvertsBSONDocuments(t *testing.T) {
+	config := compiled(t, &Config{Global: []*Transform{{Name: "strip", Op: Remove, Path: "/body/deprecated"}}})
+
+	transformed, applied, err := config.ForCycle("test").Apply(map[string]interface{}{"body": bson.M{"deprecated": 1, "text": "hi"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"body": map[string]interface{}{"text": "hi"}}, transformed)
+	assert.Len(t, applied, 1)
+}
+
+func TestPatchTransform(t *testing.T) {
+	config := compiled(t, &Config{
+		Global: []*Transform{{
+			Name: "fix-publish-reference",
+			Op:   Patch,
+			Patch: []*PatchOperation{
+				{Op: "test", Path: "/publishReference", Value: "null"},
+				{Op: "copy", From: "/uuid", Path: "/originalUuid"},
+				{Op: "move", From: "/publishReference", Path: "/brokenPublishReference"},
+				{Op: "add", Path: "/brands/-", Value: map[interface{}]interface{}{"id": "ft"}},
+				{Op: "replace", Path: "/brands/0/id", Value: "sub-brand"},
+				{Op: "remove", Path: "/brands/1"},
+			},
+		}},
+	})
+
+	transformed, applied, err := config.ForCycle("test").Apply(body(t, `{"uuid":"fake-uuid","publishReference":"null","brands":[{"id":"other"},{"id":"removed"}]}`))
+	require.NoError(t, err)
+
+	expected := body(t, `{"uuid":"fake-uuid","originalUuid":"fake-uuid","brokenPublishReference":"null","brands":[{"id":"sub-brand"},{"id":"ft"}]}`)
+	assert.Equal(t, expected, transformed)
+	assert.Equal(t, []string{"fix-publish-reference: patch"}, applied)
+}
+
+func TestPatchIsSkippedIfTestFails(t *testing.T) {
+	config := compiled(t, &Config{
+		Global: []*Transform{{
+			Name: "fix-publish-reference",
+			Op:   Patch,
+			Patch: []*PatchOperation{
+				{Op: "test", Path: "/publishReference", Value: "null"},
+				{Op: "remove", Path: "/publishReference"},
+			},
+		}},
+	})
+
+	original := body(t, `{"publishReference":"tid_1234"}`)
+	transformed, applied, err := config.ForCycle("test").Apply(original)
+	require.NoError(t, err)
+	assert.Equal(t, original, transformed)
+	assert.Empty(t, applied)
+}
+
+func TestPatchFailureChangesNothing(t *testing.T) {
+	config := compiled(t, &Config{
+		Global: []*Transform{{
+			Name: "broken",
+			Op:   Patch,
+			Patch: []*PatchOperation{
+				{Op: "remove", Path: "/title"},
+				{Op: "replace", Path: "/missing", Value: "x"},
+			},
+		}},
+	})
+
+	_, _, err := config.ForCycle("test").Apply(body(t, `{"title":"A title"}`))
+	assert.EqualError(t, err, "Transform broken failed: /missing does not exist")
+}
+
+func TestCycleTransformsRunAfterGlobalTransforms(t *testing.T) {
+	config := compiled(t, &Config{
+		Global: []*Transform{{Name: "global", Op: Set, Path: "/source", Value: "global"}},
+		Cycles: map[string][]*Transform{
+			"test": {{Name: "cycle", Op: Set, Path: "/source", Value: "cycle"}},
+		},
+	})
+
+	transformed, _, err := config.ForCycle("test").Apply(body(t, `{}`))
+	require.NoError(t, err)
+	assert.Equal(t, "cycle", transformed["source"])
+
+	transformed, _, err = config.ForCycle("other").Apply(body(t, `{}`))
+	require.NoError(t, err)
+	assert.Equal(t, "global", transformed["source"])
+}
+
+func TestValidateFails(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform *Transform
+		err       string
+	}{
+		{name: "no name", transform: &Transform{Op: Remove, Path: "/a"}, err: "Please provide a name for every transform"},
+		{name: "bad op", transform: &Transform{Name: "t", Op: "delete", Path: "/a"}, err: "Invalid op delete for transform t, please use remove, rename, set or patch"},
+		{name: "bad path", transform: &Transform{Name: "t", Op: Remove, Path: "$.a"}, err: "Invalid transform t: Invalid path $.a, paths must be JSON pointers such as /publishReference"},
+		{name: "rename without to", transform: &Transform{Name: "t", Op: Rename, Path: "/a"}, err: "Invalid transform t: Invalid path , paths must be JSON pointers such as /publishReference"},
+		{name: "empty patch", transform: &Transform{Name: "t", Op: Patch}, err: "Patch transform t has no operations"},
+		{name: "bad patch op", transform: &Transform{Name: "t", Op: Patch, Patch: []*PatchOperation{{Op: "delete", Path: "/a"}}}, err: "Invalid patch for transform t: Invalid patch op delete"},
+		{name: "move without from", transform: &Transform{Name: "t", Op: Patch, Patch: []*PatchOperation{{Op: "move", Path: "/a"}}}, err: "Invalid patch for transform t: Invalid path , paths must be JSON pointers such as /publishReference"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := (&Config{Global: []*Transform{test.transform}}).Validate()
+			assert.EqualError(t, err, test.err)
+		})
+	}
+
+	err := (&Config{Cycles: map[string][]*Transform{"test": {{Name: "t", Op: "delete"}}}}).Validate()
+	assert.EqualError(t, err, "Invalid transforms for cycle test: Invalid op delete for transform t, please use remove, rename, set or patch")
+
+	err = (&Config{Tasks: map[string][]*Transform{"annotations": {{Name: "t", Op: "delete"}}}}).Validate()
+	assert.EqualError(t, err, "Invalid transforms for task annotations: Invalid op delete for transform t, please use remove, rename, set or patch")
+}
+
+func TestForTask(t *testing.T) {
+	config := &Config{
+		Global: []*Transform{{Name: "global", Op: Set, Path: "/source", Value: "global"}},
+		Tasks:  map[string][]*Transform{"annotations": {{Name: "task", Op: Set, Path: "/source", Value: "task"}}},
+	}
+	require.NoError(t, config.Validate())
+
+	transformed, applied, err := config.ForTask("annotations").Apply(body(t, `{}`))
+	require.NoError(t, err)
+	assert.Equal(t, "task", transformed["source"])
+	assert.Len(t, applied, 1)
+	assert.Empty(t, config.ForTask("content"))
+}
+
+func TestLoadConfigFromFile(t *testing.T) {
+	config, err := LoadConfigFromFile("../transforms.yml")
+	require.NoError(t, err)
+	assert.Empty(t, config.ForCycle("methode-whole-archive"))
+}
+
+func TestLoadConfigFromFileRejectsUnknownFields(t *testing.T) {
+	f, err := ioutil.TempFile("", "transforms")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	f.WriteString("global:\n- name: strip\n  operation: remove\n  path: /a\n")
+	f.Close()
+
+	_, err = LoadConfigFromFile(f.Name())
+	assert.Error(t, err)
+}
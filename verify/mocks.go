@@ -0,0 +1,28 @@
+package verify
+
+import (
+	"github.com/stretchr/testify/mock"
+)
+
+type MockVerifier struct {
+	mock.Mock
+}
+
+func (m *MockVerifier) Verify(cycle string, uuid string, tid string, done func(result string)) {
+	m.Called(cycle, uuid, tid, done)
+}
+
+func (m *MockVerifier) MissingRates() map[string]float64 {
+	args := m.Called()
+	return args.Get(0).(map[string]float64)
+}
+
+func (m *MockVerifier) Check() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockVerifier) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
@@ -0,0 +1,291 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/cluster"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Verified means the content reached every read environment with the carousel's transaction id
+	Verified = "verified"
+	// Late means the content reached every read environment, but only after it was first checked
+	Late = "late"
+	// Missing means the content had not reached every read environment by the verification timeout
+	Missing = "missing"
+)
+
+const uuidPlaceholder = "{uuid}"
+
+// Config configures when, and for how long, published content is polled for in the read environments
+type Config struct {
+	// Delay is how long after the publish the read environments are first checked
+	Delay time.Duration
+	// Interval is the time between checks, if the content has not reached every read environment
+	Interval time.Duration
+	// Timeout is how long after the publish the content is reported as missing
+	Timeout time.Duration
+	// Path is the path of the read API in each read environment, where {uuid} is replaced with the uuid of the content
+	Path string
+	// Window is the number of recent results for each cycle which the missing rate is calculated over
+	Window int
+	// MaxPending is the number of publishes which can be verified at once. Publishes beyond this are not verified.
+	MaxPending int
+	// MaxMissingRate is the fraction of a cycle's recent publishes which can be missing before the check fails
+	MaxMissingRate float64
+}
+
+// Validate checks the verification configuration
+func (c Config) Validate() error {
+	if c.Delay < 0 {
+		return fmt.Errorf("Invalid verification delay %v", c.Delay)
+	}
+
+	if c.Interval <= 0 {
+		return fmt.Errorf("Invalid verification interval %v", c.Interval)
+	}
+
+	if c.Timeout < c.Delay {
+		return fmt.Errorf("Invalid verification timeout %v, please use a timeout of at least the delay %v", c.Timeout, c.Delay)
+	}
+
+	if !strings.Contains(c.Path, uuidPlaceholder) {
+		return fmt.Errorf("Invalid verification path %v, please include %v", c.Path, uuidPlaceholder)
+	}
+
+	if c.Window < 1 {
+		return fmt.Errorf("Invalid verification window %v, please use at least 1 publish", c.Window)
+	}
+
+	if c.MaxPending < 1 {
+		return fmt.Errorf("Invalid maximum pending verifications %v, please use at least 1", c.MaxPending)
+	}
+
+	if c.MaxMissingRate < 0 || c.MaxMissingRate > 1 {
+		return fmt.Errorf("Invalid maximum missing rate %v, please use a value between 0 and 1", c.MaxMissingRate)
+	}
+	return nil
+}
+
+// Verifier checks that published content reaches the read environments with the carousel's transaction id
+type Verifier interface {
+	// Verify polls the read environments for the content in the background, and calls done with the result
+	Verify(cycle string, uuid string, tid string, done func(result string))
+	// MissingRates returns the fraction of each cycle's recent publishes which were missing from the read environments
+	MissingRates() map[string]float64
+	// Check returns an error if too many of the recent publishes of any cycle were missing
+	Check() error
+	Close() error
+}
+
+type verifier struct {
+	environments cluster.ReadEnvironments
+	client       cluster.HttpClient
+	config       Config
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending chan struct{}
+	wg      *sync.WaitGroup
+
+	lock    *sync.Mutex
+	results map[string]*window
+}
+
+// NewVerifier returns a verifier which polls the read API of every read environment for published content
+func NewVerifier(environments cluster.ReadEnvironments, client cluster.HttpClient, config Config) (Verifier, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &verifier{
+		environments: environments,
+		client:       client,
+		config:       config,
+		ctx:          ctx,
+		cancel:       cancel,
+		pending:      make(chan struct{}, config.MaxPending),
+		wg:           &sync.WaitGroup{},
+		lock:         &sync.Mutex{},
+		results:      make(map[string]*window),
+	}, nil
+}
+
+func (v *verifier) Verify(cycle string, uuid string, tid string, done func(result string)) {
+	select {
+	case v.pending <- struct{}{}:
+	default:
+		log.WithField("cycle", cycle).WithField("uuid", uuid).WithField("transaction_id", tid).Warn("Too many publishes are being verified, skipping verification.")
+		return
+	}
+
+	v.wg.Add(1)
+	go func() {
+		defer v.wg.Done()
+		defer func() { <-v.pending }()
+
+		result, ok := v.poll(uuid, tid)
+		if !ok {
+			return
+		}
+
+		logger := log.WithField("cycle", cycle).WithField("uuid", uuid).WithField("transaction_id", tid).WithField("result", result)
+		if result == Missing {
+			logger.Warn("Published content is missing from the read environments.")
+		} else {
+			logger.Debug("Verified published content in the read environments.")
+		}
+
+		v.record(cycle, result)
+		done(result)
+	}()
+}
+
+// poll checks the read environments until the content has reached all of them, or the timeout has passed
+func (v *verifier) poll(uuid string, tid string) (string, bool) {
+	deadline := time.Now().Add(v.config.Timeout)
+	if !v.sleep(v.config.Delay) {
+		return "", false
+	}
+
+	remaining := v.environments.ReadEnvironments()
+	if len(remaining) == 0 {
+		log.WithField("uuid", uuid).WithField("transaction_id", tid).Warn("There are no read environments to verify published content in.")
+		return "", false
+	}
+
+	for checks := 0; ; checks++ {
+		remaining = v.check(remaining, uuid, tid)
+		switch {
+		case len(remaining) == 0 && checks == 0:
+			return Verified, true
+		case len(remaining) == 0:
+			return Late, true
+		case !time.Now().Add(v.config.Interval).Before(deadline):
+			return Missing, true
+		}
+
+		if !v.sleep(v.config.Interval) {
+			return "", false
+		}
+	}
+}
+
+func (v *verifier) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-v.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// check returns the environments which do not have the content with the transaction id yet
+func (v *verifier) check(environments []cluster.ReadEnvironment, uuid string, tid string) []cluster.ReadEnvironment {
+	var remaining []cluster.ReadEnvironment
+	for _, env := range environments {
+		found, err := v.read(env, uuid, tid)
+		if err != nil {
+			log.WithError(err).WithField("environment", env.Name).WithField("uuid", uuid).Debug("Failed to read content from the read environment.")
+		}
+
+		if !found {
+			remaining = append(remaining, env)
+		}
+	}
+	return remaining
+}
+
+type readContent struct {
+	PublishReference string `json:"publishReference"`
+}
+
+func (v *verifier) read(env cluster.ReadEnvironment, uuid string, tid string) (bool, error) {
+	content := readContent{}
+	found, err := env.Get(v.client, strings.Replace(v.config.Path, uuidPlaceholder, uuid, -1), &content)
+	if err != nil || !found {
+		return false, err
+	}
+	return content.PublishReference == tid, nil
+}
+
+func (v *verifier) record(cycle string, result string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	w, ok := v.results[cycle]
+	if !ok {
+		w = &window{results: make([]bool, v.config.Window)}
+		v.results[cycle] = w
+	}
+	w.add(result == Missing)
+}
+
+func (v *verifier) MissingRates() map[string]float64 {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	rates := make(map[string]float64)
+	for cycle, w := range v.results {
+		rates[cycle] = w.rate()
+	}
+	return rates
+}
+
+func (v *verifier) Check() error {
+	failing := make(map[string]float64)
+	for cycle, rate := range v.MissingRates() {
+		if rate > v.config.MaxMissingRate {
+			failing[cycle] = rate
+		}
+	}
+
+	if len(failing) == 0 {
+		return nil
+	}
+
+	b, _ := json.Marshal(failing)
+	return fmt.Errorf("Too many recent publishes of the following cycles are missing from the read environments! %s", b)
+}
+
+// Close stops any verifications in progress, without reporting their results
+func (v *verifier) Close() error {
+	v.cancel()
+	v.wg.Wait()
+	return nil
+}
+
+// window is a ring buffer of whether each of the recent publishes was missing
+type window struct {
+	results []bool
+	next    int
+	count   int
+}
+
+func (w *window) add(missing bool) {
+	w.results[w.next] = missing
+	w.next = (w.next + 1) % len(w.results)
+	if w.count < len(w.results) {
+		w.count++
+	}
+}
+
+func (w *window) rate() float64 {
+	missing := 0
+	for i := 0; i < w.count; i++ {
+		if w.results[i] {
+			missing++
+		}
+	}
+	return float64(missing) / float64(w.count)
+}
@@ -0,0 +1,189 @@
+package verify
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/publish-carousel/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testUUID = "3b5b4a3e-3e65-11e7-9d56-25f963e998b2"
+
+var testConfig = Config{Interval: 10 * time.Millisecond, Timeout: 100 * time.Millisecond, Path: "/__document-store-api/content/{uuid}", Window: 10, MaxPending: 10, MaxMissingRate: 0.1}
+
+// readServer serves the content with the given publish reference, once it has been read the given number of times
+func readServer(t *testing.T, tid string, notFoundReads int32) *httptest.Server {
+	var reads int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/__document-store-api/content/"+testUUID, r.URL.Path)
+		assert.Equal(t, "UPP Publish Carousel", r.Header.Get("User-Agent"))
+
+		if atomic.AddInt32(&reads, 1) <= notFoundReads {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"uuid":"%v","publishReference":"%v"}`, testUUID, tid)
+	}))
+}
+
+func environments(servers ...*httptest.Server) *cluster.MockReadEnvironments {
+	var envs []cluster.ReadEnvironment
+	for i, server := range servers {
+		readURL, _ := url.Parse(server.URL)
+		envs = append(envs, cluster.ReadEnvironment{Name: fmt.Sprintf("env%v", i), ReadURL: readURL})
+	}
+
+	e := new(cluster.MockReadEnvironments)
+	e.On("ReadEnvironments").Return(envs)
+	return e
+}
+
+func verifyResult(t *testing.T, v Verifier, cycle string, tid string) string {
+	results := make(chan string, 1)
+	v.Verify(cycle, testUUID, tid, func(result string) {
+		results <- result
+	})
+
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(time.Second):
+		require.Fail(t, "the publish should have been verified")
+		return ""
+	}
+}
+
+func TestVerified(t *testing.T) {
+	server1 := readServer(t, "tid_1234", 0)
+	defer server1.Close()
+	server2 := readServer(t, "tid_1234", 0)
+	defer server2.Close()
+
+	v, err := NewVerifier(environments(server1, server2), http.DefaultClient, testConfig)
+	require.NoError(t, err)
+	defer v.Close()
+
+	assert.Equal(t, Verified, verifyResult(t, v, "cycle", "tid_1234"))
+	assert.Equal(t, map[string]float64{"cycle": 0}, v.MissingRates())
+	assert.NoError(t, v.Check())
+}
+
+func TestLate(t *testing.T) {
+	server1 := readServer(t, "tid_1234", 0)
+	defer server1.Close()
+	server2 := readServer(t, "tid_1234", 2)
+	defer server2.Close()
+
+	v, err := NewVerifier(environments(server1, server2), http.DefaultClient, testConfig)
+	require.NoError(t, err)
+	defer v.Close()
+
+	assert.Equal(t, Late, verifyResult(t, v, "cycle", "tid_1234"))
+}
+
+func TestMissing(t *testing.T) {
+	server := readServer(t, "tid_older", 0)
+	defer server.Close()
+
+	v, err := NewVerifier(environments(server), http.DefaultClient, testConfig)
+	require.NoError(t, err)
+	defer v.Close()
+
+	assert.Equal(t, Missing, verifyResult(t, v, "cycle", "tid_1234"))
+	assert.Equal(t, Verified, verifyResult(t, v, "cycle", "tid_older"))
+	assert.Equal(t, Verified, verifyResult(t, v, "another-cycle", "tid_older"))
+	assert.Equal(t, map[string]float64{"cycle": 0.5, "another-cycle": 0}, v.MissingRates())
+	assert.EqualError(t, v.Check(), `Too many recent publishes of the following cycles are missing from the read environments! {"cycle":0.5}`)
+}
+
+func TestVerifyWithBasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"publishReference":"tid_1234"}`)
+	}))
+	defer server.Close()
+
+	readURL, _ := url.Parse(server.URL)
+	e := new(cluster.MockReadEnvironments)
+	e.On("ReadEnvironments").Return([]cluster.ReadEnvironment{{Name: "env", ReadURL: readURL, Username: "user", Password: "pass"}})
+
+	v, err := NewVerifier(e, http.DefaultClient, testConfig)
+	require.NoError(t, err)
+	defer v.Close()
+
+	assert.Equal(t, Verified, verifyResult(t, v, "cycle", "tid_1234"))
+}
+
+func TestCloseStopsVerification(t *testing.T) {
+	config := testConfig
+	config.Delay = time.Hour
+	config.Timeout = time.Hour
+
+	v, err := NewVerifier(environments(), http.DefaultClient, config)
+	require.NoError(t, err)
+
+	v.Verify("cycle", testUUID, "tid_1234", func(result string) {
+		assert.Fail(t, "closed verifications should not be reported")
+	})
+	assert.NoError(t, v.Close())
+	assert.Empty(t, v.MissingRates())
+}
+
+func TestTooManyPendingVerifications(t *testing.T) {
+	config := testConfig
+	config.Delay = time.Hour
+	config.Timeout = time.Hour
+	config.MaxPending = 1
+
+	e := environments()
+	v, err := NewVerifier(e, http.DefaultClient, config)
+	require.NoError(t, err)
+
+	v.Verify("cycle", testUUID, "tid_1", func(string) {})
+	v.Verify("cycle", testUUID, "tid_2", func(string) {})
+	assert.Len(t, v.(*verifier).pending, 1)
+	assert.NoError(t, v.Close())
+}
+
+func TestMissingRateWindow(t *testing.T) {
+	w := &window{results: make([]bool, 3)}
+	w.add(true)
+	assert.Equal(t, 1.0, w.rate())
+
+	w.add(false)
+	w.add(false)
+	assert.InDelta(t, 1.0/3, w.rate(), 0.001)
+
+	w.add(false)
+	assert.Equal(t, 0.0, w.rate())
+}
+
+func TestInvalidConfig(t *testing.T) {
+	config := testConfig
+	config.Path = "/content"
+	_, err := NewVerifier(environments(), http.DefaultClient, config)
+	assert.EqualError(t, err, "Invalid verification path /content, please include {uuid}")
+
+	config = testConfig
+	config.Delay = time.Minute
+	assert.Error(t, config.Validate())
+
+	config = testConfig
+	config.Window = 0
+	assert.Error(t, config.Validate())
+
+	config = testConfig
+	config.MaxMissingRate = 1.5
+	assert.Error(t, config.Validate())
+}